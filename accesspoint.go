@@ -0,0 +1,122 @@
+package s3ry
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+)
+
+// ErrInvalidARN bucket is an ARN but not of an S3 access point
+var ErrInvalidARN = errors.New("invalid access point ARN")
+
+// ErrMultiRegionAccessPoint multi-region access points need SigV4A signing, which the AWS SDK used lacks
+var ErrMultiRegionAccessPoint = errors.New("multi-region access points are not supported")
+
+// accessPointName name rule of access points, 3 to 50 lowercase letters, digits and hyphens
+var accessPointName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,48}[a-z0-9]$`)
+
+// accountID rule of AWS account IDs
+var accountID = regexp.MustCompile(`^[0-9]{12}$`)
+
+// AccessPoint S3 access point or multi-region access point addressed by its ARN instead of a bucket name
+type AccessPoint struct {
+	ARN       string
+	Partition string
+	// Region empty for a multi-region access point
+	Region  string
+	Account string
+	// Name name of the access point, the alias ending with .mrap for a multi-region access point
+	Name        string
+	MultiRegion bool
+}
+
+// isAccessPointARN check bucket is an ARN rather than a bucket name, bucket names can't contain colons
+func isAccessPointARN(bucket string) bool {
+	return strings.HasPrefix(bucket, "arn:")
+}
+
+// splitAccessPointARN split arn:...:accesspoint/name/key into the ARN and the key
+func splitAccessPointARN(s string) (string, string) {
+	fields := strings.SplitN(s, ":", 6)
+	if len(fields) < 6 {
+		// not an ARN, ParseAccessPointARN reports it
+		return s, ""
+	}
+	resource := fields[5]
+	typ := strings.IndexAny(resource, "/:")
+	if typ < 0 {
+		return s, ""
+	}
+	end := strings.IndexByte(resource[typ+1:], '/')
+	if end < 0 {
+		return s, ""
+	}
+	n := len(s) - len(resource) + typ + 1 + end
+	return s[:n], s[n+1:]
+}
+
+// ParseAccessPointARN parse and validate ARN of an access point like arn:aws:s3:us-west-2:123456789012:accesspoint/name
+// or of a multi-region access point like arn:aws:s3::123456789012:accesspoint/alias.mrap
+func ParseAccessPointARN(s string) (*AccessPoint, error) {
+	a, err := arn.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q, %v", ErrInvalidARN, s, err)
+	}
+	if a.Service != "s3" {
+		return nil, fmt.Errorf("%w %q, service %q is not s3", ErrInvalidARN, s, a.Service)
+	}
+	if !accountID.MatchString(a.AccountID) {
+		return nil, fmt.Errorf("%w %q, account %q is not 12 digits", ErrInvalidARN, s, a.AccountID)
+	}
+	typ := strings.IndexAny(a.Resource, "/:")
+	if typ < 0 || a.Resource[:typ] != "accesspoint" {
+		return nil, fmt.Errorf("%w %q, resource %q is not accesspoint/name", ErrInvalidARN, s, a.Resource)
+	}
+	ap := &AccessPoint{ARN: s, Partition: a.Partition, Region: a.Region, Account: a.AccountID, Name: a.Resource[typ+1:]}
+	if a.Region == "" {
+		ap.MultiRegion = true
+		if !strings.HasSuffix(ap.Name, ".mrap") || strings.ContainsAny(ap.Name, "/:") {
+			return nil, fmt.Errorf("%w %q, a multi-region access point without region is named by its alias ending with .mrap", ErrInvalidARN, s)
+		}
+		return ap, nil
+	}
+	if !accessPointName.MatchString(ap.Name) {
+		return nil, fmt.Errorf("%w %q, name %q must be 3 to 50 lowercase letters, digits and hyphens", ErrInvalidARN, s, ap.Name)
+	}
+	p, ok := endpoints.PartitionForRegion(endpoints.DefaultPartitions(), a.Region)
+	if !ok {
+		return nil, fmt.Errorf("%w %q, unknown region %q", ErrInvalidARN, s, a.Region)
+	}
+	if p.ID() != a.Partition {
+		return nil, fmt.Errorf("%w %q, region %s is not in partition %s", ErrInvalidARN, s, a.Region, a.Partition)
+	}
+	return ap, nil
+}
+
+// accessPointRegion return region requests to the access point ARN bucket are sent to
+func accessPointRegion(bucket string) (string, error) {
+	ap, err := ParseAccessPointARN(bucket)
+	if err != nil {
+		return "", err
+	}
+	if ap.MultiRegion {
+		return "", fmt.Errorf("%w, %s needs SigV4A signing, use a regional access point", ErrMultiRegionAccessPoint, bucket)
+	}
+	return ap.Region, nil
+}
+
+// accessPointHost return host of the access point ARN bucket, name-account.s3-accesspoint.region.amazonaws.com
+func accessPointHost(ap *AccessPoint) (string, error) {
+	e, err := endpoints.DefaultResolver().EndpointFor("s3", ap.Region, func(o *endpoints.Options) {
+		o.S3UsEast1RegionalEndpoint = endpoints.RegionalS3UsEast1Endpoint
+	})
+	if err != nil {
+		return "", err
+	}
+	host := strings.TrimPrefix(e.URL, "https://")
+	return ap.Name + "-" + ap.Account + ".s3-accesspoint." + strings.TrimPrefix(host, "s3."), nil
+}
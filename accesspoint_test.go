@@ -0,0 +1,164 @@
+package s3ry
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+const testAccessPoint = "arn:aws:s3:us-west-2:123456789012:accesspoint/my-ap"
+
+func TestParseAccessPointARN(t *testing.T) {
+	ap, err := ParseAccessPointARN(testAccessPoint)
+	assert.NoError(t, err)
+	assert.Equal(t, &AccessPoint{ARN: testAccessPoint, Partition: "aws", Region: "us-west-2", Account: "123456789012", Name: "my-ap"}, ap)
+
+	ap, err = ParseAccessPointARN("arn:aws:s3::123456789012:accesspoint/mfzwi23gnjvgw.mrap")
+	assert.NoError(t, err)
+	assert.True(t, ap.MultiRegion)
+	assert.Equal(t, "mfzwi23gnjvgw.mrap", ap.Name)
+
+	for _, invalid := range []string{
+		"arn:aws:s3",
+		"arn:aws:sqs:us-west-2:123456789012:accesspoint/my-ap",
+		"arn:aws:s3:us-west-2:1234:accesspoint/my-ap",
+		"arn:aws:s3:us-west-2:123456789012:bucket/my-ap",
+		"arn:aws:s3:us-west-2:123456789012:accesspoint/My_AP",
+		"arn:aws:s3:cn-north-1:123456789012:accesspoint/my-ap",
+		"arn:aws:s3:mars-1:123456789012:accesspoint/my-ap",
+		"arn:aws:s3::123456789012:accesspoint/my-ap",
+	} {
+		_, err := ParseAccessPointARN(invalid)
+		assert.True(t, errors.Is(err, ErrInvalidARN), invalid)
+	}
+}
+
+func TestParseS3URIAccessPoint(t *testing.T) {
+	bucket, key, err := ParseS3URI("s3://" + testAccessPoint + "/logs/a.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, testAccessPoint, bucket)
+	assert.Equal(t, "logs/a.txt", key)
+
+	bucket, key, err = ParseS3URI("s3://arn:aws:s3:us-west-2:123456789012:accesspoint:my-ap/a.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "arn:aws:s3:us-west-2:123456789012:accesspoint:my-ap", bucket)
+	assert.Equal(t, "a.txt", key)
+
+	bucket, key, err = ParseS3URI("s3://" + testAccessPoint)
+	assert.NoError(t, err)
+	assert.Equal(t, testAccessPoint, bucket)
+	assert.Empty(t, key)
+
+	_, _, err = ParseS3URI("s3://arn:aws:s3:us-west-2:123456789012:bucket/b/key")
+	assert.True(t, errors.Is(err, ErrInvalidURI))
+}
+
+func TestAccessPointURLs(t *testing.T) {
+	assert.Equal(t, testAccessPoint+"/object/logs/a%20b.txt", copySource(testAccessPoint, "logs/a b.txt"))
+	assert.Equal(t, "https://my-ap-123456789012.s3-accesspoint.us-west-2.amazonaws.com/a.txt", DefaultConfig().ObjectURL(testAccessPoint, "a.txt", "us-west-2", false))
+	assert.Equal(t, "https://my-ap-123456789012.s3-accesspoint.us-east-1.amazonaws.com/a.txt", DefaultConfig().ObjectURL(strings.Replace(testAccessPoint, "us-west-2", "us-east-1", 1), "a.txt", "us-east-1", true))
+}
+
+// roundTripFunc http.RoundTripper calling the func
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestAccessPointRequestTarget(t *testing.T) {
+	var requests []*http.Request
+	s := NewS3ryWithConfig(ApNortheastOne, DefaultConfig())
+	s.Sess.Config.
+		WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			requests = append(requests, r)
+			body := `<ListBucketResult><IsTruncated>false</IsTruncated><Contents><Key>logs/a</Key><Size>3</Size></Contents></ListBucketResult>`
+			return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(body)), Request: r}, nil
+		})}).
+		WithCredentials(credentials.NewStaticCredentials("AKID", "SECRET", ""))
+	s.Svc = s3.New(s.Sess)
+	s.history = nil
+
+	h, err := s.SizeHistogram(context.Background(), testAccessPoint, "logs/")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), h.Objects)
+	// the region comes from the ARN, without asking S3 for the bucket location
+	if assert.Len(t, requests, 1) {
+		r := requests[0]
+		assert.Equal(t, "my-ap-123456789012.s3-accesspoint.us-west-2.amazonaws.com", r.URL.Host)
+		assert.Equal(t, "/", r.URL.Path)
+		assert.Equal(t, "logs/", r.URL.Query().Get("prefix"))
+		assert.Contains(t, r.Header.Get("Authorization"), "/us-west-2/s3/aws4_request")
+	}
+
+	_, err = s.SizeHistogram(context.Background(), "arn:aws:s3::123456789012:accesspoint/mfzwi23gnjvgw.mrap", "")
+	assert.True(t, errors.Is(err, ErrMultiRegionAccessPoint))
+	assert.Len(t, requests, 1)
+}
+
+func TestSigningRegion(t *testing.T) {
+	cfg := DefaultConfig()
+	assert.Equal(t, "us-west-2", cfg.signingRegion(testAccessPoint))
+	assert.Empty(t, cfg.signingRegion("bucket"))
+	assert.Empty(t, cfg.signingRegion("arn:aws:s3::123456789012:accesspoint/mfzwi23gnjvgw.mrap"))
+
+	cfg.Buckets = map[string]BucketConfig{"proxied-*": {SigningRegion: "eu-west-1"}, testAccessPoint: {SigningRegion: "us-east-2"}}
+	assert.Equal(t, "eu-west-1", cfg.signingRegion("proxied-logs"))
+	assert.Equal(t, "us-east-2", cfg.signingRegion(testAccessPoint))
+}
+
+func TestSigningRegionRequests(t *testing.T) {
+	var auth []string
+	fake := newFakeS3("bucket", "proxied")
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get("Authorization"))
+		fake.ServeHTTP(w, r)
+	})
+	cfg := DefaultConfig()
+	cfg.Buckets = map[string]BucketConfig{"proxied": {SigningRegion: "eu-west-1"}}
+	s, srv := newTestS3ry(cfg, handler)
+	defer srv.Close()
+	s.regions.set("bucket", ApNortheastOne)
+	s.regions.set("proxied", ApNortheastOne)
+
+	_, err := s.ListObjectsPage(context.Background(), "proxied", ListOptions{})
+	assert.NoError(t, err)
+	_, err = s.ListObjectsPage(context.Background(), "bucket", ListOptions{})
+	assert.NoError(t, err)
+	if assert.Len(t, auth, 2) {
+		assert.Contains(t, auth[0], "/eu-west-1/s3/aws4_request")
+		assert.Contains(t, auth[1], "/ap-northeast-1/s3/aws4_request")
+	}
+
+}
+
+func TestSigningRegionAccessPointOverride(t *testing.T) {
+	var requests []*http.Request
+	cfg := DefaultConfig()
+	cfg.Buckets = map[string]BucketConfig{testAccessPoint: {SigningRegion: "us-east-2"}}
+	s := NewS3ryWithConfig(ApNortheastOne, cfg)
+	s.Sess.Config.
+		WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			requests = append(requests, r)
+			body := `<ListBucketResult><IsTruncated>false</IsTruncated></ListBucketResult>`
+			return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(body)), Request: r}, nil
+		})}).
+		WithCredentials(credentials.NewStaticCredentials("AKID", "SECRET", ""))
+	s.Svc = s3.New(s.Sess)
+	s.history = nil
+
+	_, err := s.ListObjectsPage(context.Background(), testAccessPoint, ListOptions{})
+	assert.NoError(t, err)
+	if assert.Len(t, requests, 1) {
+		// sent to the access point region, signed for the configured one
+		assert.Equal(t, "my-ap-123456789012.s3-accesspoint.us-west-2.amazonaws.com", requests[0].URL.Host)
+		assert.Contains(t, requests[0].Header.Get("Authorization"), "/us-east-2/s3/aws4_request")
+	}
+}
@@ -0,0 +1,177 @@
+package s3ry
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// ACL presets which can be applied to objects and buckets
+const (
+	ACLPrivate           = s3.ObjectCannedACLPrivate
+	ACLPublicRead        = s3.ObjectCannedACLPublicRead
+	ACLAuthenticatedRead = s3.ObjectCannedACLAuthenticatedRead
+)
+
+// ACLPresets canned ACLs offered by s3ry, other canned ACLs are only used through config
+var ACLPresets = []string{ACLPrivate, ACLPublicRead, ACLAuthenticatedRead}
+
+// checkACLPreset check preset is one of ACLPresets
+func checkACLPreset(preset string) error {
+	for _, p := range ACLPresets {
+		if preset == p {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown ACL preset %q, must be one of %v", preset, ACLPresets)
+}
+
+// confirmACL ask with ask before making target readable by everyone
+func confirmACL(acl string, target string, ask askFunc) error {
+	if acl != ACLPublicRead {
+		return nil
+	}
+	yes, err := ask(i18nPrinter.Sprintf("WARNING: public-read makes it readable by ANYONE on the internet. Apply? Target:% s, [Yy] / [Nn]", target))
+	if err != nil {
+		return err
+	}
+	if !yes {
+		return ErrCancelled
+	}
+	return nil
+}
+
+// ObjectGrants get ACL grants of object
+func (s S3ry) ObjectGrants(ctx context.Context, bucket string, key string) ([]*s3.Grant, error) {
+	s, err := s.forBucket(bucket)
+	if err != nil {
+		return nil, err
+	}
+	out, err := s.Svc.GetObjectAclWithContext(ctx, &s3.GetObjectAclInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Grants, nil
+}
+
+// PutObjectACL apply ACL preset to object
+func (s S3ry) PutObjectACL(ctx context.Context, bucket string, key string, preset string) (err error) {
+	done := s.track("acl", bucket, key)
+	defer func() { done(err) }()
+	if err := checkACLPreset(preset); err != nil {
+		return err
+	}
+	if err := confirmACL(preset, "s3://"+bucket+"/"+key, askStdin); err != nil {
+		return err
+	}
+	if s, err = s.forBucket(bucket); err != nil {
+		return err
+	}
+	_, err = s.Svc.PutObjectAclWithContext(ctx, &s3.PutObjectAclInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		ACL:    aws.String(preset),
+	})
+	return err
+}
+
+// PutBucketACL apply ACL preset to bucket
+func (s S3ry) PutBucketACL(ctx context.Context, bucket string, preset string) (err error) {
+	done := s.track("acl", bucket, "")
+	defer func() { done(err) }()
+	if err := checkACLPreset(preset); err != nil {
+		return err
+	}
+	if err := confirmACL(preset, "s3://"+bucket, askStdin); err != nil {
+		return err
+	}
+	if s, err = s.forBucket(bucket); err != nil {
+		return err
+	}
+	_, err = s.Svc.PutBucketAclWithContext(ctx, &s3.PutBucketAclInput{
+		Bucket: aws.String(bucket),
+		ACL:    aws.String(preset),
+	})
+	return err
+}
+
+// formatGrant format grant as "grantee: permission"
+func formatGrant(g *s3.Grant) string {
+	grantee := aws.StringValue(g.Grantee.DisplayName)
+	switch {
+	case g.Grantee.URI != nil:
+		grantee = aws.StringValue(g.Grantee.URI)
+	case g.Grantee.EmailAddress != nil:
+		grantee = aws.StringValue(g.Grantee.EmailAddress)
+	case grantee == "":
+		grantee = aws.StringValue(g.Grantee.ID)
+	}
+	return grantee + ": " + aws.StringValue(g.Permission)
+}
+
+// ChangeObjectACL show grants of object and apply selected preset, used by the TUI
+func (s S3ry) ChangeObjectACL(bucket string, key string) error {
+	grants, err := s.ObjectGrants(context.Background(), bucket, key)
+	if err != nil {
+		return err
+	}
+	fmt.Println(i18nPrinter.Sprintf("Current grants of% s", key))
+	for _, g := range grants {
+		fmt.Println("  " + formatGrant(g))
+	}
+	var items []PromptItems
+	for i, preset := range ACLPresets {
+		items = append(items, PromptItems{Key: i, Val: preset})
+	}
+	preset := s.SelectItem(i18nPrinter.Sprintf("Which ACL do you apply?"), items)
+	if err := s.PutObjectACL(context.Background(), bucket, key, preset); err != nil {
+		return err
+	}
+	fmt.Println(i18nPrinter.Sprintf("Applied ACL,% s,% s", key, preset))
+	return nil
+}
+
+// ACL show grants of s3:// URI target, or apply preset to it when given, used by the acl command
+// a URI without key targets the bucket
+func ACL(ctx context.Context, cfg *Config, target string, preset string) error {
+	bucket, key, err := ParseS3URI(target)
+	if err != nil {
+		return err
+	}
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	if preset != "" {
+		if key == "" {
+			err = s.PutBucketACL(ctx, bucket, preset)
+		} else {
+			err = s.PutObjectACL(ctx, bucket, key, preset)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Println(i18nPrinter.Sprintf("Applied ACL,% s,% s", target, preset))
+		return nil
+	}
+	var grants []*s3.Grant
+	if key == "" {
+		c, err := s.forBucket(bucket)
+		if err != nil {
+			return err
+		}
+		out, err := c.Svc.GetBucketAclWithContext(ctx, &s3.GetBucketAclInput{Bucket: aws.String(bucket)})
+		if err != nil {
+			return err
+		}
+		grants = out.Grants
+	} else if grants, err = s.ObjectGrants(ctx, bucket, key); err != nil {
+		return err
+	}
+	for _, g := range grants {
+		fmt.Println(formatGrant(g))
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package s3ry
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObjectGrants(t *testing.T) {
+	fake := newFakeS3("bucket")
+	fake.put("bucket", "key", "data")
+	o, _ := fake.get("bucket", "key")
+	o.acl = ACLPublicRead
+	s, srv := newTestS3ry(DefaultConfig(), fake)
+	defer srv.Close()
+
+	grants, err := s.ObjectGrants(context.Background(), "bucket", "key")
+	assert.NoError(t, err)
+	var formatted []string
+	for _, g := range grants {
+		formatted = append(formatted, formatGrant(g))
+	}
+	assert.Equal(t, []string{
+		"owner: FULL_CONTROL",
+		"http://acs.amazonaws.com/groups/global/AllUsers: READ",
+	}, formatted)
+}
+
+func TestPutACLPresets(t *testing.T) {
+	fake := newFakeS3("bucket")
+	fake.put("bucket", "key", "data")
+	var headers []string
+	fake.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method == http.MethodPut {
+			headers = append(headers, r.Header.Get("X-Amz-Acl"))
+		}
+		return false
+	}
+	s, srv := newTestS3ry(DefaultConfig(), fake)
+	defer srv.Close()
+
+	var asked []string
+	defer func(c func(string) bool) { confirm = c }(confirm)
+	confirm = func(message string) bool {
+		asked = append(asked, message)
+		return true
+	}
+
+	for _, preset := range ACLPresets {
+		assert.NoError(t, s.PutObjectACL(context.Background(), "bucket", "key", preset), preset)
+		assert.NoError(t, s.PutBucketACL(context.Background(), "bucket", preset), preset)
+	}
+	assert.Equal(t, []string{
+		ACLPrivate, ACLPrivate,
+		ACLPublicRead, ACLPublicRead,
+		ACLAuthenticatedRead, ACLAuthenticatedRead,
+	}, headers)
+	// only public-read asks
+	assert.Len(t, asked, 2)
+	o, _ := fake.get("bucket", "key")
+	assert.Equal(t, ACLAuthenticatedRead, o.acl)
+
+	assert.Error(t, s.PutObjectACL(context.Background(), "bucket", "key", "public-read-write"))
+	assert.Len(t, headers, 6)
+}
+
+func TestPutACLPublicReadDeclined(t *testing.T) {
+	fake := newFakeS3("bucket")
+	fake.put("bucket", "key", "data")
+	s, srv := newTestS3ry(DefaultConfig(), fake)
+	defer srv.Close()
+
+	defer func(c func(string) bool) { confirm = c }(confirm)
+	confirm = func(message string) bool { return false }
+
+	assert.Equal(t, ErrCancelled, s.PutObjectACL(context.Background(), "bucket", "key", ACLPublicRead))
+	assert.Equal(t, 0, fake.count("PUT_ACL"))
+}
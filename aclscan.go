@@ -0,0 +1,176 @@
+package s3ry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/seike460/s3ry/internal/worker"
+)
+
+// Grantee groups which expose an object beyond the accounts it is granted to
+const (
+	granteeAllUsers           = "http://acs.amazonaws.com/groups/global/AllUsers"
+	granteeAuthenticatedUsers = "http://acs.amazonaws.com/groups/global/AuthenticatedUsers"
+)
+
+// ExposedObject object whose ACL grants access to anyone or to every AWS account
+type ExposedObject struct {
+	Key string
+	// Grants exposing grants formatted as "grantee: permission"
+	Grants []string
+}
+
+// ACLScanSummary result of ScanObjectACLs
+type ACLScanSummary struct {
+	Scanned int
+	Exposed []ExposedObject
+	// Truncated the scan stopped at the limit before the end of the listing
+	Truncated bool
+	// Failed error of each key whose ACL could not be read
+	Failed map[string]error `json:"-"`
+}
+
+// exposingGrants return grants of public groups formatted by formatGrant
+func exposingGrants(grants []*s3.Grant) []string {
+	var exposing []string
+	for _, g := range grants {
+		if g.Grantee == nil {
+			continue
+		}
+		switch aws.StringValue(g.Grantee.URI) {
+		case granteeAllUsers, granteeAuthenticatedUsers:
+			exposing = append(exposing, formatGrant(g))
+		}
+	}
+	return exposing
+}
+
+// ScanObjectACLs find objects under prefix whose ACL grants access to anyone or to every AWS account
+// at most limit objects are checked, 0 for no limit, at most rate per second, 0 for no limit
+func (s S3ry) ScanObjectACLs(ctx context.Context, bucket string, prefix string, limit int64, rate int) (ACLScanSummary, error) {
+	summary := ACLScanSummary{Failed: map[string]error{}}
+	s, err := s.forBucket(bucket)
+	if err != nil {
+		return summary, err
+	}
+	var tick <-chan time.Time
+	if rate > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	var mu sync.Mutex
+	pool := worker.New(ctx, s.config().Performance.Workers)
+	var submitErr error
+	err = s.Svc.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(bucket),
+		Prefix:  aws.String(prefix),
+		MaxKeys: aws.Int64(s.config().Performance.ListPageSize),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, object := range page.Contents {
+			if limit > 0 && int64(summary.Scanned) >= limit {
+				summary.Truncated = true
+				return false
+			}
+			if tick != nil {
+				select {
+				case <-tick:
+				case <-ctx.Done():
+					submitErr = ctx.Err()
+					return false
+				}
+			}
+			key := aws.StringValue(object.Key)
+			summary.Scanned++
+			submitErr = pool.Submit(func(ctx context.Context) {
+				grants, err := s.ObjectGrants(ctx, bucket, key)
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					summary.Failed[key] = err
+					return
+				}
+				if exposing := exposingGrants(grants); len(exposing) > 0 {
+					summary.Exposed = append(summary.Exposed, ExposedObject{Key: key, Grants: exposing})
+				}
+			})
+			if submitErr != nil {
+				return false
+			}
+		}
+		return true
+	})
+	pool.Wait()
+	sort.Slice(summary.Exposed, func(a, b int) bool {
+		return summary.Exposed[a].Key < summary.Exposed[b].Key
+	})
+	if submitErr != nil {
+		return summary, submitErr
+	}
+	return summary, err
+}
+
+// ScanACL print objects under s3:// URI target exposed by their ACL, used by the acl-scan command
+// limit overrides Security.ACLScanLimit when not 0, and output "json" prints the summary as JSON
+func ScanACL(ctx context.Context, cfg *Config, target string, limit int64, output string, w io.Writer) error {
+	bucket, prefix, err := ParseS3URI(target)
+	if err != nil {
+		return err
+	}
+	if output != "" && output != "json" {
+		return fmt.Errorf("unknown output %q, use json", output)
+	}
+	if limit == 0 {
+		limit = cfg.Security.ACLScanLimit
+	}
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	// keep JSON output parseable
+	if output == "" {
+		sps(i18nPrinter.Sprintf("Checking object ACLs ..."))
+	}
+	summary, err := s.ScanObjectACLs(ctx, bucket, prefix, limit, cfg.Security.ACLScanRate)
+	if output == "" {
+		spe()
+	}
+	if err != nil {
+		return err
+	}
+	if output == "json" {
+		b, err := json.MarshalIndent(summary, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, string(b))
+	} else {
+		for _, o := range summary.Exposed {
+			fmt.Fprintln(w, o.Key)
+			for _, g := range o.Grants {
+				fmt.Fprintln(w, "  "+g)
+			}
+		}
+		var failed []string
+		for key := range summary.Failed {
+			failed = append(failed, key)
+		}
+		sort.Strings(failed)
+		for _, key := range failed {
+			fmt.Fprintln(w, i18nPrinter.Sprintf("Failed,% s: %s", key, summary.Failed[key].Error()))
+		}
+		fmt.Fprintln(w, i18nPrinter.Sprintf("Scanned: %d, exposed: %d", summary.Scanned, len(summary.Exposed)))
+		if summary.Truncated {
+			fmt.Fprintln(w, i18nPrinter.Sprintf("Stopped at the limit of %d objects", limit))
+		}
+	}
+	if len(summary.Failed) > 0 {
+		return &PartialError{Failed: len(summary.Failed), Op: "scan"}
+	}
+	return nil
+}
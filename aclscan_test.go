@@ -0,0 +1,71 @@
+package s3ry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newACLScanFake() *fakeS3 {
+	fake := newFakeS3("bucket")
+	for key, acl := range map[string]string{
+		"docs/private":  ACLPrivate,
+		"docs/public":   ACLPublicRead,
+		"docs/accounts": ACLAuthenticatedRead,
+		"docs/default":  "",
+		"other/public":  ACLPublicRead,
+	} {
+		fake.put("bucket", key, "data")
+		o, _ := fake.get("bucket", key)
+		o.acl = acl
+	}
+	return fake
+}
+
+func TestScanObjectACLs(t *testing.T) {
+	fake := newACLScanFake()
+	s, srv := newTestS3ry(DefaultConfig(), fake)
+	defer srv.Close()
+
+	summary, err := s.ScanObjectACLs(context.Background(), "bucket", "docs/", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, summary.Scanned)
+	assert.False(t, summary.Truncated)
+	assert.Equal(t, []ExposedObject{
+		{Key: "docs/accounts", Grants: []string{"http://acs.amazonaws.com/groups/global/AuthenticatedUsers: READ"}},
+		{Key: "docs/public", Grants: []string{"http://acs.amazonaws.com/groups/global/AllUsers: READ"}},
+	}, summary.Exposed)
+	assert.Equal(t, 4, fake.count("GET_ACL"))
+}
+
+func TestScanObjectACLsLimit(t *testing.T) {
+	fake := newACLScanFake()
+	cfg := DefaultConfig()
+	cfg.Performance.ListPageSize = 2
+	s, srv := newTestS3ry(cfg, fake)
+	defer srv.Close()
+
+	summary, err := s.ScanObjectACLs(context.Background(), "bucket", "", 3, 1000)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, summary.Scanned)
+	assert.True(t, summary.Truncated)
+	assert.Equal(t, 3, fake.count("GET_ACL"))
+}
+
+func TestScanACLJSON(t *testing.T) {
+	fake := newACLScanFake()
+	cfg, done := newFakeEndpoint(t, fake)
+	defer done()
+
+	out := &bytes.Buffer{}
+	assert.NoError(t, ScanACL(context.Background(), cfg, "s3://bucket/other/", 0, "json", out))
+	var summary ACLScanSummary
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &summary))
+	assert.Equal(t, 1, summary.Scanned)
+	assert.Equal(t, []ExposedObject{{Key: "other/public", Grants: []string{"http://acs.amazonaws.com/groups/global/AllUsers: READ"}}}, summary.Exposed)
+
+	assert.Error(t, ScanACL(context.Background(), cfg, "s3://bucket", 0, "yaml", out))
+}
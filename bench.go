@@ -0,0 +1,266 @@
+package s3ry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/seike460/s3ry/internal/worker"
+)
+
+// BenchOptions settings of a benchmark run
+type BenchOptions struct {
+	Bucket string
+	// Prefix objects are written under, they are deleted when the run ends
+	Prefix string
+	// Sizes object sizes in bytes
+	Sizes []int64
+	// Concurrency operations running at once
+	Concurrency int
+	// Iterations objects per size
+	Iterations int
+}
+
+// BenchResult measurements of one operation and size
+type BenchResult struct {
+	Operation   string
+	Size        int64
+	Concurrency int
+	Iterations  int
+	Bytes       int64
+	Duration    Duration
+	// Throughput MiB per second over the whole run of the operation
+	Throughput float64
+	LatencyP50 Duration
+	LatencyP95 Duration
+	LatencyMax Duration
+	// AllocBytes bytes allocated by s3ry while running the operation
+	AllocBytes uint64
+	// BaselineThroughput and ThroughputChange (percent) are set by CompareBench
+	BaselineThroughput float64 `json:",omitempty"`
+	ThroughputChange   float64 `json:",omitempty"`
+}
+
+// BenchReport result of Bench, written as JSON by the bench command
+type BenchReport struct {
+	Time      time.Time
+	GoVersion string
+	Bucket    string
+	Region    string
+	Results   []BenchResult
+}
+
+// Bench measure upload, download and list of each size against a bucket
+func (s S3ry) Bench(ctx context.Context, opts BenchOptions) (*BenchReport, error) {
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	if opts.Iterations < 1 {
+		opts.Iterations = 1
+	}
+	s, err := s.forBucket(opts.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	report := &BenchReport{
+		Time:      time.Now(),
+		GoVersion: runtime.Version(),
+		Bucket:    opts.Bucket,
+		Region:    aws.StringValue(s.Sess.Config.Region),
+	}
+	for _, size := range opts.Sizes {
+		keys := make([]string, opts.Iterations)
+		for i := range keys {
+			keys[i] = fmt.Sprintf("%ss3ry-bench-%d-%d", opts.Prefix, size, i)
+		}
+		data := make([]byte, size)
+		uploader := s3manager.NewUploader(s.Sess)
+		result, err := s.benchRun(ctx, opts, "upload", size, keys, func(ctx context.Context, key string) (int64, error) {
+			_, err := uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+				Bucket: aws.String(opts.Bucket),
+				Key:    aws.String(key),
+				Body:   bytes.NewReader(data),
+			})
+			return size, err
+		})
+		if err != nil {
+			s.benchCleanup(keys, opts.Bucket)
+			return report, err
+		}
+		report.Results = append(report.Results, result)
+
+		result, err = s.benchRun(ctx, opts, "download", size, keys, func(ctx context.Context, key string) (int64, error) {
+			out, err := s.Svc.GetObjectWithContext(ctx, &s3.GetObjectInput{Bucket: aws.String(opts.Bucket), Key: aws.String(key)})
+			if err != nil {
+				return 0, err
+			}
+			defer out.Body.Close()
+			return io.Copy(ioutil.Discard, out.Body)
+		})
+		if err == nil {
+			report.Results = append(report.Results, result)
+			result, err = s.benchRun(ctx, opts, "list", size, keys, func(ctx context.Context, key string) (int64, error) {
+				_, err := s.Svc.ListObjectsV2WithContext(ctx, &s3.ListObjectsV2Input{Bucket: aws.String(opts.Bucket), Prefix: aws.String(opts.Prefix)})
+				return 0, err
+			})
+		}
+		s.benchCleanup(keys, opts.Bucket)
+		if err != nil {
+			return report, err
+		}
+		report.Results = append(report.Results, result)
+	}
+	return report, nil
+}
+
+// benchRun run op once per key and measure it
+func (s S3ry) benchRun(ctx context.Context, opts BenchOptions, operation string, size int64, keys []string, op func(context.Context, string) (int64, error)) (BenchResult, error) {
+	var mu sync.Mutex
+	var latencies []time.Duration
+	var total int64
+	var firstErr error
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	pool := worker.New(ctx, opts.Concurrency)
+	for _, key := range keys {
+		key := key
+		if err := pool.Submit(func(ctx context.Context) {
+			opStart := time.Now()
+			n, err := op(ctx, key)
+			elapsed := time.Since(opStart)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			latencies = append(latencies, elapsed)
+			total += n
+		}); err != nil {
+			break
+		}
+	}
+	pool.Wait()
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+	if firstErr == nil {
+		firstErr = ctx.Err()
+	}
+	if firstErr != nil {
+		return BenchResult{}, fmt.Errorf("%s %d bytes: %w", operation, size, firstErr)
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	result := BenchResult{
+		Operation:   operation,
+		Size:        size,
+		Concurrency: opts.Concurrency,
+		Iterations:  len(keys),
+		Bytes:       total,
+		Duration:    Duration(elapsed),
+		LatencyP50:  Duration(percentile(latencies, 50)),
+		LatencyP95:  Duration(percentile(latencies, 95)),
+		LatencyMax:  Duration(latencies[len(latencies)-1]),
+		AllocBytes:  after.TotalAlloc - before.TotalAlloc,
+	}
+	if elapsed > 0 {
+		result.Throughput = float64(total) / (1024 * 1024) / elapsed.Seconds()
+	}
+	return result, nil
+}
+
+// benchCleanup delete benchmark objects, errors are ignored as they are only leftovers
+func (s S3ry) benchCleanup(keys []string, bucket string) {
+	var objects []*s3.ObjectIdentifier
+	for _, key := range keys {
+		objects = append(objects, &s3.ObjectIdentifier{Key: aws.String(key)})
+	}
+	s.Svc.DeleteObjects(&s3.DeleteObjectsInput{
+		Bucket: aws.String(bucket),
+		Delete: &s3.Delete{Objects: objects, Quiet: aws.Bool(true)},
+	})
+}
+
+// percentile return p-th percentile of sorted durations
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := (len(sorted)*p+99)/100 - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
+
+// CompareBench set baseline throughput and change of every result of report also found in baseline
+func CompareBench(report *BenchReport, baseline *BenchReport) {
+	base := map[string]BenchResult{}
+	for _, r := range baseline.Results {
+		base[fmt.Sprintf("%s/%d/%d", r.Operation, r.Size, r.Concurrency)] = r
+	}
+	for i, r := range report.Results {
+		b, ok := base[fmt.Sprintf("%s/%d/%d", r.Operation, r.Size, r.Concurrency)]
+		if !ok || b.Throughput == 0 {
+			continue
+		}
+		report.Results[i].BaselineThroughput = b.Throughput
+		report.Results[i].ThroughputChange = (r.Throughput - b.Throughput) / b.Throughput * 100
+	}
+}
+
+// LoadBenchReport read BenchReport written by the bench command
+func LoadBenchReport(path string) (*BenchReport, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	report := &BenchReport{}
+	if err := json.Unmarshal(b, report); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return report, nil
+}
+
+// Benchmark run Bench against s3:// URI target and write the report as JSON to w, used by the bench command
+// with baseline the report is compared against the report saved at that path
+func Benchmark(ctx context.Context, cfg *Config, target string, opts BenchOptions, baseline string, w io.Writer) error {
+	bucket, prefix, err := ParseS3URI(target)
+	if err != nil {
+		return err
+	}
+	opts.Bucket, opts.Prefix = bucket, prefix
+	var base *BenchReport
+	if baseline != "" {
+		if base, err = LoadBenchReport(baseline); err != nil {
+			return err
+		}
+	}
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	sps(i18nPrinter.Sprintf("Running benchmark ..."))
+	report, err := s.Bench(ctx, opts)
+	spe()
+	if err != nil {
+		return err
+	}
+	if base != nil {
+		CompareBench(report, base)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
@@ -0,0 +1,67 @@
+package s3ry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBench(t *testing.T) {
+	fake := newFakeS3("bucket")
+	fake.put("bucket", "keep", "data")
+	s, srv := newTestS3ry(DefaultConfig(), fake)
+	defer srv.Close()
+
+	report, err := s.Bench(context.Background(), BenchOptions{
+		Bucket:      "bucket",
+		Prefix:      "bench/",
+		Sizes:       []int64{10, 1024},
+		Concurrency: 2,
+		Iterations:  3,
+	})
+	assert.NoError(t, err)
+	if !assert.Len(t, report.Results, 6) {
+		return
+	}
+	for i, op := range []string{"upload", "download", "list", "upload", "download", "list"} {
+		r := report.Results[i]
+		assert.Equal(t, op, r.Operation)
+		assert.Equal(t, 3, r.Iterations)
+		assert.Equal(t, 2, r.Concurrency)
+		assert.True(t, r.LatencyP50 <= r.LatencyP95 && r.LatencyP95 <= r.LatencyMax, "%+v", r)
+		if op != "list" {
+			assert.Equal(t, 3*r.Size, r.Bytes)
+			assert.True(t, r.Throughput > 0)
+		}
+	}
+	assert.Equal(t, int64(1024), report.Results[3].Size)
+	// benchmark objects are deleted
+	assert.Equal(t, []string{"keep"}, fake.keys("bucket"))
+
+	// the JSON report reads back
+	var buf bytes.Buffer
+	assert.NoError(t, json.NewEncoder(&buf).Encode(report))
+	var decoded BenchReport
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, report.Results, decoded.Results)
+}
+
+func TestCompareBench(t *testing.T) {
+	baseline := &BenchReport{Results: []BenchResult{
+		{Operation: "upload", Size: 10, Concurrency: 2, Throughput: 100},
+		{Operation: "download", Size: 10, Concurrency: 2, Throughput: 0},
+	}}
+	report := &BenchReport{Results: []BenchResult{
+		{Operation: "upload", Size: 10, Concurrency: 2, Throughput: 150},
+		{Operation: "upload", Size: 10, Concurrency: 4, Throughput: 150},
+		{Operation: "download", Size: 10, Concurrency: 2, Throughput: 50},
+	}}
+	CompareBench(report, baseline)
+	assert.Equal(t, 100.0, report.Results[0].BaselineThroughput)
+	assert.Equal(t, 50.0, report.Results[0].ThroughputChange)
+	assert.Zero(t, report.Results[1].BaselineThroughput)
+	assert.Zero(t, report.Results[2].BaselineThroughput)
+}
@@ -0,0 +1,252 @@
+package s3ry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+)
+
+// ErrNoBucketMetrics CloudWatch has no storage metrics of the bucket, e.g. it was created within the last day
+var ErrNoBucketMetrics = errors.New("no CloudWatch metrics of the bucket")
+
+// bucketMetricsTTL time fetched metrics are reused, S3 reports storage metrics once a day
+const bucketMetricsTTL = 6 * time.Hour
+
+// requestMetricsFilter filter id of request metrics of the whole bucket, as the S3 console names it
+const requestMetricsFilter = "EntireBucket"
+
+// RequestMetrics requests of the last day, counted by the request metrics of the bucket
+type RequestMetrics struct {
+	Requests   int64
+	Downloaded int64
+	Uploaded   int64
+}
+
+// BucketMetrics storage metrics S3 reports to CloudWatch for a bucket
+type BucketMetrics struct {
+	Bucket string
+	// Time day the metrics were measured
+	Time time.Time
+	// Bytes stored by storage type, e.g. StandardStorage
+	Bytes   map[string]int64
+	Objects int64
+	// Requests nil when request metrics of the whole bucket are not enabled
+	Requests *RequestMetrics `json:",omitempty"`
+}
+
+// TotalBytes return bytes stored in every storage type
+func (m *BucketMetrics) TotalBytes() int64 {
+	var total int64
+	for _, n := range m.Bytes {
+		total += n
+	}
+	return total
+}
+
+// latestDatapoint return latest value of statistic of metric, false when CloudWatch has none
+func latestDatapoint(ctx context.Context, cw cloudwatchiface.CloudWatchAPI, metric string, dimensions map[string]string, statistic string, start time.Time, end time.Time) (float64, time.Time, bool, error) {
+	input := &cloudwatch.GetMetricStatisticsInput{
+		Namespace:  aws.String("AWS/S3"),
+		MetricName: aws.String(metric),
+		StartTime:  aws.Time(start),
+		EndTime:    aws.Time(end),
+		Period:     aws.Int64(int64((24 * time.Hour).Seconds())),
+		Statistics: aws.StringSlice([]string{statistic}),
+	}
+	for name, value := range dimensions {
+		input.Dimensions = append(input.Dimensions, &cloudwatch.Dimension{Name: aws.String(name), Value: aws.String(value)})
+	}
+	out, err := cw.GetMetricStatisticsWithContext(ctx, input)
+	if err != nil {
+		return 0, time.Time{}, false, err
+	}
+	var latest *cloudwatch.Datapoint
+	for _, d := range out.Datapoints {
+		if latest == nil || aws.TimeValue(d.Timestamp).After(aws.TimeValue(latest.Timestamp)) {
+			latest = d
+		}
+	}
+	if latest == nil {
+		return 0, time.Time{}, false, nil
+	}
+	v := latest.Average
+	if statistic == cloudwatch.StatisticSum {
+		v = latest.Sum
+	}
+	return aws.Float64Value(v), aws.TimeValue(latest.Timestamp), true, nil
+}
+
+// fetchBucketMetrics read the latest storage metrics of bucket from cw, in the region of the bucket
+// request metrics are read when they are enabled for the whole bucket
+func fetchBucketMetrics(ctx context.Context, cw cloudwatchiface.CloudWatchAPI, bucket string, now time.Time) (*BucketMetrics, error) {
+	// storage types the bucket has data in
+	var storageTypes []string
+	err := cw.ListMetricsPagesWithContext(ctx, &cloudwatch.ListMetricsInput{
+		Namespace:  aws.String("AWS/S3"),
+		MetricName: aws.String("BucketSizeBytes"),
+		Dimensions: []*cloudwatch.DimensionFilter{{Name: aws.String("BucketName"), Value: aws.String(bucket)}},
+	}, func(page *cloudwatch.ListMetricsOutput, lastPage bool) bool {
+		for _, metric := range page.Metrics {
+			for _, d := range metric.Dimensions {
+				if aws.StringValue(d.Name) == "StorageType" {
+					storageTypes = append(storageTypes, aws.StringValue(d.Value))
+				}
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	m := &BucketMetrics{Bucket: bucket, Bytes: map[string]int64{}}
+	// the metrics of a day arrive up to a day later
+	start := now.Add(-3 * 24 * time.Hour)
+	for _, storageType := range storageTypes {
+		v, t, ok, err := latestDatapoint(ctx, cw, "BucketSizeBytes", map[string]string{"BucketName": bucket, "StorageType": storageType}, cloudwatch.StatisticAverage, start, now)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		m.Bytes[storageType] = int64(v)
+		if t.After(m.Time) {
+			m.Time = t
+		}
+	}
+	if len(m.Bytes) == 0 {
+		return nil, fmt.Errorf("%w s3://%s", ErrNoBucketMetrics, bucket)
+	}
+	v, _, _, err := latestDatapoint(ctx, cw, "NumberOfObjects", map[string]string{"BucketName": bucket, "StorageType": "AllStorageTypes"}, cloudwatch.StatisticAverage, start, now)
+	if err != nil {
+		return nil, err
+	}
+	m.Objects = int64(v)
+
+	requests := &RequestMetrics{}
+	for _, r := range []struct {
+		metric string
+		value  *int64
+	}{
+		{"AllRequests", &requests.Requests},
+		{"BytesDownloaded", &requests.Downloaded},
+		{"BytesUploaded", &requests.Uploaded},
+	} {
+		v, _, ok, err := latestDatapoint(ctx, cw, r.metric, map[string]string{"BucketName": bucket, "FilterId": requestMetricsFilter}, cloudwatch.StatisticSum, now.Add(-24*time.Hour), now)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			m.Requests = requests
+			*r.value = int64(v)
+		}
+	}
+	return m, nil
+}
+
+// cachedBucketMetrics metrics of a bucket and when they were fetched
+type cachedBucketMetrics struct {
+	Fetched time.Time
+	Metrics *BucketMetrics
+}
+
+// bucketMetricsCache fetched metrics by bucket, kept across runs
+// an empty path keeps the cache in memory only
+type bucketMetricsCache struct {
+	path    string
+	entries map[string]cachedBucketMetrics
+}
+
+// defaultBucketMetricsPath return path of the metrics cache next to the config file
+func defaultBucketMetricsPath() string {
+	return configDirFile("metrics.json")
+}
+
+// loadBucketMetricsCache load cache from path
+func loadBucketMetricsCache(path string) *bucketMetricsCache {
+	c := &bucketMetricsCache{path: path, entries: map[string]cachedBucketMetrics{}}
+	if path != "" {
+		if b, err := ioutil.ReadFile(path); err == nil {
+			json.Unmarshal(b, &c.entries)
+		}
+	}
+	return c
+}
+
+// get return metrics of bucket fetched less than bucketMetricsTTL before now
+func (c *bucketMetricsCache) get(bucket string, now time.Time) (*BucketMetrics, bool) {
+	e, ok := c.entries[bucket]
+	if !ok || e.Metrics == nil || now.Sub(e.Fetched) >= bucketMetricsTTL {
+		return nil, false
+	}
+	return e.Metrics, true
+}
+
+// set cache metrics of bucket fetched at now
+// like the upload journal it is best effort, a failed write only fetches the metrics again
+func (c *bucketMetricsCache) set(bucket string, m *BucketMetrics, now time.Time) {
+	c.entries[bucket] = cachedBucketMetrics{Fetched: now, Metrics: m}
+	if c.path == "" {
+		return
+	}
+	b, err := json.Marshal(c.entries)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0700); err != nil {
+		return
+	}
+	ioutil.WriteFile(c.path, b, 0600)
+}
+
+// BucketMetrics return storage metrics of bucket from CloudWatch, without listing its objects
+// the metrics are cached for bucketMetricsTTL, ErrNoBucketMetrics when CloudWatch has none
+func (s S3ry) BucketMetrics(ctx context.Context, bucket string) (*BucketMetrics, error) {
+	cache := loadBucketMetricsCache(defaultBucketMetricsPath())
+	now := time.Now()
+	if m, ok := cache.get(bucket, now); ok {
+		return m, nil
+	}
+	s, err := s.forBucket(bucket)
+	if err != nil {
+		return nil, err
+	}
+	m, err := fetchBucketMetrics(ctx, cloudwatch.New(s.Sess), bucket, now)
+	if err != nil {
+		return nil, err
+	}
+	cache.set(bucket, m, now)
+	return m, nil
+}
+
+// Print write metrics as a table of bytes by storage type, with the object count and requests
+func (m *BucketMetrics) Print(w io.Writer) {
+	storageTypes := make([]string, 0, len(m.Bytes))
+	for storageType := range m.Bytes {
+		storageTypes = append(storageTypes, storageType)
+	}
+	sort.Strings(storageTypes)
+	fmt.Fprintf(w, "s3://%s on %s (CloudWatch)\n", m.Bucket, m.Time.UTC().Format("2006-01-02"))
+	fmt.Fprintf(w, "%-28s %16s\n", "storage type", "bytes")
+	for _, storageType := range storageTypes {
+		fmt.Fprintf(w, "%-28s %16d\n", storageType, m.Bytes[storageType])
+	}
+	fmt.Fprintf(w, "%-28s %16d\n", "total", m.TotalBytes())
+	fmt.Fprintf(w, "objects %d\n", m.Objects)
+	if m.Requests == nil {
+		fmt.Fprintf(w, "requests unknown, enable request metrics with filter %s\n", requestMetricsFilter)
+		return
+	}
+	fmt.Fprintf(w, "requests %d, downloaded %d bytes, uploaded %d bytes in the last day\n", m.Requests.Requests, m.Requests.Downloaded, m.Requests.Uploaded)
+}
@@ -0,0 +1,131 @@
+package s3ry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeCloudWatch CloudWatch returning datapoints by metric name and dimension values
+type fakeCloudWatch struct {
+	cloudwatchiface.CloudWatchAPI
+	storageTypes []string
+	// datapoints keyed by metric and the StorageType or FilterId dimension
+	datapoints map[string][]*cloudwatch.Datapoint
+}
+
+func (f *fakeCloudWatch) ListMetricsPagesWithContext(ctx aws.Context, input *cloudwatch.ListMetricsInput, fn func(*cloudwatch.ListMetricsOutput, bool) bool, opts ...request.Option) error {
+	out := &cloudwatch.ListMetricsOutput{}
+	for _, storageType := range f.storageTypes {
+		out.Metrics = append(out.Metrics, &cloudwatch.Metric{
+			MetricName: input.MetricName,
+			Dimensions: []*cloudwatch.Dimension{
+				{Name: aws.String("BucketName"), Value: input.Dimensions[0].Value},
+				{Name: aws.String("StorageType"), Value: aws.String(storageType)},
+			},
+		})
+	}
+	fn(out, true)
+	return nil
+}
+
+func (f *fakeCloudWatch) GetMetricStatisticsWithContext(ctx aws.Context, input *cloudwatch.GetMetricStatisticsInput, opts ...request.Option) (*cloudwatch.GetMetricStatisticsOutput, error) {
+	key := aws.StringValue(input.MetricName)
+	for _, d := range input.Dimensions {
+		if aws.StringValue(d.Name) != "BucketName" {
+			key += " " + aws.StringValue(d.Value)
+		}
+	}
+	return &cloudwatch.GetMetricStatisticsOutput{Datapoints: f.datapoints[key]}, nil
+}
+
+func averageDatapoint(v float64, t time.Time) *cloudwatch.Datapoint {
+	return &cloudwatch.Datapoint{Average: aws.Float64(v), Timestamp: aws.Time(t)}
+}
+
+func sumDatapoint(v float64, t time.Time) *cloudwatch.Datapoint {
+	return &cloudwatch.Datapoint{Sum: aws.Float64(v), Timestamp: aws.Time(t)}
+}
+
+func TestFetchBucketMetrics(t *testing.T) {
+	now := time.Date(2020, 8, 20, 12, 0, 0, 0, time.UTC)
+	day := now.Truncate(24 * time.Hour)
+	cw := &fakeCloudWatch{
+		storageTypes: []string{"StandardStorage", "GlacierStorage", "StandardIAStorage"},
+		datapoints: map[string][]*cloudwatch.Datapoint{
+			"BucketSizeBytes StandardStorage": {averageDatapoint(1000, day.Add(-24*time.Hour)), averageDatapoint(1500, day)},
+			"BucketSizeBytes GlacierStorage":  {averageDatapoint(4000, day)},
+			"NumberOfObjects AllStorageTypes": {averageDatapoint(30, day)},
+			"AllRequests EntireBucket":        {sumDatapoint(120, day)},
+			"BytesDownloaded EntireBucket":    {sumDatapoint(2048, day)},
+			"BytesUploaded EntireBucket":      {sumDatapoint(512, day)},
+		},
+	}
+	m, err := fetchBucketMetrics(context.Background(), cw, "bucket", now)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"StandardStorage": 1500, "GlacierStorage": 4000}, m.Bytes)
+	assert.Equal(t, int64(5500), m.TotalBytes())
+	assert.Equal(t, int64(30), m.Objects)
+	assert.Equal(t, day, m.Time)
+	assert.Equal(t, &RequestMetrics{Requests: 120, Downloaded: 2048, Uploaded: 512}, m.Requests)
+
+	var out bytes.Buffer
+	m.Print(&out)
+	assert.Contains(t, out.String(), "s3://bucket on 2020-08-20")
+	assert.Contains(t, out.String(), "objects 30")
+	assert.Contains(t, out.String(), "requests 120, downloaded 2048 bytes")
+}
+
+func TestFetchBucketMetricsMissing(t *testing.T) {
+	now := time.Now()
+	// a new bucket has no metrics yet
+	_, err := fetchBucketMetrics(context.Background(), &fakeCloudWatch{}, "bucket", now)
+	assert.True(t, errors.Is(err, ErrNoBucketMetrics))
+
+	// without request metrics only the storage metrics are read
+	cw := &fakeCloudWatch{
+		storageTypes: []string{"StandardStorage"},
+		datapoints:   map[string][]*cloudwatch.Datapoint{"BucketSizeBytes StandardStorage": {averageDatapoint(10, now)}},
+	}
+	m, err := fetchBucketMetrics(context.Background(), cw, "bucket", now)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), m.TotalBytes())
+	assert.Zero(t, m.Objects)
+	assert.Nil(t, m.Requests)
+	var out bytes.Buffer
+	m.Print(&out)
+	assert.Contains(t, out.String(), "requests unknown")
+}
+
+func TestBucketMetricsCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "s3ry")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "metrics.json")
+	now := time.Now()
+
+	c := loadBucketMetricsCache(path)
+	_, ok := c.get("bucket", now)
+	assert.False(t, ok)
+	c.set("bucket", &BucketMetrics{Bucket: "bucket", Bytes: map[string]int64{"StandardStorage": 10}, Objects: 2}, now)
+
+	c = loadBucketMetricsCache(path)
+	m, ok := c.get("bucket", now.Add(time.Hour))
+	assert.True(t, ok)
+	assert.Equal(t, int64(2), m.Objects)
+	_, ok = c.get("bucket", now.Add(bucketMetricsTTL))
+	assert.False(t, ok)
+	_, ok = c.get("other", now)
+	assert.False(t, ok)
+}
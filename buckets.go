@@ -0,0 +1,133 @@
+package s3ry
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// BucketConfig default settings of the buckets matching a Buckets key
+// empty values keep the setting of a less specific match
+type BucketConfig struct {
+	// StorageClass storage class of uploaded objects, e.g. STANDARD_IA
+	StorageClass string `json:",omitempty"`
+	// ACL canned ACL of uploaded objects, e.g. bucket-owner-full-control
+	ACL string `json:",omitempty"`
+	// Prefix prepended to the key of uploaded objects, e.g. "incoming/"
+	Prefix string `json:",omitempty"`
+	// ContentType of uploaded objects, detected from each file when empty
+	ContentType string `json:",omitempty"`
+	// ChecksumAlgorithm checksum S3 verifies uploads with: CRC32, CRC32C, SHA1, SHA256 or NONE (default CRC32C)
+	ChecksumAlgorithm string `json:",omitempty"`
+	// ContentMD5 check uploads sent in one request against their Content-MD5, for storage not supporting
+	// ChecksumAlgorithm, parts of multipart uploads are checked by their ETag anyway
+	ContentMD5 bool `json:",omitempty"`
+	// Encryption server-side encryption of uploaded objects
+	Encryption EncryptionConfig
+	// SigningRegion region requests to the bucket are signed for when it differs from the region they are sent to,
+	// the region of an access point ARN by default
+	SigningRegion string `json:",omitempty"`
+}
+
+// merge override c with the non-empty values of o
+func (c BucketConfig) merge(o BucketConfig) BucketConfig {
+	if o.StorageClass != "" {
+		c.StorageClass = o.StorageClass
+	}
+	if o.ACL != "" {
+		c.ACL = o.ACL
+	}
+	if o.Prefix != "" {
+		c.Prefix = o.Prefix
+	}
+	if o.ContentType != "" {
+		c.ContentType = o.ContentType
+	}
+	if o.ChecksumAlgorithm != "" {
+		c.ChecksumAlgorithm = o.ChecksumAlgorithm
+	}
+	if o.ContentMD5 {
+		c.ContentMD5 = true
+	}
+	if o.SigningRegion != "" {
+		c.SigningRegion = o.SigningRegion
+	}
+	if o.Encryption.Mode != "" {
+		c.Encryption.Mode = o.Encryption.Mode
+	}
+	if o.Encryption.KMSKeyID != "" {
+		c.Encryption.KMSKeyID = o.Encryption.KMSKeyID
+	}
+	if o.Encryption.CustomerKey != "" {
+		c.Encryption.CustomerKey = o.Encryption.CustomerKey
+	}
+	return c
+}
+
+// matchBuckets return Buckets keys matching bucket, least specific first
+//
+// Keys are bucket names or path.Match patterns. The exact bucket name is the
+// most specific, then patterns with more literal characters, so "logs-prod-*"
+// overrides "logs-*" which overrides "*".
+func (c *Config) matchBuckets(bucket string) []string {
+	var keys []string
+	for key := range c.Buckets {
+		if ok, err := path.Match(key, bucket); err == nil && ok {
+			keys = append(keys, key)
+		}
+	}
+	literal := func(key string) int {
+		if key == bucket {
+			return len(key) + 1
+		}
+		return len(key) - strings.Count(key, "*") - strings.Count(key, "?")
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if li, lj := literal(keys[i]), literal(keys[j]); li != lj {
+			return li < lj
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// bucketConfig return settings for bucket
+// Encryption is overridden by matching Buckets, which are overridden by Flags
+func (c *Config) bucketConfig(bucket string) BucketConfig {
+	bc := BucketConfig{Encryption: c.Encryption}
+	for _, key := range c.matchBuckets(bucket) {
+		bc = bc.merge(c.Buckets[key])
+	}
+	return bc.merge(c.Flags)
+}
+
+// forBucket return copy of c with the settings of bucket applied
+func (c *Config) forBucket(bucket string) *Config {
+	if len(c.Buckets) == 0 && c.Flags == (BucketConfig{}) {
+		return c
+	}
+	bc := c.bucketConfig(bucket)
+	copied := *c
+	copied.Encryption = bc.Encryption
+	copied.Flags = bc
+	copied.Buckets = nil
+	return &copied
+}
+
+// applyUpload set the bucket defaults on input
+func (c BucketConfig) applyUpload(input *s3manager.UploadInput) error {
+	if c.StorageClass != "" {
+		input.StorageClass = aws.String(c.StorageClass)
+	}
+	if c.ACL != "" {
+		input.ACL = aws.String(c.ACL)
+	}
+	if c.ContentType != "" {
+		input.ContentType = aws.String(c.ContentType)
+	}
+	input.Key = aws.String(c.Prefix + aws.StringValue(input.Key))
+	return c.Encryption.applyUpload(input)
+}
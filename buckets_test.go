@@ -0,0 +1,105 @@
+package s3ry
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBucketConfigPrecedence(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Encryption = EncryptionConfig{Mode: SSEModeS3}
+	cfg.Buckets = map[string]BucketConfig{
+		"*":           {StorageClass: "STANDARD_IA", Prefix: "all/"},
+		"logs-*":      {StorageClass: "GLACIER", ACL: "private"},
+		"logs-prod-*": {ACL: "bucket-owner-full-control"},
+		"logs-prod-1": {Prefix: "one/", Encryption: EncryptionConfig{Mode: SSEModeKMS, KMSKeyID: "alias/logs"}},
+	}
+
+	assert.Equal(t, BucketConfig{
+		StorageClass: "STANDARD_IA",
+		Prefix:       "all/",
+		Encryption:   EncryptionConfig{Mode: SSEModeS3},
+	}, cfg.bucketConfig("photos"))
+	assert.Equal(t, BucketConfig{
+		StorageClass: "GLACIER",
+		ACL:          "bucket-owner-full-control",
+		Prefix:       "all/",
+		Encryption:   EncryptionConfig{Mode: SSEModeS3},
+	}, cfg.bucketConfig("logs-prod-2"))
+	assert.Equal(t, BucketConfig{
+		StorageClass: "GLACIER",
+		ACL:          "bucket-owner-full-control",
+		Prefix:       "one/",
+		Encryption:   EncryptionConfig{Mode: SSEModeKMS, KMSKeyID: "alias/logs"},
+	}, cfg.bucketConfig("logs-prod-1"))
+
+	// flags override every bucket default
+	cfg.Flags = BucketConfig{StorageClass: "STANDARD", Encryption: EncryptionConfig{Mode: SSEModeS3}}
+	bc := cfg.bucketConfig("logs-prod-1")
+	assert.Equal(t, "STANDARD", bc.StorageClass)
+	assert.Equal(t, SSEModeS3, bc.Encryption.Mode)
+	assert.Equal(t, "one/", bc.Prefix)
+
+	// forBucket is idempotent
+	assert.Equal(t, bc, cfg.forBucket("logs-prod-1").bucketConfig("logs-prod-1"))
+}
+
+func TestUploadObjectBucketDefaults(t *testing.T) {
+	fake := newFakeS3("logs-prod")
+	cfg := DefaultConfig()
+	cfg.Buckets = map[string]BucketConfig{
+		"logs-*": {StorageClass: "STANDARD_IA", ACL: "bucket-owner-full-control", Prefix: "incoming/", Encryption: EncryptionConfig{Mode: SSEModeS3}},
+	}
+	var acl string
+	fake.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method == http.MethodPut {
+			acl = r.Header.Get("X-Amz-Acl")
+		}
+		return false
+	}
+	s, srv := newTestS3ry(cfg, fake)
+	defer srv.Close()
+
+	// a relative name keeps the key readable
+	f, err := ioutil.TempFile(".", "s3ry")
+	assert.NoError(t, err)
+	f.WriteString("log")
+	f.Close()
+	name := filepath.Base(f.Name())
+	defer os.Remove(name)
+
+	assert.NoError(t, s.UploadObject("logs-prod", name))
+	o, ok := fake.get("logs-prod", "incoming/"+name)
+	if assert.True(t, ok, "%v", fake.keys("logs-prod")) {
+		assert.Equal(t, "STANDARD_IA", o.header.Get("X-Amz-Storage-Class"))
+		assert.Equal(t, "AES256", o.header.Get("X-Amz-Server-Side-Encryption"))
+	}
+	assert.Equal(t, "bucket-owner-full-control", acl)
+}
+
+func TestLoadConfigBuckets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "s3ry")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.json")
+	json := `{
+  "Buckets": {
+    "logs-*": {"StorageClass": "GLACIER", "Encryption": {"Mode": "SSE-S3"}},
+    "[bad": {"ACL": "private"},
+    "photos": {"Storage": "STANDARD"}
+  }
+}`
+	assert.NoError(t, ioutil.WriteFile(path, []byte(json), 0600))
+	cfg, err := LoadConfig(path)
+	assert.Equal(t, ConfigErrors{
+		{File: path, Line: 4, Field: "Buckets.[bad", Msg: "invalid pattern"},
+		{File: path, Line: 5, Field: "Buckets.photos.Storage", Msg: "unknown field"},
+	}, err)
+	assert.Equal(t, BucketConfig{StorageClass: "GLACIER", Encryption: EncryptionConfig{Mode: SSEModeS3}}, cfg.Buckets["logs-*"])
+}
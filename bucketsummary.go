@@ -0,0 +1,309 @@
+package s3ry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/seike460/s3ry/internal/worker"
+)
+
+// summaryLargest largest objects kept by a BucketSummary
+const summaryLargest = 10
+
+// bucketSummaryTTL time a summary is reused, so running stats again doesn't list a large bucket again
+const bucketSummaryTTL = 5 * time.Minute
+
+// SummaryObject object named by a BucketSummary
+type SummaryObject struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// ClassTotal objects and bytes of a storage class
+type ClassTotal struct {
+	Objects int64
+	Bytes   int64
+}
+
+// BucketSummary totals of the objects under a prefix of a bucket, computed from one listing
+type BucketSummary struct {
+	Bucket      string
+	Prefix      string `json:",omitempty"`
+	Time        time.Time
+	Objects     int64
+	Bytes       int64
+	AverageSize int64
+	// StorageClasses totals by storage class
+	StorageClasses map[string]ClassTotal
+	// Largest the largest objects, largest first
+	Largest []SummaryObject
+	Oldest  *SummaryObject `json:",omitempty"`
+	Newest  *SummaryObject `json:",omitempty"`
+	// IncompleteUploads multipart uploads started and neither completed nor aborted
+	IncompleteUploads int64
+	Histogram         *SizeHistogram
+}
+
+// newBucketSummary create empty BucketSummary
+func newBucketSummary(bucket string, prefix string) *BucketSummary {
+	return &BucketSummary{Bucket: bucket, Prefix: prefix, StorageClasses: map[string]ClassTotal{}, Histogram: NewSizeHistogram()}
+}
+
+// add count object
+func (b *BucketSummary) add(object *s3.Object) {
+	o := SummaryObject{Key: aws.StringValue(object.Key), Size: aws.Int64Value(object.Size), LastModified: aws.TimeValue(object.LastModified)}
+	b.Objects++
+	b.Bytes += o.Size
+	class := aws.StringValue(object.StorageClass)
+	if class == "" {
+		class = s3.ObjectStorageClassStandard
+	}
+	total := b.StorageClasses[class]
+	total.Objects++
+	total.Bytes += o.Size
+	b.StorageClasses[class] = total
+	b.Histogram.Add(o.Size)
+	b.addLargest(o)
+	if b.Oldest == nil || o.LastModified.Before(b.Oldest.LastModified) {
+		oldest := o
+		b.Oldest = &oldest
+	}
+	if b.Newest == nil || o.LastModified.After(b.Newest.LastModified) {
+		newest := o
+		b.Newest = &newest
+	}
+}
+
+// addLargest keep o when it is one of the summaryLargest largest objects
+func (b *BucketSummary) addLargest(o SummaryObject) {
+	if len(b.Largest) == summaryLargest && o.Size <= b.Largest[summaryLargest-1].Size {
+		return
+	}
+	i := sort.Search(len(b.Largest), func(i int) bool { return b.Largest[i].Size < o.Size })
+	b.Largest = append(b.Largest, SummaryObject{})
+	copy(b.Largest[i+1:], b.Largest[i:])
+	b.Largest[i] = o
+	if len(b.Largest) > summaryLargest {
+		b.Largest = b.Largest[:summaryLargest]
+	}
+}
+
+// merge add the totals of other listed separately
+func (b *BucketSummary) merge(other *BucketSummary) {
+	b.Objects += other.Objects
+	b.Bytes += other.Bytes
+	for class, t := range other.StorageClasses {
+		total := b.StorageClasses[class]
+		total.Objects += t.Objects
+		total.Bytes += t.Bytes
+		b.StorageClasses[class] = total
+	}
+	for i, bin := range other.Histogram.Bins {
+		b.Histogram.Bins[i].Objects += bin.Objects
+		b.Histogram.Bins[i].Bytes += bin.Bytes
+	}
+	b.Histogram.Objects += other.Histogram.Objects
+	b.Histogram.Bytes += other.Histogram.Bytes
+	for _, o := range other.Largest {
+		b.addLargest(o)
+	}
+	if other.Oldest != nil && (b.Oldest == nil || other.Oldest.LastModified.Before(b.Oldest.LastModified)) {
+		b.Oldest = other.Oldest
+	}
+	if other.Newest != nil && (b.Newest == nil || other.Newest.LastModified.After(b.Newest.LastModified)) {
+		b.Newest = other.Newest
+	}
+}
+
+// Print write summary as text
+func (b *BucketSummary) Print(w io.Writer) {
+	fmt.Fprintln(w, i18nPrinter.Sprintf("s3://%s/%s at %s", b.Bucket, b.Prefix, locale.Time(b.Time)))
+	fmt.Fprintln(w, i18nPrinter.Sprintf("Objects: %s, size: %s, average size: %s", locale.Number(b.Objects), locale.Bytes(b.Bytes), locale.Bytes(b.AverageSize)))
+	fmt.Fprintln(w, i18nPrinter.Sprintf("Incomplete multipart uploads: %s", locale.Number(b.IncompleteUploads)))
+	var classes []string
+	for class := range b.StorageClasses {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	fmt.Fprintf(w, "%-20s %12s %16s\n", "storage class", "objects", "bytes")
+	for _, class := range classes {
+		fmt.Fprintf(w, "%-20s %12s %16s\n", class, locale.Number(b.StorageClasses[class].Objects), locale.Number(b.StorageClasses[class].Bytes))
+	}
+	if b.Oldest != nil {
+		fmt.Fprintln(w, i18nPrinter.Sprintf("Oldest: %s %s", locale.Time(b.Oldest.LastModified), b.Oldest.Key))
+		fmt.Fprintln(w, i18nPrinter.Sprintf("Newest: %s %s", locale.Time(b.Newest.LastModified), b.Newest.Key))
+	}
+	if len(b.Largest) > 0 {
+		fmt.Fprintln(w, i18nPrinter.Sprintf("Largest:"))
+		for _, o := range b.Largest {
+			fmt.Fprintf(w, "%16s %s\n", locale.Bytes(o.Size), o.Key)
+		}
+	}
+	b.Histogram.Print(w)
+}
+
+// listSummary list every object under prefix into summary
+func (s S3ry) listSummary(ctx context.Context, bucket string, prefix string) (*BucketSummary, error) {
+	summary := newBucketSummary(bucket, prefix)
+	err := s.Svc.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(bucket),
+		Prefix:  aws.String(prefix),
+		MaxKeys: aws.Int64(s.config().Performance.ListPageSize),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, object := range page.Contents {
+			summary.add(object)
+		}
+		return true
+	})
+	return summary, err
+}
+
+// BucketSummary summarize the objects and incomplete multipart uploads under prefix of bucket
+// the first level of "/" prefixes is listed by Performance.Workers concurrently, and the summary is cached for bucketSummaryTTL
+func (s S3ry) BucketSummary(ctx context.Context, bucket string, prefix string) (*BucketSummary, error) {
+	cache := loadBucketSummaryCache(defaultBucketSummaryPath())
+	now := time.Now()
+	if summary, ok := cache.get(bucket, prefix, now); ok {
+		return summary, nil
+	}
+	s, err := s.forBucket(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := newBucketSummary(bucket, prefix)
+	var prefixes []string
+	err = s.Svc.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(bucket),
+		Prefix:    aws.String(prefix),
+		Delimiter: aws.String("/"),
+		MaxKeys:   aws.Int64(s.config().Performance.ListPageSize),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, object := range page.Contents {
+			summary.add(object)
+		}
+		for _, p := range page.CommonPrefixes {
+			prefixes = append(prefixes, aws.StringValue(p.Prefix))
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	var firstErr error
+	pool := worker.New(ctx, s.config().Performance.Workers)
+	for _, p := range prefixes {
+		p := p
+		if err := pool.Submit(func(ctx context.Context) {
+			sub, err := s.listSummary(ctx, bucket, p)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			summary.merge(sub)
+		}); err != nil {
+			mu.Lock()
+			firstErr = err
+			mu.Unlock()
+			break
+		}
+	}
+	pool.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	err = s.Svc.ListMultipartUploadsPagesWithContext(ctx, &s3.ListMultipartUploadsInput{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListMultipartUploadsOutput, lastPage bool) bool {
+		summary.IncompleteUploads += int64(len(page.Uploads))
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if summary.Objects > 0 {
+		summary.AverageSize = summary.Bytes / summary.Objects
+	}
+	summary.Time = now
+	cache.set(bucket, prefix, summary, now)
+	return summary, nil
+}
+
+// cachedBucketSummary summary with the time it was computed
+type cachedBucketSummary struct {
+	Computed time.Time
+	Summary  *BucketSummary
+}
+
+// bucketSummaryCache computed summaries by s3:// URI, kept across runs
+// an empty path keeps the cache in memory only
+type bucketSummaryCache struct {
+	path    string
+	entries map[string]cachedBucketSummary
+}
+
+// defaultBucketSummaryPath return path of the summary cache next to the config file
+func defaultBucketSummaryPath() string {
+	return configDirFile("summaries.json")
+}
+
+// loadBucketSummaryCache load cache from path
+func loadBucketSummaryCache(path string) *bucketSummaryCache {
+	c := &bucketSummaryCache{path: path, entries: map[string]cachedBucketSummary{}}
+	if path != "" {
+		if b, err := ioutil.ReadFile(path); err == nil {
+			json.Unmarshal(b, &c.entries)
+		}
+	}
+	return c
+}
+
+// get return summary of prefix of bucket computed less than bucketSummaryTTL before now
+func (c *bucketSummaryCache) get(bucket string, prefix string, now time.Time) (*BucketSummary, bool) {
+	e, ok := c.entries["s3://"+bucket+"/"+prefix]
+	if !ok || e.Summary == nil || now.Sub(e.Computed) >= bucketSummaryTTL {
+		return nil, false
+	}
+	return e.Summary, true
+}
+
+// set cache summary of prefix of bucket computed at now, dropping expired summaries
+// like the upload journal it is best effort, a failed write only lists the bucket again
+func (c *bucketSummaryCache) set(bucket string, prefix string, summary *BucketSummary, now time.Time) {
+	for uri, e := range c.entries {
+		if now.Sub(e.Computed) >= bucketSummaryTTL {
+			delete(c.entries, uri)
+		}
+	}
+	c.entries["s3://"+bucket+"/"+prefix] = cachedBucketSummary{Computed: now, Summary: summary}
+	if c.path == "" {
+		return
+	}
+	b, err := json.Marshal(c.entries)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0700); err != nil {
+		return
+	}
+	ioutil.WriteFile(c.path, b, 0600)
+}
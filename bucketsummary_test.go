@@ -0,0 +1,113 @@
+package s3ry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+// newSummaryFake create bucket of objects of size i+1 modified i hours after 2020-01-01 under several prefixes
+func newSummaryFake(t *testing.T) *fakeS3 {
+	fake := newFakeS3("bucket")
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 30; i++ {
+		key := fmt.Sprintf("dir%d/sub/%02d", i%4, i)
+		if i%10 == 0 {
+			key = fmt.Sprintf("top-%02d", i)
+		}
+		fake.put("bucket", key, strings.Repeat("x", i+1))
+		o, _ := fake.get("bucket", key)
+		o.lastModified = start.Add(time.Duration(i) * time.Hour)
+		if i%3 == 0 {
+			o.header.Set("X-Amz-Storage-Class", "GLACIER")
+		}
+	}
+	return fake
+}
+
+func TestBucketSummary(t *testing.T) {
+	fake := newSummaryFake(t)
+	cfg, done := newFakeEndpoint(t, fake)
+	defer done()
+	cfg.Performance.ListPageSize = 4
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	_, err := s.Svc.CreateMultipartUpload(&s3.CreateMultipartUploadInput{Bucket: aws.String("bucket"), Key: aws.String("dir1/big")})
+	assert.NoError(t, err)
+	_, err = s.Svc.CreateMultipartUpload(&s3.CreateMultipartUploadInput{Bucket: aws.String("bucket"), Key: aws.String("other/big")})
+	assert.NoError(t, err)
+
+	summary, err := s.BucketSummary(context.Background(), "bucket", "")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(30), summary.Objects)
+	assert.Equal(t, int64(465), summary.Bytes)
+	assert.Equal(t, int64(15), summary.AverageSize)
+	assert.Equal(t, map[string]ClassTotal{
+		"STANDARD": {Objects: 20, Bytes: 320},
+		"GLACIER":  {Objects: 10, Bytes: 145},
+	}, summary.StorageClasses)
+	if assert.Len(t, summary.Largest, summaryLargest) {
+		assert.Equal(t, SummaryObject{Key: "dir1/sub/29", Size: 30, LastModified: time.Date(2020, 1, 2, 5, 0, 0, 0, time.UTC)}, summary.Largest[0])
+		assert.Equal(t, "top-20", summary.Largest[9].Key)
+	}
+	assert.Equal(t, "top-00", summary.Oldest.Key)
+	assert.Equal(t, "dir1/sub/29", summary.Newest.Key)
+	assert.Equal(t, int64(2), summary.IncompleteUploads)
+	assert.Equal(t, int64(30), summary.Histogram.Objects)
+	// the prefixes are listed separately
+	assert.Equal(t, 1+4*2, fake.count("LIST"))
+
+	summary, err = s.BucketSummary(context.Background(), "bucket", "dir1/")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(8), summary.Objects)
+	assert.Equal(t, int64(1), summary.IncompleteUploads)
+}
+
+func TestBucketSummaryCached(t *testing.T) {
+	fake := newSummaryFake(t)
+	cfg, done := newFakeEndpoint(t, fake)
+	defer done()
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+
+	first, err := s.BucketSummary(context.Background(), "bucket", "")
+	assert.NoError(t, err)
+	lists := fake.count("LIST")
+	fake.put("bucket", "new", "new")
+	second, err := s.BucketSummary(context.Background(), "bucket", "")
+	assert.NoError(t, err)
+	assert.Equal(t, lists, fake.count("LIST"))
+	assert.Equal(t, first.Objects, second.Objects)
+
+	cache := loadBucketSummaryCache(defaultBucketSummaryPath())
+	_, ok := cache.get("bucket", "", time.Now().Add(bucketSummaryTTL))
+	assert.False(t, ok)
+}
+
+func TestStatsOutput(t *testing.T) {
+	fake := newSummaryFake(t)
+	cfg, done := newFakeEndpoint(t, fake)
+	defer done()
+
+	var out bytes.Buffer
+	assert.NoError(t, Stats(context.Background(), cfg, "s3://bucket/dir2/", false, "", &out))
+	assert.Contains(t, out.String(), "Objects: 6, size: 94 B, average size: 15 B")
+	assert.Contains(t, out.String(), "Incomplete multipart uploads: 0")
+	assert.Contains(t, out.String(), "Oldest: 2020-01-01T02:00:00Z dir2/sub/02")
+	assert.Contains(t, out.String(), "<1KB")
+
+	out.Reset()
+	assert.NoError(t, Stats(context.Background(), cfg, "s3://bucket/dir2/", false, "json", &out))
+	var summary BucketSummary
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &summary))
+	assert.Equal(t, int64(6), summary.Objects)
+	assert.Equal(t, "dir2/", summary.Prefix)
+
+	assert.Error(t, Stats(context.Background(), cfg, "s3://bucket", false, "xml", &out))
+}
@@ -0,0 +1,94 @@
+package s3ry
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// checkpointEvery processed objects between checkpoints within a listing page
+const checkpointEvery = 100
+
+// Checkpoint progress of a job listing objects, enough to resume it
+type Checkpoint struct {
+	// ContinuationToken ListObjectsV2 token of the page being processed, empty for the first page
+	ContinuationToken string
+	// Processed keys of that page which are done
+	Processed map[string]bool
+}
+
+// CheckpointStore persist Checkpoints by job id
+type CheckpointStore interface {
+	// Load return checkpoint of job, nil when there is none
+	Load(job string) (*Checkpoint, error)
+	Save(job string, c *Checkpoint) error
+	// Delete forget checkpoint of a finished job
+	Delete(job string) error
+}
+
+// FileCheckpointStore CheckpointStore keeping a JSON file per job in Dir
+type FileCheckpointStore struct {
+	Dir string
+}
+
+// defaultCheckpointDir return directory of checkpoints next to the config file
+func defaultCheckpointDir() string {
+	return configDirFile("checkpoints")
+}
+
+// path return file of job, named by its hash since job ids contain URIs
+func (f FileCheckpointStore) path(job string) string {
+	sum := sha1.Sum([]byte(job))
+	return filepath.Join(f.Dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// Load read checkpoint of job
+func (f FileCheckpointStore) Load(job string) (*Checkpoint, error) {
+	b, err := ioutil.ReadFile(f.path(job))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &Checkpoint{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Save write checkpoint of job, replacing the file so a crash never leaves half of it
+func (f FileCheckpointStore) Save(job string, c *Checkpoint) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(f.Dir, 0700); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(f.Dir, "checkpoint-*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(b)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path(job))
+}
+
+// Delete remove checkpoint of job
+func (f FileCheckpointStore) Delete(job string) error {
+	if err := os.Remove(f.path(job)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package s3ry
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryCheckpointStore CheckpointStore in memory, copying checkpoints like a file would
+type memoryCheckpointStore struct {
+	mu          sync.Mutex
+	checkpoints map[string]Checkpoint
+	saves       int
+}
+
+func (m *memoryCheckpointStore) Load(job string) (*Checkpoint, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.checkpoints[job]
+	if !ok {
+		return nil, nil
+	}
+	return &c, nil
+}
+
+func (m *memoryCheckpointStore) Save(job string, c *Checkpoint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	saved := Checkpoint{ContinuationToken: c.ContinuationToken, Processed: map[string]bool{}}
+	for key := range c.Processed {
+		saved.Processed[key] = true
+	}
+	m.checkpoints[job] = saved
+	m.saves++
+	return nil
+}
+
+func (m *memoryCheckpointStore) Delete(job string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.checkpoints, job)
+	return nil
+}
+
+func TestCopyPrefixResumesFromCheckpoint(t *testing.T) {
+	fake := newFakeS3("src", "dst")
+	for i := 0; i < 25; i++ {
+		fake.put("src", fmt.Sprintf("p/%02d", i), "x")
+	}
+	ctx, crash := context.WithCancel(context.Background())
+	defer crash()
+	var mu sync.Mutex
+	var copies int
+	var tokens []string
+	fake.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2" {
+			tokens = append(tokens, r.URL.Query().Get("continuation-token"))
+		}
+		if r.Header.Get("X-Amz-Copy-Source") == "" {
+			return false
+		}
+		copies++
+		if copies == 16 {
+			// crash in the middle of the second page
+			crash()
+			w.WriteHeader(http.StatusBadRequest)
+			return true
+		}
+		return false
+	}
+	cfg := DefaultConfig()
+	cfg.Performance.Workers = 1
+	cfg.Performance.ListPageSize = 10
+	s, srv := newTestS3ry(cfg, fake)
+	defer srv.Close()
+	store := &memoryCheckpointStore{checkpoints: map[string]Checkpoint{}}
+	s.Checkpoints = store
+
+	summary, err := s.CopyPrefix(ctx, "src", "p/", "dst", "q/")
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 15, summary.Copied)
+	checkpoint, _ := store.Load("cp s3://src/p/ s3://dst/q/")
+	if assert.NotNil(t, checkpoint) {
+		assert.Equal(t, "p/09", checkpoint.ContinuationToken)
+		assert.Len(t, checkpoint.Processed, 5)
+	}
+
+	mu.Lock()
+	copies, tokens = 0, nil
+	mu.Unlock()
+	summary, err = s.CopyPrefix(context.Background(), "src", "p/", "dst", "q/")
+	assert.NoError(t, err)
+	assert.Equal(t, 10, summary.Copied)
+	assert.Equal(t, 5, summary.Skipped)
+	assert.Empty(t, summary.Failed)
+	assert.Equal(t, 10, copies)
+	assert.Equal(t, []string{"p/09", "p/19"}, tokens)
+	assert.Len(t, fake.keys("dst"), 25)
+	assert.Empty(t, store.checkpoints)
+}
+
+func TestFileCheckpointStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "s3ry")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	store := FileCheckpointStore{Dir: dir}
+
+	c, err := store.Load("job")
+	assert.NoError(t, err)
+	assert.Nil(t, c)
+
+	saved := &Checkpoint{ContinuationToken: "token", Processed: map[string]bool{"a": true}}
+	assert.NoError(t, store.Save("job", saved))
+	c, err = store.Load("job")
+	assert.NoError(t, err)
+	assert.Equal(t, saved, c)
+	c, _ = store.Load("other job")
+	assert.Nil(t, c)
+
+	assert.NoError(t, store.Delete("job"))
+	assert.NoError(t, store.Delete("job"))
+	c, _ = store.Load("job")
+	assert.Nil(t, c)
+}
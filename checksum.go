@@ -0,0 +1,165 @@
+package s3ry
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Checksum algorithms of uploads
+const (
+	ChecksumCRC32  = "CRC32"
+	ChecksumCRC32C = "CRC32C"
+	ChecksumSHA1   = "SHA1"
+	ChecksumSHA256 = "SHA256"
+	// ChecksumNone disable checksums, for storage which rejects them
+	ChecksumNone = "NONE"
+)
+
+// ErrCodeChecksumMismatch error code of an upload whose checksum S3 reported differently
+const ErrCodeChecksumMismatch = "ChecksumMismatch"
+
+// defaultChecksumAlgorithm checksum of uploads without ChecksumAlgorithm, CRC32C is the fastest to compute
+const defaultChecksumAlgorithm = ChecksumCRC32C
+
+// newChecksum return hash of algorithm, nil for ChecksumNone
+func newChecksum(algorithm string) (hash.Hash, error) {
+	switch strings.ToUpper(algorithm) {
+	case "":
+		return newChecksum(defaultChecksumAlgorithm)
+	case ChecksumCRC32:
+		return crc32.NewIEEE(), nil
+	case ChecksumCRC32C:
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli)), nil
+	case ChecksumSHA1:
+		return sha1.New(), nil
+	case ChecksumSHA256:
+		return sha256.New(), nil
+	case ChecksumNone:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unknown checksum algorithm %q, use CRC32, CRC32C, SHA1, SHA256 or NONE", algorithm)
+}
+
+// checksumHeader header carrying the checksum of algorithm, e.g. X-Amz-Checksum-Crc32c
+func checksumHeader(algorithm string) string {
+	if algorithm == "" {
+		algorithm = defaultChecksumAlgorithm
+	}
+	return "X-Amz-Checksum-" + strings.ToLower(algorithm)
+}
+
+// checksumHandlers add the ChecksumAlgorithm of the bucket to PutObject bodies and verify the checksum S3 returns
+//
+// S3 rejects a body that doesn't match the checksum header. Parts of multipart
+// uploads are sent without one: S3 rejects a part checksum unless
+// CreateMultipartUpload declared its algorithm, which the SDK can't send,
+// so parts are only checked by their ETag.
+func checksumHandlers(cfg *Config) (request.NamedHandler, request.NamedHandler) {
+	algorithm := func(r *request.Request) (string, bool) {
+		p, ok := r.Params.(*s3.PutObjectInput)
+		if !ok {
+			return "", false
+		}
+		bucket := aws.StringValue(p.Bucket)
+		a := strings.ToUpper(cfg.bucketConfig(bucket).ChecksumAlgorithm)
+		return a, a != ChecksumNone
+	}
+	add := request.NamedHandler{
+		Name: "s3ry.ChecksumHandler",
+		Fn: func(r *request.Request) {
+			a, ok := algorithm(r)
+			if !ok {
+				return
+			}
+			h, err := newChecksum(a)
+			if err != nil {
+				r.Error = err
+				return
+			}
+			body := r.GetBody()
+			start, err := body.Seek(0, io.SeekCurrent)
+			if err != nil {
+				r.Error = err
+				return
+			}
+			if _, err := io.Copy(h, body); err != nil {
+				r.Error = err
+				return
+			}
+			if _, err := body.Seek(start, io.SeekStart); err != nil {
+				r.Error = err
+				return
+			}
+			r.HTTPRequest.Header.Set(checksumHeader(a), base64.StdEncoding.EncodeToString(h.Sum(nil)))
+		},
+	}
+	verify := request.NamedHandler{
+		Name: "s3ry.ChecksumVerifyHandler",
+		Fn: func(r *request.Request) {
+			a, ok := algorithm(r)
+			if !ok || r.Error != nil {
+				return
+			}
+			header := checksumHeader(a)
+			sent, got := r.HTTPRequest.Header.Get(header), r.HTTPResponse.Header.Get(header)
+			// storage without checksum support doesn't return it
+			if got != "" && got != sent {
+				r.Error = awserr.New(ErrCodeChecksumMismatch, fmt.Sprintf("%s checksum sent %s, stored %s", a, sent, got), nil)
+			}
+		},
+	}
+	return add, verify
+}
+
+// contentMD5Handlers check PutObject requests of buckets with ContentMD5 against the Content-MD5 header the SDK sends
+// with every seekable body, turning a mismatch into ErrCodeChecksumMismatch: S3 rejecting the body as BadDigest,
+// or storage ignoring the header and storing the body with another ETag
+// the BadDigest one runs after the retry decision, once the SDK has unmarshalled the error
+func contentMD5Handlers(cfg *Config) (request.NamedHandler, request.NamedHandler) {
+	enabled := func(r *request.Request) bool {
+		p, ok := r.Params.(*s3.PutObjectInput)
+		return ok && cfg.bucketConfig(aws.StringValue(p.Bucket)).ContentMD5
+	}
+	rejected := request.NamedHandler{
+		Name: "s3ry.ContentMD5RejectedHandler",
+		Fn: func(r *request.Request) {
+			if aerr, ok := r.Error.(awserr.Error); ok && enabled(r) && aerr.Code() == "BadDigest" {
+				r.Error = awserr.New(ErrCodeChecksumMismatch, fmt.Sprintf("Content-MD5 sent %s doesn't match the body S3 received",
+					r.HTTPRequest.Header.Get("Content-Md5")), r.Error)
+			}
+		},
+	}
+	verify := request.NamedHandler{
+		Name: "s3ry.ContentMD5VerifyHandler",
+		Fn: func(r *request.Request) {
+			if !enabled(r) || r.Error != nil {
+				return
+			}
+			// the response is read from the headers, the handler runs before the SDK unmarshals them
+			h := r.HTTPResponse.Header
+			etag := strings.Trim(h.Get("ETag"), `"`)
+			// objects encrypted with KMS or a customer key have an ETag which isn't their MD5
+			if h.Get("X-Amz-Server-Side-Encryption-Customer-Algorithm") != "" || h.Get("X-Amz-Server-Side-Encryption") == s3.ServerSideEncryptionAwsKms || len(etag) != md5.Size*2 {
+				return
+			}
+			sent, err := base64.StdEncoding.DecodeString(r.HTTPRequest.Header.Get("Content-Md5"))
+			if err == nil && len(sent) > 0 && hex.EncodeToString(sent) != etag {
+				r.Error = awserr.New(ErrCodeChecksumMismatch, fmt.Sprintf("MD5 sent %x, stored %s", sent, etag), nil)
+			}
+		},
+	}
+	return rejected, verify
+}
@@ -0,0 +1,151 @@
+package s3ry
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecksumHeaderPerAlgorithm(t *testing.T) {
+	// checksums of "hello" as S3 documents them, base64 of the big-endian digest
+	expected := map[string]string{
+		ChecksumCRC32:  "NhCmhg==",
+		ChecksumCRC32C: "mnG7TA==",
+		ChecksumSHA1:   "qvTGHdzF6KLavt4PO0gs2a6pQ00=",
+		ChecksumSHA256: "LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ=",
+		"":             "mnG7TA==",
+	}
+	for algorithm, sum := range expected {
+		fake := newFakeS3("bucket")
+		var got http.Header
+		fake.hook = func(w http.ResponseWriter, r *http.Request) bool {
+			if r.Method == http.MethodPut {
+				got = r.Header.Clone()
+			}
+			return false
+		}
+		cfg := DefaultConfig()
+		cfg.Flags.ChecksumAlgorithm = algorithm
+		s, srv := newTestS3ry(cfg, fake)
+		_, err := s.PutStream(context.Background(), "bucket", "key", strings.NewReader("hello"))
+		srv.Close()
+		assert.NoError(t, err, algorithm)
+		assert.Equal(t, sum, got.Get(checksumHeader(algorithm)), algorithm)
+	}
+}
+
+func TestChecksumMultipartUpload(t *testing.T) {
+	fake := newFakeS3("bucket")
+	var mu sync.Mutex
+	parts := 0
+	fake.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.URL.Query().Get("partNumber") != "" {
+			mu.Lock()
+			parts++
+			mu.Unlock()
+		}
+		return false
+	}
+	// the default algorithm, parts are sent without a checksum S3 rejects for undeclared algorithms
+	s, srv := newTestS3ry(DefaultConfig(), fake)
+	defer srv.Close()
+	_, err := s.PutStream(context.Background(), "bucket", "big", bytes.NewReader(make([]byte, 6*1024*1024)))
+	assert.NoError(t, err)
+
+	f, err := ioutil.TempFile("", "s3ry")
+	assert.NoError(t, err)
+	f.Write(make([]byte, 6*1024*1024))
+	f.Close()
+	defer os.Remove(f.Name())
+	assert.NoError(t, s.UploadObject("bucket", f.Name()))
+	assert.Equal(t, 4, parts)
+}
+
+func TestChecksumVerifiesResponse(t *testing.T) {
+	fake := newFakeS3("bucket")
+	fake.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method == http.MethodPut {
+			w.Header().Set("X-Amz-Checksum-Crc32c", "AAAAAA==")
+			w.WriteHeader(http.StatusOK)
+			return true
+		}
+		return false
+	}
+	s, srv := newTestS3ry(DefaultConfig(), fake)
+	defer srv.Close()
+	_, err := s.PutStream(context.Background(), "bucket", "key", strings.NewReader("hello"))
+	if aerr, ok := err.(awserr.Error); assert.True(t, ok, "%v", err) {
+		assert.Contains(t, aerr.Error(), ErrCodeChecksumMismatch)
+	}
+
+	s.Config.Flags.ChecksumAlgorithm = "MD4"
+	_, err = s.PutStream(context.Background(), "bucket", "key", strings.NewReader("hello"))
+	assert.Error(t, err)
+
+	s.Config.Flags.ChecksumAlgorithm = ChecksumNone
+	fake.hook = nil
+	_, err = s.PutStream(context.Background(), "bucket", "key", strings.NewReader("hello"))
+	assert.NoError(t, err)
+}
+
+func TestContentMD5Header(t *testing.T) {
+	fake := newFakeS3("bucket")
+	var sent string
+	fake.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method == http.MethodPut {
+			sent = r.Header.Get("Content-Md5")
+		}
+		return false
+	}
+	cfg := DefaultConfig()
+	cfg.Flags.ContentMD5 = true
+	s, srv := newTestS3ry(cfg, fake)
+	defer srv.Close()
+
+	_, err := s.PutStream(context.Background(), "bucket", "key", strings.NewReader("hello"))
+	assert.NoError(t, err)
+	// base64 of the MD5 of "hello", which the ETag of the fake matches
+	assert.Equal(t, "XUFAKrxLKna5cZ2REBfFkg==", sent)
+}
+
+func TestContentMD5Mismatch(t *testing.T) {
+	fake := newFakeS3("bucket")
+	fake.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method == http.MethodPut && r.URL.Path == "/bucket/rejected" {
+			writeFakeError(w, http.StatusBadRequest, "BadDigest")
+			return true
+		}
+		if r.Method == http.MethodPut && r.URL.Path == "/bucket/changed" {
+			w.Header().Set("ETag", `"00000000000000000000000000000000"`)
+			return true
+		}
+		return false
+	}
+	cfg := DefaultConfig()
+	cfg.Flags.ContentMD5 = true
+	s, srv := newTestS3ry(cfg, fake)
+	defer srv.Close()
+
+	for _, key := range []string{"rejected", "changed"} {
+		_, err := s.PutStream(context.Background(), "bucket", key, strings.NewReader("hello"))
+		if aerr, ok := err.(awserr.Error); assert.True(t, ok, "%v", err) {
+			assert.Contains(t, aerr.Error(), ErrCodeChecksumMismatch, key)
+			assert.Contains(t, aerr.Error(), "MD5", key)
+		}
+	}
+	// BadDigest isn't sent again
+	assert.Equal(t, 0, fake.count("PUT"))
+
+	// without ContentMD5 a different ETag is left to the checksum
+	cfg.Flags.ContentMD5 = false
+	_, err := s.PutStream(context.Background(), "bucket", "changed", strings.NewReader("hello"))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,259 @@
+package s3ry
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// partialPattern name pattern of download temp files
+const partialPattern = "s3ry-*.partial"
+
+// pendingUpload multipart upload started by s3ry
+type pendingUpload struct {
+	Bucket   string
+	Key      string
+	UploadID string
+	Started  time.Time
+}
+
+// uploadJournal multipart uploads started by s3ry and not yet completed or aborted
+//
+// ListMultipartUploads doesn't return object metadata, so uploads can't be
+// tagged as s3ry's own; they are recorded here instead, a JSON file per upload
+// in dir so processes running at the same time don't overwrite each other's
+// uploads. An empty dir keeps the journal in memory only.
+type uploadJournal struct {
+	mu      sync.Mutex
+	dir     string
+	uploads map[string]pendingUpload
+}
+
+// defaultJournalDir return directory of the journal next to the config file
+func defaultJournalDir() string {
+	return configDirFile("uploads")
+}
+
+// newUploadJournal create journal in dir
+func newUploadJournal(dir string) *uploadJournal {
+	return &uploadJournal{dir: dir, uploads: map[string]pendingUpload{}}
+}
+
+// path return file of upload, named by its hash like the checkpoints
+func (j *uploadJournal) path(uploadID string) string {
+	sum := sha1.Sum([]byte(uploadID))
+	return filepath.Join(j.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// add record upload
+// the journal is best effort, a failed write only loses a cleanup candidate
+func (j *uploadJournal) add(u pendingUpload) {
+	if j.dir == "" {
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		j.uploads[u.UploadID] = u
+		return
+	}
+	b, err := json.Marshal(u)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(j.dir, 0700); err != nil {
+		return
+	}
+	// replace the file so list never reads half of it
+	tmp, err := ioutil.TempFile(j.dir, "upload-*.tmp")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(b)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), j.path(u.UploadID))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+// remove forget upload
+func (j *uploadJournal) remove(uploadID string) {
+	if j.dir == "" {
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		delete(j.uploads, uploadID)
+		return
+	}
+	os.Remove(j.path(uploadID))
+}
+
+// list return recorded uploads, of every process
+func (j *uploadJournal) list() []pendingUpload {
+	var uploads []pendingUpload
+	if j.dir == "" {
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		for _, u := range j.uploads {
+			uploads = append(uploads, u)
+		}
+		return uploads
+	}
+	files, _ := filepath.Glob(filepath.Join(j.dir, "*.json"))
+	for _, file := range files {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var u pendingUpload
+		if err := json.Unmarshal(b, &u); err == nil {
+			uploads = append(uploads, u)
+		}
+	}
+	return uploads
+}
+
+// handler record multipart uploads of every client created from the session
+func (j *uploadJournal) handler() request.NamedHandler {
+	return request.NamedHandler{
+		Name: "s3ry.UploadJournalHandler",
+		Fn: func(r *request.Request) {
+			if r.Error != nil {
+				return
+			}
+			switch r.Operation.Name {
+			case "CreateMultipartUpload":
+				out := r.Data.(*s3.CreateMultipartUploadOutput)
+				j.add(pendingUpload{
+					Bucket:   aws.StringValue(out.Bucket),
+					Key:      aws.StringValue(out.Key),
+					UploadID: aws.StringValue(out.UploadId),
+					Started:  time.Now(),
+				})
+			case "CompleteMultipartUpload":
+				j.remove(aws.StringValue(r.Params.(*s3.CompleteMultipartUploadInput).UploadId))
+			case "AbortMultipartUpload":
+				j.remove(aws.StringValue(r.Params.(*s3.AbortMultipartUploadInput).UploadId))
+			}
+		},
+	}
+}
+
+// CleanupSummary result of CleanupInterruptedTransfers
+type CleanupSummary struct {
+	AbortedUploads  int
+	RemovedPartials int
+}
+
+// CleanupInterruptedTransfers abort multipart uploads s3ry started and never finished,
+// and remove partial download files, when they are older than Cleanup.StaleAfter
+func (s S3ry) CleanupInterruptedTransfers() (CleanupSummary, error) {
+	summary := CleanupSummary{}
+	staleBefore := time.Now().Add(-time.Duration(s.config().Cleanup.StaleAfter))
+
+	for _, u := range s.journal.list() {
+		if u.Started.After(staleBefore) {
+			continue
+		}
+		c, err := s.forBucket(u.Bucket)
+		if err != nil {
+			return summary, err
+		}
+		_, err = c.Svc.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(u.Bucket),
+			Key:      aws.String(u.Key),
+			UploadId: aws.String(u.UploadID),
+		})
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchUpload {
+			// already completed or aborted elsewhere
+			s.journal.remove(u.UploadID)
+			continue
+		}
+		if err != nil {
+			return summary, err
+		}
+		summary.AbortedUploads++
+	}
+
+	partials, err := filepath.Glob(filepath.Join(s.config().tempDir(), partialPattern))
+	if err != nil {
+		return summary, err
+	}
+	for _, partial := range partials {
+		info, err := os.Stat(partial)
+		if err != nil || info.ModTime().After(staleBefore) {
+			continue
+		}
+		if err := os.Remove(partial); err != nil {
+			return summary, err
+		}
+		summary.RemovedPartials++
+	}
+	return summary, nil
+}
+
+// createPartial create temp file for downloading name
+func (c *Config) createPartial(name string) (*os.File, error) {
+	dir := c.tempDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return ioutil.TempFile(dir, "s3ry-"+filepath.Base(name)+"-*.partial")
+}
+
+// tempDir return directory for transient files
+func (c *Config) tempDir() string {
+	if c.Performance.TempDir != "" {
+		return c.Performance.TempDir
+	}
+	return os.TempDir()
+}
+
+// commitPartial move completed partial file to filename
+func commitPartial(partial string, filename string) error {
+	if err := os.Rename(partial, filename); err == nil {
+		return nil
+	}
+	// the temp dir may be on another device
+	src, err := os.Open(partial)
+	if err != nil {
+		return err
+	}
+	dst, err := os.Create(filename)
+	if err != nil {
+		src.Close()
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	src.Close()
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Remove(partial)
+}
+
+// Cleanup clean up interrupted transfers and print the result to w, used on start and by the cleanup command
+func Cleanup(cfg *Config, w io.Writer) error {
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	summary, err := s.CleanupInterruptedTransfers()
+	if summary.AbortedUploads > 0 || summary.RemovedPartials > 0 {
+		fmt.Fprintln(w, i18nPrinter.Sprintf("Cleaned up interrupted transfers: %d uploads aborted, %d partial files removed", summary.AbortedUploads, summary.RemovedPartials))
+	}
+	return err
+}
@@ -0,0 +1,109 @@
+package s3ry
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUploadJournalRecordsMultipartUploads(t *testing.T) {
+	fake := newFakeS3("bucket")
+	s, srv := newTestS3ry(DefaultConfig(), fake)
+	defer srv.Close()
+
+	upload, err := s.Svc.CreateMultipartUpload(&s3.CreateMultipartUploadInput{Bucket: aws.String("bucket"), Key: aws.String("key")})
+	assert.NoError(t, err)
+	if assert.Len(t, s.journal.list(), 1) {
+		assert.Equal(t, aws.StringValue(upload.UploadId), s.journal.list()[0].UploadID)
+	}
+	_, err = s.Svc.CompleteMultipartUpload(&s3.CompleteMultipartUploadInput{Bucket: aws.String("bucket"), Key: aws.String("key"), UploadId: upload.UploadId})
+	assert.NoError(t, err)
+	assert.Empty(t, s.journal.list())
+}
+
+func TestCleanupInterruptedTransfers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "s3ry")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	fake := newFakeS3("bucket")
+	cfg := DefaultConfig()
+	cfg.Performance.TempDir = dir
+	cfg.Cleanup.StaleAfter = Duration(time.Hour)
+	s, srv := newTestS3ry(cfg, fake)
+	defer srv.Close()
+
+	stale := time.Now().Add(-2 * time.Hour)
+	s.journal.add(pendingUpload{Bucket: "bucket", Key: "old", UploadID: "old-upload", Started: stale})
+	s.journal.add(pendingUpload{Bucket: "bucket", Key: "new", UploadID: "new-upload", Started: time.Now()})
+	oldPartial := filepath.Join(dir, "s3ry-old-1.partial")
+	newPartial := filepath.Join(dir, "s3ry-new-2.partial")
+	other := filepath.Join(dir, "other.txt")
+	for _, path := range []string{oldPartial, newPartial, other} {
+		assert.NoError(t, ioutil.WriteFile(path, []byte("data"), 0600))
+	}
+	assert.NoError(t, os.Chtimes(oldPartial, stale, stale))
+	assert.NoError(t, os.Chtimes(other, stale, stale))
+
+	summary, err := s.CleanupInterruptedTransfers()
+	assert.NoError(t, err)
+	assert.Equal(t, CleanupSummary{AbortedUploads: 1, RemovedPartials: 1}, summary)
+	assert.Equal(t, 1, fake.count("ABORT_MULTIPART"))
+	if assert.Len(t, s.journal.list(), 1) {
+		assert.Equal(t, "new-upload", s.journal.list()[0].UploadID)
+	}
+	_, err = os.Stat(oldPartial)
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(newPartial)
+	assert.NoError(t, err)
+	_, err = os.Stat(other)
+	assert.NoError(t, err)
+}
+
+func TestUploadJournalPersists(t *testing.T) {
+	dir, err := ioutil.TempDir("", "s3ry")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "s3ry", "uploads")
+	j := newUploadJournal(path)
+	j.add(pendingUpload{Bucket: "bucket", Key: "key", UploadID: "id"})
+	assert.Len(t, newUploadJournal(path).list(), 1)
+	j.remove("id")
+	assert.Empty(t, newUploadJournal(path).list())
+}
+
+func TestUploadJournalsOfProcessesKeepEachOthersUploads(t *testing.T) {
+	dir, err := ioutil.TempDir("", "s3ry")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// journals of two processes started before either recorded an upload
+	a, b := newUploadJournal(dir), newUploadJournal(dir)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			a.add(pendingUpload{Bucket: "bucket", Key: "a", UploadID: fmt.Sprintf("a/%d", i)})
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			b.add(pendingUpload{Bucket: "bucket", Key: "b", UploadID: fmt.Sprintf("b+%d", i)})
+		}(i)
+	}
+	wg.Wait()
+	assert.Len(t, newUploadJournal(dir).list(), 20)
+	a.remove("a/0")
+	b.remove("a/1")
+	assert.Len(t, a.list(), 18)
+	assert.Len(t, b.list(), 18)
+}
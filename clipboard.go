@@ -0,0 +1,70 @@
+package s3ry
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// ErrNoClipboard no clipboard command was found
+var ErrNoClipboard = errors.New("no clipboard command found")
+
+// clipboardCommands commands writing stdin to the clipboard by GOOS, the first one found is used
+var clipboardCommands = map[string][][]string{
+	"darwin":  {{"pbcopy"}},
+	"windows": {{"clip"}},
+	"linux": {
+		{"wl-copy"},
+		{"xclip", "-selection", "clipboard"},
+		{"xsel", "--clipboard", "--input"},
+	},
+}
+
+// copyToClipboard write text to the clipboard, replaced in tests
+var copyToClipboard = func(text string) error {
+	for _, command := range clipboardCommands[runtime.GOOS] {
+		if _, err := exec.LookPath(command[0]); err != nil {
+			continue
+		}
+		cmd := exec.Command(command[0], command[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		return cmd.Run()
+	}
+	return ErrNoClipboard
+}
+
+// printObjectURIs print s3:// URI and HTTPS URLs of object and copy the URI to the clipboard
+// the URI is only printed when the clipboard is unavailable, e.g. over SSH
+func (s S3ry) printObjectURIs(bucket string, key string, w io.Writer) {
+	region, err := s.BucketRegion(bucket)
+	if err != nil {
+		region = s.config().DefaultRegion()
+	}
+	uri := ObjectURI(bucket, key)
+	fmt.Fprintln(w, uri)
+	fmt.Fprintln(w, s.config().ObjectURL(bucket, key, region, true))
+	fmt.Fprintln(w, s.config().ObjectURL(bucket, key, region, false))
+	if err := copyToClipboard(uri); err != nil {
+		fmt.Fprintln(w, i18nPrinter.Sprintf("Clipboard is unavailable (%s), copy the URI above", err.Error()))
+		return
+	}
+	fmt.Fprintln(w, i18nPrinter.Sprintf("Copied to clipboard,% s", uri))
+}
+
+// URL print HTTPS URL of s3:// URI target, virtual-hosted unless pathStyle, used by the url command
+func URL(cfg *Config, target string, pathStyle bool, w io.Writer) error {
+	bucket, key, err := ParseS3URI(target)
+	if err != nil {
+		return err
+	}
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	region, err := s.BucketRegion(bucket)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, cfg.ObjectURL(bucket, key, region, !pathStyle))
+	return nil
+}
@@ -1,10 +1,868 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/seike460/s3ry"
 )
 
 func main() {
-	region, selectBucket := s3ry.SelectBucketAndRegion()
-	s3ry.Operations(region, selectBucket)
+	defaultRegion := flag.String("region", "", "region used before a bucket is selected (default AWS_REGION, AWS.Region in the config, the profile region or AWS.FallbackRegion)")
+	readOnly := flag.Bool("read-only", false, "disable operations which modify S3")
+	strictConfig := flag.Bool("strict-config", false, "refuse to start on any config error")
+	sse := flag.String("sse", "", "server-side encryption for uploads: SSE-S3, SSE-KMS or SSE-C")
+	sseKMSKeyID := flag.String("sse-kms-key-id", "", "KMS key id for SSE-KMS")
+	sseCKey := flag.String("sse-c-key", "", "base64 encoded 256-bit key for SSE-C")
+	storageClass := flag.String("storage-class", "", "storage class for uploads, e.g. STANDARD_IA")
+	acl := flag.String("acl", "", "canned ACL for uploads, e.g. bucket-owner-full-control")
+	contentType := flag.String("content-type", "", "content type for uploads, detected from each file by default")
+	checksumAlgorithm := flag.String("checksum-algorithm", "", "checksum S3 verifies uploads with: CRC32, CRC32C, SHA1, SHA256 or NONE (default CRC32C)")
+	contentMD5 := flag.Bool("content-md5", false, "check uploads sent in one request were stored with their Content-MD5")
+	preflight := flag.Bool("preflight", false, "check the permissions of bulk operations before they start")
+	yes := flag.Bool("yes", false, "don't ask before deleting or overwriting, for scripts")
+	quiet := flag.Bool("quiet", false, "show no progress, only the final summary, same as --progress=none")
+	progress := flag.String("progress", "", "how progress is shown: bar, plain or none (default Progress.Style in the config)")
+	summary := flag.String("summary", "", "summary of what cp --recursive and put of several files changed: text or json (default Summary.Format in the config)")
+	summaryFile := flag.String("summary-file", "", "also write the summary as JSON to this file")
+	notifyAfter := flag.Duration("notify-after", 0, "notify when a command ran at least this long, e.g. 30s (default Notify.After in the config)")
+	timeout := flag.Duration("timeout", 0, "abort the command after this long, e.g. 10m (default Timeouts in the config)")
+	flag.Parse()
+
+	// config errors may quote secrets, so redact before loading it
+	setupLogging(nil)
+	cfg, err := s3ry.LoadConfig(s3ry.DefaultConfigPath())
+	if err != nil {
+		if _, ok := err.(s3ry.ConfigErrors); !ok || *strictConfig {
+			s3ry.Exit(err)
+		}
+		// invalid values keep their defaults
+		log.Println(err.Error())
+	}
+	setupLogging(cfg.Logging.RedactPatterns)
+	cfg.AWS.RegionFlag = *defaultRegion
+	if *readOnly {
+		cfg.Security.ReadOnly = true
+	}
+	if *preflight {
+		cfg.Security.Preflight = true
+	}
+	cfg.Security.AssumeYes = *yes
+	if *progress != "" {
+		cfg.Progress.Style = *progress
+	}
+	if *quiet {
+		cfg.Progress.Style = s3ry.ProgressNone
+	}
+	if err := s3ry.SetProgress(cfg.Progress); err != nil {
+		usage(err.Error())
+	}
+	if err := s3ry.SetLocale(cfg.Locale, cfg.SizeUnits); err != nil {
+		usage(err.Error())
+	}
+	if *summary != "" {
+		if *summary != "text" && *summary != "json" {
+			usage("--summary text|json")
+		}
+		cfg.Summary.Format = *summary
+	}
+	if *summaryFile != "" {
+		cfg.Summary.File = *summaryFile
+	}
+	// flags override the per-bucket defaults
+	cfg.Flags = s3ry.BucketConfig{
+		StorageClass:      *storageClass,
+		ACL:               *acl,
+		ContentType:       *contentType,
+		ChecksumAlgorithm: *checksumAlgorithm,
+		ContentMD5:        *contentMD5,
+		Encryption: s3ry.EncryptionConfig{
+			Mode:        *sse,
+			KMSKeyID:    *sseKMSKeyID,
+			CustomerKey: *sseCKey,
+		},
+	}
+
+	if *notifyAfter > 0 {
+		cfg.Notify.After = s3ry.Duration(*notifyAfter)
+	}
+
+	if *timeout > 0 {
+		// the interactive mode waits on its prompts, only commands have a time limit
+		if flag.NArg() == 0 {
+			log.Println("--timeout needs a command, e.g. s3ry --timeout 10m cp s3://bucket/a s3://bucket/b")
+			os.Exit(s3ry.ExitUsage)
+		}
+		if cfg.Timeouts == nil {
+			cfg.Timeouts = map[string]s3ry.Duration{}
+		}
+		cfg.Timeouts[flag.Arg(0)] = s3ry.Duration(*timeout)
+	}
+
+	// init fixes the config, every other command builds clients of it
+	if flag.Arg(0) != "init" {
+		if err := s3ry.CheckConfig(cfg); err != nil {
+			s3ry.Exit(err)
+		}
+	}
+
+	// cleanup builds clients, which init's unchecked config may not allow
+	if cfg.Cleanup.OnStart && flag.Arg(0) != "cleanup" && flag.Arg(0) != "init" {
+		// stdout may be the object of get -
+		if err := s3ry.Cleanup(cfg, os.Stderr); err != nil {
+			log.Println(err.Error())
+		}
+	}
+
+	switch flag.Arg(0) {
+	case "init":
+		if err := s3ry.NewInitWizard(os.Stdin, os.Stdout).Run(); err != nil {
+			s3ry.Exit(err)
+		}
+		return
+	case "login":
+		runLogin(cfg, flag.Args()[1:])
+		return
+	case "cp":
+		runCopy(cfg, flag.Args()[1:])
+		return
+	case "apply":
+		runApply(cfg, flag.Args()[1:])
+		return
+	case "put":
+		runPut(cfg, flag.Args()[1:])
+		return
+	case "get":
+		runGet(cfg, flag.Args()[1:])
+		return
+	case "ls":
+		runLs(cfg, flag.Args()[1:])
+		return
+	case "stats":
+		runStats(cfg, flag.Args()[1:])
+		return
+	case "bench":
+		runBench(cfg, flag.Args()[1:])
+		return
+	case "fix-content-types":
+		runFixContentTypes(cfg, flag.Args()[1:])
+		return
+	case "select":
+		runSelect(cfg, flag.Args()[1:])
+		return
+	case "notifications":
+		runNotifications(cfg, flag.Args()[1:])
+		return
+	case "acl":
+		runACL(cfg, flag.Args()[1:])
+		return
+	case "acl-scan":
+		runACLScan(cfg, flag.Args()[1:])
+		return
+	case "tags":
+		runTags(cfg, flag.Args()[1:])
+		return
+	case "expire":
+		runExpire(cfg, flag.Args()[1:])
+		return
+	case "normalize":
+		runNormalize(cfg, flag.Args()[1:])
+		return
+	case "tag-objects":
+		runTagObjects(cfg, flag.Args()[1:])
+		return
+	case "abort-rule":
+		runAbortRule(cfg, flag.Args()[1:])
+		return
+	case "logging":
+		runLogging(cfg, flag.Args()[1:])
+		return
+	case "ownership":
+		runOwnership(cfg, flag.Args()[1:])
+		return
+	case "compare":
+		runCompare(cfg, flag.Args()[1:])
+		return
+	case "verify":
+		runVerify(cfg, flag.Args()[1:])
+		return
+	case "reencrypt":
+		runReencrypt(cfg, flag.Args()[1:])
+		return
+	case "manifest":
+		runManifest(cfg, flag.Args()[1:])
+		return
+	case "url":
+		runURL(cfg, flag.Args()[1:])
+		return
+	case "restore":
+		runRestore(cfg, flag.Args()[1:])
+		return
+	case "consume":
+		runConsume(cfg, flag.Args()[1:])
+		return
+	case "history":
+		runHistory(cfg, flag.Args()[1:])
+		return
+	case "cleanup":
+		if err := s3ry.Cleanup(cfg, os.Stdout); err != nil {
+			s3ry.Exit(err)
+		}
+		return
+	}
+
+	region, selectBucket := s3ry.SelectBucketAndRegionWithConfig(cfg)
+	s3ry.OperationsWithConfig(cfg, region, selectBucket)
+}
+
+// setupLogging redact secrets in log output
+func setupLogging(patterns []string) {
+	r, err := s3ry.NewRedactor(patterns)
+	if err != nil {
+		log.Println("Logging.RedactPatterns: " + err.Error())
+		os.Exit(s3ry.ExitUsage)
+	}
+	log.SetOutput(r.Writer(os.Stderr))
+}
+
+// runHistory history command
+func runHistory(cfg *s3ry.Config, args []string) {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	since := fs.String("since", "", "show operations from this date, e.g. 2006-01-02")
+	until := fs.String("until", "", "show operations until the end of this date")
+	operation := fs.String("operation", "", "show only this operation, e.g. upload")
+	bucket := fs.String("bucket", "", "show only the operations on this bucket")
+	by := fs.String("by", "", "print the operations, failures, error rate and throughput by operation or bucket")
+	output := fs.String("output", "", "print the operations as json lines")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		usage("s3ry history [--since date] [--until date] [--operation name] [--bucket name] [--by operation|bucket] [--output json]")
+	}
+	if err := s3ry.History(cfg, *since, *until, *operation, *bucket, *by, *output, os.Stdout); err != nil {
+		s3ry.Exit(err)
+	}
+}
+
+// runLogin login command
+func runLogin(cfg *s3ry.Config, args []string) {
+	ctx, cancel := commandContext(cfg, "login")
+	defer cancel()
+	fs := flag.NewFlagSet("login", flag.ExitOnError)
+	profile := fs.String("sso", "", "AWS SSO profile to log in to")
+	fs.Parse(args)
+	if *profile == "" || fs.NArg() != 0 {
+		usage("s3ry login --sso profile")
+	}
+	if err := s3ry.SSOLogin(ctx, cfg, *profile, os.Stderr); err != nil {
+		exit(ctx, err)
+	}
+}
+
+// runCopy cp command
+func runCopy(cfg *s3ry.Config, args []string) {
+	ctx, cancel := commandContext(cfg, "cp")
+	defer cancel()
+	fs := flag.NewFlagSet("cp", flag.ExitOnError)
+	recursive := fs.Bool("recursive", false, "copy every object under the source prefix")
+	verbose := fs.Bool("verbose", false, "print each object copied with --recursive")
+	expression := fs.String("filter", "", `copy only the objects matching this expression with --recursive, e.g. 'size > 1GB && storage_class == "STANDARD"'`)
+	plan := fs.Bool("plan", false, "print the copies of --recursive as a JSON plan for s3ry apply instead of copying")
+	fs.Parse(args)
+	if fs.NArg() != 2 || ((*expression != "" || *plan) && !*recursive) {
+		usage("s3ry cp [--recursive [--verbose] [--filter expression] [--plan]] s3://bucket/key s3://bucket/key")
+	}
+	setFilter(cfg, *expression)
+	if *plan {
+		if err := s3ry.PlanCopyPrefix(ctx, cfg, fs.Arg(0), fs.Arg(1), os.Stdout); err != nil {
+			exit(ctx, err)
+		}
+		return
+	}
+	if err := s3ry.Copy(ctx, cfg, fs.Arg(0), fs.Arg(1), *recursive, *verbose); err != nil {
+		exit(ctx, err)
+	}
+}
+
+// runApply apply command
+func runApply(cfg *s3ry.Config, args []string) {
+	ctx, cancel := commandContext(cfg, "apply")
+	defer cancel()
+	fs := flag.NewFlagSet("apply", flag.ExitOnError)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		usage("s3ry apply plan.json|-")
+	}
+	if err := s3ry.Apply(ctx, cfg, fs.Arg(0), os.Stdout); err != nil {
+		exit(ctx, err)
+	}
+}
+
+// setFilter select the objects of the command by expression, exiting before anything runs when it is malformed
+func setFilter(cfg *s3ry.Config, expression string) {
+	if expression == "" {
+		return
+	}
+	if err := s3ry.ValidateFilter(expression); err != nil {
+		usage(err.Error())
+	}
+	cfg.Filter = expression
+}
+
+// runPut put command
+func runPut(cfg *s3ry.Config, args []string) {
+	ctx, cancel := commandContext(cfg, "put")
+	defer cancel()
+	fs := flag.NewFlagSet("put", flag.ExitOnError)
+	keyTemplate := fs.String("key-template", "", "key of each file under the prefix, e.g. {date}/{filename}, with {date}, {filename}, {ext}, {hash} and {index}")
+	recursive := fs.Bool("recursive", false, "upload the files under a directory")
+	followSymlinks := fs.Bool("follow-symlinks", false, "upload the targets of symbolic links instead of skipping them (default Upload.Symlinks in the config)")
+	preserveMode := fs.Bool("preserve-mode", false, "store the permission bits of each file, restored by get --preserve-mode")
+	expireIn := fs.String("expire-in", "", "tag the objects to be deleted by the expire command this long after the upload, e.g. 30d or 12h")
+	compress := fs.Bool("compress", false, "gzip the files and store them with Content-Encoding: gzip, decoded by get --decompress")
+	fs.Parse(args)
+	if fs.NArg() < 2 || (*recursive && (fs.NArg() != 2 || *keyTemplate != "")) {
+		usage("s3ry put [--compress] [--expire-in ttl] [--key-template template] file|- s3://bucket/key | s3ry put [--compress] [--expire-in ttl] [--key-template template] file... s3://bucket/prefix/ | s3ry put --recursive [--follow-symlinks] [--preserve-mode] [--compress] [--expire-in ttl] dir s3://bucket/prefix/")
+	}
+	if *compress {
+		cfg.Upload.Compress = true
+	}
+	if *expireIn != "" {
+		ttl, err := s3ry.ParseExpiry(*expireIn)
+		if err != nil {
+			usage(err.Error())
+		}
+		cfg.Upload.ExpireIn = s3ry.Duration(ttl)
+	}
+	srcs, dst := fs.Args()[:fs.NArg()-1], fs.Arg(fs.NArg()-1)
+	if *recursive {
+		if *followSymlinks {
+			cfg.Upload.Symlinks = s3ry.SymlinksFollow
+		}
+		if *preserveMode {
+			cfg.Upload.PreserveMode = true
+		}
+		if err := s3ry.PutDirectory(ctx, cfg, srcs[0], dst); err != nil {
+			exit(ctx, err)
+		}
+		return
+	}
+	if len(srcs) == 1 && *keyTemplate == "" {
+		if err := s3ry.Put(ctx, cfg, srcs[0], dst); err != nil {
+			exit(ctx, err)
+		}
+		return
+	}
+	if err := s3ry.PutFiles(ctx, cfg, srcs, dst, *keyTemplate); err != nil {
+		exit(ctx, err)
+	}
+}
+
+// runGet get command
+func runGet(cfg *s3ry.Config, args []string) {
+	ctx, cancel := commandContext(cfg, "get")
+	defer cancel()
+	fs := flag.NewFlagSet("get", flag.ExitOnError)
+	skipExisting := fs.Bool("skip-existing", false, "keep the local file when the object is unchanged since it was downloaded")
+	preserveMode := fs.Bool("preserve-mode", false, "restore the permission bits stored by put --recursive --preserve-mode")
+	decompress := fs.Bool("decompress", false, "decode objects stored with Content-Encoding gzip or deflate, instead of writing their raw bytes")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		usage("s3ry get [--skip-existing] [--preserve-mode] [--decompress] s3://bucket/key file|-")
+	}
+	if *decompress {
+		cfg.Upload.Decompress = true
+	}
+	if *skipExisting {
+		cfg.Performance.SkipExisting = true
+	}
+	if *preserveMode {
+		cfg.Upload.PreserveMode = true
+	}
+	if err := s3ry.Get(ctx, cfg, fs.Arg(0), fs.Arg(1)); err != nil {
+		exit(ctx, err)
+	}
+}
+
+// runLs ls command
+func runLs(cfg *s3ry.Config, args []string) {
+	ctx, cancel := commandContext(cfg, "ls")
+	defer cancel()
+	fs := flag.NewFlagSet("ls", flag.ExitOnError)
+	startAfter := fs.String("start-after", "", "list the keys after this key")
+	maxKeys := fs.Int64("max-keys", 0, "keys of the page (default Performance.ListPageSize in the config)")
+	token := fs.String("continuation-token", "", "list the page after the one printing this token")
+	output := fs.String("output", "", "print the page as json")
+	expression := fs.String("filter", "", "print only the objects of the page matching this expression, e.g. 'size > 1GB'")
+	fs.Parse(args)
+	if fs.NArg() != 1 || *maxKeys < 0 || *maxKeys > 1000 {
+		usage("s3ry ls [--start-after key] [--max-keys 1-1000] [--continuation-token token] [--filter expression] [--output json] s3://bucket[/prefix]")
+	}
+	setFilter(cfg, *expression)
+	opts := s3ry.ListOptions{StartAfter: *startAfter, MaxKeys: *maxKeys, ContinuationToken: *token}
+	if err := s3ry.List(ctx, cfg, fs.Arg(0), opts, *output, os.Stdout); err != nil {
+		exit(ctx, err)
+	}
+}
+
+// runStats stats command
+func runStats(cfg *s3ry.Config, args []string) {
+	ctx, cancel := commandContext(cfg, "stats")
+	defer cancel()
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	cloudwatch := fs.Bool("cloudwatch", false, "read the totals of the bucket from CloudWatch instead of listing it")
+	output := fs.String("output", "", "print the summary as json")
+	allBuckets := fs.Bool("all-buckets", false, "summarize every bucket and their total")
+	buckets := fs.String("buckets", "", "summarize the buckets matching this pattern, e.g. 'logs-*', and their total")
+	fs.Parse(args)
+	if pattern := bucketPattern(*allBuckets, *buckets); pattern != "" {
+		if fs.NArg() != 0 || *cloudwatch {
+			usage("s3ry stats [--output json] --all-buckets | --buckets pattern")
+		}
+		if err := s3ry.StatsBuckets(ctx, cfg, pattern, *output, os.Stdout); err != nil {
+			exit(ctx, err)
+		}
+		return
+	}
+	if fs.NArg() != 1 {
+		usage("s3ry stats [--cloudwatch] [--output json] s3://bucket[/prefix] | s3ry stats [--output json] --all-buckets | --buckets pattern")
+	}
+	if err := s3ry.Stats(ctx, cfg, fs.Arg(0), *cloudwatch, *output, os.Stdout); err != nil {
+		exit(ctx, err)
+	}
+}
+
+// bucketPattern return the pattern of the buckets selected by --all-buckets or --buckets, empty for neither
+func bucketPattern(all bool, pattern string) string {
+	if all && pattern != "" {
+		usage("use --all-buckets or --buckets, not both")
+	}
+	if all {
+		return "*"
+	}
+	return pattern
+}
+
+// runBench bench command
+func runBench(cfg *s3ry.Config, args []string) {
+	ctx, cancel := commandContext(cfg, "bench")
+	defer cancel()
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	sizes := fs.String("sizes", "1024,1048576,16777216", "comma separated object sizes in bytes")
+	concurrency := fs.Int("concurrency", 4, "operations running at once")
+	iterations := fs.Int("iterations", 10, "objects per size")
+	out := fs.String("out", "", "write the JSON report to this file instead of stdout")
+	baseline := fs.String("baseline", "", "compare with a JSON report written before")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		usage("s3ry bench [flags] s3://bucket/prefix/")
+	}
+	opts := s3ry.BenchOptions{Concurrency: *concurrency, Iterations: *iterations}
+	for _, size := range strings.Split(*sizes, ",") {
+		n, err := strconv.ParseInt(strings.TrimSpace(size), 10, 64)
+		if err != nil || n < 0 {
+			log.Println("invalid size: " + size)
+			os.Exit(s3ry.ExitUsage)
+		}
+		opts.Sizes = append(opts.Sizes, n)
+	}
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			exit(ctx, err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := s3ry.Benchmark(ctx, cfg, fs.Arg(0), opts, *baseline, w); err != nil {
+		exit(ctx, err)
+	}
+}
+
+// runFixContentTypes fix-content-types command
+func runFixContentTypes(cfg *s3ry.Config, args []string) {
+	ctx, cancel := commandContext(cfg, "fix-content-types")
+	defer cancel()
+	fs := flag.NewFlagSet("fix-content-types", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "only list objects with a wrong content type")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		usage("s3ry fix-content-types [--dry-run] s3://bucket/prefix")
+	}
+	if err := s3ry.FixContentType(ctx, cfg, fs.Arg(0), *dryRun); err != nil {
+		exit(ctx, err)
+	}
+}
+
+// runSelect select command
+func runSelect(cfg *s3ry.Config, args []string) {
+	ctx, cancel := commandContext(cfg, "select")
+	defer cancel()
+	fs := flag.NewFlagSet("select", flag.ExitOnError)
+	output := fs.String("output", "", "write the records to this file instead of stdout")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		usage("s3ry select [--output file] s3://bucket/key 'SELECT * FROM s3object s'")
+	}
+	if err := s3ry.Select(ctx, cfg, fs.Arg(0), fs.Arg(1), *output); err != nil {
+		exit(ctx, err)
+	}
+}
+
+// runNotifications notifications command
+func runNotifications(cfg *s3ry.Config, args []string) {
+	ctx, cancel := commandContext(cfg, "notifications")
+	defer cancel()
+	fs := flag.NewFlagSet("notifications", flag.ExitOnError)
+	add := fs.Bool("add", false, "add an event notification rule interactively")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		usage("s3ry notifications [--add] bucket")
+	}
+	if err := s3ry.Notification(ctx, cfg, fs.Arg(0), *add, os.Stdin, os.Stdout); err != nil {
+		exit(ctx, err)
+	}
+}
+
+// runACL acl command
+func runACL(cfg *s3ry.Config, args []string) {
+	ctx, cancel := commandContext(cfg, "acl")
+	defer cancel()
+	fs := flag.NewFlagSet("acl", flag.ExitOnError)
+	acl := fs.String("acl", "", "ACL preset to apply: private, public-read or authenticated-read")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		usage("s3ry acl [--acl preset] s3://bucket[/key]")
+	}
+	if err := s3ry.ACL(ctx, cfg, fs.Arg(0), *acl); err != nil {
+		exit(ctx, err)
+	}
+}
+
+// runACLScan acl-scan command
+func runACLScan(cfg *s3ry.Config, args []string) {
+	ctx, cancel := commandContext(cfg, "acl-scan")
+	defer cancel()
+	fs := flag.NewFlagSet("acl-scan", flag.ExitOnError)
+	limit := fs.Int64("limit", 0, "objects to check (default Security.ACLScanLimit in the config)")
+	output := fs.String("output", "", "print the report as json")
+	allBuckets := fs.Bool("all-buckets", false, "check every bucket")
+	buckets := fs.String("buckets", "", "check the buckets matching this pattern, e.g. 'logs-*'")
+	fs.Parse(args)
+	if pattern := bucketPattern(*allBuckets, *buckets); pattern != "" {
+		if fs.NArg() != 0 || *limit < 0 {
+			usage("s3ry acl-scan [--limit n] [--output json] --all-buckets | --buckets pattern")
+		}
+		if err := s3ry.ScanACLBuckets(ctx, cfg, pattern, *limit, *output, os.Stdout); err != nil {
+			exit(ctx, err)
+		}
+		return
+	}
+	if fs.NArg() != 1 || *limit < 0 {
+		usage("s3ry acl-scan [--limit n] [--output json] s3://bucket[/prefix] | s3ry acl-scan [--limit n] [--output json] --all-buckets | --buckets pattern")
+	}
+	if err := s3ry.ScanACL(ctx, cfg, fs.Arg(0), *limit, *output, os.Stdout); err != nil {
+		exit(ctx, err)
+	}
+}
+
+// runTags tags command
+func runTags(cfg *s3ry.Config, args []string) {
+	ctx, cancel := commandContext(cfg, "tags")
+	defer cancel()
+	fs := flag.NewFlagSet("tags", flag.ExitOnError)
+	remove := fs.String("remove", "", "comma separated tag keys to remove")
+	fs.Parse(args)
+	if fs.NArg() < 1 {
+		usage("s3ry tags [--remove key,...] bucket [key=value ...]")
+	}
+	var keys []string
+	if *remove != "" {
+		keys = strings.Split(*remove, ",")
+	}
+	if err := s3ry.Tags(ctx, cfg, fs.Arg(0), fs.Args()[1:], keys, os.Stdout); err != nil {
+		exit(ctx, err)
+	}
+}
+
+// runTagObjects tag-objects command
+func runTagObjects(cfg *s3ry.Config, args []string) {
+	ctx, cancel := commandContext(cfg, "tag-objects")
+	defer cancel()
+	fs := flag.NewFlagSet("tag-objects", flag.ExitOnError)
+	remove := fs.String("remove", "", "comma separated tag keys to remove")
+	replace := fs.Bool("replace", false, "replace every tag of the objects with the given ones")
+	dryRun := fs.Bool("dry-run", false, "only print the objects which would change and their new tags")
+	expression := fs.String("filter", "", "tag only the objects matching this expression, e.g. 'last_modified < 2023-01-01'")
+	fs.Parse(args)
+	if fs.NArg() < 1 {
+		usage("s3ry tag-objects [--remove key,...] [--replace] [--dry-run] [--filter expression] s3://bucket[/prefix] [key=value ...]")
+	}
+	setFilter(cfg, *expression)
+	var keys []string
+	if *remove != "" {
+		keys = strings.Split(*remove, ",")
+	}
+	change, err := s3ry.ParseTagChange(fs.Args()[1:], keys, *replace)
+	if err != nil {
+		exit(ctx, err)
+	}
+	if err := s3ry.TagObjects(ctx, cfg, fs.Arg(0), change, *dryRun, os.Stdout); err != nil {
+		exit(ctx, err)
+	}
+}
+
+// runExpire expire command
+func runExpire(cfg *s3ry.Config, args []string) {
+	ctx, cancel := commandContext(cfg, "expire")
+	defer cancel()
+	fs := flag.NewFlagSet("expire", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "only print the expired objects")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		usage("s3ry expire [--dry-run] s3://bucket[/prefix]")
+	}
+	if err := s3ry.Expire(ctx, cfg, fs.Arg(0), *dryRun, os.Stdout); err != nil {
+		exit(ctx, err)
+	}
+}
+
+// runNormalize normalize command
+func runNormalize(cfg *s3ry.Config, args []string) {
+	ctx, cancel := commandContext(cfg, "normalize")
+	defer cancel()
+	fs := flag.NewFlagSet("normalize", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "only print the keys with problems and their normalized keys")
+	lowercase := fs.Bool("lowercase", false, "lowercase the keys too (default Normalize.Lowercase in the config)")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		usage("s3ry normalize [--dry-run] [--lowercase] s3://bucket[/prefix]")
+	}
+	if *lowercase {
+		cfg.Normalize.Lowercase = true
+	}
+	if err := s3ry.Normalize(ctx, cfg, fs.Arg(0), *dryRun, os.Stdout); err != nil {
+		exit(ctx, err)
+	}
+}
+
+// runAbortRule abort-rule command
+func runAbortRule(cfg *s3ry.Config, args []string) {
+	ctx, cancel := commandContext(cfg, "abort-rule")
+	defer cancel()
+	fs := flag.NewFlagSet("abort-rule", flag.ExitOnError)
+	days := fs.Int64("days", 0, "abort incomplete multipart uploads this many days after they were started (default Cleanup.AbortUploadsAfterDays in the config)")
+	report := fs.Bool("report", false, "print the buckets without a rule aborting incomplete multipart uploads, every bucket when none are given")
+	fs.Parse(args)
+	if (fs.NArg() == 0 && !*report) || *days < 0 {
+		usage("s3ry abort-rule [--days n] bucket... | s3ry abort-rule --report [bucket...]")
+	}
+	if err := s3ry.AbortRule(ctx, cfg, fs.Args(), *days, *report, os.Stdout); err != nil {
+		exit(ctx, err)
+	}
+}
+
+// runLogging logging command
+func runLogging(cfg *s3ry.Config, args []string) {
+	ctx, cancel := commandContext(cfg, "logging")
+	defer cancel()
+	fs := flag.NewFlagSet("logging", flag.ExitOnError)
+	target := fs.String("target", "", "enable server access logging to this bucket")
+	prefix := fs.String("prefix", "", "prefix of the log objects in the target bucket, e.g. logs/")
+	disable := fs.Bool("disable", false, "disable server access logging")
+	report := fs.Bool("report", false, "print the buckets with server access logging disabled, every bucket when none are given")
+	fs.Parse(args)
+	if (fs.NArg() == 0 && !*report) || (*target != "" && *disable) {
+		usage("s3ry logging [--target bucket [--prefix prefix] | --disable] bucket... | s3ry logging --report [bucket...]")
+	}
+	if err := s3ry.Logging(ctx, cfg, fs.Args(), *target, *prefix, *disable, *report, os.Stdout); err != nil {
+		exit(ctx, err)
+	}
+}
+
+// runOwnership ownership command
+func runOwnership(cfg *s3ry.Config, args []string) {
+	ctx, cancel := commandContext(cfg, "ownership")
+	defer cancel()
+	fs := flag.NewFlagSet("ownership", flag.ExitOnError)
+	enforce := fs.Bool("enforce", false, "disable ACLs by applying BucketOwnerEnforced, after asking")
+	report := fs.Bool("report", false, "print the buckets whose ACLs are enabled, every bucket when none are given")
+	fs.Parse(args)
+	if (fs.NArg() == 0 && !*report) || (*enforce && *report) {
+		usage("s3ry ownership [--enforce] bucket... | s3ry ownership --report [bucket...]")
+	}
+	if err := s3ry.Ownership(ctx, cfg, fs.Args(), *enforce, *report, os.Stdout); err != nil {
+		exit(ctx, err)
+	}
+}
+
+// runCompare compare command
+func runCompare(cfg *s3ry.Config, args []string) {
+	ctx, cancel := commandContext(cfg, "compare")
+	defer cancel()
+	if len(args) != 2 {
+		usage("s3ry compare s3://bucket/key s3://bucket/key")
+	}
+	if err := s3ry.Compare(ctx, cfg, args[0], args[1], os.Stdout); err != nil {
+		exit(ctx, err)
+	}
+}
+
+// runVerify verify command
+func runVerify(cfg *s3ry.Config, args []string) {
+	ctx, cancel := commandContext(cfg, "verify")
+	defer cancel()
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	deep := fs.Bool("deep", false, "read every object to recompute its checksum")
+	rate := fs.Int("rate", -1, "objects to check per second, 0 for no limit (default Performance.VerifyRate in the config)")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		usage("s3ry verify [--deep] [--rate n] s3://bucket[/prefix]")
+	}
+	if *rate >= 0 {
+		cfg.Performance.VerifyRate = *rate
+	}
+	if err := s3ry.Verify(ctx, cfg, fs.Arg(0), *deep, os.Stdout); err != nil {
+		exit(ctx, err)
+	}
+}
+
+// runReencrypt reencrypt command
+func runReencrypt(cfg *s3ry.Config, args []string) {
+	ctx, cancel := commandContext(cfg, "reencrypt")
+	defer cancel()
+	fs := flag.NewFlagSet("reencrypt", flag.ExitOnError)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		usage("s3ry --sse SSE-S3|SSE-KMS [--sse-kms-key-id key] reencrypt s3://bucket[/prefix]")
+	}
+	if err := s3ry.Reencrypt(ctx, cfg, fs.Arg(0), os.Stdout); err != nil {
+		exit(ctx, err)
+	}
+}
+
+// runManifest manifest command
+func runManifest(cfg *s3ry.Config, args []string) {
+	ctx, cancel := commandContext(cfg, "manifest")
+	defer cancel()
+	fs := flag.NewFlagSet("manifest", flag.ExitOnError)
+	format := fs.String("format", "json", "json or sha256sum")
+	verify := fs.String("verify", "", "check the directory against this manifest instead")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		usage("s3ry manifest [--format json|sha256sum] [--verify manifest] dir")
+	}
+	var err error
+	if *verify != "" {
+		err = s3ry.VerifyManifest(ctx, cfg, fs.Arg(0), *verify, os.Stdout)
+	} else {
+		err = s3ry.Manifest(ctx, cfg, fs.Arg(0), *format, os.Stdout)
+	}
+	if err != nil {
+		exit(ctx, err)
+	}
+}
+
+// runURL url command
+func runURL(cfg *s3ry.Config, args []string) {
+	ctx, cancel := commandContext(cfg, "url")
+	defer cancel()
+	fs := flag.NewFlagSet("url", flag.ExitOnError)
+	pathStyle := fs.Bool("path-style", false, "print host/bucket/key instead of bucket.host/key")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		usage("s3ry url [--path-style] s3://bucket/key")
+	}
+	if err := s3ry.URL(cfg, fs.Arg(0), *pathStyle, os.Stdout); err != nil {
+		exit(ctx, err)
+	}
+}
+
+// runRestore restore command
+func runRestore(cfg *s3ry.Config, args []string) {
+	ctx, cancel := commandContext(cfg, "restore")
+	defer cancel()
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	days := fs.Int64("days", 1, "days to keep the restored copy")
+	tier := fs.String("tier", "Standard", "retrieval tier: Standard, Bulk or Expedited")
+	callback := fs.String("callback", "", "URL to POST to or shell command to run when the restore completes")
+	wait := fs.Bool("wait", false, "check pending restores until they complete, running their callbacks")
+	interval := fs.Duration("interval", 5*time.Minute, "time between checks with --wait")
+	fs.Parse(args)
+	if *wait && fs.NArg() == 0 {
+		if err := s3ry.WaitRestores(ctx, cfg, *interval, os.Stdout); err != nil {
+			exit(ctx, err)
+		}
+		return
+	}
+	if fs.NArg() != 1 {
+		usage("s3ry restore [--days n] [--tier tier] [--callback url|command] s3://bucket/key, or s3ry restore --wait")
+	}
+	if err := s3ry.Restore(ctx, cfg, fs.Arg(0), *days, *tier, *callback, os.Stdout); err != nil {
+		exit(ctx, err)
+	}
+	if *wait {
+		if err := s3ry.WaitRestores(ctx, cfg, *interval, os.Stdout); err != nil {
+			exit(ctx, err)
+		}
+	}
+}
+
+// runConsume consume command
+func runConsume(cfg *s3ry.Config, args []string) {
+	ctx, cancel := commandContext(cfg, "consume")
+	defer cancel()
+	fs := flag.NewFlagSet("consume", flag.ExitOnError)
+	queueURL := fs.String("queue-url", cfg.Consumer.QueueURL, "SQS queue receiving the bucket notifications")
+	fs.Parse(args)
+	cfg.Consumer.QueueURL = *queueURL
+	if err := s3ry.Consume(ctx, cfg); err != nil {
+		exit(ctx, err)
+	}
+}
+
+// usage print usage of a command and exit
+func usage(msg string) {
+	log.Println("usage: " + msg)
+	os.Exit(s3ry.ExitUsage)
+}
+
+// finished notify that the running command finished, set by commandContext
+var finished = func(err error) {}
+
+// exit exit with err, reported as a timeout when ctx exceeded its deadline
+func exit(ctx context.Context, err error) {
+	err = s3ry.DeadlineError(ctx, err)
+	finished(err)
+	s3ry.Exit(err)
+}
+
+// commandContext return context of command cancelled by Ctrl+C or its timeout
+// the returned cancel, deferred by every command, notifies that it finished
+func commandContext(cfg *s3ry.Config, command string) (context.Context, context.CancelFunc) {
+	ctx, cancel := cfg.CommandContext(interruptContext(), command)
+	finished = cfg.CommandNotifier(command)
+	return ctx, func() {
+		finished(nil)
+		cancel()
+	}
+}
+
+// interruptContext return context cancelled by Ctrl+C
+func interruptContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+	go func() {
+		<-c
+		cancel()
+	}()
+	return ctx
 }
@@ -0,0 +1,196 @@
+package s3ry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// compareChunk bytes read from each object at a time when comparing content
+const compareChunk = 1024 * 1024
+
+// ErrObjectsDiffer compared objects are different
+var ErrObjectsDiffer = errors.New("objects differ")
+
+// objectDigest size and checksums of an object from HeadObject
+type objectDigest struct {
+	Size int64
+	ETag string
+	// Checksums stored checksum by algorithm, "-N" suffixed ones are composed of N parts
+	Checksums map[string]string
+}
+
+// CompareResult result of CompareObjects
+type CompareResult struct {
+	Identical bool
+	// Method what decided the result: size, etag, content or the checksum algorithm
+	Method string
+}
+
+// headDigest return digest of object, asking S3 for the stored checksums
+func (s S3ry) headDigest(ctx context.Context, bucket string, key string) (objectDigest, error) {
+	input := &s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)}
+	if f, err := s.config().Encryption.fields(); err == nil {
+		input.SSECustomerAlgorithm = f.SSECustomerAlgorithm
+		input.SSECustomerKey = f.SSECustomerKey
+	}
+	req, out := s.Svc.HeadObjectRequest(input)
+	req.SetContext(ctx)
+	// the SDK doesn't know checksums yet, so ask for them and read the headers
+	req.HTTPRequest.Header.Set("X-Amz-Checksum-Mode", "ENABLED")
+	if err := req.Send(); err != nil {
+		return objectDigest{}, err
+	}
+	d := objectDigest{
+		Size:      aws.Int64Value(out.ContentLength),
+		ETag:      strings.Trim(aws.StringValue(out.ETag), `"`),
+		Checksums: map[string]string{},
+	}
+	for _, algorithm := range []string{ChecksumCRC32, ChecksumCRC32C, ChecksumSHA1, ChecksumSHA256} {
+		if v := req.HTTPResponse.Header.Get(checksumHeader(algorithm)); v != "" {
+			d.Checksums[algorithm] = v
+		}
+	}
+	return d, nil
+}
+
+// compareDigests decide from digests of two objects, false when they don't tell
+func compareDigests(a objectDigest, b objectDigest) (CompareResult, bool) {
+	if a.Size != b.Size {
+		return CompareResult{Identical: false, Method: "size"}, true
+	}
+	for _, algorithm := range []string{ChecksumSHA256, ChecksumSHA1, ChecksumCRC32C, ChecksumCRC32} {
+		ca, cb := a.Checksums[algorithm], b.Checksums[algorithm]
+		if ca == "" || cb == "" {
+			continue
+		}
+		if ca == cb {
+			return CompareResult{Identical: true, Method: algorithm}, true
+		}
+		// checksums composed of parts differ when the part sizes do
+		if !strings.Contains(ca, "-") && !strings.Contains(cb, "-") {
+			return CompareResult{Identical: false, Method: algorithm}, true
+		}
+	}
+	// an ETag is not always the MD5 of the content (multipart, SSE-KMS), so only equal ones tell
+	if a.ETag != "" && a.ETag == b.ETag {
+		return CompareResult{Identical: true, Method: "etag"}, true
+	}
+	return CompareResult{}, false
+}
+
+// CompareObjects check two objects are identical by size and checksums,
+// reading both when they don't tell
+func (s S3ry) CompareObjects(ctx context.Context, bucketA string, keyA string, bucketB string, keyB string) (CompareResult, error) {
+	a, err := s.forBucket(bucketA)
+	if err != nil {
+		return CompareResult{}, err
+	}
+	b, err := s.forBucket(bucketB)
+	if err != nil {
+		return CompareResult{}, err
+	}
+	da, err := a.headDigest(ctx, bucketA, keyA)
+	if err != nil {
+		return CompareResult{}, err
+	}
+	db, err := b.headDigest(ctx, bucketB, keyB)
+	if err != nil {
+		return CompareResult{}, err
+	}
+	if result, ok := compareDigests(da, db); ok {
+		return result, nil
+	}
+
+	ra, err := a.openObject(ctx, bucketA, keyA)
+	if err != nil {
+		return CompareResult{}, err
+	}
+	defer ra.Close()
+	rb, err := b.openObject(ctx, bucketB, keyB)
+	if err != nil {
+		return CompareResult{}, err
+	}
+	defer rb.Close()
+	identical, err := sameContent(ra, rb)
+	return CompareResult{Identical: identical, Method: "content"}, err
+}
+
+// openObject return body of object
+func (s S3ry) openObject(ctx context.Context, bucket string, key string) (io.ReadCloser, error) {
+	return s.openObjectPart(ctx, bucket, key, 0)
+}
+
+// openObjectPart return body of part of a multipart uploaded object, of the whole object when part is 0
+func (s S3ry) openObjectPart(ctx context.Context, bucket string, key string, part int64) (io.ReadCloser, error) {
+	input := &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)}
+	if part > 0 {
+		input.PartNumber = aws.Int64(part)
+	}
+	if f, err := s.config().Encryption.fields(); err == nil {
+		input.SSECustomerAlgorithm = f.SSECustomerAlgorithm
+		input.SSECustomerKey = f.SSECustomerKey
+	}
+	out, err := s.Svc.GetObjectWithContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+// sameContent compare a and b chunk by chunk
+func sameContent(a io.Reader, b io.Reader) (bool, error) {
+	bufA := make([]byte, compareChunk)
+	bufB := make([]byte, compareChunk)
+	for {
+		na, errA := io.ReadFull(a, bufA)
+		nb, errB := io.ReadFull(b, bufB)
+		if !bytes.Equal(bufA[:na], bufB[:nb]) {
+			return false, nil
+		}
+		endA := errA == io.EOF || errA == io.ErrUnexpectedEOF
+		endB := errB == io.EOF || errB == io.ErrUnexpectedEOF
+		if errA != nil && !endA {
+			return false, errA
+		}
+		if errB != nil && !endB {
+			return false, errB
+		}
+		if endA || endB {
+			return endA == endB, nil
+		}
+	}
+}
+
+// Compare compare s3:// URIs a and b and print the result, used by the compare command
+// different objects return ErrObjectsDiffer
+func Compare(ctx context.Context, cfg *Config, a string, b string, w io.Writer) error {
+	bucketA, keyA, err := ParseS3URI(a)
+	if err != nil {
+		return err
+	}
+	bucketB, keyB, err := ParseS3URI(b)
+	if err != nil {
+		return err
+	}
+	if keyA == "" || keyB == "" {
+		return fmt.Errorf("%w, compare needs object keys", ErrInvalidURI)
+	}
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	result, err := s.CompareObjects(ctx, bucketA, keyA, bucketB, keyB)
+	if err != nil {
+		return err
+	}
+	if !result.Identical {
+		fmt.Fprintln(w, i18nPrinter.Sprintf("Different, by %s", result.Method))
+		return ErrObjectsDiffer
+	}
+	fmt.Fprintln(w, i18nPrinter.Sprintf("Identical, by %s", result.Method))
+	return nil
+}
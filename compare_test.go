@@ -0,0 +1,93 @@
+package s3ry
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareObjects(t *testing.T) {
+	fake := newFakeS3("a", "b")
+	fake.put("a", "same", "hello")
+	fake.put("b", "same", "hello")
+	fake.put("a", "short", "hello")
+	fake.put("b", "short", "hello world")
+	fake.put("a", "changed", "hello")
+	fake.put("b", "changed", "jello")
+	s, srv := newTestS3ry(DefaultConfig(), fake)
+	defer srv.Close()
+	ctx := context.Background()
+
+	// uploads through s3ry store a checksum
+	_, err := s.PutStream(ctx, "a", "summed", strings.NewReader("hello"))
+	assert.NoError(t, err)
+	_, err = s.PutStream(ctx, "b", "summed", strings.NewReader("hello"))
+	assert.NoError(t, err)
+
+	for _, c := range []struct {
+		key      string
+		expected CompareResult
+	}{
+		{"summed", CompareResult{Identical: true, Method: ChecksumCRC32C}},
+		{"same", CompareResult{Identical: true, Method: "etag"}},
+		{"short", CompareResult{Identical: false, Method: "size"}},
+		{"changed", CompareResult{Identical: false, Method: "content"}},
+	} {
+		result, err := s.CompareObjects(ctx, "a", c.key, "b", c.key)
+		assert.NoError(t, err, c.key)
+		assert.Equal(t, c.expected, result, c.key)
+	}
+	assert.Equal(t, 2, fake.count("GET"))
+
+	_, err = s.CompareObjects(ctx, "a", "missing", "b", "same")
+	assert.Error(t, err)
+}
+
+func TestCompareDigests(t *testing.T) {
+	a := objectDigest{Size: 5, ETag: "x-2", Checksums: map[string]string{ChecksumSHA256: "abc-2"}}
+	b := objectDigest{Size: 5, ETag: "y-3", Checksums: map[string]string{ChecksumSHA256: "def-3"}}
+	// composite checksums of different part sizes don't tell
+	_, ok := compareDigests(a, b)
+	assert.False(t, ok)
+
+	a.Checksums[ChecksumCRC32] = "AAAA"
+	b.Checksums[ChecksumCRC32] = "BBBB"
+	result, ok := compareDigests(a, b)
+	assert.True(t, ok)
+	assert.Equal(t, CompareResult{Identical: false, Method: ChecksumCRC32}, result)
+}
+
+func TestSameContent(t *testing.T) {
+	long := bytes.Repeat([]byte("0123456789"), compareChunk/5)
+	for _, c := range []struct {
+		a, b     []byte
+		expected bool
+	}{
+		{long, long, true},
+		{long, long[:len(long)-1], false},
+		{nil, nil, true},
+		{[]byte("a"), nil, false},
+	} {
+		same, err := sameContent(bytes.NewReader(c.a), bytes.NewReader(c.b))
+		assert.NoError(t, err)
+		assert.Equal(t, c.expected, same)
+	}
+}
+
+func TestCompareExitCode(t *testing.T) {
+	fake := newFakeS3("a")
+	fake.put("a", "x", "1")
+	fake.put("a", "y", "2")
+	cfg, done := newFakeEndpoint(t, fake)
+	defer done()
+
+	out := &bytes.Buffer{}
+	err := Compare(context.Background(), cfg, "s3://a/x", "s3://a/y", out)
+	assert.Equal(t, ExitDiffer, ExitCode(err))
+	assert.Contains(t, out.String(), "Different")
+	assert.NoError(t, Compare(context.Background(), cfg, "s3://a/x", "s3://a/x", out))
+	assert.Equal(t, ExitUsage, ExitCode(Compare(context.Background(), cfg, "s3://a", "s3://a/x", out)))
+}
@@ -0,0 +1,467 @@
+package s3ry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// Config s3ry settings
+type Config struct {
+	AWS         AWSConfig
+	HTTP        HTTPConfig
+	Performance PerformanceConfig
+	Security    SecurityConfig
+	Encryption  EncryptionConfig
+	Cleanup     CleanupConfig
+	Logging     LoggingConfig
+	Consumer    ConsumerConfig
+	Progress    ProgressConfig
+	Summary     SummaryConfig
+	Notify      NotifyConfig
+	Upload      UploadConfig
+	Normalize   NormalizeConfig
+	// Timeouts time limit of each command by name, e.g. "cp": "1h" (default none, acl and notifications 1m)
+	// the --timeout flag overrides it
+	Timeouts map[string]Duration `json:",omitempty"`
+	// KeySanitizer maps key names to local file names on download, portable (default), none or one added with RegisterKeySanitizer
+	KeySanitizer string `json:",omitempty"`
+	// Theme colors and symbols of the interactive selects: default, dark, light, high-contrast, plain
+	// or one of Themes, an unknown theme falls back to default
+	Theme string `json:",omitempty"`
+	// Themes custom themes by name, e.g. "mine": {"Pointer": "*", "Active": "green|bold"}
+	Themes map[string]Theme `json:",omitempty"`
+	// Locale formats of numbers, sizes and times in summaries, progress and the interactive selects, e.g. ja-JP,
+	// or C for plain numbers and RFC 3339 times (default from the LC_ALL and LANG environment variables)
+	Locale string `json:",omitempty"`
+	// SizeUnits units of byte sizes, binary (KiB, MiB, default) or decimal (kB, MB)
+	SizeUnits string `json:",omitempty" enum:"binary,decimal"`
+	// ContentTypes content type of uploads by file extension, e.g. ".md": "text/markdown"
+	// overrides the system types, unknown extensions are detected from the file content
+	ContentTypes map[string]string `json:",omitempty"`
+	// Buckets default settings keyed by bucket name or path.Match pattern
+	Buckets map[string]BucketConfig `json:",omitempty"`
+	// Flags settings given on the command line, they override Buckets
+	Flags BucketConfig `json:"-"`
+	// Filter expression selecting the objects of ls, cp --recursive and tag-objects, set by their --filter flag
+	Filter string `json:"-"`
+}
+
+// AWSConfig settings for AWS credentials and endpoint
+// empty values fall back to the AWS SDK default chain
+type AWSConfig struct {
+	// Profile shared config profile name
+	Profile string `json:",omitempty"`
+	// Region default region used to search buckets, AWS_REGION and the --region flag override it
+	Region string `json:",omitempty"`
+	// FallbackRegion region used when neither Region nor the profile sets one (default us-east-1)
+	FallbackRegion string `json:",omitempty"`
+	// RegionFlag region given by the --region flag
+	RegionFlag string `json:"-"`
+	// Endpoint custom endpoint for S3 compatible storage
+	Endpoint string `json:",omitempty"`
+	// SignatureVersion v4, or v2 for legacy storage at Endpoint which only supports it (default v4)
+	SignatureVersion string `json:",omitempty" enum:"v4,v2"`
+	// AccessKeyID static credentials, prefer Profile
+	AccessKeyID string `json:",omitempty"`
+	// SecretAccessKey static credentials, prefer Profile
+	SecretAccessKey string `json:",omitempty"`
+	// CredentialProvider name of a provider added with RegisterCredentialProvider (default the settings above)
+	CredentialProvider string `json:",omitempty"`
+	// RefreshCredentialsBefore temporary credentials are refreshed this long before they expire, 0 to refresh them
+	// once expired (default 5m)
+	RefreshCredentialsBefore Duration `min:"0s"`
+}
+
+// HTTPConfig settings for the HTTP client used by the S3 client
+//
+// The defaults are tuned for high throughput: the SDK default transport
+// keeps only 2 idle connections per host, which forces new TLS handshakes
+// when s3manager transfers parts concurrently.
+type HTTPConfig struct {
+	// MaxIdleConns max idle connections across all hosts (default 100)
+	MaxIdleConns int `min:"0"`
+	// MaxIdleConnsPerHost max idle connections per host (default 100)
+	MaxIdleConnsPerHost int `min:"0"`
+	// IdleConnTimeout time an idle connection is kept open (default 90s)
+	IdleConnTimeout Duration `min:"0s"`
+	// Timeout per-request time limit including reading the body (default 0, no limit)
+	// large objects take a long time to transfer, so set this with care
+	Timeout Duration `min:"0s"`
+	// TLSHandshakeTimeout time limit for the TLS handshake (default 10s)
+	TLSHandshakeTimeout Duration `min:"0s"`
+}
+
+// PerformanceConfig settings for bulk operations
+type PerformanceConfig struct {
+	// Workers objects processed concurrently by bulk operations (default 10)
+	Workers int `min:"1"`
+	// BucketWorkers buckets processed concurrently by stats and acl-scan with --all-buckets or --buckets (default 4)
+	// each of them runs its own Workers
+	BucketWorkers int `min:"1"`
+	// MultipartCopyThreshold objects larger than this are copied in parts (default 5GiB, the CopyObject limit)
+	MultipartCopyThreshold int64 `min:"1" max:"5368709120"`
+	// CopyPartSize size of each part of a multipart copy (default 512MiB)
+	CopyPartSize int64 `min:"5242880" max:"5368709120"`
+	// ListPageSize objects requested per listing page (default 1000, the S3 maximum)
+	ListPageSize int64 `min:"1" max:"1000"`
+	// MultipartDownloadThreshold objects larger than this are downloaded in resumable ranges (default 64MiB)
+	MultipartDownloadThreshold int64 `min:"1"`
+	// DownloadPartSize size of each range of a ranged download (default 8MiB)
+	DownloadPartSize int64 `min:"1048576"`
+	// AdaptivePartSize size the parts of each multipart upload by the measured throughput of the parts before it,
+	// from 5MiB up to 256MiB, instead of fixed 5MiB parts (default false)
+	AdaptivePartSize bool
+	// UploadPartAttempts times a part of a multipart upload is sent while S3 stores it differently (default 3)
+	UploadPartAttempts int `min:"1"`
+	// BatchRetries rounds retrying the objects which failed in a bulk operation, cp --recursive, put --recursive,
+	// reencrypt and tag-objects, before reporting them (default 0)
+	BatchRetries int `min:"0"`
+	// BatchRetryBackoff wait before the first retry round, doubled before each next one (default 1s)
+	BatchRetryBackoff Duration
+	// VerifyRate objects per second checked by verify, 0 for no limit (default 100)
+	VerifyRate int `min:"0"`
+	// TempDir directory for partial downloads (default os.TempDir())
+	TempDir string `json:",omitempty"`
+	// SkipExisting keep a local file get downloaded before while the object is unchanged (default false)
+	// the get --skip-existing flag sets it
+	SkipExisting bool
+}
+
+// LoggingConfig settings for log output
+type LoggingConfig struct {
+	// RedactPatterns regular expressions masked in log output in addition to
+	// access keys, signatures and tokens, the first group of a match is kept
+	RedactPatterns []string `json:",omitempty"`
+	// History record every operation in history.jsonl next to the config file (default true)
+	History bool
+}
+
+// ConsumerConfig settings of the consume command reacting to S3 event notifications from SQS
+type ConsumerConfig struct {
+	// QueueURL SQS queue receiving the bucket notifications
+	QueueURL string `json:",omitempty"`
+	// DeadLetterQueueURL queue failed messages are moved to (default none, left to the redrive policy)
+	DeadLetterQueueURL string `json:",omitempty"`
+	// MaxReceives receives after which a failing message is dead-lettered (default 5)
+	MaxReceives int `min:"1"`
+	// VisibilityTimeout time a message is hidden while it is processed, extended while it runs (default 60s)
+	VisibilityTimeout Duration `min:"2s"`
+	// Reactions run for every event, in order
+	Reactions []ReactionConfig `json:",omitempty"`
+}
+
+// ReactionConfig a reaction to S3 events
+type ReactionConfig struct {
+	// Type one of copy, tag and log
+	Type string
+	// Events event names the reaction runs for, e.g. "ObjectCreated:*" (default every event)
+	Events []string `json:",omitempty"`
+	// KeyPrefix and KeySuffix filter object keys, empty matches every key
+	KeyPrefix string `json:",omitempty"`
+	KeySuffix string `json:",omitempty"`
+	// Bucket and Prefix destination of copy
+	Bucket string `json:",omitempty"`
+	Prefix string `json:",omitempty"`
+	// Tags tag set of tag
+	Tags map[string]string `json:",omitempty"`
+}
+
+// CleanupConfig settings for cleaning up interrupted transfers
+type CleanupConfig struct {
+	// OnStart abort stale multipart uploads and remove stale partial downloads on start (default false)
+	OnStart bool
+	// StaleAfter age after which an unfinished transfer is considered interrupted (default 24h)
+	StaleAfter Duration `min:"0s"`
+	// AbortUploadsAfterDays days after which the lifecycle rule set by abort-rule aborts an incomplete multipart upload (default 7)
+	AbortUploadsAfterDays int64 `min:"1"`
+}
+
+// SecurityConfig settings restricting what s3ry may do
+type SecurityConfig struct {
+	// ReadOnly disable every operation that modifies S3
+	ReadOnly bool
+	// MaxUploadBytes reject uploading files larger than this (default 0, no limit)
+	MaxUploadBytes int64 `min:"0"`
+	// WarnUploadBytes ask before uploading files larger than this (default 0, never ask)
+	WarnUploadBytes int64 `min:"0"`
+	// ConfirmDestructive when to ask before deleting or overwriting: never, bulk-only or always (default always)
+	ConfirmDestructive string `enum:"never,bulk-only,always"`
+	// ACLScanLimit objects checked by acl-scan, 0 for no limit (default 10000), the --limit flag overrides it
+	ACLScanLimit int64 `min:"0"`
+	// ACLScanRate GetObjectAcl requests per second of acl-scan, 0 for no limit (default 100)
+	ACLScanRate int `min:"0"`
+	// Preflight check the permissions a bulk operation needs before it starts, reporting every missing one (default false)
+	Preflight bool
+	// AssumeYes answer yes when asked before deleting or overwriting, set by the --yes flag
+	AssumeYes bool `json:"-"`
+}
+
+// Duration time.Duration that reads "90s" style strings from JSON
+type Duration time.Duration
+
+// MarshalJSON encode Duration as string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON decode Duration from string
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// DefaultConfig return Config with default values
+func DefaultConfig() *Config {
+	return &Config{
+		AWS: AWSConfig{
+			RefreshCredentialsBefore: Duration(5 * time.Minute),
+		},
+		HTTP: HTTPConfig{
+			MaxIdleConns:        100,
+			MaxIdleConnsPerHost: 100,
+			IdleConnTimeout:     Duration(90 * time.Second),
+			TLSHandshakeTimeout: Duration(10 * time.Second),
+		},
+		Performance: PerformanceConfig{
+			Workers:                    10,
+			BucketWorkers:              4,
+			MultipartCopyThreshold:     5 * 1024 * 1024 * 1024,
+			CopyPartSize:               512 * 1024 * 1024,
+			ListPageSize:               1000,
+			MultipartDownloadThreshold: 64 * 1024 * 1024,
+			DownloadPartSize:           8 * 1024 * 1024,
+			UploadPartAttempts:         3,
+			BatchRetryBackoff:          Duration(time.Second),
+			VerifyRate:                 100,
+		},
+		Security: SecurityConfig{
+			ConfirmDestructive: ConfirmAlways,
+			ACLScanLimit:       10000,
+			ACLScanRate:        100,
+		},
+		Cleanup: CleanupConfig{
+			StaleAfter:            Duration(24 * time.Hour),
+			AbortUploadsAfterDays: 7,
+		},
+		Logging: LoggingConfig{
+			History: true,
+		},
+		Consumer: ConsumerConfig{
+			MaxReceives:       5,
+			VisibilityTimeout: Duration(60 * time.Second),
+		},
+		Progress: ProgressConfig{
+			Style:           ProgressBar,
+			RefreshInterval: Duration(100 * time.Millisecond),
+		},
+		Summary: SummaryConfig{
+			Format: "text",
+		},
+		Notify: NotifyConfig{
+			On:            NotifyAlways,
+			Bell:          true,
+			WebhookFormat: "json",
+		},
+		Upload: UploadConfig{
+			Symlinks:     SymlinksSkip,
+			Overwrite:    OverwriteAllow,
+			BackupPrefix: ".s3ry-backup/",
+		},
+		Normalize: NormalizeConfig{
+			CollapseSlashes: true,
+			TrimSpaces:      true,
+		},
+		Timeouts: map[string]Duration{
+			"acl":           Duration(time.Minute),
+			"notifications": Duration(time.Minute),
+		},
+	}
+}
+
+// DefaultConfigPath return path of the config file
+func DefaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "s3ry", "config.json")
+}
+
+// CommandContext return ctx limited by the timeout of command, cancel must be called
+func (c *Config) CommandContext(ctx context.Context, command string) (context.Context, context.CancelFunc) {
+	if d := time.Duration(c.Timeouts[command]); d > 0 {
+		return context.WithTimeout(ctx, d)
+	}
+	return context.WithCancel(ctx)
+}
+
+// configDirFile return path of name next to the config file, empty when there is no config dir
+func configDirFile(name string) string {
+	path := DefaultConfigPath()
+	if path == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(path), name)
+}
+
+// LoadConfig load Config from JSON file, missing values keep their defaults
+//
+// Problems in the file are returned as ConfigErrors together with a usable
+// Config where the invalid values keep their defaults.
+func LoadConfig(path string) (*Config, error) {
+	cfg := DefaultConfig()
+	if path == "" {
+		return cfg, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if errs := decodeConfig(path, b, cfg); len(errs) > 0 {
+		return cfg, errs
+	}
+	return cfg, nil
+}
+
+// SaveConfig save Config to JSON file
+// the file may hold credentials, so it is only readable by the owner
+func SaveConfig(path string, cfg *Config) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(b, '\n'), 0600)
+}
+
+// GlobalDefaultRegion region used when nothing else sets one
+const GlobalDefaultRegion = "us-east-1"
+
+// DefaultRegion return region used before a bucket is selected, the first set of
+// the --region flag, AWS_REGION, AWS.Region, the region of the profile and AWS.FallbackRegion
+func (c *Config) DefaultRegion() string {
+	if c.AWS.RegionFlag != "" {
+		return c.AWS.RegionFlag
+	}
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		return region
+	}
+	if c.AWS.Region != "" {
+		return c.AWS.Region
+	}
+	if region := c.AWS.profileRegion(); region != "" {
+		return region
+	}
+	if c.AWS.FallbackRegion != "" {
+		return c.AWS.FallbackRegion
+	}
+	return GlobalDefaultRegion
+}
+
+// profileRegion return region of the shared config profile, AWS_PROFILE or default when Profile is empty
+func (c AWSConfig) profileRegion() string {
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Profile:           c.Profile,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return ""
+	}
+	return aws.StringValue(sess.Config.Region)
+}
+
+// config return Config of S3ry, DefaultConfig when it was created without one
+func (s S3ry) config() *Config {
+	if s.Config == nil {
+		return DefaultConfig()
+	}
+	return s.Config
+}
+
+// ErrInvalidConfig no client can be built from the config, e.g. of an unknown AWS.CredentialProvider
+var ErrInvalidConfig = errors.New("invalid config")
+
+// CheckConfig return ErrInvalidConfig when no client can be built from cfg, which NewS3ryWithConfig panics on
+func CheckConfig(cfg *Config) error {
+	if _, err := cfg.newSession(cfg.DefaultRegion()); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	return nil
+}
+
+// newSession create session from AWSConfig and HTTPConfig
+func (c *Config) newSession(region string) (*session.Session, error) {
+	awsConfig := aws.Config{
+		Region:     aws.String(region),
+		HTTPClient: c.HTTP.newHTTPClient(),
+	}
+	if c.AWS.Endpoint != "" {
+		awsConfig.Endpoint = aws.String(c.AWS.Endpoint)
+		awsConfig.S3ForcePathStyle = aws.Bool(true)
+	}
+	if err := c.AWS.checkSignatureVersion(); err != nil {
+		return nil, err
+	}
+	creds, err := c.credentials()
+	if err != nil {
+		return nil, err
+	}
+	awsConfig.Credentials = creds
+	opts := session.Options{Config: awsConfig}
+	if c.AWS.Profile != "" {
+		opts.Profile = c.AWS.Profile
+		opts.SharedConfigState = session.SharedConfigEnable
+	}
+	sess, err := session.NewSessionWithOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	sess.Config.Credentials = c.refreshing(sess.Config.Credentials)
+	return sess, nil
+}
+
+// newHTTPClient create http.Client from HTTPConfig
+func (c HTTPConfig) newHTTPClient() *http.Client {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          c.MaxIdleConns,
+		MaxIdleConnsPerHost:   c.MaxIdleConnsPerHost,
+		IdleConnTimeout:       time.Duration(c.IdleConnTimeout),
+		TLSHandshakeTimeout:   time.Duration(c.TLSHandshakeTimeout),
+		ExpectContinueTimeout: 1 * time.Second,
+		// objects stored with Content-Encoding: gzip are downloaded as they are, Upload.Decompress decodes them
+		DisableCompression: true,
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   time.Duration(c.Timeout),
+	}
+}
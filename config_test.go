@@ -0,0 +1,140 @@
+package s3ry
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewS3ryWithConfigTransport(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.HTTP.MaxIdleConns = 10
+	cfg.HTTP.MaxIdleConnsPerHost = 5
+	cfg.HTTP.IdleConnTimeout = Duration(30 * time.Second)
+	cfg.HTTP.Timeout = Duration(time.Minute)
+	cfg.HTTP.TLSHandshakeTimeout = Duration(3 * time.Second)
+	s := NewS3ryWithConfig(ApNortheastOne, cfg)
+
+	client := s.Sess.Config.HTTPClient
+	assert.Equal(t, time.Minute, client.Timeout)
+	transport, ok := client.Transport.(*http.Transport)
+	assert.True(t, ok)
+	assert.Equal(t, 10, transport.MaxIdleConns)
+	assert.Equal(t, 5, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, 30*time.Second, transport.IdleConnTimeout)
+	assert.Equal(t, 3*time.Second, transport.TLSHandshakeTimeout)
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "s3ry")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// missing file returns defaults
+	cfg, err := LoadConfig(filepath.Join(dir, "nothing.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultConfig(), cfg)
+
+	path := filepath.Join(dir, "config.json")
+	json := `{"HTTP": {"MaxIdleConnsPerHost": 32, "IdleConnTimeout": "2m"}}`
+	assert.NoError(t, ioutil.WriteFile(path, []byte(json), 0600))
+	cfg, err = LoadConfig(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 32, cfg.HTTP.MaxIdleConnsPerHost)
+	assert.Equal(t, Duration(2*time.Minute), cfg.HTTP.IdleConnTimeout)
+	assert.Equal(t, 100, cfg.HTTP.MaxIdleConns)
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"HTTP": {"Timeout": "soon"}}`), 0600))
+	_, err = LoadConfig(path)
+	assert.Error(t, err)
+}
+
+func TestLoadConfigReportsFieldAndLine(t *testing.T) {
+	dir, err := ioutil.TempDir("", "s3ry")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.json")
+	json := `{
+  "HTTP": {
+    "MaxIdelConns": 10,
+    "MaxIdleConnsPerHost": -1,
+    "IdleConnTimeout": "2m"
+  },
+  "Security": {
+    "ReadOnly": "yes",
+    "ConfirmDestructive": "sometimes"
+  }
+}`
+	assert.NoError(t, ioutil.WriteFile(path, []byte(json), 0600))
+	cfg, err := LoadConfig(path)
+	errs, ok := err.(ConfigErrors)
+	if !assert.True(t, ok, "%v", err) {
+		return
+	}
+	assert.Equal(t, ConfigErrors{
+		{File: path, Line: 3, Field: "HTTP.MaxIdelConns", Msg: "unknown field"},
+		{File: path, Line: 4, Field: "HTTP.MaxIdleConnsPerHost", Msg: "must be at least 0"},
+		{File: path, Line: 8, Field: "Security.ReadOnly", Msg: `invalid value "yes"`},
+		{File: path, Line: 9, Field: "Security.ConfirmDestructive", Msg: "must be one of never, bulk-only, always"},
+	}, errs)
+	assert.Contains(t, err.Error(), path+":3: HTTP.MaxIdelConns: unknown field")
+
+	// valid values are applied, invalid ones keep their defaults
+	assert.Equal(t, Duration(2*time.Minute), cfg.HTTP.IdleConnTimeout)
+	assert.Equal(t, 100, cfg.HTTP.MaxIdleConnsPerHost)
+	assert.False(t, cfg.Security.ReadOnly)
+	assert.Equal(t, ConfirmAlways, cfg.Security.ConfirmDestructive)
+}
+
+func TestLoadConfigSyntaxError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "s3ry")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("{\n  \"HTTP\": {,\n}"), 0600))
+	cfg, err := LoadConfig(path)
+	errs, ok := err.(ConfigErrors)
+	if assert.True(t, ok, "%v", err) && assert.Len(t, errs, 1) {
+		assert.Equal(t, 2, errs[0].Line)
+	}
+	assert.Equal(t, DefaultConfig(), cfg)
+}
+
+func TestDefaultRegionFallbackChain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "s3ry")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	awsConfig := filepath.Join(dir, "config")
+	assert.NoError(t, ioutil.WriteFile(awsConfig, []byte("[profile work]\nregion = eu-central-1\n\n[profile none]\noutput = json\n"), 0600))
+	for name, value := range map[string]string{
+		"AWS_CONFIG_FILE":             awsConfig,
+		"AWS_SHARED_CREDENTIALS_FILE": filepath.Join(dir, "credentials"),
+		"AWS_REGION":                  "",
+		"AWS_DEFAULT_REGION":          "",
+		"AWS_PROFILE":                 "",
+	} {
+		defer os.Setenv(name, os.Getenv(name))
+		os.Setenv(name, value)
+	}
+
+	cfg := DefaultConfig()
+	assert.Equal(t, GlobalDefaultRegion, cfg.DefaultRegion())
+	cfg.AWS.Profile = "none"
+	cfg.AWS.FallbackRegion = "sa-east-1"
+	assert.Equal(t, "sa-east-1", cfg.DefaultRegion())
+	cfg.AWS.Profile = "work"
+	assert.Equal(t, "eu-central-1", cfg.DefaultRegion())
+	cfg.AWS.Region = "us-west-2"
+	assert.Equal(t, "us-west-2", cfg.DefaultRegion())
+	os.Setenv("AWS_REGION", "ap-southeast-2")
+	assert.Equal(t, "ap-southeast-2", cfg.DefaultRegion())
+	cfg.AWS.RegionFlag = "eu-west-1"
+	assert.Equal(t, "eu-west-1", cfg.DefaultRegion())
+}
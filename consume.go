@@ -0,0 +1,159 @@
+package s3ry
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/seike460/s3ry/internal/consumer"
+)
+
+// ReactionFactory create a reaction of a ReactionConfig
+type ReactionFactory func(s *S3ry, rc ReactionConfig) (consumer.Reaction, error)
+
+// reactionTypes reactions by ReactionConfig.Type
+var reactionTypes = map[string]ReactionFactory{
+	"copy":     copyReaction,
+	"tag":      tagReaction,
+	"log":      logReaction,
+	"restored": restoredReaction,
+}
+
+// RegisterReaction add reaction type name usable in Consumer.Reactions
+func RegisterReaction(name string, factory ReactionFactory) {
+	reactionTypes[name] = factory
+}
+
+// copyReaction copy the object to Bucket under Prefix
+// copying within the same bucket emits ObjectCreated:Copy, so filter events to avoid loops
+func copyReaction(s *S3ry, rc ReactionConfig) (consumer.Reaction, error) {
+	if rc.Bucket == "" {
+		return nil, fmt.Errorf("copy reaction needs a Bucket")
+	}
+	return consumer.ReactionFunc(func(ctx context.Context, r consumer.Record) error {
+		return s.CopyObject(ctx, r.Bucket, r.Key, rc.Bucket, rc.Prefix+r.Key, r.Size)
+	}), nil
+}
+
+// tagReaction set Tags on the object, replacing its tag set
+func tagReaction(s *S3ry, rc ReactionConfig) (consumer.Reaction, error) {
+	if len(rc.Tags) == 0 {
+		return nil, fmt.Errorf("tag reaction needs Tags")
+	}
+	tagging := &s3.Tagging{}
+	for k, v := range rc.Tags {
+		tagging.TagSet = append(tagging.TagSet, &s3.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	return consumer.ReactionFunc(func(ctx context.Context, r consumer.Record) error {
+		b, err := s.forBucket(r.Bucket)
+		if err != nil {
+			return err
+		}
+		_, err = b.Svc.PutObjectTaggingWithContext(ctx, &s3.PutObjectTaggingInput{
+			Bucket:  aws.String(r.Bucket),
+			Key:     aws.String(r.Key),
+			Tagging: tagging,
+		})
+		return err
+	}), nil
+}
+
+// logReaction log the event
+func logReaction(s *S3ry, rc ReactionConfig) (consumer.Reaction, error) {
+	return consumer.ReactionFunc(func(ctx context.Context, r consumer.Record) error {
+		log.Printf("%s s3://%s/%s %d bytes", r.EventName, r.Bucket, r.Key, r.Size)
+		return nil
+	}), nil
+}
+
+// matches check record passes the Events, KeyPrefix and KeySuffix filters
+func (rc ReactionConfig) matches(r consumer.Record) bool {
+	if !strings.HasPrefix(r.Key, rc.KeyPrefix) || !strings.HasSuffix(r.Key, rc.KeySuffix) {
+		return false
+	}
+	if len(rc.Events) == 0 {
+		return true
+	}
+	for _, e := range rc.Events {
+		// accept the s3:ObjectCreated:* form of notification configurations
+		e = strings.TrimSuffix(strings.TrimPrefix(e, "s3:"), "*")
+		if strings.HasPrefix(r.EventName, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// reaction create the reaction of rc, running only for matching records
+func (s *S3ry) reaction(rc ReactionConfig) (consumer.Reaction, error) {
+	factory, ok := reactionTypes[rc.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown reaction type %q", rc.Type)
+	}
+	reaction, err := factory(s, rc)
+	if err != nil {
+		return nil, err
+	}
+	return consumer.ReactionFunc(func(ctx context.Context, r consumer.Record) error {
+		if !rc.matches(r) {
+			return nil
+		}
+		return reaction.React(ctx, r)
+	}), nil
+}
+
+// queueRegion return region of an SQS queue URL, empty when it isn't an AWS one
+func queueRegion(queueURL string) string {
+	u, err := url.Parse(queueURL)
+	if err != nil {
+		return ""
+	}
+	// sqs.<region>.amazonaws.com
+	parts := strings.Split(u.Hostname(), ".")
+	if len(parts) >= 3 && parts[0] == "sqs" {
+		return parts[1]
+	}
+	return ""
+}
+
+// Consume run the reactions of Consumer config for S3 events received from its queue until ctx is done, used by the consume command
+func Consume(ctx context.Context, cfg *Config) error {
+	cc := cfg.Consumer
+	if cc.QueueURL == "" {
+		return fmt.Errorf("Consumer.QueueURL is not set")
+	}
+	if len(cc.Reactions) == 0 {
+		return fmt.Errorf("Consumer.Reactions is empty")
+	}
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	var reactions []consumer.Reaction
+	for _, rc := range cc.Reactions {
+		reaction, err := s.reaction(rc)
+		if err != nil {
+			return err
+		}
+		reactions = append(reactions, reaction)
+	}
+	region := queueRegion(cc.QueueURL)
+	if region == "" {
+		region = cfg.DefaultRegion()
+	}
+	c := &consumer.Consumer{
+		SQS:                sqs.New(s.Sess, aws.NewConfig().WithRegion(region)),
+		QueueURL:           cc.QueueURL,
+		DeadLetterQueueURL: cc.DeadLetterQueueURL,
+		MaxReceives:        cc.MaxReceives,
+		VisibilityTimeout:  time.Duration(cc.VisibilityTimeout),
+		WaitTime:           20 * time.Second,
+		Reactions:          reactions,
+		Errorf:             log.Printf,
+	}
+	c.Run(ctx)
+	return nil
+}
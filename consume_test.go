@@ -0,0 +1,44 @@
+package s3ry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/seike460/s3ry/internal/consumer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReactionConfigMatches(t *testing.T) {
+	r := consumer.Record{EventName: "ObjectCreated:Put", Bucket: "b", Key: "in/a.csv"}
+	assert.True(t, ReactionConfig{}.matches(r))
+	assert.True(t, ReactionConfig{Events: []string{"s3:ObjectCreated:*"}}.matches(r))
+	assert.True(t, ReactionConfig{Events: []string{"ObjectRemoved:*", "ObjectCreated:Put"}, KeyPrefix: "in/", KeySuffix: ".csv"}.matches(r))
+	assert.False(t, ReactionConfig{Events: []string{"ObjectRemoved:*"}}.matches(r))
+	assert.False(t, ReactionConfig{KeyPrefix: "out/"}.matches(r))
+	assert.False(t, ReactionConfig{KeySuffix: ".json"}.matches(r))
+}
+
+func TestQueueRegion(t *testing.T) {
+	assert.Equal(t, "ap-northeast-1", queueRegion("https://sqs.ap-northeast-1.amazonaws.com/123456789012/q"))
+	assert.Equal(t, "", queueRegion("http://localhost:9324/queue/q"))
+}
+
+func TestCopyReaction(t *testing.T) {
+	fake := newFakeS3("src", "dst")
+	fake.put("src", "in/a.csv", "a,b")
+	fake.put("src", "in/b.json", "{}")
+	s, srv := newTestS3ry(DefaultConfig(), fake)
+	defer srv.Close()
+
+	reaction, err := s.reaction(ReactionConfig{Type: "copy", KeySuffix: ".csv", Bucket: "dst", Prefix: "copied/"})
+	if assert.NoError(t, err) {
+		assert.NoError(t, reaction.React(context.Background(), consumer.Record{EventName: "ObjectCreated:Put", Bucket: "src", Key: "in/a.csv", Size: 3}))
+		assert.NoError(t, reaction.React(context.Background(), consumer.Record{EventName: "ObjectCreated:Put", Bucket: "src", Key: "in/b.json", Size: 2}))
+	}
+	assert.Equal(t, []string{"copied/in/a.csv"}, fake.keys("dst"))
+
+	_, err = s.reaction(ReactionConfig{Type: "unknown"})
+	assert.Error(t, err)
+	_, err = s.reaction(ReactionConfig{Type: "copy"})
+	assert.Error(t, err)
+}
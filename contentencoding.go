@@ -0,0 +1,37 @@
+package s3ry
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strings"
+)
+
+// encodingGzip Content-Encoding of uploads compressed by Upload.Compress
+const encodingGzip = "gzip"
+
+// gzipReader return r compressed with gzip as it is read, closing it stops the compression
+func gzipReader(r io.Reader) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		zw := gzip.NewWriter(pw)
+		_, err := io.Copy(zw, r)
+		if err == nil {
+			err = zw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+// decodingReader return r decoded by Content-Encoding encoding, r itself for identity and unknown encodings
+// deflate is the zlib format of HTTP, not raw deflate
+func decodingReader(encoding string, r io.Reader) (io.Reader, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "gzip", "x-gzip":
+		return gzip.NewReader(r)
+	case "deflate":
+		return zlib.NewReader(r)
+	}
+	return r, nil
+}
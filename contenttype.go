@@ -0,0 +1,177 @@
+package s3ry
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// sniffLen bytes read to detect content type, http.DetectContentType reads no more
+const sniffLen = 512
+
+// detectContentType return content type of name by ContentTypes, then by extension,
+// then by sniffing head when given, empty when unknown
+func (c *Config) detectContentType(name string, head []byte) string {
+	ext := strings.ToLower(path.Ext(name))
+	if t, ok := c.ContentTypes[ext]; ok {
+		return t
+	}
+	if t := mime.TypeByExtension(ext); t != "" {
+		return t
+	}
+	if len(head) > 0 {
+		return http.DetectContentType(head)
+	}
+	return ""
+}
+
+// sniff read the head of r for detectContentType and rewind it
+func sniff(r io.ReadSeeker) ([]byte, error) {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return head[:n], nil
+}
+
+// sameMediaType compare content types ignoring parameters like charset
+func sameMediaType(a string, b string) bool {
+	ma, _, err := mime.ParseMediaType(a)
+	if err != nil {
+		return a == b
+	}
+	mb, _, err := mime.ParseMediaType(b)
+	if err != nil {
+		return a == b
+	}
+	return ma == mb
+}
+
+// ContentTypeFix object whose content type doesn't match its extension
+type ContentTypeFix struct {
+	Key      string
+	Current  string
+	Detected string
+}
+
+// ContentTypeSummary result of FixContentTypes
+type ContentTypeSummary struct {
+	Checked    int
+	Mismatched []ContentTypeFix
+	Fixed      int
+	// Failed error of each key which could not be checked or fixed
+	Failed map[string]error
+}
+
+// FixContentTypes find objects under prefix whose content type doesn't match their extension
+// and replace it with CopyObject keeping the other metadata, only reporting them with dryRun
+func (s S3ry) FixContentTypes(ctx context.Context, bucket string, prefix string, dryRun bool) (ContentTypeSummary, error) {
+	summary := ContentTypeSummary{Failed: map[string]error{}}
+	s, err := s.forBucket(bucket)
+	if err != nil {
+		return summary, err
+	}
+	err = s.Svc.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, object := range page.Contents {
+			key := aws.StringValue(object.Key)
+			detected := s.config().detectContentType(key, nil)
+			if detected == "" {
+				continue
+			}
+			summary.Checked++
+			head, err := s.headSource(ctx, s.config().Encryption, bucket, key)
+			if err != nil {
+				summary.Failed[key] = err
+				continue
+			}
+			current := aws.StringValue(head.ContentType)
+			if sameMediaType(current, detected) {
+				continue
+			}
+			summary.Mismatched = append(summary.Mismatched, ContentTypeFix{Key: key, Current: current, Detected: detected})
+			if dryRun {
+				continue
+			}
+			if err := s.replaceContentType(ctx, bucket, key, head, detected); err != nil {
+				summary.Failed[key] = err
+				continue
+			}
+			summary.Fixed++
+		}
+		return true
+	})
+	return summary, err
+}
+
+// replaceContentType copy object onto itself with contentType and the metadata of head
+func (s S3ry) replaceContentType(ctx context.Context, bucket string, key string, head *s3.HeadObjectOutput, contentType string) error {
+	if aws.Int64Value(head.ContentLength) > maxCopySize {
+		return fmt.Errorf("%s is larger than 5GiB, CopyObject can't replace its metadata", key)
+	}
+	input := &s3.CopyObjectInput{
+		Bucket:             aws.String(bucket),
+		Key:                aws.String(key),
+		CopySource:         aws.String(copySource(bucket, key)),
+		MetadataDirective:  aws.String(s3.MetadataDirectiveReplace),
+		ContentType:        aws.String(contentType),
+		ContentEncoding:    head.ContentEncoding,
+		ContentDisposition: head.ContentDisposition,
+		ContentLanguage:    head.ContentLanguage,
+		CacheControl:       head.CacheControl,
+		Metadata:           head.Metadata,
+		StorageClass:       head.StorageClass,
+	}
+	if err := s.config().Encryption.applyCopy(input, head.SSECustomerAlgorithm != nil); err != nil {
+		return err
+	}
+	_, err := s.Svc.CopyObjectWithContext(ctx, input)
+	return err
+}
+
+// FixContentType fix content types under s3:// URI target and print them, used by the fix-content-types command
+func FixContentType(ctx context.Context, cfg *Config, target string, dryRun bool) error {
+	bucket, prefix, err := ParseS3URI(target)
+	if err != nil {
+		return err
+	}
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	if !dryRun {
+		if err := s.preflight(ctx, bucket, prefix, PermListBucket, PermGetObject, PermPutObject); err != nil {
+			return err
+		}
+	}
+	sps(i18nPrinter.Sprintf("Checking content types ..."))
+	summary, err := s.FixContentTypes(ctx, bucket, prefix, dryRun)
+	spe()
+	for _, fix := range summary.Mismatched {
+		fmt.Println(i18nPrinter.Sprintf("%s: %s -> %s", fix.Key, fix.Current, fix.Detected))
+	}
+	var failed []string
+	for key := range summary.Failed {
+		failed = append(failed, key)
+	}
+	sort.Strings(failed)
+	for _, key := range failed {
+		fmt.Println(i18nPrinter.Sprintf("Failed,% s: %s", key, summary.Failed[key].Error()))
+	}
+	fmt.Println(i18nPrinter.Sprintf("Checked: %d, mismatched: %d, fixed: %d", summary.Checked, len(summary.Mismatched), summary.Fixed))
+	if err == nil && len(summary.Failed) > 0 {
+		err = &PartialError{Failed: len(summary.Failed), Op: "fix"}
+	}
+	return err
+}
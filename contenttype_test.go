@@ -0,0 +1,98 @@
+package s3ry
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectContentType(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ContentTypes = map[string]string{".md": "text/markdown", ".json": "application/x-s3ry"}
+	tests := []struct {
+		name string
+		head string
+		want string
+	}{
+		{name: "image.PNG", want: "image/png"},
+		{name: "index.html", want: "text/html; charset=utf-8"},
+		{name: "README.md", want: "text/markdown"},
+		{name: "data.json", want: "application/x-s3ry"},
+		{name: "noext", head: "\x89PNG\r\n\x1a\n", want: "image/png"},
+		{name: "noext", head: "plain words", want: "text/plain; charset=utf-8"},
+		{name: "noext", want: ""},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.want, cfg.detectContentType(test.name, []byte(test.head)), test.name)
+	}
+}
+
+func TestUploadObjectContentType(t *testing.T) {
+	dir, err := ioutil.TempDir("", "s3ry")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	page := filepath.Join(dir, "page.html")
+	assert.NoError(t, ioutil.WriteFile(page, []byte("<p>hi</p>"), 0600))
+	blob := filepath.Join(dir, "blob")
+	assert.NoError(t, ioutil.WriteFile(blob, []byte("%PDF-1.4 "+strings.Repeat("x", 1024)), 0600))
+
+	fake := newFakeS3("bucket")
+	cfg := DefaultConfig()
+	s, srv := newTestS3ry(cfg, fake)
+	defer srv.Close()
+	assert.NoError(t, s.UploadObject("bucket", page))
+	assert.NoError(t, s.UploadObject("bucket", blob))
+	o, _ := fake.get("bucket", strings.TrimPrefix(page, "/"))
+	assert.Equal(t, "text/html; charset=utf-8", o.header.Get("Content-Type"))
+	o, _ = fake.get("bucket", strings.TrimPrefix(blob, "/"))
+	assert.Equal(t, "application/pdf", o.header.Get("Content-Type"))
+	assert.Len(t, o.data, 9+1024, "sniffing must not consume the body")
+
+	// --content-type overrides detection
+	cfg.Flags.ContentType = "text/plain"
+	assert.NoError(t, s.UploadObject("bucket", page))
+	o, _ = fake.get("bucket", strings.TrimPrefix(page, "/"))
+	assert.Equal(t, "text/plain", o.header.Get("Content-Type"))
+}
+
+func TestFixContentTypes(t *testing.T) {
+	fake := newFakeS3("bucket")
+	fake.put("bucket", "site/index.html", "<p>hi</p>")
+	fake.put("bucket", "site/logo.png", "png")
+	fake.put("bucket", "site/data.json", "{}")
+	fake.put("bucket", "site/unknown", "?")
+	for key, contentType := range map[string]string{
+		"site/index.html": "text/html",
+		"site/logo.png":   "binary/octet-stream",
+		"site/data.json":  "text/plain",
+	} {
+		o, _ := fake.get("bucket", key)
+		o.header.Set("Content-Type", contentType)
+		o.header.Set("X-Amz-Meta-Owner", "s3ry")
+	}
+	s, srv := newTestS3ry(DefaultConfig(), fake)
+	defer srv.Close()
+
+	summary, err := s.FixContentTypes(context.Background(), "bucket", "site/", true)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, summary.Checked)
+	assert.Equal(t, []ContentTypeFix{
+		{Key: "site/data.json", Current: "text/plain", Detected: "application/json"},
+		{Key: "site/logo.png", Current: "binary/octet-stream", Detected: "image/png"},
+	}, summary.Mismatched)
+	assert.Equal(t, 0, summary.Fixed)
+	assert.Equal(t, 0, fake.count("COPY"))
+
+	summary, err = s.FixContentTypes(context.Background(), "bucket", "site/", false)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, summary.Fixed)
+	o, _ := fake.get("bucket", "site/logo.png")
+	assert.Equal(t, "image/png", o.header.Get("Content-Type"))
+	assert.Equal(t, "s3ry", o.header.Get("X-Amz-Meta-Owner"))
+	assert.Equal(t, "png", string(o.data))
+}
@@ -0,0 +1,405 @@
+package s3ry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/seike460/s3ry/internal/events"
+	"github.com/seike460/s3ry/internal/worker"
+)
+
+// maxParts max number of parts of a multipart upload
+const maxParts = 10000
+
+// maxCopySize largest object CopyObject can copy
+const maxCopySize = 5 * 1024 * 1024 * 1024
+
+// CopySummary result of CopyPrefix
+type CopySummary struct {
+	Copied int
+	Bytes  int64
+	// Skipped objects copied before an interruption, by the checkpoint resumed from
+	Skipped int
+	// Failed error of each source key which could not be copied
+	Failed map[string]error
+}
+
+// CopyObject copy object server-side, parts are copied when it is larger than MultipartCopyThreshold
+// buckets may be in different regions
+func (s S3ry) CopyObject(ctx context.Context, srcBucket string, srcKey string, dstBucket string, dstKey string, size int64) (err error) {
+	done := s.track("copy", dstBucket, dstKey)
+	defer func() { done(err) }()
+	src, err := s.forBucket(srcBucket)
+	if err != nil {
+		return err
+	}
+	dst, err := s.forBucket(dstBucket)
+	if err != nil {
+		return err
+	}
+	progress := s.progress("copy", dstBucket, dstKey, size)
+	progress(0)
+	defer func() {
+		if err == nil {
+			progress(size)
+		}
+	}()
+	if size > s.config().Performance.MultipartCopyThreshold {
+		return dst.copyParts(ctx, src, srcBucket, srcKey, dstBucket, dstKey, size)
+	}
+	input := &s3.CopyObjectInput{
+		Bucket:     aws.String(dstBucket),
+		Key:        aws.String(dstKey),
+		CopySource: aws.String(copySource(srcBucket, srcKey)),
+	}
+	enc := dst.config().Encryption
+	sourceSSEC := false
+	if enc.Mode == SSEModeC {
+		// the source key is sent only for an SSE-C source, S3 refuses it for others
+		head, err := src.headSource(ctx, enc, srcBucket, srcKey)
+		if err != nil {
+			return err
+		}
+		sourceSSEC = head.SSECustomerAlgorithm != nil
+	}
+	if err := enc.applyCopy(input, sourceSSEC); err != nil {
+		return err
+	}
+	_, err = dst.Svc.CopyObjectWithContext(ctx, input)
+	return err
+}
+
+// copyParts copy object with UploadPartCopy, s is a client of the destination region
+func (s S3ry) copyParts(ctx context.Context, src S3ry, srcBucket string, srcKey string, dstBucket string, dstKey string, size int64) error {
+	enc := s.config().Encryption
+	head, err := src.headSource(ctx, enc, srcBucket, srcKey)
+	if err != nil {
+		return err
+	}
+	// parts are not copied with their metadata, storage class or tags, so set them on the new upload
+	createInput := &s3.CreateMultipartUploadInput{
+		Bucket:                  aws.String(dstBucket),
+		Key:                     aws.String(dstKey),
+		StorageClass:            head.StorageClass,
+		ContentType:             head.ContentType,
+		ContentEncoding:         head.ContentEncoding,
+		ContentDisposition:      head.ContentDisposition,
+		CacheControl:            head.CacheControl,
+		ContentLanguage:         head.ContentLanguage,
+		WebsiteRedirectLocation: head.WebsiteRedirectLocation,
+		Metadata:                head.Metadata,
+	}
+	// HeadObject returns Expires as the header text, an invalid date is dropped
+	if expires, err := http.ParseTime(aws.StringValue(head.Expires)); err == nil {
+		createInput.Expires = aws.Time(expires)
+	}
+	// reading tags needs s3:GetObjectTagging, which copying in one request doesn't
+	tagging, err := src.Svc.GetObjectTaggingWithContext(ctx, &s3.GetObjectTaggingInput{Bucket: aws.String(srcBucket), Key: aws.String(srcKey)}, keepKeyPath)
+	if accessDenied(err) {
+		reporter.println(i18nPrinter.Sprintf("Warning: %s is copied without its tags, reading them was denied", "s3://"+srcBucket+"/"+srcKey))
+		tagging, err = &s3.GetObjectTaggingOutput{}, nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(tagging.TagSet) > 0 {
+		tags := url.Values{}
+		for _, t := range tagging.TagSet {
+			tags.Set(aws.StringValue(t.Key), aws.StringValue(t.Value))
+		}
+		createInput.Tagging = aws.String(tags.Encode())
+	}
+	if err := enc.applyCreateMultipart(createInput); err != nil {
+		return err
+	}
+	upload, err := s.Svc.CreateMultipartUploadWithContext(ctx, createInput)
+	if err != nil {
+		return err
+	}
+
+	partSize := s.config().Performance.CopyPartSize
+	if size/partSize >= maxParts {
+		partSize = size/maxParts + 1
+	}
+	var parts []*s3.CompletedPart
+	for start, number := int64(0), int64(1); start < size; start, number = start+partSize, number+1 {
+		end := start + partSize - 1
+		if end >= size {
+			end = size - 1
+		}
+		input := &s3.UploadPartCopyInput{
+			Bucket:          aws.String(dstBucket),
+			Key:             aws.String(dstKey),
+			UploadId:        upload.UploadId,
+			PartNumber:      aws.Int64(number),
+			CopySource:      aws.String(copySource(srcBucket, srcKey)),
+			CopySourceRange: aws.String(fmt.Sprintf("bytes=%d-%d", start, end)),
+		}
+		if err = enc.applyUploadPartCopy(input, head.SSECustomerAlgorithm != nil); err != nil {
+			break
+		}
+		var out *s3.UploadPartCopyOutput
+		out, err = s.Svc.UploadPartCopyWithContext(ctx, input)
+		if err != nil {
+			break
+		}
+		parts = append(parts, &s3.CompletedPart{ETag: out.CopyPartResult.ETag, PartNumber: aws.Int64(number)})
+	}
+	if err == nil {
+		_, err = s.Svc.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket:          aws.String(dstBucket),
+			Key:             aws.String(dstKey),
+			UploadId:        upload.UploadId,
+			MultipartUpload: &s3.CompletedMultipartUpload{Parts: parts},
+		})
+	}
+	if err != nil {
+		// don't leave parts behind, they are charged until aborted
+		s.Svc.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(dstBucket),
+			Key:      aws.String(dstKey),
+			UploadId: upload.UploadId,
+		})
+	}
+	return err
+}
+
+// destinationKey return key under srcPrefix moved under dstPrefix, a source prefix without its trailing / doesn't double the / of dstPrefix
+func destinationKey(key string, srcPrefix string, dstPrefix string) string {
+	rel := strings.TrimPrefix(key, srcPrefix)
+	if dstPrefix == "" || strings.HasSuffix(dstPrefix, "/") {
+		rel = strings.TrimPrefix(rel, "/")
+	}
+	return dstPrefix + rel
+}
+
+// CopyPrefix copy every object under srcPrefix selected by Filter to dstPrefix keeping the keys relative to the prefix
+// objects are copied concurrently by Performance.Workers, cancelling ctx stops starting new copies
+// with Checkpoints the progress is saved after every listing page and every checkpointEvery objects,
+// so copying the same prefixes again after an interruption resumes from the last checkpoint.
+// Objects which failed are copied again by Performance.BatchRetries once the others are done
+func (s S3ry) CopyPrefix(ctx context.Context, srcBucket string, srcPrefix string, dstBucket string, dstPrefix string) (CopySummary, error) {
+	summary := CopySummary{Failed: map[string]error{}}
+	src, err := s.forBucket(srcBucket)
+	if err != nil {
+		return summary, err
+	}
+	f, err := s.config().objectFilter()
+	if err != nil {
+		return summary, err
+	}
+	job := "cp s3://" + srcBucket + "/" + srcPrefix + " s3://" + dstBucket + "/" + dstPrefix
+	if f != nil {
+		job += " --filter " + f.String()
+	}
+	checkpoint, err := s.loadCheckpoint(job)
+	if err != nil {
+		return summary, err
+	}
+
+	var mu sync.Mutex
+	// sizes size of each failed key, to retry it
+	sizes := map[string]int64{}
+	pool := worker.New(ctx, s.config().Performance.Workers)
+	var submitErr error
+	input := &s3.ListObjectsV2Input{
+		Bucket:  aws.String(srcBucket),
+		Prefix:  aws.String(srcPrefix),
+		MaxKeys: aws.Int64(s.config().Performance.ListPageSize),
+	}
+	if checkpoint.ContinuationToken != "" {
+		input.ContinuationToken = aws.String(checkpoint.ContinuationToken)
+	}
+	for {
+		var page *s3.ListObjectsV2Output
+		page, err = src.Svc.ListObjectsV2WithContext(ctx, input)
+		if err != nil {
+			break
+		}
+		// the page is finished before the next one, so the checkpoint only needs its token
+		var wg sync.WaitGroup
+		for _, object := range page.Contents {
+			if !selected(f, object) {
+				continue
+			}
+			key := aws.StringValue(object.Key)
+			size := aws.Int64Value(object.Size)
+			dstKey := destinationKey(key, srcPrefix, dstPrefix)
+			mu.Lock()
+			done := checkpoint.Processed[key]
+			if done {
+				summary.Skipped++
+				s.Events.Publish(events.Event{Type: events.Skipped, Operation: "copy", Bucket: srcBucket, Key: key})
+			}
+			mu.Unlock()
+			if done {
+				continue
+			}
+			wg.Add(1)
+			submitErr = pool.Submit(func(ctx context.Context) {
+				defer wg.Done()
+				err := s.CopyObject(ctx, srcBucket, key, dstBucket, dstKey, size)
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					summary.Failed[key] = err
+					sizes[key] = size
+					return
+				}
+				summary.Copied++
+				summary.Bytes += size
+				checkpoint.Processed[key] = true
+				if len(checkpoint.Processed)%checkpointEvery == 0 {
+					s.saveCheckpoint(job, checkpoint)
+				}
+			})
+			if submitErr != nil {
+				wg.Done()
+				break
+			}
+		}
+		wg.Wait()
+		if submitErr != nil || ctx.Err() != nil {
+			// keep the checkpoint within the page, the rest of it is copied on resume
+			s.saveCheckpoint(job, checkpoint)
+			break
+		}
+		if !aws.BoolValue(page.IsTruncated) {
+			break
+		}
+		checkpoint = &Checkpoint{ContinuationToken: aws.StringValue(page.NextContinuationToken), Processed: map[string]bool{}}
+		s.saveCheckpoint(job, checkpoint)
+		input.ContinuationToken = page.NextContinuationToken
+	}
+	pool.Wait()
+	if submitErr != nil {
+		return summary, submitErr
+	}
+	if err != nil {
+		return summary, err
+	}
+	if err := ctx.Err(); err != nil {
+		return summary, err
+	}
+	s.deleteCheckpoint(job)
+	err = s.retryFailed(ctx, summary.Failed, func(ctx context.Context, key string) error {
+		if err := s.CopyObject(ctx, srcBucket, key, dstBucket, destinationKey(key, srcPrefix, dstPrefix), sizes[key]); err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		summary.Copied++
+		summary.Bytes += sizes[key]
+		return nil
+	})
+	return summary, err
+}
+
+// loadCheckpoint return checkpoint of job, empty without Checkpoints or a saved checkpoint
+func (s S3ry) loadCheckpoint(job string) (*Checkpoint, error) {
+	if s.Checkpoints != nil {
+		c, err := s.Checkpoints.Load(job)
+		if err != nil || c != nil {
+			if c != nil && c.Processed == nil {
+				c.Processed = map[string]bool{}
+			}
+			return c, err
+		}
+	}
+	return &Checkpoint{Processed: map[string]bool{}}, nil
+}
+
+// saveCheckpoint persist checkpoint of job
+// checkpoints are best effort like the upload journal, a failed write only restarts more of the job
+func (s S3ry) saveCheckpoint(job string, c *Checkpoint) {
+	if s.Checkpoints != nil {
+		s.Checkpoints.Save(job, c)
+	}
+}
+
+// deleteCheckpoint forget checkpoint of finished job
+func (s S3ry) deleteCheckpoint(job string) {
+	if s.Checkpoints != nil {
+		s.Checkpoints.Delete(job)
+	}
+}
+
+// Copy copy s3:// URI src to dst and print the result, used by the cp command
+// with recursive every object under the src prefix is copied, showing the progress of them all,
+// and verbose also prints each copied object
+func Copy(ctx context.Context, cfg *Config, src string, dst string, recursive bool, verbose bool) error {
+	srcBucket, srcKey, err := ParseS3URI(src)
+	if err != nil {
+		return err
+	}
+	dstBucket, dstKey, err := ParseS3URI(dst)
+	if err != nil {
+		return err
+	}
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	s.Events = events.NewBus()
+	defer s.Events.Close()
+
+	if !recursive {
+		s.Events.Subscribe(spinnerProgress)
+		if dstKey == "" || strings.HasSuffix(dstKey, "/") {
+			dstKey += srcKey[strings.LastIndex(srcKey, "/")+1:]
+		}
+		src, err := s.forBucket(srcBucket)
+		if err != nil {
+			return err
+		}
+		head, err := src.Svc.HeadObjectWithContext(ctx, &s3.HeadObjectInput{Bucket: aws.String(srcBucket), Key: aws.String(srcKey)})
+		if err != nil {
+			return err
+		}
+		if err := s.CopyObject(ctx, srcBucket, srcKey, dstBucket, dstKey, aws.Int64Value(head.ContentLength)); err != nil {
+			return err
+		}
+		fmt.Println(i18nPrinter.Sprintf("Copied object,% s", "s3://"+dstBucket+"/"+dstKey))
+		return nil
+	}
+
+	if err := s.preflight(ctx, srcBucket, srcKey, PermListBucket, PermGetObject); err != nil {
+		return err
+	}
+	if err := s.preflight(ctx, dstBucket, dstKey, PermPutObject); err != nil {
+		return err
+	}
+	if err := cfg.confirmDestructive(true, i18nPrinter.Sprintf("Copy every object under% s to% s? Existing objects are overwritten, [Yy] / [Nn]", src, dst)); err != nil {
+		return err
+	}
+	stop := events.Aggregate(s.Events, "copy")
+	report := events.Collect(s.Events)
+	s.Events.Subscribe(spinnerSummary)
+	if verbose {
+		s.Events.Subscribe(func(e events.Event) {
+			if e.Type == events.Completed {
+				reporter.println(i18nPrinter.Sprintf("Copied object,% s", "s3://"+e.Bucket+"/"+e.Key))
+			}
+		})
+	}
+	sps(i18nPrinter.Sprintf("Copying objects ..."))
+	if dir := defaultCheckpointDir(); dir != "" {
+		s.Checkpoints = FileCheckpointStore{Dir: dir}
+	}
+	summary, err := s.CopyPrefix(ctx, srcBucket, srcKey, dstBucket, dstKey)
+	stop()
+	spe()
+	if werr := cfg.writeReport(report(), os.Stdout); werr != nil && err == nil {
+		err = werr
+	}
+	if len(summary.Failed) > 0 {
+		if err == nil {
+			err = &PartialError{Failed: len(summary.Failed), Op: "copy"}
+		}
+	}
+	return err
+}
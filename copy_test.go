@@ -0,0 +1,189 @@
+package s3ry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/seike460/s3ry/internal/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCopyPrefixPreservesStructure(t *testing.T) {
+	fake := newFakeS3("src", "dst")
+	fake.put("src", "logs/2020/a.txt", "a")
+	fake.put("src", "logs/2020/01/b.txt", "bb")
+	fake.put("src", "logs/c.txt", "ccc")
+	fake.put("src", "other/d.txt", "d")
+	s, srv := newTestS3ry(DefaultConfig(), fake)
+	defer srv.Close()
+
+	summary, err := s.CopyPrefix(context.Background(), "src", "logs/", "dst", "backup/logs/")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, summary.Copied)
+	assert.Equal(t, int64(6), summary.Bytes)
+	assert.Empty(t, summary.Failed)
+	assert.Equal(t, []string{"backup/logs/2020/01/b.txt", "backup/logs/2020/a.txt", "backup/logs/c.txt"}, fake.keys("dst"))
+	o, _ := fake.get("dst", "backup/logs/2020/01/b.txt")
+	assert.Equal(t, "bb", string(o.data))
+
+	// a source prefix without its trailing / doesn't double the / of the destination
+	_, err = s.CopyPrefix(context.Background(), "src", "other", "dst", "copy/")
+	assert.NoError(t, err)
+	_, ok := fake.get("dst", "copy/d.txt")
+	assert.True(t, ok, "%v", fake.keys("dst"))
+}
+
+func TestDestinationKey(t *testing.T) {
+	assert.Equal(t, "out/file", destinationKey("in/file", "in/", "out/"))
+	assert.Equal(t, "out/file", destinationKey("in/file", "in", "out/"))
+	assert.Equal(t, "out/file", destinationKey("in/file", "in", "out"))
+	assert.Equal(t, "file", destinationKey("in/file", "in", ""))
+	assert.Equal(t, "out/in/file", destinationKey("in/file", "", "out/"))
+}
+
+func TestCopyPrefixAggregatesProgress(t *testing.T) {
+	fake := newFakeS3("src", "dst")
+	for i := 0; i < 20; i++ {
+		fake.put("src", fmt.Sprintf("p/%02d", i), strings.Repeat("x", i))
+	}
+	s, srv := newTestS3ry(DefaultConfig(), fake)
+	defer srv.Close()
+	s.Events = events.NewBus()
+	a := events.NewAggregator("copy")
+	s.Events.Subscribe(func(e events.Event) { a.Add(e) })
+
+	_, err := s.CopyPrefix(context.Background(), "src", "p/", "dst", "q/")
+	s.Events.Close()
+	assert.NoError(t, err)
+	summary := a.Summary()
+	assert.Equal(t, 20, summary.Objects)
+	assert.Equal(t, 20, summary.Done)
+	assert.Equal(t, int64(190), summary.Bytes)
+	assert.Equal(t, int64(190), summary.Total)
+}
+
+func TestCopyObjectMultipart(t *testing.T) {
+	fake := newFakeS3("src", "dst")
+	fake.put("src", "big.bin", strings.Repeat("0123456789", 1024*1024))
+	cfg := DefaultConfig()
+	cfg.Performance.MultipartCopyThreshold = 5 * 1024 * 1024
+	cfg.Performance.CopyPartSize = 5 * 1024 * 1024
+	s, srv := newTestS3ry(cfg, fake)
+	defer srv.Close()
+
+	assert.NoError(t, s.CopyObject(context.Background(), "src", "big.bin", "dst", "big.bin", 10*1024*1024))
+	assert.Equal(t, 2, fake.count("UPLOAD_PART_COPY"))
+	assert.Equal(t, 0, fake.count("COPY"))
+	src, _ := fake.get("src", "big.bin")
+	dst, _ := fake.get("dst", "big.bin")
+	assert.Equal(t, src.data, dst.data)
+}
+
+func TestCopyObjectMultipartKeepsTags(t *testing.T) {
+	fake := newFakeS3("src", "dst")
+	fake.put("src", "big.bin", "data")
+	o, _ := fake.get("src", "big.bin")
+	o.header.Set("X-Amz-Tagging", "s3ry-expires=2026-10-16&team=a")
+	cfg := DefaultConfig()
+	cfg.Performance.MultipartCopyThreshold = 1
+	s, srv := newTestS3ry(cfg, fake)
+	defer srv.Close()
+
+	assert.NoError(t, s.CopyObject(context.Background(), "src", "big.bin", "dst", "big.bin", 4))
+	assert.Equal(t, 1, fake.count("UPLOAD_PART_COPY"))
+	dst, _ := fake.get("dst", "big.bin")
+	assert.Equal(t, "s3ry-expires=2026-10-16&team=a", dst.header.Get("X-Amz-Tagging"))
+
+	// re-encrypting in parts keeps them too
+	cfg.Flags.Encryption = EncryptionConfig{Mode: SSEModeS3}
+	copied, err := s.ReencryptObject(context.Background(), "dst", "big.bin")
+	assert.NoError(t, err)
+	assert.True(t, copied)
+	assert.Equal(t, 2, fake.count("UPLOAD_PART_COPY"))
+	dst, _ = fake.get("dst", "big.bin")
+	assert.Equal(t, "s3ry-expires=2026-10-16&team=a", dst.header.Get("X-Amz-Tagging"))
+	assert.Equal(t, "AES256", dst.header.Get("X-Amz-Server-Side-Encryption"))
+}
+
+func TestCopyObjectMultipartKeepsHeaders(t *testing.T) {
+	fake := newFakeS3("src", "dst")
+	fake.put("src", "big.bin", "data")
+	o, _ := fake.get("src", "big.bin")
+	headers := map[string]string{
+		"Content-Language":                "ja",
+		"Expires":                         "Thu, 15 Oct 2026 12:00:00 GMT",
+		"X-Amz-Website-Redirect-Location": "/other.html",
+	}
+	for k, v := range headers {
+		o.header.Set(k, v)
+	}
+	o.header.Set("X-Amz-Tagging", "team=a")
+	cfg := DefaultConfig()
+	cfg.Performance.MultipartCopyThreshold = 1
+	s, srv := newTestS3ry(cfg, fake)
+	defer srv.Close()
+
+	assert.NoError(t, s.CopyObject(context.Background(), "src", "big.bin", "dst", "big.bin", 4))
+	dst, _ := fake.get("dst", "big.bin")
+	for k, v := range headers {
+		assert.Equal(t, v, dst.header.Get(k), k)
+	}
+
+	// without s3:GetObjectTagging the object is copied without its tags
+	fake.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method == http.MethodGet && has(r.URL.Query(), "tagging") {
+			writeFakeError(w, http.StatusForbidden, "AccessDenied")
+			return true
+		}
+		return false
+	}
+	assert.NoError(t, s.CopyObject(context.Background(), "src", "big.bin", "dst", "untagged.bin", 4))
+	dst, ok := fake.get("dst", "untagged.bin")
+	if assert.True(t, ok) {
+		assert.Empty(t, dst.header.Get("X-Amz-Tagging"))
+	}
+}
+
+func TestCopyPrefixCancel(t *testing.T) {
+	fake := newFakeS3("src", "dst")
+	for _, key := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		fake.put("src", "p/"+key, key)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var copies int32
+	fake.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Header.Get("X-Amz-Copy-Source") != "" && atomic.AddInt32(&copies, 1) == 2 {
+			cancel()
+		}
+		return false
+	}
+	cfg := DefaultConfig()
+	cfg.Performance.Workers = 1
+	s, srv := newTestS3ry(cfg, fake)
+	defer srv.Close()
+
+	summary, err := s.CopyPrefix(ctx, "src", "p/", "dst", "q/")
+	assert.Equal(t, context.Canceled, err)
+	assert.True(t, summary.Copied < 8, "copied %d", summary.Copied)
+	assert.True(t, len(fake.keys("dst")) <= 2, "%v", fake.keys("dst"))
+}
+
+func TestParseS3URI(t *testing.T) {
+	bucket, key, err := ParseS3URI("s3://bucket/dir/key.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "bucket", bucket)
+	assert.Equal(t, "dir/key.txt", key)
+
+	bucket, key, err = ParseS3URI("s3://bucket")
+	assert.NoError(t, err)
+	assert.Equal(t, "bucket", bucket)
+	assert.Equal(t, "", key)
+
+	_, _, err = ParseS3URI("bucket/key")
+	assert.Error(t, err)
+}
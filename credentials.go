@@ -0,0 +1,175 @@
+package s3ry
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+)
+
+// CredentialProvider source of the AWS credentials of every client, selected by AWS.CredentialProvider
+type CredentialProvider interface {
+	// Credentials return credentials for cfg, nil for the AWS SDK default chain
+	Credentials(cfg *Config) (*credentials.Credentials, error)
+}
+
+// CredentialProviderFunc CredentialProvider of a function
+type CredentialProviderFunc func(cfg *Config) (*credentials.Credentials, error)
+
+// Credentials call f
+func (f CredentialProviderFunc) Credentials(cfg *Config) (*credentials.Credentials, error) {
+	return f(cfg)
+}
+
+// defaultCredentialProvider name of the provider used when AWS.CredentialProvider is empty
+const defaultCredentialProvider = "default"
+
+// credentialProviders providers by AWS.CredentialProvider
+var credentialProviders = map[string]CredentialProvider{
+	defaultCredentialProvider: CredentialProviderFunc(sdkCredentials),
+}
+
+// RegisterCredentialProvider add provider usable as AWS.CredentialProvider, e.g. one reading a secret manager
+func RegisterCredentialProvider(name string, provider CredentialProvider) {
+	credentialProviders[name] = provider
+}
+
+// sdkCredentials AWS.AccessKeyID or the SSO profile, otherwise the SDK default chain with AWS.Profile
+func sdkCredentials(c *Config) (*credentials.Credentials, error) {
+	if c.AWS.Profile != "" {
+		// the SDK doesn't read sso_* settings
+		p, err := loadSSOProfile(sharedConfigFilename(), c.AWS.Profile)
+		if err == nil {
+			provider, err := newSSOProvider(c, p, os.Stderr)
+			if err != nil {
+				return nil, err
+			}
+			return credentials.NewCredentials(provider), nil
+		}
+		if err != ErrNotSSOProfile && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	if c.AWS.AccessKeyID != "" {
+		return credentials.NewStaticCredentials(c.AWS.AccessKeyID, c.AWS.SecretAccessKey, ""), nil
+	}
+	return nil, nil
+}
+
+// credentials return credentials of the configured provider, nil for the SDK default chain
+func (c *Config) credentials() (*credentials.Credentials, error) {
+	name := c.AWS.CredentialProvider
+	if name == "" {
+		name = defaultCredentialProvider
+	}
+	provider, ok := credentialProviders[name]
+	if !ok {
+		return nil, fmt.Errorf("AWS.CredentialProvider: unknown provider %q", name)
+	}
+	return provider.Credentials(c)
+}
+
+// credentialRefreshRetry wait after a failed early refresh before trying again, while the credentials are still valid
+const credentialRefreshRetry = time.Minute
+
+// refreshingProvider provider refreshing credentials before they expire instead of when they have, so a long
+// operation doesn't sign requests with credentials lapsing while they are sent
+// warnings go to out when the credentials can't be refreshed before they expire
+type refreshingProvider struct {
+	creds *credentials.Credentials
+	// before time before the expiry credentials are refreshed
+	before time.Duration
+	out    io.Writer
+	now    func() time.Time
+
+	mu       sync.Mutex
+	value    credentials.Value
+	expires  time.Time
+	failedAt time.Time
+	// warned expiry a warning was written for, so it is written once
+	warned time.Time
+}
+
+// newRefreshingProvider create refreshingProvider of creds
+func newRefreshingProvider(creds *credentials.Credentials, before time.Duration, out io.Writer) *refreshingProvider {
+	return &refreshingProvider{creds: creds, before: before, out: out, now: time.Now}
+}
+
+// Retrieve get credentials, forcing creds to refresh when they expire within before
+// when refreshing fails the current credentials are kept until they expire
+func (p *refreshingProvider) Retrieve() (credentials.Value, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	early := p.value.AccessKeyID != "" && !p.expires.IsZero() && now.Before(p.expires)
+	if early {
+		p.creds.Expire()
+	}
+	v, err := p.creds.Get()
+	if err != nil {
+		if early {
+			p.failedAt = now
+			p.warn(i18nPrinter.Sprintf("Credentials expiring at %s could not be refreshed, requests after then will fail: %s",
+				p.expires.Format(time.RFC3339), err.Error()))
+			return p.value, nil
+		}
+		return v, err
+	}
+	p.value = v
+	p.expires, err = p.creds.ExpiresAt()
+	if err != nil {
+		// the provider doesn't tell, creds still refreshes them once expired
+		p.expires = time.Time{}
+	}
+	p.failedAt = time.Time{}
+	if !p.expires.IsZero() && now.Add(p.before).After(p.expires) {
+		// the source hands out the same credentials until closer to their expiry, ask it again later
+		p.failedAt = now
+	}
+	return v, nil
+}
+
+// warn write msg once for the current expiry
+func (p *refreshingProvider) warn(msg string) {
+	if p.warned.Equal(p.expires) {
+		return
+	}
+	p.warned = p.expires
+	fmt.Fprintln(p.out, msg)
+}
+
+// IsExpired report whether the credentials expire within before, or have expired when their expiry is unknown
+func (p *refreshingProvider) IsExpired() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.value.AccessKeyID == "" {
+		return true
+	}
+	if p.expires.IsZero() {
+		return p.creds.IsExpired()
+	}
+	now := p.now()
+	if !now.Before(p.expires) {
+		return true
+	}
+	return now.Add(p.before).After(p.expires) && now.Sub(p.failedAt) >= credentialRefreshRetry
+}
+
+// ExpiresAt return expiry of the current credentials, zero when unknown
+func (p *refreshingProvider) ExpiresAt() time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.expires
+}
+
+// refreshing return creds refreshed AWS.RefreshCredentialsBefore their expiry
+// anonymous credentials are kept as they are, the signer recognizes them
+func (c *Config) refreshing(creds *credentials.Credentials) *credentials.Credentials {
+	if creds == nil || creds == credentials.AnonymousCredentials || c.AWS.RefreshCredentialsBefore <= 0 {
+		return creds
+	}
+	return credentials.NewCredentials(newRefreshingProvider(creds, time.Duration(c.AWS.RefreshCredentialsBefore), os.Stderr))
+}
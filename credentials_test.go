@@ -0,0 +1,139 @@
+package s3ry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCredentialProvider(t *testing.T) {
+	fake := newFakeS3("bucket")
+	var mu sync.Mutex
+	var authorization string
+	fake.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		mu.Lock()
+		authorization = r.Header.Get("Authorization")
+		mu.Unlock()
+		return false
+	}
+	cfg, done := newFakeEndpoint(t, fake)
+	defer done()
+	defer delete(credentialProviders, "vault")
+	calls := 0
+	RegisterCredentialProvider("vault", CredentialProviderFunc(func(c *Config) (*credentials.Credentials, error) {
+		calls++
+		return credentials.NewStaticCredentials("VAULTKEY", "VAULTSECRET", "TOKEN"), nil
+	}))
+	cfg.AWS.CredentialProvider = "vault"
+
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	_, err := s.ListObjectItems(context.Background(), "bucket", "")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, calls)
+	mu.Lock()
+	assert.True(t, strings.Contains(authorization, "Credential=VAULTKEY/"), authorization)
+	mu.Unlock()
+
+	// the default provider uses the static keys of the config
+	cfg.AWS.CredentialProvider = ""
+	s = NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	_, err = s.ListObjectItems(context.Background(), "bucket", "")
+	assert.NoError(t, err)
+	mu.Lock()
+	assert.True(t, strings.Contains(authorization, "Credential=AKID/"), authorization)
+	mu.Unlock()
+
+	cfg.AWS.CredentialProvider = "missing"
+	_, err = cfg.newSession(cfg.DefaultRegion())
+	assert.Error(t, err)
+	// checked before building a client, which would panic
+	err = CheckConfig(cfg)
+	assert.True(t, errors.Is(err, ErrInvalidConfig), err)
+	assert.Equal(t, ExitUsage, ExitCode(err))
+	cfg.AWS.CredentialProvider = ""
+	assert.NoError(t, CheckConfig(cfg))
+}
+
+// clockProvider provider of credentials valid for ttl on a fake clock
+type clockProvider struct {
+	credentials.Expiry
+	now       *time.Time
+	ttl       time.Duration
+	retrieves int
+	fail      bool
+}
+
+func (p *clockProvider) Retrieve() (credentials.Value, error) {
+	if p.fail {
+		return credentials.Value{}, errors.New("no source")
+	}
+	p.retrieves++
+	p.SetExpiration(p.now.Add(p.ttl), 0)
+	return credentials.Value{AccessKeyID: "AKID" + strings.Repeat("I", p.retrieves), SecretAccessKey: "SECRET", ProviderName: "clock"}, nil
+}
+
+func newClockCredentials(ttl time.Duration) (*credentials.Credentials, *clockProvider, *refreshingProvider, *time.Time, *bytes.Buffer) {
+	now := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	inner := &clockProvider{now: &now, ttl: ttl}
+	inner.CurrentTime = func() time.Time { return now }
+	out := &bytes.Buffer{}
+	p := newRefreshingProvider(credentials.NewCredentials(inner), 5*time.Minute, out)
+	p.now = func() time.Time { return now }
+	return credentials.NewCredentials(p), inner, p, &now, out
+}
+
+func TestRefreshingCredentials(t *testing.T) {
+	creds, inner, p, now, out := newClockCredentials(15 * time.Minute)
+
+	// a request every minute of an operation taking an hour never signs with credentials about to expire
+	start := *now
+	for *now = start; now.Before(start.Add(time.Hour)); *now = now.Add(time.Minute) {
+		_, err := creds.Get()
+		assert.NoError(t, err)
+		assert.True(t, p.ExpiresAt().Sub(*now) > 4*time.Minute, now.Sub(start))
+	}
+	// refreshed after 11, 22, 33, 44 and 55 minutes
+	assert.Equal(t, 6, inner.retrieves)
+	assert.Empty(t, out.String())
+
+	expires, err := creds.ExpiresAt()
+	assert.NoError(t, err)
+	assert.Equal(t, start.Add(70*time.Minute), expires)
+}
+
+func TestRefreshingCredentialsWarnsWhenRefreshFails(t *testing.T) {
+	creds, inner, _, now, out := newClockCredentials(15 * time.Minute)
+	start := *now
+	v, err := creds.Get()
+	assert.NoError(t, err)
+	inner.fail = true
+
+	// the credentials are kept until they expire
+	for *now = start.Add(11 * time.Minute); now.Before(start.Add(15 * time.Minute)); *now = now.Add(30 * time.Second) {
+		got, err := creds.Get()
+		assert.NoError(t, err)
+		assert.Equal(t, v, got)
+	}
+	assert.Equal(t, 1, strings.Count(out.String(), "could not be refreshed"))
+	assert.Contains(t, out.String(), "2026-01-02T00:15:00Z")
+
+	_, err = creds.Get()
+	assert.Error(t, err)
+}
+
+func TestRefreshingKeepsAnonymous(t *testing.T) {
+	cfg := DefaultConfig()
+	assert.True(t, cfg.refreshing(credentials.AnonymousCredentials) == credentials.AnonymousCredentials)
+	static := credentials.NewStaticCredentials("AKID", "SECRET", "")
+	assert.False(t, cfg.refreshing(static) == static)
+	cfg.AWS.RefreshCredentialsBefore = 0
+	assert.True(t, cfg.refreshing(static) == static)
+}
@@ -0,0 +1,246 @@
+package s3ry
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/seike460/s3ry/internal/worker"
+)
+
+// Formats of directory manifests
+const (
+	ManifestJSON = "json"
+	// ManifestSHA256Sum lines of "hash  path" like sha256sum, without sizes
+	ManifestSHA256Sum = "sha256sum"
+)
+
+// ErrManifestMismatch directory doesn't match its manifest
+var ErrManifestMismatch = errors.New("directory doesn't match the manifest")
+
+// ManifestEntry file of a directory manifest
+type ManifestEntry struct {
+	// Path slash separated path relative to the directory
+	Path string
+	// Size -1 when the manifest has no sizes
+	Size int64
+	// SHA256 hex digest of the content
+	SHA256 string
+}
+
+// DirManifest files of a directory sorted by path, the same for the same content
+type DirManifest struct {
+	Files []ManifestEntry
+}
+
+// ManifestDiff difference of a directory from its manifest
+type ManifestDiff struct {
+	Added    []string
+	Removed  []string
+	Modified []string
+}
+
+// Empty check the directory matches the manifest
+func (d ManifestDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
+
+// hashFile return size and hex SHA-256 of file path
+func hashFile(path string) (int64, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return 0, "", err
+	}
+	return n, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// HashDirectory hash every regular file under dir by workers concurrently
+// symbolic links and other special files are skipped
+func HashDirectory(ctx context.Context, dir string, workers int) (*DirManifest, error) {
+	var paths []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			paths = append(paths, path)
+		}
+		return ctx.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	m := &DirManifest{Files: make([]ManifestEntry, len(paths))}
+	var mu sync.Mutex
+	var firstErr error
+	pool := worker.New(ctx, workers)
+	for i, path := range paths {
+		i, path := i, path
+		if err := pool.Submit(func(ctx context.Context) {
+			size, sum, err := hashFile(path)
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+				return
+			}
+			rel, _ := filepath.Rel(dir, path)
+			m.Files[i] = ManifestEntry{Path: filepath.ToSlash(rel), Size: size, SHA256: sum}
+		}); err != nil {
+			mu.Lock()
+			firstErr = err
+			mu.Unlock()
+			break
+		}
+	}
+	pool.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	sort.Slice(m.Files, func(a, b int) bool {
+		return m.Files[a].Path < m.Files[b].Path
+	})
+	return m, nil
+}
+
+// Write write manifest to w in format
+func (m *DirManifest) Write(w io.Writer, format string) error {
+	switch format {
+	case "", ManifestJSON:
+		b, err := json.MarshalIndent(m, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(b))
+		return err
+	case ManifestSHA256Sum:
+		for _, f := range m.Files {
+			if _, err := fmt.Fprintf(w, "%s  %s\n", f.SHA256, f.Path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown manifest format %q, use json or sha256sum", format)
+}
+
+// ReadDirManifest read manifest written by Write in either format
+func ReadDirManifest(r io.Reader) (*DirManifest, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	m := &DirManifest{}
+	if trimmed := bytes.TrimSpace(b); len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := json.Unmarshal(trimmed, m); err != nil {
+			return nil, fmt.Errorf("invalid manifest: %v", err)
+		}
+		return m, nil
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		parts := strings.SplitN(text, "  ", 2)
+		if len(parts) != 2 || len(parts[0]) != sha256.Size*2 {
+			return nil, fmt.Errorf("invalid manifest line %d, use \"sha256  path\"", line)
+		}
+		m.Files = append(m.Files, ManifestEntry{Path: parts[1], Size: -1, SHA256: strings.ToLower(parts[0])})
+	}
+	return m, scanner.Err()
+}
+
+// Compare return how current differs from m
+func (m *DirManifest) Compare(current *DirManifest) ManifestDiff {
+	var diff ManifestDiff
+	expected := map[string]ManifestEntry{}
+	for _, f := range m.Files {
+		expected[f.Path] = f
+	}
+	for _, f := range current.Files {
+		e, ok := expected[f.Path]
+		if !ok {
+			diff.Added = append(diff.Added, f.Path)
+			continue
+		}
+		delete(expected, f.Path)
+		if e.SHA256 != f.SHA256 || (e.Size >= 0 && e.Size != f.Size) {
+			diff.Modified = append(diff.Modified, f.Path)
+		}
+	}
+	for path := range expected {
+		diff.Removed = append(diff.Removed, path)
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Modified)
+	return diff
+}
+
+// Manifest print manifest of local directory dir in format, used by the manifest command
+func Manifest(ctx context.Context, cfg *Config, dir string, format string, w io.Writer) error {
+	if format != "" && format != ManifestJSON && format != ManifestSHA256Sum {
+		return fmt.Errorf("unknown manifest format %q, use json or sha256sum", format)
+	}
+	m, err := HashDirectory(ctx, dir, cfg.Performance.Workers)
+	if err != nil {
+		return err
+	}
+	return m.Write(w, format)
+}
+
+// VerifyManifest check local directory dir against the manifest file and print the differences, used by manifest --verify
+// a directory which differs returns ErrManifestMismatch
+func VerifyManifest(ctx context.Context, cfg *Config, dir string, manifest string, w io.Writer) error {
+	f, err := os.Open(manifest)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	expected, err := ReadDirManifest(f)
+	if err != nil {
+		return err
+	}
+	current, err := HashDirectory(ctx, dir, cfg.Performance.Workers)
+	if err != nil {
+		return err
+	}
+	diff := expected.Compare(current)
+	for _, path := range diff.Added {
+		fmt.Fprintln(w, i18nPrinter.Sprintf("added    %s", path))
+	}
+	for _, path := range diff.Removed {
+		fmt.Fprintln(w, i18nPrinter.Sprintf("removed  %s", path))
+	}
+	for _, path := range diff.Modified {
+		fmt.Fprintln(w, i18nPrinter.Sprintf("modified %s", path))
+	}
+	if !diff.Empty() {
+		return ErrManifestMismatch
+	}
+	fmt.Fprintln(w, i18nPrinter.Sprintf("OK, %d files match", len(current.Files)))
+	return nil
+}
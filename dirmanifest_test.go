@@ -0,0 +1,91 @@
+package s3ry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newManifestDir create directory with files by slash separated path
+func newManifestDir(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "s3ry-manifest")
+	assert.NoError(t, err)
+	for path, content := range files {
+		path = filepath.Join(dir, filepath.FromSlash(path))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+	}
+	return dir
+}
+
+func TestHashDirectoryDeterministic(t *testing.T) {
+	files := map[string]string{"a.txt": "hello", "sub/b.txt": "world", "sub/deep/c": ""}
+	for i := 0; i < 20; i++ {
+		files[fmt.Sprintf("many/%02d", i)] = fmt.Sprint(i)
+	}
+	dir := newManifestDir(t, files)
+	defer os.RemoveAll(dir)
+
+	var first bytes.Buffer
+	m, err := HashDirectory(context.Background(), dir, 8)
+	assert.NoError(t, err)
+	assert.NoError(t, m.Write(&first, ManifestJSON))
+	for i := 0; i < 5; i++ {
+		var again bytes.Buffer
+		m, err := HashDirectory(context.Background(), dir, 8)
+		assert.NoError(t, err)
+		assert.NoError(t, m.Write(&again, ManifestJSON))
+		assert.Equal(t, first.String(), again.String())
+	}
+	assert.Len(t, m.Files, 23)
+	assert.Equal(t, ManifestEntry{Path: "a.txt", Size: 5, SHA256: "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"}, m.Files[0])
+	assert.Equal(t, "sub/deep/c", m.Files[22].Path)
+}
+
+func TestVerifyManifest(t *testing.T) {
+	dir := newManifestDir(t, map[string]string{"keep": "same", "change": "before", "remove": "gone", "sub/keep": "same"})
+	defer os.RemoveAll(dir)
+	out, err := ioutil.TempDir("", "s3ry-manifest-out")
+	assert.NoError(t, err)
+	defer os.RemoveAll(out)
+	cfg := DefaultConfig()
+
+	for _, format := range []string{ManifestJSON, ManifestSHA256Sum} {
+		t.Run(format, func(t *testing.T) {
+			var b bytes.Buffer
+			assert.NoError(t, Manifest(context.Background(), cfg, dir, format, &b))
+			manifest := filepath.Join(out, "manifest."+format)
+			assert.NoError(t, ioutil.WriteFile(manifest, b.Bytes(), 0600))
+
+			var result bytes.Buffer
+			assert.NoError(t, VerifyManifest(context.Background(), cfg, dir, manifest, &result))
+			assert.Equal(t, "OK, 4 files match\n", result.String())
+		})
+	}
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "change"), []byte("after!"), 0600))
+	assert.NoError(t, os.Remove(filepath.Join(dir, "remove")))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "sub", "new"), []byte("new"), 0600))
+	for _, format := range []string{ManifestJSON, ManifestSHA256Sum} {
+		var result bytes.Buffer
+		err := VerifyManifest(context.Background(), cfg, dir, filepath.Join(out, "manifest."+format), &result)
+		assert.True(t, errors.Is(err, ErrManifestMismatch))
+		assert.Equal(t, ExitDiffer, ExitCode(err))
+		assert.Equal(t, "added    sub/new\nremoved  remove\nmodified change\n", result.String())
+	}
+}
+
+func TestReadDirManifestInvalid(t *testing.T) {
+	_, err := ReadDirManifest(bytes.NewBufferString("not a manifest\n"))
+	assert.Error(t, err)
+	_, err = ReadDirManifest(bytes.NewBufferString("{"))
+	assert.Error(t, err)
+	assert.Error(t, Manifest(context.Background(), DefaultConfig(), ".", "xml", &bytes.Buffer{}))
+}
@@ -0,0 +1,206 @@
+package s3ry
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/seike460/s3ry/internal/events"
+	"github.com/seike460/s3ry/internal/worker"
+)
+
+// Policies for symbolic links in directory uploads
+const (
+	SymlinksSkip   = "skip"
+	SymlinksFollow = "follow"
+)
+
+// modeMetadata user metadata holding the octal permission bits of an uploaded file, x-amz-meta-mode
+const modeMetadata = "Mode"
+
+// UploadConfig settings of uploads
+type UploadConfig struct {
+	// Symlinks skip or follow symbolic links in put --recursive (default skip), --follow-symlinks overrides it
+	// links back to a directory being uploaded are always skipped
+	Symlinks string `enum:"skip,follow"`
+	// PreserveMode store the permission bits of uploaded files, and restore them when get downloads the object
+	PreserveMode bool
+	// Overwrite what an upload does to an existing object of a bucket without versioning (default allow),
+	// backup copies it under BackupPrefix first, refuse fails, prompt asks. Versioned buckets keep the old version anyway
+	Overwrite string `enum:"allow,backup,refuse,prompt"`
+	// BackupPrefix prefix of the backups of overwritten objects, followed by the time and the key
+	BackupPrefix string
+	// ExpireIn tag uploaded objects to expire this long after the upload, deleted by the expire command (default 0, never)
+	// put --expire-in sets it
+	ExpireIn Duration
+	// Compress gzip uploads and store them with Content-Encoding: gzip, put --compress sets it
+	Compress bool
+	// Decompress decode objects stored with Content-Encoding gzip or deflate when get downloads them,
+	// otherwise their raw bytes are written. get --decompress sets it
+	Decompress bool
+}
+
+// uploadFile local file of a directory upload
+type uploadFile struct {
+	path string
+	// rel slash separated path relative to the directory, the key under the prefix
+	rel  string
+	mode os.FileMode
+}
+
+// walkUpload list files under dir in name order, following symbolic links when follow is set
+// links to a directory being walked would never end, so they are returned as cycles instead
+func walkUpload(dir string, follow bool) (files []uploadFile, cycles []string, err error) {
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	// ancestors real paths of the directories being walked
+	ancestors := map[string]bool{}
+	var walk func(dir string, real string, rel string) error
+	walk = func(dir string, real string, rel string) error {
+		ancestors[real] = true
+		defer delete(ancestors, real)
+		infos, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, info := range infos {
+			name := filepath.Join(dir, info.Name())
+			childRel := path.Join(rel, info.Name())
+			childReal := filepath.Join(real, info.Name())
+			if info.Mode()&os.ModeSymlink != 0 {
+				if !follow {
+					continue
+				}
+				if info, err = os.Stat(name); err != nil {
+					// a dangling link fails to upload like any unreadable file
+					files = append(files, uploadFile{path: name, rel: childRel})
+					continue
+				}
+				if childReal, err = filepath.EvalSymlinks(name); err != nil {
+					return err
+				}
+				if info.IsDir() && ancestors[childReal] {
+					cycles = append(cycles, name)
+					continue
+				}
+			}
+			switch {
+			case info.IsDir():
+				if err := walk(name, childReal, childRel); err != nil {
+					return err
+				}
+			case info.Mode().IsRegular():
+				files = append(files, uploadFile{path: name, rel: childRel, mode: info.Mode().Perm()})
+			}
+		}
+		return nil
+	}
+	if err := walk(dir, root, ""); err != nil {
+		return nil, nil, err
+	}
+	return files, cycles, nil
+}
+
+// metadataMode return permission bits stored by a directory upload with Upload.PreserveMode
+func metadataMode(metadata map[string]*string) (os.FileMode, bool) {
+	for k, v := range metadata {
+		if !strings.EqualFold(k, modeMetadata) {
+			continue
+		}
+		mode, err := strconv.ParseUint(aws.StringValue(v), 8, 32)
+		if err != nil {
+			return 0, false
+		}
+		return os.FileMode(mode) & os.ModePerm, true
+	}
+	return 0, false
+}
+
+// PutDirectory upload the files under local directory dir under s3:// URI dst, a bucket or a prefix ending with /,
+// used by put --recursive
+// files are uploaded by Performance.Workers concurrently, and a file failing to upload doesn't stop the others,
+// it is retried by Performance.BatchRetries after the others
+func PutDirectory(ctx context.Context, cfg *Config, dir string, dst string) error {
+	bucket, prefix, err := ParseS3URI(dst)
+	if err != nil {
+		return err
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		return fmt.Errorf("%w %q, directories are uploaded under a prefix ending with /", ErrInvalidURI, dst)
+	}
+	files, cycles, err := walkUpload(dir, cfg.Upload.Symlinks == SymlinksFollow)
+	if err != nil {
+		return err
+	}
+	for _, link := range cycles {
+		fmt.Fprintln(os.Stderr, i18nPrinter.Sprintf("Skipped symbolic link cycle,% s", link))
+	}
+
+	names := loadKeyNames(defaultKeyNamesPath())
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	if err := s.preflight(ctx, bucket, prefix, PermPutObject); err != nil {
+		return err
+	}
+	s.Events = events.NewBus()
+	defer s.Events.Close()
+	report := events.Collect(s.Events)
+	upload := func(ctx context.Context, file uploadFile, key string) error {
+		f, err := os.Open(file.path)
+		if err != nil {
+			s.Events.Publish(events.Event{Type: events.Failed, Operation: "upload", Bucket: bucket, Key: key, Err: err})
+			return err
+		}
+		defer f.Close()
+		var metadata map[string]*string
+		if cfg.Upload.PreserveMode {
+			metadata = map[string]*string{modeMetadata: aws.String(fmt.Sprintf("%04o", file.mode))}
+		}
+		_, err = s.putStream(ctx, bucket, key, f, metadata)
+		return err
+	}
+	var mu sync.Mutex
+	failed := map[string]error{}
+	// byKey file of each key, to retry it
+	byKey := map[string]uploadFile{}
+	pool := worker.New(ctx, cfg.Performance.Workers)
+	for _, file := range files {
+		file := file
+		key := prefix + path.Join(path.Dir(file.rel), names.original(file.path))
+		byKey[key] = file
+		err := pool.Submit(func(ctx context.Context) {
+			if err := upload(ctx, file, key); err != nil {
+				mu.Lock()
+				failed[key] = err
+				mu.Unlock()
+			}
+		})
+		if err != nil {
+			break
+		}
+	}
+	pool.Wait()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := s.retryFailed(ctx, failed, func(ctx context.Context, key string) error {
+		return upload(ctx, byKey[key], key)
+	}); err != nil {
+		return err
+	}
+	if err := cfg.writeReport(report(), os.Stderr); err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return &PartialError{Failed: len(failed), Op: "upload"}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package s3ry
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// uploadRels return rel of files
+func uploadRels(files []uploadFile) []string {
+	var rels []string
+	for _, f := range files {
+		rels = append(rels, f.rel)
+	}
+	sort.Strings(rels)
+	return rels
+}
+
+func TestWalkUploadSymlinks(t *testing.T) {
+	dir := newManifestDir(t, map[string]string{"a.txt": "a", "sub/b.txt": "b"})
+	defer os.RemoveAll(dir)
+	other := newManifestDir(t, map[string]string{"c.txt": "c"})
+	defer os.RemoveAll(other)
+	assert.NoError(t, os.Symlink(filepath.Join(dir, "a.txt"), filepath.Join(dir, "link.txt")))
+	assert.NoError(t, os.Symlink(other, filepath.Join(dir, "other")))
+	// cycles back to the directory and to its parent
+	assert.NoError(t, os.Symlink(dir, filepath.Join(dir, "sub", "loop")))
+	assert.NoError(t, os.Symlink("..", filepath.Join(dir, "sub", "up")))
+
+	files, cycles, err := walkUpload(dir, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a.txt", "sub/b.txt"}, uploadRels(files))
+	assert.Empty(t, cycles)
+
+	files, cycles, err = walkUpload(dir, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a.txt", "link.txt", "other/c.txt", "sub/b.txt"}, uploadRels(files))
+	assert.Equal(t, []string{filepath.Join(dir, "sub", "loop"), filepath.Join(dir, "sub", "up")}, cycles)
+}
+
+func TestPutDirectoryPreserveMode(t *testing.T) {
+	fake := newFakeS3("bucket")
+	cfg, done := newFakeEndpoint(t, fake)
+	defer done()
+	cfg.Upload.PreserveMode = true
+	dir := newManifestDir(t, map[string]string{"run.sh": "#!/bin/sh\n", "sub/data": "data"})
+	defer os.RemoveAll(dir)
+	assert.NoError(t, os.Chmod(filepath.Join(dir, "run.sh"), 0751))
+	assert.NoError(t, os.Chmod(filepath.Join(dir, "sub", "data"), 0640))
+
+	assert.NoError(t, PutDirectory(context.Background(), cfg, dir, "s3://bucket/up/"))
+	o, ok := fake.get("bucket", "up/run.sh")
+	if assert.True(t, ok) {
+		assert.Equal(t, "0751", o.header.Get("X-Amz-Meta-Mode"))
+	}
+	_, ok = fake.get("bucket", "up/sub/data")
+	assert.True(t, ok)
+
+	out := filepath.Join(dir, "downloaded")
+	assert.NoError(t, Get(context.Background(), cfg, "s3://bucket/up/run.sh", out))
+	info, err := os.Stat(out)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0751), info.Mode().Perm())
+
+	// the mode is kept only when asked for
+	cfg.Upload.PreserveMode = false
+	out = filepath.Join(dir, "plain")
+	assert.NoError(t, Get(context.Background(), cfg, "s3://bucket/up/sub/data", out))
+	info, err = os.Stat(out)
+	assert.NoError(t, err)
+	assert.NotEqual(t, os.FileMode(0640), info.Mode().Perm())
+
+	assert.Error(t, PutDirectory(context.Background(), cfg, dir, "s3://bucket/up"))
+}
@@ -0,0 +1,282 @@
+package s3ry
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/seike460/s3ry/internal/worker"
+)
+
+// rangeState completed ranges of a resumable download, saved next to the partial file
+type rangeState struct {
+	ETag     string
+	Size     int64
+	PartSize int64
+	Done     map[int64]bool
+}
+
+// resumablePartial return path of the partial file and the range state of an object version
+// the name is derived from the object, so an interrupted download is found again
+func (c *Config) resumablePartial(bucket string, key string, etag string) (string, string) {
+	sum := sha1.Sum([]byte(bucket + "/" + key + "\x00" + etag))
+	name := "s3ry-" + sanitizePortable(path.Base(key)) + "-" + hex.EncodeToString(sum[:8])
+	// both match partialPattern, so CleanupInterruptedTransfers removes them
+	return filepath.Join(c.tempDir(), name+".partial"), filepath.Join(c.tempDir(), name+".ranges.partial")
+}
+
+// loadRangeState read range state, a missing or different one starts over
+func loadRangeState(path string, etag string, size int64, partSize int64) *rangeState {
+	state := &rangeState{ETag: etag, Size: size, PartSize: partSize, Done: map[int64]bool{}}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return state
+	}
+	saved := &rangeState{}
+	if json.Unmarshal(b, saved) != nil || saved.ETag != etag || saved.Size != size || saved.PartSize != partSize || saved.Done == nil {
+		return state
+	}
+	return saved
+}
+
+// save write range state
+func (r *rangeState) save(path string) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0600)
+}
+
+// A resumable partial file is locked by the run writing it. The lock is refreshed every partialLockRefresh,
+// and a lock not refreshed for partialLockTimeout was left by a killed run and is taken over
+const (
+	partialLockRefresh = 10 * time.Second
+	partialLockTimeout = time.Minute
+)
+
+// errPartialLocked another run is downloading into the partial file
+var errPartialLocked = errors.New("partial file is locked by another download")
+
+// lockPartial lock partial file for this run, so concurrent runs downloading the same object version
+// don't write the same file, the returned func releases the lock
+// the lock file matches partialPattern, so CleanupInterruptedTransfers removes it when it is stale
+func lockPartial(partial string) (func(), error) {
+	lock := strings.TrimSuffix(partial, ".partial") + ".lock.partial"
+	f, err := os.OpenFile(lock, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		info, serr := os.Stat(lock)
+		if serr != nil || time.Since(info.ModTime()) < partialLockTimeout {
+			return nil, errPartialLocked
+		}
+		os.Remove(lock)
+		f, err = os.OpenFile(lock, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	}
+	if os.IsExist(err) {
+		return nil, errPartialLocked
+	}
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(partialLockRefresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				os.Chtimes(lock, now, now)
+			case <-stop:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(stop)
+			os.Remove(lock)
+		})
+	}, nil
+}
+
+// downloadStream download object with s3manager into a new partial file and move it to filename
+func (s S3ry) downloadStream(input *s3.GetObjectInput, filename string) (int64, error) {
+	file, err := s.config().createPartial(filename)
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	downloader := s3manager.NewDownloader(s.Sess)
+	w := &progressWriterAt{w: file, publish: s.progress("download", aws.StringValue(input.Bucket), aws.StringValue(input.Key), 0)}
+	n, err := downloader.Download(w, input)
+	if err != nil {
+		return n, err
+	}
+	if err := file.Close(); err != nil {
+		return n, err
+	}
+	return n, commitPartial(file.Name(), filename)
+}
+
+// downloadRanges download object of size in Performance.DownloadPartSize ranges fetched concurrently
+//
+// Completed ranges are recorded, so downloading the same object version again
+// after an interruption only fetches the missing ranges. When the server
+// ignores the Range header the first response is the whole object and is
+// written as is.
+func (s S3ry) downloadRanges(ctx context.Context, input *s3.GetObjectInput, size int64, etag string, filename string) (int64, error) {
+	bucket, key := aws.StringValue(input.Bucket), aws.StringValue(input.Key)
+	partSize := s.config().Performance.DownloadPartSize
+	partial, statePath := s.config().resumablePartial(bucket, key, etag)
+	if err := os.MkdirAll(filepath.Dir(partial), 0700); err != nil {
+		return 0, err
+	}
+	unlock, err := lockPartial(partial)
+	if err == errPartialLocked {
+		// another run is downloading the same version, download into a partial file of this run without resuming
+		return s.downloadStream(input, filename)
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	file, err := os.OpenFile(partial, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	if err := file.Truncate(size); err != nil {
+		return 0, err
+	}
+	state := loadRangeState(statePath, etag, size, partSize)
+
+	var written int64
+	publish := s.progress("download", bucket, key, size)
+	for part := range state.Done {
+		written += partLength(part, partSize, size)
+	}
+	publish(written)
+
+	// fetch get part and write it, whole is true when the server sent the whole object
+	fetch := func(ctx context.Context, part int64) (whole bool, err error) {
+		start := part * partSize
+		end := start + partLength(part, partSize, size) - 1
+		ranged := *input
+		ranged.Range = aws.String(fmt.Sprintf("bytes=%d-%d", start, end))
+		if etag != "" {
+			// fail rather than mix versions when the object changed
+			ranged.IfMatch = aws.String(etag)
+		}
+		out, err := s.Svc.GetObjectWithContext(ctx, &ranged)
+		if err != nil {
+			return false, err
+		}
+		defer out.Body.Close()
+		b, err := ioutil.ReadAll(out.Body)
+		if err != nil {
+			return false, err
+		}
+		if out.ContentRange == nil {
+			start = 0
+		}
+		if _, err := file.WriteAt(b, start); err != nil {
+			return false, err
+		}
+		publish(atomic.AddInt64(&written, int64(len(b))))
+		return out.ContentRange == nil, nil
+	}
+
+	parts := (size + partSize - 1) / partSize
+	var first int64 = -1
+	for part := int64(0); part < parts; part++ {
+		if !state.Done[part] {
+			first = part
+			break
+		}
+	}
+	if first >= 0 {
+		// probe with one range before fetching the rest concurrently
+		whole, err := fetch(ctx, first)
+		if err != nil {
+			return 0, err
+		}
+		if !whole {
+			state.Done[first] = true
+			if err := state.save(statePath); err != nil {
+				return 0, err
+			}
+			if err := s.fetchRanges(ctx, state, statePath, first+1, parts, fetch); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	if err := file.Close(); err != nil {
+		return 0, err
+	}
+	if err := commitPartial(partial, filename); err != nil {
+		return 0, err
+	}
+	os.Remove(statePath)
+	return size, nil
+}
+
+// fetchRanges fetch missing parts from first by Performance.Workers, recording each completed part
+func (s S3ry) fetchRanges(ctx context.Context, state *rangeState, statePath string, first int64, parts int64, fetch func(context.Context, int64) (bool, error)) error {
+	var missing []int64
+	for part := first; part < parts; part++ {
+		if !state.Done[part] {
+			missing = append(missing, part)
+		}
+	}
+	var mu sync.Mutex
+	var firstErr error
+	pool := worker.New(ctx, s.config().Performance.Workers)
+	for _, part := range missing {
+		part := part
+		if err := pool.Submit(func(ctx context.Context) {
+			_, err := fetch(ctx, part)
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				state.Done[part] = true
+				err = state.save(statePath)
+			}
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}); err != nil {
+			break
+		}
+	}
+	pool.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// partLength return length of part
+func partLength(part int64, partSize int64, size int64) int64 {
+	if rest := size - part*partSize; rest < partSize {
+		return rest
+	}
+	return partSize
+}
@@ -0,0 +1,158 @@
+package s3ry
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rangedTest create fake S3 with a 3.5MiB object, a config downloading it in 1MiB ranges,
+// and chdir into a temp dir, returning object data and the count of ranged GETs
+func rangedTest(t *testing.T) (*fakeS3, *Config, []byte, *int32, func()) {
+	dir, err := ioutil.TempDir("", "s3ry")
+	assert.NoError(t, err)
+	wd, _ := os.Getwd()
+	assert.NoError(t, os.Chdir(dir))
+
+	data := make([]byte, 3*1024*1024+512*1024)
+	rand.New(rand.NewSource(1)).Read(data)
+	fake := newFakeS3("bucket")
+	fake.put("bucket", "dir/big.bin", string(data))
+	var ranged int32
+	fake.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method == http.MethodGet && r.Header.Get("Range") != "" {
+			atomic.AddInt32(&ranged, 1)
+		}
+		return false
+	}
+	cfg := DefaultConfig()
+	cfg.Performance.TempDir = dir
+	cfg.Performance.MultipartDownloadThreshold = 1024 * 1024
+	cfg.Performance.DownloadPartSize = 1024 * 1024
+	cfg.Performance.Workers = 3
+	return fake, cfg, data, &ranged, func() {
+		os.Chdir(wd)
+		os.RemoveAll(dir)
+	}
+}
+
+func TestGetObjectRanges(t *testing.T) {
+	fake, cfg, data, ranged, cleanup := rangedTest(t)
+	defer cleanup()
+	s, srv := newTestS3ry(cfg, fake)
+	defer srv.Close()
+
+	assert.NoError(t, s.GetObject("bucket", "dir/big.bin"))
+	assert.Equal(t, int32(4), atomic.LoadInt32(ranged))
+	b, err := ioutil.ReadFile("big.bin")
+	assert.NoError(t, err)
+	assert.True(t, bytes.Equal(data, b))
+	// nothing is left in the temp dir but the downloaded file
+	partials, _ := filepath.Glob(filepath.Join(cfg.tempDir(), partialPattern))
+	assert.Empty(t, partials)
+}
+
+func TestGetObjectRangesResume(t *testing.T) {
+	fake, cfg, data, ranged, cleanup := rangedTest(t)
+	defer cleanup()
+	s, srv := newTestS3ry(cfg, fake)
+	defer srv.Close()
+
+	// an interrupted download completed the first two ranges
+	sum := md5.Sum(data)
+	partial, statePath := cfg.resumablePartial("bucket", "dir/big.bin", `"`+hex.EncodeToString(sum[:])+`"`)
+	written := make([]byte, len(data))
+	copy(written, data[:2*1024*1024])
+	assert.NoError(t, ioutil.WriteFile(partial, written, 0600))
+	state := loadRangeState(statePath, `"`+hex.EncodeToString(sum[:])+`"`, int64(len(data)), 1024*1024)
+	state.Done[0], state.Done[1] = true, true
+	assert.NoError(t, state.save(statePath))
+
+	assert.NoError(t, s.GetObject("bucket", "dir/big.bin"))
+	assert.Equal(t, int32(2), atomic.LoadInt32(ranged))
+	b, err := ioutil.ReadFile("big.bin")
+	assert.NoError(t, err)
+	assert.True(t, bytes.Equal(data, b))
+	_, err = os.Stat(statePath)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestGetObjectRangesIgnored(t *testing.T) {
+	fake, cfg, data, _, cleanup := rangedTest(t)
+	defer cleanup()
+	// the server doesn't support ranges
+	fake.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		r.Header.Del("Range")
+		return false
+	}
+	s, srv := newTestS3ry(cfg, fake)
+	defer srv.Close()
+
+	assert.NoError(t, s.GetObject("bucket", "dir/big.bin"))
+	assert.Equal(t, 1, fake.count("GET"))
+	b, err := ioutil.ReadFile("big.bin")
+	assert.NoError(t, err)
+	assert.True(t, bytes.Equal(data, b))
+}
+
+func TestLockPartial(t *testing.T) {
+	dir, err := ioutil.TempDir("", "s3ry")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	partial := filepath.Join(dir, "s3ry-abc.partial")
+
+	unlock, err := lockPartial(partial)
+	assert.NoError(t, err)
+	locks, _ := filepath.Glob(filepath.Join(dir, partialPattern))
+	assert.Equal(t, []string{filepath.Join(dir, "s3ry-abc.lock.partial")}, locks)
+	_, err = lockPartial(partial)
+	assert.Equal(t, errPartialLocked, err)
+	unlock()
+	unlock()
+	locks, _ = filepath.Glob(filepath.Join(dir, partialPattern))
+	assert.Empty(t, locks)
+
+	// a lock left by a killed run is taken over
+	stale := time.Now().Add(-2 * partialLockTimeout)
+	lock := filepath.Join(dir, "s3ry-abc.lock.partial")
+	assert.NoError(t, ioutil.WriteFile(lock, nil, 0600))
+	assert.NoError(t, os.Chtimes(lock, stale, stale))
+	unlock, err = lockPartial(partial)
+	assert.NoError(t, err)
+	unlock()
+}
+
+func TestGetObjectRangesLocked(t *testing.T) {
+	fake, cfg, data, ranged, cleanup := rangedTest(t)
+	defer cleanup()
+	s, srv := newTestS3ry(cfg, fake)
+	defer srv.Close()
+
+	// another run is downloading the same version
+	sum := md5.Sum(data)
+	partial, _ := cfg.resumablePartial("bucket", "dir/big.bin", `"`+hex.EncodeToString(sum[:])+`"`)
+	unlock, err := lockPartial(partial)
+	assert.NoError(t, err)
+	defer unlock()
+
+	assert.NoError(t, s.GetObject("bucket", "dir/big.bin"))
+	b, err := ioutil.ReadFile("big.bin")
+	assert.NoError(t, err)
+	assert.True(t, bytes.Equal(data, b))
+	// its partial file is left alone
+	_, err = os.Stat(partial)
+	assert.True(t, os.IsNotExist(err))
+	assert.True(t, atomic.LoadInt32(ranged) > 0)
+	partials, _ := filepath.Glob(filepath.Join(cfg.tempDir(), partialPattern))
+	assert.Len(t, partials, 1)
+}
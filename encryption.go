@@ -0,0 +1,167 @@
+package s3ry
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// server-side encryption modes
+const (
+	// SSEModeS3 S3 managed keys (AES256)
+	SSEModeS3 = "SSE-S3"
+	// SSEModeKMS AWS KMS managed keys
+	SSEModeKMS = "SSE-KMS"
+	// SSEModeC customer provided keys
+	SSEModeC = "SSE-C"
+)
+
+// EncryptionConfig server-side encryption settings, empty Mode uses the bucket default
+type EncryptionConfig struct {
+	// Mode SSE-S3, SSE-KMS or SSE-C
+	Mode string `json:",omitempty"`
+	// KMSKeyID KMS key for SSE-KMS, empty uses the AWS managed key
+	KMSKeyID string `json:",omitempty"`
+	// CustomerKey base64 encoded 256-bit key for SSE-C
+	CustomerKey string `json:",omitempty"`
+}
+
+// sseFields values of encryption request fields, nil when unset
+type sseFields struct {
+	ServerSideEncryption *string
+	SSEKMSKeyID          *string
+	SSECustomerAlgorithm *string
+	SSECustomerKey       *string
+}
+
+// fields check EncryptionConfig and return request field values
+func (e EncryptionConfig) fields() (sseFields, error) {
+	f := sseFields{}
+	switch e.Mode {
+	case "":
+	case SSEModeS3:
+		f.ServerSideEncryption = aws.String(s3.ServerSideEncryptionAes256)
+	case SSEModeKMS:
+		f.ServerSideEncryption = aws.String(s3.ServerSideEncryptionAwsKms)
+		if e.KMSKeyID != "" {
+			f.SSEKMSKeyID = aws.String(e.KMSKeyID)
+		}
+	case SSEModeC:
+		key, err := e.customerKey()
+		if err != nil {
+			return f, err
+		}
+		f.SSECustomerAlgorithm = aws.String(s3.ServerSideEncryptionAes256)
+		f.SSECustomerKey = aws.String(key)
+	default:
+		return f, fmt.Errorf("unknown encryption mode %q", e.Mode)
+	}
+	return f, nil
+}
+
+// customerKey decode and check SSE-C key
+func (e EncryptionConfig) customerKey() (string, error) {
+	key, err := base64.StdEncoding.DecodeString(e.CustomerKey)
+	if err != nil {
+		return "", fmt.Errorf("SSE-C key must be base64 encoded: %s", err.Error())
+	}
+	if len(key) != 32 {
+		return "", fmt.Errorf("SSE-C key must be 256-bit, got %d bits", len(key)*8)
+	}
+	return string(key), nil
+}
+
+// applyUpload set encryption headers to UploadInput
+// s3manager passes them on to PutObject or every multipart request
+func (e EncryptionConfig) applyUpload(input *s3manager.UploadInput) error {
+	f, err := e.fields()
+	if err != nil {
+		return err
+	}
+	input.ServerSideEncryption = f.ServerSideEncryption
+	input.SSEKMSKeyId = f.SSEKMSKeyID
+	input.SSECustomerAlgorithm = f.SSECustomerAlgorithm
+	input.SSECustomerKey = f.SSECustomerKey
+	return nil
+}
+
+// applyDownload set SSE-C key to GetObjectInput, other modes need nothing to download
+func (e EncryptionConfig) applyDownload(input *s3.GetObjectInput) error {
+	f, err := e.fields()
+	if err != nil {
+		return err
+	}
+	input.SSECustomerAlgorithm = f.SSECustomerAlgorithm
+	input.SSECustomerKey = f.SSECustomerKey
+	return nil
+}
+
+// applyCopy set encryption of the copy, a source encrypted with SSE-C, sourceSSEC, is read with the same key
+func (e EncryptionConfig) applyCopy(input *s3.CopyObjectInput, sourceSSEC bool) error {
+	f, err := e.fields()
+	if err != nil {
+		return err
+	}
+	input.ServerSideEncryption = f.ServerSideEncryption
+	input.SSEKMSKeyId = f.SSEKMSKeyID
+	input.SSECustomerAlgorithm = f.SSECustomerAlgorithm
+	input.SSECustomerKey = f.SSECustomerKey
+	if sourceSSEC {
+		input.CopySourceSSECustomerAlgorithm = f.SSECustomerAlgorithm
+		input.CopySourceSSECustomerKey = f.SSECustomerKey
+	}
+	return nil
+}
+
+// applyCreateMultipart set encryption of a multipart upload
+func (e EncryptionConfig) applyCreateMultipart(input *s3.CreateMultipartUploadInput) error {
+	f, err := e.fields()
+	if err != nil {
+		return err
+	}
+	input.ServerSideEncryption = f.ServerSideEncryption
+	input.SSEKMSKeyId = f.SSEKMSKeyID
+	input.SSECustomerAlgorithm = f.SSECustomerAlgorithm
+	input.SSECustomerKey = f.SSECustomerKey
+	return nil
+}
+
+// applyUploadPartCopy set SSE-C keys of a part copy, the source only when it is encrypted with SSE-C, sourceSSEC
+func (e EncryptionConfig) applyUploadPartCopy(input *s3.UploadPartCopyInput, sourceSSEC bool) error {
+	f, err := e.fields()
+	if err != nil {
+		return err
+	}
+	input.SSECustomerAlgorithm = f.SSECustomerAlgorithm
+	input.SSECustomerKey = f.SSECustomerKey
+	if sourceSSEC {
+		input.CopySourceSSECustomerAlgorithm = f.SSECustomerAlgorithm
+		input.CopySourceSSECustomerKey = f.SSECustomerKey
+	}
+	return nil
+}
+
+// headSource head the source of a copy encrypted by enc, s is a client of the source region
+// the key is sent as it is, so messy keys being normalized are found, and with SSE-C a source that can't be read
+// without a key is read with its key, and SSECustomerAlgorithm of the result tells the source is encrypted with SSE-C
+func (s S3ry) headSource(ctx context.Context, enc EncryptionConfig, bucket string, key string) (*s3.HeadObjectOutput, error) {
+	input := &s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)}
+	head, err := s.Svc.HeadObjectWithContext(ctx, input, keepKeyPath)
+	f, ferr := enc.fields()
+	if ferr != nil || f.SSECustomerKey == nil {
+		return head, err
+	}
+	// S3 refuses to head an SSE-C object without its key
+	if rerr, ok := err.(awserr.RequestFailure); !ok || rerr.StatusCode() != http.StatusBadRequest {
+		return head, err
+	}
+	input.SSECustomerAlgorithm = f.SSECustomerAlgorithm
+	input.SSECustomerKey = f.SSECustomerKey
+	return s.Svc.HeadObjectWithContext(ctx, input, keepKeyPath)
+}
@@ -0,0 +1,128 @@
+package s3ry
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testCustomerKey = strings.Repeat("k", 32)
+
+func TestUploadObjectEncryptionHeaders(t *testing.T) {
+	keyMD5 := md5.Sum([]byte(testCustomerKey))
+	tests := []struct {
+		config  EncryptionConfig
+		headers map[string]string
+	}{
+		{
+			config:  EncryptionConfig{Mode: SSEModeS3},
+			headers: map[string]string{"X-Amz-Server-Side-Encryption": "AES256"},
+		},
+		{
+			config: EncryptionConfig{Mode: SSEModeKMS, KMSKeyID: "alias/s3ry"},
+			headers: map[string]string{
+				"X-Amz-Server-Side-Encryption":                "aws:kms",
+				"X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id": "alias/s3ry",
+			},
+		},
+		{
+			config: EncryptionConfig{Mode: SSEModeC, CustomerKey: base64.StdEncoding.EncodeToString([]byte(testCustomerKey))},
+			headers: map[string]string{
+				"X-Amz-Server-Side-Encryption-Customer-Algorithm": "AES256",
+				"X-Amz-Server-Side-Encryption-Customer-Key":       base64.StdEncoding.EncodeToString([]byte(testCustomerKey)),
+				"X-Amz-Server-Side-Encryption-Customer-Key-Md5":   base64.StdEncoding.EncodeToString(keyMD5[:]),
+			},
+		},
+	}
+
+	f, err := ioutil.TempFile("", "s3ry")
+	assert.NoError(t, err)
+	f.WriteString("body")
+	f.Close()
+	defer os.Remove(f.Name())
+
+	for _, test := range tests {
+		var header http.Header
+		cfg := DefaultConfig()
+		cfg.Encryption = test.config
+		s, srv := newTestS3ry(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header = r.Header
+		}))
+		assert.NoError(t, s.UploadObject("bucket", f.Name()), test.config.Mode)
+		srv.Close()
+		for name, value := range test.headers {
+			assert.Equal(t, value, header.Get(name), test.config.Mode)
+		}
+	}
+}
+
+func TestUploadObjectRejectsShortCustomerKey(t *testing.T) {
+	requests := 0
+	cfg := DefaultConfig()
+	cfg.Encryption = EncryptionConfig{Mode: SSEModeC, CustomerKey: base64.StdEncoding.EncodeToString([]byte("short key"))}
+	s, srv := newTestS3ry(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer srv.Close()
+
+	err := s.UploadObject("bucket", "testUploadFile")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "256-bit")
+	}
+	assert.Equal(t, 0, requests)
+}
+
+func TestGetObjectSuppliesCustomerKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "s3ry")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	wd, _ := os.Getwd()
+	assert.NoError(t, os.Chdir(dir))
+	defer os.Chdir(wd)
+
+	var header http.Header
+	cfg := DefaultConfig()
+	cfg.Encryption = EncryptionConfig{Mode: SSEModeC, CustomerKey: base64.StdEncoding.EncodeToString([]byte(testCustomerKey))}
+	s, srv := newTestS3ry(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		w.Write([]byte("secret"))
+	}))
+	defer srv.Close()
+
+	assert.NoError(t, s.GetObject("bucket", "dir/secret.txt"))
+	assert.Equal(t, "AES256", header.Get("X-Amz-Server-Side-Encryption-Customer-Algorithm"))
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte(testCustomerKey)), header.Get("X-Amz-Server-Side-Encryption-Customer-Key"))
+	b, err := ioutil.ReadFile("secret.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", string(b))
+}
+
+func TestCopyIntoCustomerKeyConfig(t *testing.T) {
+	fake := newFakeS3("src", "dst")
+	fake.put("src", "plain", "plain")
+	fake.put("src", "secret", "secret")
+	fake.buckets["src"]["secret"].header.Set("X-Amz-Server-Side-Encryption-Customer-Algorithm", "AES256")
+	cfg := DefaultConfig()
+	cfg.Encryption = EncryptionConfig{Mode: SSEModeC, CustomerKey: base64.StdEncoding.EncodeToString([]byte(testCustomerKey))}
+	s, srv := newTestS3ry(cfg, fake)
+	defer srv.Close()
+	ctx := context.Background()
+
+	// a plain source is read without the key, an SSE-C one with it, whole or in parts
+	for _, threshold := range []int64{cfg.Performance.MultipartCopyThreshold, 1} {
+		cfg.Performance.MultipartCopyThreshold = threshold
+		for _, key := range []string{"plain", "secret"} {
+			assert.NoError(t, s.CopyObject(ctx, "src", key, "dst", key, int64(len(key))), "%s above %d", key, threshold)
+		}
+	}
+	assert.Equal(t, 2, fake.count("COPY"))
+	assert.Equal(t, 2, fake.count("UPLOAD_PART_COPY"))
+	assert.Equal(t, []string{"plain", "secret"}, fake.keys("dst"))
+}
@@ -0,0 +1,139 @@
+package s3ry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/manifoldco/promptui"
+)
+
+// Exit codes of the s3ry command, scripts may rely on them
+const (
+	// ExitOK success
+	ExitOK = 0
+	// ExitError any other error
+	ExitError = 1
+	// ExitUsage invalid arguments or config
+	ExitUsage = 2
+	// ExitAuth missing or rejected credentials, or access denied
+	ExitAuth = 3
+	// ExitNotFound bucket, object or local file not found
+	ExitNotFound = 4
+	// ExitPartial some objects of a bulk operation failed
+	ExitPartial = 5
+	// ExitCancelled declined by the user or interrupted
+	ExitCancelled = 6
+	// ExitTimeout the command exceeded its timeout
+	ExitTimeout = 7
+	// ExitDiffer compared objects are different, a directory doesn't match its manifest, or objects changed since a plan
+	ExitDiffer = 8
+)
+
+// ErrTimeout command exceeded its timeout
+var ErrTimeout = errors.New("timed out")
+
+// authErrorCodes AWS error codes of missing, invalid or insufficient credentials
+var authErrorCodes = map[string]bool{
+	"AccessDenied":                true,
+	"AllAccessDisabled":           true,
+	"ExpiredToken":                true,
+	"InvalidAccessKeyId":          true,
+	"InvalidClientTokenId":        true,
+	"InvalidToken":                true,
+	"NoCredentialProviders":       true,
+	"SignatureDoesNotMatch":       true,
+	"UnrecognizedClientException": true,
+}
+
+// notFoundErrorCodes AWS error codes of missing resources
+var notFoundErrorCodes = map[string]bool{
+	"NoSuchBucket":  true,
+	"NoSuchKey":     true,
+	"NoSuchUpload":  true,
+	"NoSuchVersion": true,
+	"NotFound":      true,
+	"AWS.SimpleQueueService.NonExistentQueue": true,
+}
+
+// PartialError some objects of a bulk operation failed while the others succeeded
+type PartialError struct {
+	Failed int
+	// Op operation that failed, e.g. copy
+	Op string
+}
+
+// Error format PartialError
+func (e *PartialError) Error() string {
+	return fmt.Sprintf("%d objects failed to %s", e.Failed, e.Op)
+}
+
+// ExitCode return exit code of the s3ry command for err
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	var configErrs ConfigErrors
+	if errors.As(err, &configErrs) || errors.Is(err, ErrInvalidConfig) || errors.Is(err, ErrInvalidURI) || errors.Is(err, ErrNotSSOProfile) || errors.Is(err, ErrInvalidKeyTemplate) {
+		return ExitUsage
+	}
+	var partial *PartialError
+	if errors.As(err, &partial) {
+		return ExitPartial
+	}
+	if errors.Is(err, ErrObjectsDiffer) || errors.Is(err, ErrManifestMismatch) || errors.Is(err, ErrStalePlan) {
+		return ExitDiffer
+	}
+	if errors.Is(err, ErrTimeout) || errors.Is(err, context.DeadlineExceeded) {
+		return ExitTimeout
+	}
+	if errors.Is(err, ErrCancelled) || errors.Is(err, context.Canceled) || errors.Is(err, promptui.ErrInterrupt) || errors.Is(err, promptui.ErrEOF) {
+		return ExitCancelled
+	}
+	var permission *PermissionError
+	if errors.As(err, &permission) {
+		return ExitAuth
+	}
+	var aerr awserr.Error
+	if errors.As(err, &aerr) {
+		switch {
+		case authErrorCodes[aerr.Code()]:
+			return ExitAuth
+		case notFoundErrorCodes[aerr.Code()]:
+			return ExitNotFound
+		}
+		// HEAD responses have no body, so only the status tells what happened
+		if rf, ok := aerr.(awserr.RequestFailure); ok {
+			switch rf.StatusCode() {
+			case http.StatusForbidden:
+				return ExitAuth
+			case http.StatusNotFound:
+				return ExitNotFound
+			}
+		}
+		return ExitError
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return ExitNotFound
+	}
+	return ExitError
+}
+
+// DeadlineError return err as ErrTimeout when ctx exceeded its deadline
+// requests cut by the deadline fail with SDK errors which don't wrap context.DeadlineExceeded
+func DeadlineError(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() != context.DeadlineExceeded || errors.Is(err, ErrTimeout) {
+		return err
+	}
+	return fmt.Errorf("%w: %s", ErrTimeout, err.Error())
+}
+
+// Exit log err and exit with its exit code
+func Exit(err error) {
+	log.Println(err.Error())
+	os.Exit(ExitCode(err))
+}
@@ -0,0 +1,123 @@
+package s3ry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/manifoldco/promptui"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExitCode(t *testing.T) {
+	_, statErr := os.Stat("does-not-exist")
+	_, _, uriErr := ParseS3URI("bucket/key")
+	cases := map[string]struct {
+		err  error
+		code int
+	}{
+		"nil":             {nil, ExitOK},
+		"other":           {errors.New("boom"), ExitError},
+		"config":          {ConfigErrors{{File: "config.json", Line: 1, Msg: "unknown field"}}, ExitUsage},
+		"uri":             {uriErr, ExitUsage},
+		"access denied":   {awserr.NewRequestFailure(awserr.New("AccessDenied", "Access Denied", nil), 403, "id"), ExitAuth},
+		"no credentials":  {awserr.New("NoCredentialProviders", "no valid providers in chain", nil), ExitAuth},
+		"forbidden head":  {awserr.NewRequestFailure(awserr.New("Forbidden", "Forbidden", nil), 403, "id"), ExitAuth},
+		"no such key":     {awserr.NewRequestFailure(awserr.New("NoSuchKey", "", nil), 404, "id"), ExitNotFound},
+		"wrapped missing": {fmt.Errorf("s3://b/k: %w", awserr.New("NoSuchBucket", "", nil)), ExitNotFound},
+		"local file":      {statErr, ExitNotFound},
+		"partial":         {&PartialError{Failed: 2, Op: "copy"}, ExitPartial},
+		"declined":        {ErrCancelled, ExitCancelled},
+		"interrupted":     {fmt.Errorf("listing: %w", context.Canceled), ExitCancelled},
+		"prompt":          {promptui.ErrInterrupt, ExitCancelled},
+		"server error":    {awserr.NewRequestFailure(awserr.New("InternalError", "", nil), 500, "id"), ExitError},
+	}
+	for name, c := range cases {
+		assert.Equal(t, c.code, ExitCode(c.err), name)
+	}
+}
+
+func TestExitCodeOfResponses(t *testing.T) {
+	fake := newFakeS3("bucket")
+	fake.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.URL.Path == "/bucket/secret" {
+			writeFakeError(w, http.StatusForbidden, "AccessDenied")
+			return true
+		}
+		return false
+	}
+	s, srv := newTestS3ry(DefaultConfig(), fake)
+	defer srv.Close()
+
+	_, err := s.Svc.HeadObject(&s3.HeadObjectInput{Bucket: aws.String("bucket"), Key: aws.String("missing")})
+	assert.Equal(t, ExitNotFound, ExitCode(err))
+	_, err = s.Svc.GetObject(&s3.GetObjectInput{Bucket: aws.String("bucket"), Key: aws.String("secret")})
+	assert.Equal(t, ExitAuth, ExitCode(err))
+
+	s.Config.Security.ReadOnly = true
+	_, err = s.Svc.DeleteObject(&s3.DeleteObjectInput{Bucket: aws.String("bucket"), Key: aws.String("key")})
+	assert.Equal(t, ExitError, ExitCode(err))
+}
+
+func TestCommandContext(t *testing.T) {
+	cfg := DefaultConfig()
+	ctx, cancel := cfg.CommandContext(context.Background(), "cp")
+	defer cancel()
+	_, ok := ctx.Deadline()
+	assert.False(t, ok)
+
+	ctx, cancel = cfg.CommandContext(context.Background(), "acl")
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+}
+
+func TestDeadlineCancelsHangingRequests(t *testing.T) {
+	fake := newFakeS3("bucket")
+	fake.put("bucket", "key", "data")
+	// a stuck connection, nothing is sent until the client gives up
+	release := make(chan struct{})
+	fake.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		q := r.URL.Query()
+		if _, location := q["location"]; (r.Method == http.MethodGet && !location) || q.Get("partNumber") != "" {
+			select {
+			case <-r.Context().Done():
+			case <-release:
+			}
+			return true
+		}
+		return false
+	}
+	s, srv := newTestS3ry(DefaultConfig(), fake)
+	defer srv.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := s.GetStream(ctx, "bucket", "key", ioutil.Discard)
+	err = DeadlineError(ctx, err)
+	assert.True(t, errors.Is(err, ErrTimeout))
+	assert.Equal(t, ExitTimeout, ExitCode(err))
+	assert.True(t, time.Since(start) < 5*time.Second)
+
+	// the multipart upload cut by the deadline is aborted
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = s.PutStream(ctx, "bucket", "big", bytes.NewReader(make([]byte, 6*1024*1024)))
+	assert.Equal(t, ExitTimeout, ExitCode(DeadlineError(ctx, err)))
+	assert.True(t, fake.count("ABORT_MULTIPART") > 0)
+
+	assert.Nil(t, DeadlineError(ctx, nil))
+	assert.Equal(t, ExitError, ExitCode(DeadlineError(context.Background(), errors.New("boom"))))
+}
@@ -0,0 +1,200 @@
+package s3ry
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/seike460/s3ry/internal/worker"
+)
+
+// expiresTag object tag holding the time an object expires at, RFC 3339 in UTC
+const expiresTag = "s3ry-expires"
+
+// maxDeleteObjects keys DeleteObjects deletes at once
+const maxDeleteObjects = 1000
+
+// ParseExpiry parse a time to live like 30d, or a duration like 12h
+func ParseExpiry(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil || days <= 0 {
+			return 0, fmt.Errorf("invalid expiry %q, use days like 30d or a duration like 12h", s)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid expiry %q, use days like 30d or a duration like 12h", s)
+	}
+	return d, nil
+}
+
+// applyExpiry tag input to expire Upload.ExpireIn after now
+func (c *Config) applyExpiry(input *s3manager.UploadInput, now time.Time) {
+	if c.Upload.ExpireIn <= 0 {
+		return
+	}
+	expires := now.Add(time.Duration(c.Upload.ExpireIn)).UTC().Format(time.RFC3339)
+	input.Tagging = aws.String(url.Values{expiresTag: {expires}}.Encode())
+}
+
+// objectExpiry return the time tags say the object expires at, false without a valid expiry tag
+func objectExpiry(tags map[string]string) (time.Time, bool) {
+	v, ok := tags[expiresTag]
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	return t, err == nil
+}
+
+// ExpiredObject object whose expiry has passed
+type ExpiredObject struct {
+	Key     string
+	Expires time.Time
+}
+
+// SweepSummary result of FindExpired and DeleteExpired
+type SweepSummary struct {
+	Expired []ExpiredObject
+	// Kept objects which haven't expired or have no expiry
+	Kept    int
+	Deleted int
+	// Failed error of each key whose tags could not be read or which could not be deleted
+	Failed map[string]error
+}
+
+// FindExpired find objects under prefix whose expiry tag is before now, reading the tags by Performance.Workers at once
+func (s S3ry) FindExpired(ctx context.Context, bucket string, prefix string, now time.Time) (SweepSummary, error) {
+	summary := SweepSummary{Failed: map[string]error{}}
+	s, err := s.forBucket(bucket)
+	if err != nil {
+		return summary, err
+	}
+
+	var mu sync.Mutex
+	pool := worker.New(ctx, s.config().Performance.Workers)
+	var submitErr error
+	err = s.Svc.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(bucket),
+		Prefix:  aws.String(prefix),
+		MaxKeys: aws.Int64(s.config().Performance.ListPageSize),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, object := range page.Contents {
+			key := aws.StringValue(object.Key)
+			submitErr = pool.Submit(func(ctx context.Context) {
+				tags, err := s.ObjectTags(ctx, bucket, key)
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					summary.Failed[key] = err
+					return
+				}
+				if expires, ok := objectExpiry(tags); ok && expires.Before(now) {
+					summary.Expired = append(summary.Expired, ExpiredObject{Key: key, Expires: expires})
+					return
+				}
+				summary.Kept++
+			})
+			if submitErr != nil {
+				return false
+			}
+		}
+		return true
+	})
+	pool.Wait()
+	sort.Slice(summary.Expired, func(a, b int) bool {
+		return summary.Expired[a].Key < summary.Expired[b].Key
+	})
+	if submitErr != nil {
+		return summary, submitErr
+	}
+	return summary, err
+}
+
+// DeleteExpired delete the expired objects of summary, maxDeleteObjects a request
+func (s S3ry) DeleteExpired(ctx context.Context, bucket string, summary *SweepSummary) error {
+	s, err := s.forBucket(bucket)
+	if err != nil {
+		return err
+	}
+	for start := 0; start < len(summary.Expired); start += maxDeleteObjects {
+		end := start + maxDeleteObjects
+		if end > len(summary.Expired) {
+			end = len(summary.Expired)
+		}
+		var objects []*s3.ObjectIdentifier
+		for _, o := range summary.Expired[start:end] {
+			objects = append(objects, &s3.ObjectIdentifier{Key: aws.String(o.Key)})
+		}
+		out, err := s.Svc.DeleteObjectsWithContext(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(bucket),
+			Delete: &s3.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return err
+		}
+		failed := map[string]error{}
+		for _, e := range out.Errors {
+			failed[aws.StringValue(e.Key)] = fmt.Errorf("%s: %s", aws.StringValue(e.Code), aws.StringValue(e.Message))
+		}
+		for _, o := range summary.Expired[start:end] {
+			s.track("expire", bucket, o.Key)(failed[o.Key])
+			if err, ok := failed[o.Key]; ok {
+				summary.Failed[o.Key] = err
+				continue
+			}
+			summary.Deleted++
+		}
+	}
+	return nil
+}
+
+// Expire delete the objects under s3:// URI target whose expiry set by put --expire-in has passed,
+// used by the expire command. dryRun only prints them
+func Expire(ctx context.Context, cfg *Config, target string, dryRun bool, w io.Writer) error {
+	bucket, prefix, err := ParseS3URI(target)
+	if err != nil {
+		return err
+	}
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	if !dryRun {
+		if err := s.preflight(ctx, bucket, prefix, PermListBucket, PermDeleteObject); err != nil {
+			return err
+		}
+	}
+	sps(i18nPrinter.Sprintf("Checking object expiry ..."))
+	summary, err := s.FindExpired(ctx, bucket, prefix, time.Now())
+	spe()
+	if err != nil {
+		return err
+	}
+	for _, o := range summary.Expired {
+		fmt.Fprintf(w, "%s %s\n", o.Key, o.Expires.Format(time.RFC3339))
+	}
+	if dryRun {
+		fmt.Fprintln(w, i18nPrinter.Sprintf("Would delete: %d, kept: %d, failed: %d", len(summary.Expired), summary.Kept, len(summary.Failed)))
+	} else {
+		if len(summary.Expired) > 0 {
+			if err := cfg.confirmDestructive(true, i18nPrinter.Sprintf("Delete %d expired objects under% s? [Yy] / [Nn]", len(summary.Expired), target)); err != nil {
+				return err
+			}
+			err = s.DeleteExpired(ctx, bucket, &summary)
+		}
+		fmt.Fprintln(w, i18nPrinter.Sprintf("Deleted: %d, kept: %d, failed: %d", summary.Deleted, summary.Kept, len(summary.Failed)))
+	}
+	if err == nil && len(summary.Failed) > 0 {
+		err = &PartialError{Failed: len(summary.Failed), Op: "expire"}
+	}
+	return err
+}
@@ -0,0 +1,87 @@
+package s3ry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseExpiry(t *testing.T) {
+	d, err := ParseExpiry("30d")
+	assert.NoError(t, err)
+	assert.Equal(t, 30*24*time.Hour, d)
+	d, err = ParseExpiry("12h")
+	assert.NoError(t, err)
+	assert.Equal(t, 12*time.Hour, d)
+	for _, s := range []string{"", "d", "0d", "-1d", "xd", "soon", "-1h"} {
+		_, err := ParseExpiry(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestPutExpireIn(t *testing.T) {
+	fake := newFakeS3("bucket")
+	cfg := DefaultConfig()
+	cfg.Upload.ExpireIn = Duration(30 * 24 * time.Hour)
+	s, srv := newTestS3ry(cfg, fake)
+	defer srv.Close()
+
+	before := time.Now().Add(30 * 24 * time.Hour).Truncate(time.Second)
+	_, err := s.PutStream(context.Background(), "bucket", "key", strings.NewReader("hello"))
+	assert.NoError(t, err)
+	tags, err := s.ObjectTags(context.Background(), "bucket", "key")
+	assert.NoError(t, err)
+	expires, ok := objectExpiry(tags)
+	if assert.True(t, ok, tags) {
+		assert.False(t, expires.Before(before))
+		assert.True(t, expires.Before(before.Add(time.Minute)))
+	}
+}
+
+func TestExpire(t *testing.T) {
+	fake := newFakeS3("bucket")
+	for _, key := range []string{"tmp/old", "tmp/older", "tmp/new", "tmp/untagged", "keep/old"} {
+		fake.put("bucket", key, key)
+	}
+	cfg, done := newFakeEndpoint(t, fake)
+	defer done()
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	ctx := context.Background()
+	now := time.Now()
+	expiry := func(key string, at time.Time) {
+		assert.NoError(t, s.putObjectTags(ctx, "bucket", key, map[string]string{expiresTag: at.UTC().Format(time.RFC3339)}))
+	}
+	expiry("tmp/old", now.Add(-time.Hour))
+	expiry("tmp/older", now.Add(-48*time.Hour))
+	expiry("tmp/new", now.Add(time.Hour))
+	expiry("keep/old", now.Add(-time.Hour))
+
+	summary, err := s.FindExpired(ctx, "bucket", "tmp/", now)
+	assert.NoError(t, err)
+	if assert.Len(t, summary.Expired, 2) {
+		assert.Equal(t, "tmp/old", summary.Expired[0].Key)
+		assert.Equal(t, "tmp/older", summary.Expired[1].Key)
+	}
+	assert.Equal(t, 2, summary.Kept)
+
+	var out bytes.Buffer
+	assert.NoError(t, Expire(ctx, cfg, "s3://bucket/tmp/", true, &out))
+	assert.Contains(t, out.String(), "tmp/old ")
+	assert.Len(t, fake.keys("bucket"), 5)
+
+	defer func(c func(string) bool) { confirm = c }(confirm)
+	confirm = func(string) bool { return false }
+	assert.True(t, errors.Is(Expire(ctx, cfg, "s3://bucket/tmp/", false, &out), ErrCancelled))
+	assert.Len(t, fake.keys("bucket"), 5)
+
+	confirm = func(string) bool { return true }
+	out.Reset()
+	assert.NoError(t, Expire(ctx, cfg, "s3://bucket/tmp/", false, &out))
+	assert.Equal(t, []string{"keep/old", "tmp/new", "tmp/untagged"}, fake.keys("bucket"))
+	assert.Equal(t, 1, fake.count("DELETE_OBJECTS"))
+}
@@ -0,0 +1,738 @@
+package s3ry
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fakeObject object stored in fakeS3
+type fakeObject struct {
+	data         []byte
+	header       http.Header
+	lastModified time.Time
+	// acl canned ACL set by PutObjectAcl
+	acl string
+	// parts sizes of the parts of a multipart upload, read by GetObject partNumber
+	parts []int
+	// tagging body of PutObjectTagging
+	tagging []byte
+}
+
+// fakeS3 in-memory S3 for tests, path-style requests only
+type fakeS3 struct {
+	mu      sync.Mutex
+	buckets map[string]map[string]*fakeObject
+	uploads map[string]map[int][]byte
+	// uploadKeys "bucket/key" of each multipart upload in uploads
+	uploadKeys map[string]string
+	// uploadHeaders headers of each multipart upload stored with the object
+	uploadHeaders map[string]http.Header
+	// acls canned ACL set by PutBucketAcl
+	acls map[string]string
+	// notifications body of PutBucketNotificationConfiguration
+	notifications map[string][]byte
+	// tagging body of PutBucketTagging
+	tagging map[string][]byte
+	// lifecycles body of PutBucketLifecycleConfiguration
+	lifecycles map[string][]byte
+	// logging body of PutBucketLogging
+	logging map[string][]byte
+	// policies body of PutBucketPolicy
+	policies map[string][]byte
+	// ownership body of PutBucketOwnershipControls
+	ownership map[string][]byte
+	// versioned buckets whose versioning is enabled
+	versioned map[string]bool
+	// requests operation names in received order, e.g. "PUT", "COPY"
+	requests []string
+	// hook called before handling a request, returning true stops handling
+	hook func(w http.ResponseWriter, r *http.Request) bool
+}
+
+// newFakeS3 create fakeS3 with empty buckets
+func newFakeS3(buckets ...string) *fakeS3 {
+	f := &fakeS3{buckets: map[string]map[string]*fakeObject{}, uploads: map[string]map[int][]byte{}, uploadKeys: map[string]string{}, uploadHeaders: map[string]http.Header{}, acls: map[string]string{}, notifications: map[string][]byte{}, tagging: map[string][]byte{}, lifecycles: map[string][]byte{}, logging: map[string][]byte{}, policies: map[string][]byte{}, ownership: map[string][]byte{}, versioned: map[string]bool{}}
+	for _, b := range buckets {
+		f.buckets[b] = map[string]*fakeObject{}
+	}
+	return f
+}
+
+// put store object directly
+func (f *fakeS3) put(bucket string, key string, data string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.buckets[bucket][key] = &fakeObject{data: []byte(data), header: http.Header{}, lastModified: time.Now()}
+}
+
+// get return stored object
+func (f *fakeS3) get(bucket string, key string) (*fakeObject, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	o, ok := f.buckets[bucket][key]
+	return o, ok
+}
+
+// keys return sorted keys of bucket
+func (f *fakeS3) keys(bucket string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var keys []string
+	for k := range f.buckets[bucket] {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// count return number of requests with the operation name
+func (f *fakeS3) count(op string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, r := range f.requests {
+		if r == op {
+			n++
+		}
+	}
+	return n
+}
+
+func (f *fakeS3) record(op string) {
+	f.mu.Lock()
+	f.requests = append(f.requests, op)
+	f.mu.Unlock()
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if f.hook != nil && f.hook(w, r) {
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	parts := strings.SplitN(path, "/", 2)
+	bucket := parts[0]
+	key := ""
+	if len(parts) == 2 {
+		key = parts[1]
+	}
+	q := r.URL.Query()
+	if path == "" {
+		f.record("LIST_BUCKETS")
+		var buckets []string
+		f.mu.Lock()
+		for name := range f.buckets {
+			buckets = append(buckets, "<Bucket><Name>"+name+"</Name></Bucket>")
+		}
+		f.mu.Unlock()
+		sort.Strings(buckets)
+		fmt.Fprintf(w, `<ListAllMyBucketsResult><Buckets>%s</Buckets></ListAllMyBucketsResult>`, strings.Join(buckets, ""))
+		return
+	}
+
+	f.mu.Lock()
+	objects, ok := f.buckets[bucket]
+	f.mu.Unlock()
+	if !ok {
+		f.record("NoSuchBucket")
+		writeFakeError(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	switch {
+	case key == "" && has(q, "location"):
+		f.record("LOCATION")
+		w.Write([]byte(`<LocationConstraint xmlns="http://s3.amazonaws.com/doc/2006-03-01/"/>`))
+	case has(q, "notification") && r.Method == http.MethodPut:
+		f.record("PUT_NOTIFICATION")
+		body, _ := ioutil.ReadAll(r.Body)
+		f.mu.Lock()
+		f.notifications[bucket] = body
+		f.mu.Unlock()
+	case has(q, "notification"):
+		f.record("GET_NOTIFICATION")
+		f.mu.Lock()
+		body := f.notifications[bucket]
+		f.mu.Unlock()
+		if body == nil {
+			body = []byte(`<NotificationConfiguration/>`)
+		}
+		w.Write(body)
+	case key == "" && has(q, "logging") && r.Method == http.MethodPut:
+		f.record("PUT_LOGGING")
+		body, _ := ioutil.ReadAll(r.Body)
+		f.mu.Lock()
+		f.logging[bucket] = body
+		f.mu.Unlock()
+	case key == "" && has(q, "logging"):
+		f.record("GET_LOGGING")
+		f.mu.Lock()
+		body := f.logging[bucket]
+		f.mu.Unlock()
+		if body == nil {
+			body = []byte(`<BucketLoggingStatus/>`)
+		}
+		w.Write(body)
+	case key == "" && has(q, "ownershipControls") && r.Method == http.MethodPut:
+		f.record("PUT_OWNERSHIP")
+		if r.Header.Get("Content-Md5") == "" {
+			writeFakeError(w, http.StatusBadRequest, "InvalidRequest")
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		f.mu.Lock()
+		f.ownership[bucket] = body
+		f.mu.Unlock()
+	case key == "" && has(q, "ownershipControls"):
+		f.record("GET_OWNERSHIP")
+		f.mu.Lock()
+		body := f.ownership[bucket]
+		f.mu.Unlock()
+		if body == nil {
+			writeFakeError(w, http.StatusNotFound, "OwnershipControlsNotFoundError")
+			return
+		}
+		w.Write(body)
+	case key == "" && has(q, "versioning") && r.Method == http.MethodGet:
+		f.record("GET_VERSIONING")
+		f.mu.Lock()
+		versioned := f.versioned[bucket]
+		f.mu.Unlock()
+		status := ""
+		if versioned {
+			status = "<Status>Enabled</Status>"
+		}
+		fmt.Fprintf(w, `<VersioningConfiguration>%s</VersioningConfiguration>`, status)
+	case key == "" && has(q, "policy") && r.Method == http.MethodPut:
+		f.record("PUT_POLICY")
+		body, _ := ioutil.ReadAll(r.Body)
+		f.mu.Lock()
+		f.policies[bucket] = body
+		f.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	case key == "" && has(q, "policy"):
+		f.record("GET_POLICY")
+		f.mu.Lock()
+		body := f.policies[bucket]
+		f.mu.Unlock()
+		if body == nil {
+			writeFakeError(w, http.StatusNotFound, "NoSuchBucketPolicy")
+			return
+		}
+		w.Write(body)
+	case key == "" && has(q, "lifecycle") && r.Method == http.MethodPut:
+		f.record("PUT_LIFECYCLE")
+		body, _ := ioutil.ReadAll(r.Body)
+		f.mu.Lock()
+		f.lifecycles[bucket] = body
+		f.mu.Unlock()
+	case key == "" && has(q, "lifecycle"):
+		f.record("GET_LIFECYCLE")
+		f.mu.Lock()
+		body := f.lifecycles[bucket]
+		f.mu.Unlock()
+		if body == nil {
+			writeFakeError(w, http.StatusNotFound, "NoSuchLifecycleConfiguration")
+			return
+		}
+		w.Write(body)
+	case has(q, "tagging") && key != "":
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		o, ok := f.buckets[bucket][key]
+		if !ok {
+			writeFakeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		switch r.Method {
+		case http.MethodPut:
+			f.requests = append(f.requests, "PUT_OBJECT_TAGGING")
+			o.tagging, _ = ioutil.ReadAll(r.Body)
+		case http.MethodDelete:
+			f.requests = append(f.requests, "DELETE_OBJECT_TAGGING")
+			o.tagging = nil
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			f.requests = append(f.requests, "GET_OBJECT_TAGGING")
+			if o.tagging != nil {
+				w.Write(o.tagging)
+				return
+			}
+			// tags set by the upload
+			tags, _ := url.ParseQuery(o.header.Get("X-Amz-Tagging"))
+			var set []string
+			for k := range tags {
+				set = append(set, fmt.Sprintf(`<Tag><Key>%s</Key><Value>%s</Value></Tag>`, k, tags.Get(k)))
+			}
+			sort.Strings(set)
+			fmt.Fprintf(w, `<Tagging><TagSet>%s</TagSet></Tagging>`, strings.Join(set, ""))
+		}
+	case key == "" && has(q, "tagging") && r.Method == http.MethodPut:
+		f.record("PUT_TAGGING")
+		body, _ := ioutil.ReadAll(r.Body)
+		f.mu.Lock()
+		f.tagging[bucket] = body
+		f.mu.Unlock()
+	case key == "" && has(q, "tagging") && r.Method == http.MethodDelete:
+		f.record("DELETE_TAGGING")
+		f.mu.Lock()
+		delete(f.tagging, bucket)
+		f.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	case key == "" && has(q, "tagging"):
+		f.record("GET_TAGGING")
+		f.mu.Lock()
+		body := f.tagging[bucket]
+		f.mu.Unlock()
+		if body == nil {
+			writeFakeError(w, http.StatusNotFound, "NoSuchTagSet")
+			return
+		}
+		w.Write(body)
+	case has(q, "acl") && r.Method == http.MethodPut:
+		f.record("PUT_ACL")
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if key == "" {
+			f.acls[bucket] = r.Header.Get("X-Amz-Acl")
+			return
+		}
+		o, ok := objects[key]
+		if !ok {
+			writeFakeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		o.acl = r.Header.Get("X-Amz-Acl")
+	case has(q, "acl"):
+		f.record("GET_ACL")
+		acl := f.acls[bucket]
+		if key != "" {
+			o, ok := f.get(bucket, key)
+			if !ok {
+				writeFakeError(w, http.StatusNotFound, "NoSuchKey")
+				return
+			}
+			acl = o.acl
+		}
+		writeFakeACL(w, acl)
+	case key == "" && r.Method == http.MethodGet && has(q, "uploads"):
+		f.record("LIST_MULTIPART")
+		f.mu.Lock()
+		var uploads []string
+		for id, uploadKey := range f.uploadKeys {
+			if strings.HasPrefix(uploadKey, bucket+"/"+q.Get("prefix")) {
+				uploads = append(uploads, fmt.Sprintf(`<Upload><Key>%s</Key><UploadId>%s</UploadId></Upload>`, strings.TrimPrefix(uploadKey, bucket+"/"), id))
+			}
+		}
+		f.mu.Unlock()
+		sort.Strings(uploads)
+		fmt.Fprintf(w, `<ListMultipartUploadsResult><Bucket>%s</Bucket><IsTruncated>false</IsTruncated>%s</ListMultipartUploadsResult>`, bucket, strings.Join(uploads, ""))
+	case key == "" && r.Method == http.MethodGet && has(q, "versions"):
+		f.record("LIST_VERSIONS")
+		f.listVersions(w, bucket, q)
+	case key == "" && r.Method == http.MethodGet:
+		f.record("LIST")
+		f.list(w, bucket, q)
+	case r.Method == http.MethodPost && has(q, "delete"):
+		f.record("DELETE_OBJECTS")
+		var input struct {
+			Objects []struct{ Key string } `xml:"Object"`
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		xml.Unmarshal(body, &input)
+		f.mu.Lock()
+		for _, o := range input.Objects {
+			delete(objects, o.Key)
+		}
+		f.mu.Unlock()
+		w.Write([]byte(`<DeleteResult/>`))
+	case r.Method == http.MethodPost && has(q, "restore"):
+		f.record("RESTORE")
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		o, ok := objects[key]
+		if !ok {
+			writeFakeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		if o.header.Get("X-Amz-Restore") != "" {
+			writeFakeError(w, http.StatusConflict, "RestoreAlreadyInProgress")
+			return
+		}
+		o.header.Set("X-Amz-Restore", `ongoing-request="true"`)
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodPost && has(q, "uploads"):
+		f.record("CREATE_MULTIPART")
+		id := fmt.Sprintf("upload-%d", len(f.requests))
+		f.mu.Lock()
+		f.uploads[id] = map[int][]byte{}
+		f.uploadKeys[id] = bucket + "/" + key
+		f.uploadHeaders[id] = amzHeaders(r.Header)
+		f.mu.Unlock()
+		fmt.Fprintf(w, `<InitiateMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>`, bucket, key, id)
+	case r.Method == http.MethodPut && q.Get("uploadId") != "":
+		n, _ := strconv.Atoi(q.Get("partNumber"))
+		var data []byte
+		if src := r.Header.Get("X-Amz-Copy-Source"); src != "" {
+			f.record("UPLOAD_PART_COPY")
+			o, ok := f.source(src)
+			if !ok {
+				writeFakeError(w, http.StatusNotFound, "NoSuchKey")
+				return
+			}
+			if customerKeyMismatch(o, r.Header, "X-Amz-Copy-Source-") {
+				writeFakeError(w, http.StatusBadRequest, "InvalidRequest")
+				return
+			}
+			data = o.data
+			if rng := r.Header.Get("X-Amz-Copy-Source-Range"); rng != "" {
+				data = sliceRange(data, rng)
+			}
+			sum := md5.Sum(data)
+			fmt.Fprintf(w, `<CopyPartResult><ETag>"%s"</ETag></CopyPartResult>`, hex.EncodeToString(sum[:]))
+		} else {
+			f.record("UPLOAD_PART")
+			// like S3, a part checksum needs the algorithm declared by CreateMultipartUpload
+			f.mu.Lock()
+			declared := f.uploadHeaders[q.Get("uploadId")].Get("X-Amz-Checksum-Algorithm")
+			f.mu.Unlock()
+			for k := range r.Header {
+				if strings.HasPrefix(k, "X-Amz-Checksum-") && k != "X-Amz-Checksum-Algorithm" && !strings.EqualFold(k, "X-Amz-Checksum-"+declared) {
+					writeFakeError(w, http.StatusBadRequest, "InvalidRequest")
+					return
+				}
+			}
+			data, _ = ioutil.ReadAll(r.Body)
+			sum := md5.Sum(data)
+			w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+		}
+		f.mu.Lock()
+		if parts, ok := f.uploads[q.Get("uploadId")]; ok {
+			parts[n] = data
+		}
+		f.mu.Unlock()
+	case r.Method == http.MethodPost && q.Get("uploadId") != "":
+		f.record("COMPLETE_MULTIPART")
+		f.mu.Lock()
+		parts := f.uploads[q.Get("uploadId")]
+		var numbers []int
+		for n := range parts {
+			numbers = append(numbers, n)
+		}
+		sort.Ints(numbers)
+		var data []byte
+		var sizes []int
+		for _, n := range numbers {
+			data = append(data, parts[n]...)
+			sizes = append(sizes, len(parts[n]))
+		}
+		header := f.uploadHeaders[q.Get("uploadId")]
+		if header == nil {
+			header = http.Header{}
+		}
+		delete(f.uploads, q.Get("uploadId"))
+		delete(f.uploadKeys, q.Get("uploadId"))
+		delete(f.uploadHeaders, q.Get("uploadId"))
+		objects[key] = &fakeObject{data: data, header: header, lastModified: time.Now(), parts: sizes}
+		f.mu.Unlock()
+		fmt.Fprintf(w, `<CompleteMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><ETag>"multipart"</ETag></CompleteMultipartUploadResult>`, bucket, key)
+	case r.Method == http.MethodDelete && q.Get("uploadId") != "":
+		f.record("ABORT_MULTIPART")
+		f.mu.Lock()
+		delete(f.uploads, q.Get("uploadId"))
+		delete(f.uploadKeys, q.Get("uploadId"))
+		f.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		f.record("COPY")
+		o, ok := f.source(r.Header.Get("X-Amz-Copy-Source"))
+		if !ok {
+			writeFakeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		if customerKeyMismatch(o, r.Header, "X-Amz-Copy-Source-") {
+			writeFakeError(w, http.StatusBadRequest, "InvalidRequest")
+			return
+		}
+		header := o.header
+		if r.Header.Get("X-Amz-Metadata-Directive") == "REPLACE" {
+			header = amzHeaders(r.Header)
+		} else {
+			// like S3, encryption and storage class are those of the request, not of the source
+			header = http.Header{}
+			for k, v := range o.header {
+				header[k] = v
+			}
+			for _, k := range []string{"X-Amz-Storage-Class", "X-Amz-Server-Side-Encryption", "X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", "X-Amz-Server-Side-Encryption-Customer-Algorithm"} {
+				header.Del(k)
+				if v := r.Header.Get(k); v != "" {
+					header.Set(k, v)
+				}
+			}
+		}
+		f.mu.Lock()
+		objects[key] = &fakeObject{data: o.data, header: header, lastModified: time.Now()}
+		f.mu.Unlock()
+		fmt.Fprintf(w, `<CopyObjectResult><ETag>"copy"</ETag><LastModified>%s</LastModified></CopyObjectResult>`, time.Now().UTC().Format(time.RFC3339))
+	case r.Method == http.MethodPut:
+		f.record("PUT")
+		data, _ := ioutil.ReadAll(r.Body)
+		f.mu.Lock()
+		objects[key] = &fakeObject{data: data, header: amzHeaders(r.Header), lastModified: time.Now()}
+		f.mu.Unlock()
+		sum := md5.Sum(data)
+		w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		f.record(r.Method)
+		o, ok := f.get(bucket, key)
+		if !ok {
+			writeFakeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		if customerKeyMismatch(o, r.Header, "X-Amz-") {
+			writeFakeError(w, http.StatusBadRequest, "InvalidRequest")
+			return
+		}
+		for k, v := range o.header {
+			w.Header()[k] = v
+		}
+		sum := md5.Sum(o.data)
+		w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+		w.Header().Set("Last-Modified", o.lastModified.UTC().Format(http.TimeFormat))
+		data := o.data
+		if n, _ := strconv.Atoi(q.Get("partNumber")); n > 0 && n <= len(o.parts) {
+			start := 0
+			for _, size := range o.parts[:n-1] {
+				start += size
+			}
+			data = o.data[start : start+o.parts[n-1]]
+			w.Header().Set("X-Amz-Mp-Parts-Count", strconv.Itoa(len(o.parts)))
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			w.WriteHeader(http.StatusPartialContent)
+		} else if rng := r.Header.Get("Range"); rng != "" {
+			data = sliceRange(o.data, rng)
+			start, _ := parseFakeRange(rng, len(o.data))
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, start+len(data)-1, len(o.data)))
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			w.WriteHeader(http.StatusPartialContent)
+		} else {
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		}
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+	case r.Method == http.MethodDelete:
+		f.record("DELETE")
+		f.mu.Lock()
+		delete(objects, key)
+		f.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeFakeError(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+// source find object of x-amz-copy-source
+func (f *fakeS3) source(src string) (*fakeObject, bool) {
+	src, _ = url.PathUnescape(strings.TrimPrefix(src, "/"))
+	parts := strings.SplitN(src, "/", 2)
+	if len(parts) != 2 {
+		return nil, false
+	}
+	return f.get(parts[0], parts[1])
+}
+
+type fakeListResult struct {
+	XMLName               xml.Name          `xml:"ListBucketResult"`
+	Name                  string            `xml:"Name"`
+	Prefix                string            `xml:"Prefix"`
+	IsTruncated           bool              `xml:"IsTruncated"`
+	KeyCount              int               `xml:"KeyCount"`
+	NextContinuationToken string            `xml:"NextContinuationToken,omitempty"`
+	NextMarker            string            `xml:"NextMarker,omitempty"`
+	Contents              []fakeListContent `xml:"Contents"`
+	CommonPrefixes        []fakeListPrefix  `xml:"CommonPrefixes"`
+}
+
+type fakeListPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+type fakeListContent struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int    `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+// list ListObjects and ListObjectsV2, continuation token is the last returned key
+func (f *fakeS3) list(w http.ResponseWriter, bucket string, q url.Values) {
+	maxKeys := 1000
+	if v := q.Get("max-keys"); v != "" {
+		maxKeys, _ = strconv.Atoi(v)
+	}
+	after := q.Get("start-after")
+	if v := q.Get("continuation-token"); v != "" {
+		after = v
+	}
+	if v := q.Get("marker"); v != "" {
+		after = v
+	}
+	prefix := q.Get("prefix")
+	delimiter := q.Get("delimiter")
+	result := fakeListResult{Name: bucket, Prefix: prefix}
+	for _, key := range f.keys(bucket) {
+		if !strings.HasPrefix(key, prefix) || key <= after {
+			continue
+		}
+		// common prefixes aren't paginated, enough for tests
+		if i := strings.Index(key[len(prefix):], delimiter); delimiter != "" && i >= 0 {
+			common := key[:len(prefix)+i+len(delimiter)]
+			if n := len(result.CommonPrefixes); n == 0 || result.CommonPrefixes[n-1].Prefix != common {
+				result.CommonPrefixes = append(result.CommonPrefixes, fakeListPrefix{Prefix: common})
+			}
+			continue
+		}
+		if len(result.Contents) == maxKeys {
+			result.IsTruncated = true
+			break
+		}
+		o, _ := f.get(bucket, key)
+		class := o.header.Get("X-Amz-Storage-Class")
+		if class == "" {
+			class = "STANDARD"
+		}
+		sum := md5.Sum(o.data)
+		result.Contents = append(result.Contents, fakeListContent{
+			Key:          key,
+			LastModified: o.lastModified.UTC().Format(time.RFC3339),
+			ETag:         `"` + hex.EncodeToString(sum[:]) + `"`,
+			Size:         len(o.data),
+			StorageClass: class,
+		})
+	}
+	result.KeyCount = len(result.Contents)
+	if result.IsTruncated {
+		last := result.Contents[len(result.Contents)-1].Key
+		if q.Get("list-type") == "2" {
+			result.NextContinuationToken = last
+		} else {
+			result.NextMarker = last
+		}
+	}
+	b, _ := xml.Marshal(result)
+	w.Write(b)
+}
+
+// listVersions ListObjectVersions, every object is its only version "null", key marker is the last returned key
+func (f *fakeS3) listVersions(w http.ResponseWriter, bucket string, q url.Values) {
+	maxKeys := 1000
+	if v := q.Get("max-keys"); v != "" {
+		maxKeys, _ = strconv.Atoi(v)
+	}
+	var versions []string
+	truncated := false
+	next := ""
+	for _, key := range f.keys(bucket) {
+		if !strings.HasPrefix(key, q.Get("prefix")) || key <= q.Get("key-marker") {
+			continue
+		}
+		if len(versions) == maxKeys {
+			truncated = true
+			break
+		}
+		o, _ := f.get(bucket, key)
+		versions = append(versions, fmt.Sprintf(`<Version><Key>%s</Key><VersionId>null</VersionId><IsLatest>true</IsLatest><LastModified>%s</LastModified><Size>%d</Size><StorageClass>STANDARD</StorageClass></Version>`,
+			key, o.lastModified.UTC().Format(time.RFC3339), len(o.data)))
+		next = key
+	}
+	markers := ""
+	if truncated {
+		markers = fmt.Sprintf(`<NextKeyMarker>%s</NextKeyMarker><NextVersionIdMarker>null</NextVersionIdMarker>`, next)
+	}
+	fmt.Fprintf(w, `<ListVersionsResult><Name>%s</Name><IsTruncated>%t</IsTruncated>%s%s</ListVersionsResult>`, bucket, truncated, markers, strings.Join(versions, ""))
+}
+
+func has(q url.Values, name string) bool {
+	_, ok := q[name]
+	return ok
+}
+
+// customerKeyMismatch check the SSE-C key of request h, with its headers starting with prefix, doesn't fit object o:
+// like S3, an SSE-C object is only read with its key and other objects only without one
+func customerKeyMismatch(o *fakeObject, h http.Header, prefix string) bool {
+	stored := o.header.Get("X-Amz-Server-Side-Encryption-Customer-Algorithm") != ""
+	return stored != (h.Get(prefix+"Server-Side-Encryption-Customer-Algorithm") != "")
+}
+
+// amzHeaders keep headers stored with the object
+func amzHeaders(h http.Header) http.Header {
+	stored := http.Header{}
+	for k, v := range h {
+		if strings.HasPrefix(k, "X-Amz-Meta-") || strings.HasPrefix(k, "X-Amz-Checksum-") || k == "Content-Type" || k == "Content-Encoding" ||
+			k == "Content-Language" || k == "Expires" || k == "X-Amz-Website-Redirect-Location" ||
+			k == "X-Amz-Storage-Class" || k == "X-Amz-Server-Side-Encryption" ||
+			k == "X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id" || k == "X-Amz-Tagging" ||
+			k == "X-Amz-Server-Side-Encryption-Customer-Algorithm" {
+			stored[k] = v
+		}
+	}
+	return stored
+}
+
+// parseFakeRange parse "bytes=start-end"
+func parseFakeRange(rng string, size int) (int, int) {
+	rng = strings.TrimPrefix(rng, "bytes=")
+	parts := strings.SplitN(rng, "-", 2)
+	start, _ := strconv.Atoi(parts[0])
+	end := size - 1
+	if len(parts) == 2 && parts[1] != "" {
+		end, _ = strconv.Atoi(parts[1])
+	}
+	if end >= size {
+		end = size - 1
+	}
+	return start, end
+}
+
+// sliceRange return bytes of rng
+func sliceRange(data []byte, rng string) []byte {
+	start, end := parseFakeRange(rng, len(data))
+	if start > end {
+		return nil
+	}
+	return data[start : end+1]
+}
+
+// writeFakeACL write AccessControlPolicy of canned ACL
+func writeFakeACL(w http.ResponseWriter, acl string) {
+	grants := `<Grant><Grantee xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:type="CanonicalUser"><ID>owner-id</ID><DisplayName>owner</DisplayName></Grantee><Permission>FULL_CONTROL</Permission></Grant>`
+	group := map[string]string{
+		"public-read":        "http://acs.amazonaws.com/groups/global/AllUsers",
+		"authenticated-read": "http://acs.amazonaws.com/groups/global/AuthenticatedUsers",
+	}[acl]
+	if group != "" {
+		grants += `<Grant><Grantee xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:type="Group"><URI>` + group + `</URI></Grantee><Permission>READ</Permission></Grant>`
+	}
+	if acl == "log-delivery-write" {
+		for _, permission := range []string{"WRITE", "READ_ACP"} {
+			grants += `<Grant><Grantee xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:type="Group"><URI>http://acs.amazonaws.com/groups/s3/LogDelivery</URI></Grantee><Permission>` + permission + `</Permission></Grant>`
+		}
+	}
+	fmt.Fprintf(w, `<AccessControlPolicy><Owner><ID>owner-id</ID><DisplayName>owner</DisplayName></Owner><AccessControlList>%s</AccessControlList></AccessControlPolicy>`, grants)
+}
+
+func writeFakeError(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<Error><Code>%s</Code><Message>%s</Message></Error>`, code, code)
+}
@@ -0,0 +1,134 @@
+package s3ry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// SizeBin objects whose size is below Max
+type SizeBin struct {
+	Label string
+	// Max exclusive upper bound, 0 for no bound
+	Max     int64
+	Objects int64
+	Bytes   int64
+}
+
+// SizeHistogram object count and bytes by size
+type SizeHistogram struct {
+	Bins    []SizeBin
+	Objects int64
+	Bytes   int64
+}
+
+// NewSizeHistogram create SizeHistogram of <1KB, 1KB-1MB, 1MB-1GB and >=1GB bins
+func NewSizeHistogram() *SizeHistogram {
+	return &SizeHistogram{Bins: []SizeBin{
+		{Label: "<1KB", Max: 1024},
+		{Label: "1KB-1MB", Max: 1024 * 1024},
+		{Label: "1MB-1GB", Max: 1024 * 1024 * 1024},
+		{Label: ">=1GB"},
+	}}
+}
+
+// Add count object of size in its bin
+func (h *SizeHistogram) Add(size int64) {
+	h.Objects++
+	h.Bytes += size
+	for i := range h.Bins {
+		if h.Bins[i].Max == 0 || size < h.Bins[i].Max {
+			h.Bins[i].Objects++
+			h.Bins[i].Bytes += size
+			return
+		}
+	}
+}
+
+// SizeHistogram list objects under prefix and count them by size
+func (s S3ry) SizeHistogram(ctx context.Context, bucket string, prefix string) (*SizeHistogram, error) {
+	h := NewSizeHistogram()
+	s, err := s.forBucket(bucket)
+	if err != nil {
+		return h, err
+	}
+	err = s.Svc.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(bucket),
+		Prefix:  aws.String(prefix),
+		MaxKeys: aws.Int64(s.config().Performance.ListPageSize),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, object := range page.Contents {
+			h.Add(aws.Int64Value(object.Size))
+		}
+		return true
+	})
+	return h, err
+}
+
+// Print write histogram as a table with the share of objects and bytes of each bin
+func (h *SizeHistogram) Print(w io.Writer) {
+	share := func(n int64, total int64) float64 {
+		if total == 0 {
+			return 0
+		}
+		return float64(n) * 100 / float64(total)
+	}
+	fmt.Fprintf(w, "%-8s %12s %7s %16s %7s\n", "size", "objects", "", "bytes", "")
+	for _, b := range h.Bins {
+		fmt.Fprintf(w, "%-8s %12s %6s%% %16s %6s%%\n", b.Label, locale.Number(b.Objects), locale.Float(share(b.Objects, h.Objects), 1), locale.Number(b.Bytes), locale.Float(share(b.Bytes, h.Bytes), 1))
+	}
+	fmt.Fprintf(w, "%-8s %12s %7s %16s\n", "total", locale.Number(h.Objects), "", locale.Number(h.Bytes))
+}
+
+// Stats print summary of the objects under s3:// URI target, used by the stats command
+// output "json" prints it as JSON
+// with cloudwatch the totals of a whole bucket are read from its CloudWatch metrics instead of listing it,
+// falling back to listing when CloudWatch has no metrics of the bucket
+func Stats(ctx context.Context, cfg *Config, target string, cloudwatch bool, output string, w io.Writer) error {
+	bucket, prefix, err := ParseS3URI(target)
+	if err != nil {
+		return err
+	}
+	if output != "" && output != "json" {
+		return fmt.Errorf("unknown output %q, use json", output)
+	}
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	if cloudwatch {
+		if prefix != "" {
+			return fmt.Errorf("%w %q, CloudWatch keeps metrics of whole buckets only", ErrInvalidURI, target)
+		}
+		m, err := s.BucketMetrics(ctx, bucket)
+		if err == nil {
+			return printStats(m, output, w)
+		}
+		if !errors.Is(err, ErrNoBucketMetrics) {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, err.Error()+", listing the objects instead")
+	}
+	summary, err := s.BucketSummary(ctx, bucket, prefix)
+	if err != nil {
+		return err
+	}
+	return printStats(summary, output, w)
+}
+
+// printStats write stats as text, or JSON when output is "json"
+func printStats(stats interface{ Print(io.Writer) }, output string, w io.Writer) error {
+	if output != "json" {
+		stats.Print(w)
+		return nil
+	}
+	b, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
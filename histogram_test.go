@@ -0,0 +1,47 @@
+package s3ry
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSizeHistogramAdd(t *testing.T) {
+	h := NewSizeHistogram()
+	for _, size := range []int64{0, 1023, 1024, 1024*1024 - 1, 1024 * 1024, 1024*1024*1024 - 1, 1024 * 1024 * 1024, 5 * 1024 * 1024 * 1024} {
+		h.Add(size)
+	}
+	var objects []int64
+	for _, b := range h.Bins {
+		objects = append(objects, b.Objects)
+	}
+	assert.Equal(t, []int64{2, 2, 2, 2}, objects)
+	assert.Equal(t, int64(1023), h.Bins[0].Bytes)
+	assert.Equal(t, int64(6*1024*1024*1024), h.Bins[3].Bytes)
+	assert.Equal(t, int64(8), h.Objects)
+}
+
+func TestSizeHistogramOfBucket(t *testing.T) {
+	fake := newFakeS3("bucket")
+	fake.put("bucket", "logs/a", "a")
+	fake.put("bucket", "logs/b", "bb")
+	fake.put("bucket", "logs/c", strings.Repeat("c", 2048))
+	fake.put("bucket", "other/d", "d")
+	s, srv := newTestS3ry(DefaultConfig(), fake)
+	defer srv.Close()
+
+	h, err := s.SizeHistogram(context.Background(), "bucket", "logs/")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), h.Bins[0].Objects)
+	assert.Equal(t, int64(3), h.Bins[0].Bytes)
+	assert.Equal(t, int64(1), h.Bins[1].Objects)
+	assert.Equal(t, int64(3), h.Objects)
+
+	var out bytes.Buffer
+	h.Print(&out)
+	assert.Contains(t, out.String(), "<1KB")
+	assert.Contains(t, out.String(), "66.7%")
+}
@@ -0,0 +1,191 @@
+package s3ry
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/seike460/s3ry/internal/history"
+)
+
+// historyRecorder record operations tracked by S3ry in the history store
+// a nil recorder records nothing
+type historyRecorder struct {
+	store *history.Store
+	user  string
+	mu    sync.Mutex
+	// bytes transferred so far by running operations, keyed by historyKey
+	bytes map[string]int64
+	// partSizes part size chosen by running adaptive uploads, keyed by historyKey
+	partSizes map[string]int64
+}
+
+// defaultHistoryPath return path of the operation history next to the config file
+func defaultHistoryPath() string {
+	return configDirFile("history.jsonl")
+}
+
+// newHistoryRecorder create historyRecorder appending to path
+func newHistoryRecorder(path string) *historyRecorder {
+	return &historyRecorder{store: history.Open(path), user: currentUser(), bytes: map[string]int64{}, partSizes: map[string]int64{}}
+}
+
+// currentUser return name of the user running s3ry
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// historyKey key of a running operation
+func historyKey(operation string, bucket string, key string) string {
+	return operation + " " + bucket + "/" + key
+}
+
+// progress remember bytes transferred by the operation
+func (h *historyRecorder) progress(operation string, bucket string, key string, n int64) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.bytes[historyKey(operation, bucket, key)] = n
+}
+
+// partSize remember the part size the adaptive upload settled on
+func (h *historyRecorder) partSize(operation string, bucket string, key string, size int64) {
+	if h == nil || size == 0 {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.partSizes[historyKey(operation, bucket, key)] = size
+}
+
+// finish record the operation started at start
+// like the upload journal it is best effort, a failed write never fails the operation
+func (h *historyRecorder) finish(operation string, bucket string, key string, start time.Time, err error) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	n := h.bytes[historyKey(operation, bucket, key)]
+	partSize := h.partSizes[historyKey(operation, bucket, key)]
+	delete(h.bytes, historyKey(operation, bucket, key))
+	delete(h.partSizes, historyKey(operation, bucket, key))
+	h.mu.Unlock()
+	target := "s3://" + bucket
+	if key != "" {
+		target += "/" + key
+	}
+	e := history.Entry{
+		Time:      start,
+		User:      h.user,
+		Operation: operation,
+		Target:    target,
+		Bytes:     n,
+		Duration:  time.Since(start),
+		PartSize:  partSize,
+		Result:    history.OK,
+	}
+	if err != nil {
+		e.Result = history.Failed
+		e.Error = err.Error()
+	}
+	h.store.Append(e)
+}
+
+// parseHistoryTime parse a date like 2006-01-02 in local time or an RFC 3339 time
+func parseHistoryTime(s string) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// History print recorded operations from since until the end of until, used by the history command
+// since and until are dates like 2006-01-02 or RFC 3339 times, empty for no limit, operation selects one operation
+// like upload and bucket the operations on one bucket, by prints their totals by operation or bucket instead,
+// and output "json" prints JSON lines
+func History(cfg *Config, since string, until string, operation string, bucket string, by string, output string, w io.Writer) error {
+	if output != "" && output != "json" {
+		return fmt.Errorf("unknown output %q, use json", output)
+	}
+	filter := history.Filter{Operation: operation, Bucket: bucket}
+	var err error
+	if since != "" {
+		if filter.Since, err = parseHistoryTime(since); err != nil {
+			return fmt.Errorf("invalid --since %q, use 2006-01-02 or an RFC 3339 time", since)
+		}
+	}
+	if until != "" {
+		if filter.Until, err = parseHistoryTime(until); err != nil {
+			return fmt.Errorf("invalid --until %q, use 2006-01-02 or an RFC 3339 time", until)
+		}
+		if !strings.Contains(until, "T") {
+			// include the whole day
+			filter.Until = filter.Until.AddDate(0, 0, 1)
+		}
+	}
+	entries, err := history.Open(defaultHistoryPath()).Query(filter)
+	if err != nil {
+		return err
+	}
+	if by != "" {
+		aggregates, err := history.Aggregates(entries, by)
+		if err != nil {
+			return err
+		}
+		return printAggregates(aggregates, output, w)
+	}
+	for _, e := range entries {
+		if output == "json" {
+			b, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(w, string(b))
+			continue
+		}
+		line := fmt.Sprintf("%s %s %s %s %s %s %s", locale.Time(e.Time.Local()), e.User, e.Operation, e.Target, locale.Bytes(e.Bytes), locale.Duration(e.Duration), e.Result)
+		if e.Error != "" {
+			// AWS errors span lines, keep one line per operation
+			line += ": " + strings.Join(strings.Fields(e.Error), " ")
+		}
+		fmt.Fprintln(w, line)
+	}
+	return nil
+}
+
+// historyAggregate history.Aggregate with its rates, as printed
+type historyAggregate struct {
+	history.Aggregate
+	ErrorRate float64
+	// Throughput bytes per second
+	Throughput float64
+}
+
+// printAggregates write aggregates as a table or JSON lines
+func printAggregates(aggregates []history.Aggregate, output string, w io.Writer) error {
+	if output != "json" {
+		fmt.Fprintf(w, "%-30s %10s %8s %10s %16s %14s\n", "", "operations", "failed", "error rate", "bytes", "bytes/s")
+	}
+	for _, a := range aggregates {
+		if output == "json" {
+			b, err := json.Marshal(historyAggregate{Aggregate: a, ErrorRate: a.ErrorRate(), Throughput: a.Throughput()})
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(w, string(b))
+			continue
+		}
+		fmt.Fprintf(w, "%-30s %10s %8s %9s%% %16s %14s\n", a.Key, locale.Number(int64(a.Operations)), locale.Number(int64(a.Failed)), locale.Float(a.ErrorRate()*100, 1), locale.Number(a.Bytes), locale.Float(a.Throughput(), 0))
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package s3ry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seike460/s3ry/internal/history"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistoryRecordsOperations(t *testing.T) {
+	fake := newFakeS3("bucket")
+	cfg, done := newFakeEndpoint(t, fake)
+	defer done()
+	cfg.Security.ConfirmDestructive = ConfirmNever
+	src := filepath.Join(cfg.Performance.TempDir, "file")
+	ioutil.WriteFile(src, []byte("hello"), 0644)
+	ctx := context.Background()
+
+	assert.NoError(t, Put(ctx, cfg, src, "s3://bucket/file"))
+	assert.Error(t, Get(ctx, cfg, "s3://bucket/missing", src))
+
+	out := &bytes.Buffer{}
+	assert.NoError(t, History(cfg, "", "", "", "", "", "json", out))
+	var entries []history.Entry
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var e history.Entry
+		assert.NoError(t, json.Unmarshal([]byte(line), &e))
+		entries = append(entries, e)
+	}
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "upload", entries[0].Operation)
+		assert.Equal(t, "s3://bucket/file", entries[0].Target)
+		assert.Equal(t, int64(5), entries[0].Bytes)
+		assert.Equal(t, history.OK, entries[0].Result)
+		assert.Equal(t, currentUser(), entries[0].User)
+		assert.Equal(t, "download", entries[1].Operation)
+		assert.Equal(t, history.Failed, entries[1].Result)
+		assert.NotEmpty(t, entries[1].Error)
+	}
+
+	out.Reset()
+	assert.NoError(t, History(cfg, "", "", "upload", "", "", "", out))
+	assert.Equal(t, 1, strings.Count(out.String(), "\n"))
+	assert.Contains(t, out.String(), "upload s3://bucket/file 5 B")
+
+	// until a date includes the whole day
+	today := time.Now().Format("2006-01-02")
+	out.Reset()
+	assert.NoError(t, History(cfg, today, today, "", "", "", "", out))
+	assert.Equal(t, 2, strings.Count(out.String(), "\n"))
+	out.Reset()
+	assert.NoError(t, History(cfg, time.Now().AddDate(0, 0, 1).Format("2006-01-02"), "", "", "", "", "", out))
+	assert.Empty(t, out.String())
+
+	assert.Error(t, History(cfg, "yesterday", "", "", "", "", "", out))
+
+	// totals by operation, of one bucket
+	out.Reset()
+	assert.NoError(t, History(cfg, "", "", "", "bucket", history.ByOperation, "json", out))
+	var aggregates []historyAggregate
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var a historyAggregate
+		assert.NoError(t, json.Unmarshal([]byte(line), &a))
+		aggregates = append(aggregates, a)
+	}
+	if assert.Len(t, aggregates, 2) {
+		assert.Equal(t, "download", aggregates[0].Key)
+		assert.Equal(t, float64(1), aggregates[0].ErrorRate)
+		assert.Equal(t, "upload", aggregates[1].Key)
+		assert.Equal(t, int64(5), aggregates[1].Bytes)
+	}
+	out.Reset()
+	assert.NoError(t, History(cfg, "", "", "", "other", history.ByOperation, "", out))
+	assert.Equal(t, 1, strings.Count(out.String(), "\n"))
+	assert.Error(t, History(cfg, "", "", "", "", "class", "", out))
+
+	cfg.Logging.History = false
+	assert.NoError(t, Put(ctx, cfg, src, "s3://bucket/file"))
+	out.Reset()
+	assert.NoError(t, History(cfg, "", "", "", "", "", "", out))
+	assert.Equal(t, 2, strings.Count(out.String(), "\n"))
+}
@@ -0,0 +1,236 @@
+// Package consumer runs reactions to S3 event notifications delivered through SQS.
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+)
+
+// Record S3 event of a notification
+type Record struct {
+	// EventName e.g. ObjectCreated:Put, without the s3: prefix as S3 sends it
+	EventName string
+	Bucket    string
+	// Key URL-decoded object key
+	Key  string
+	Size int64
+}
+
+// Reaction run for every record of a received notification
+type Reaction interface {
+	React(ctx context.Context, r Record) error
+}
+
+// ReactionFunc function as Reaction
+type ReactionFunc func(ctx context.Context, r Record) error
+
+// React call f
+func (f ReactionFunc) React(ctx context.Context, r Record) error {
+	return f(ctx, r)
+}
+
+// Consumer receive S3 event notifications from an SQS queue and run Reactions
+//
+// A message is deleted once every reaction succeeded for every record. While
+// it is processed its visibility timeout is extended, so a slow reaction
+// doesn't make it visible to other consumers. A failed message becomes visible
+// again and is retried, until it was received MaxReceives times and is moved
+// to DeadLetterQueueURL.
+type Consumer struct {
+	SQS      sqsiface.SQSAPI
+	QueueURL string
+	// DeadLetterQueueURL queue failed messages are moved to, empty leaves them to the queue's redrive policy
+	DeadLetterQueueURL string
+	// MaxReceives receives after which a failing message is dead-lettered
+	MaxReceives int
+	// VisibilityTimeout visibility of a message being processed, extended at half of it
+	VisibilityTimeout time.Duration
+	// WaitTime long polling wait of each receive
+	WaitTime  time.Duration
+	Reactions []Reaction
+	// Errorf report errors of messages, which never stop the consumer
+	Errorf func(format string, args ...interface{})
+}
+
+// event S3 event notification body
+type event struct {
+	Records []struct {
+		EventName string `json:"eventName"`
+		S3        struct {
+			Bucket struct {
+				Name string `json:"name"`
+			} `json:"bucket"`
+			Object struct {
+				Key  string `json:"key"`
+				Size int64  `json:"size"`
+			} `json:"object"`
+		} `json:"s3"`
+	}
+}
+
+// Run receive and process messages until ctx is done
+// receive errors are reported and retried after a growing delay of up to a minute
+func (c *Consumer) Run(ctx context.Context) {
+	delay := time.Second
+	for {
+		err := c.Poll(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			delay = time.Second
+			continue
+		}
+		c.errorf("receiving messages: %s", err.Error())
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > time.Minute {
+			delay = time.Minute
+		}
+	}
+}
+
+// Poll receive one batch of messages and process them concurrently
+func (c *Consumer) Poll(ctx context.Context) error {
+	out, err := c.SQS.ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:            aws.String(c.QueueURL),
+		MaxNumberOfMessages: aws.Int64(10),
+		WaitTimeSeconds:     aws.Int64(int64(c.WaitTime / time.Second)),
+		VisibilityTimeout:   aws.Int64(int64(c.visibilityTimeout() / time.Second)),
+		AttributeNames:      aws.StringSlice([]string{sqs.MessageSystemAttributeNameApproximateReceiveCount}),
+	})
+	if err != nil {
+		return err
+	}
+	var wg sync.WaitGroup
+	for _, msg := range out.Messages {
+		wg.Add(1)
+		go func(msg *sqs.Message) {
+			defer wg.Done()
+			if err := c.handle(ctx, msg); err != nil {
+				c.errorf("message %s: %s", aws.StringValue(msg.MessageId), err.Error())
+			}
+		}(msg)
+	}
+	wg.Wait()
+	return nil
+}
+
+// handle process msg and delete it, or dead-letter it when it failed too often
+func (c *Consumer) handle(ctx context.Context, msg *sqs.Message) error {
+	stop := c.keepInvisible(ctx, msg)
+	err := c.process(ctx, msg)
+	stop()
+	if err == nil {
+		return c.delete(ctx, msg)
+	}
+	receives, _ := strconv.Atoi(aws.StringValue(msg.Attributes[sqs.MessageSystemAttributeNameApproximateReceiveCount]))
+	if c.DeadLetterQueueURL == "" || c.MaxReceives <= 0 || receives < c.MaxReceives {
+		// visible again after the timeout and retried
+		return err
+	}
+	if _, serr := c.SQS.SendMessageWithContext(ctx, &sqs.SendMessageInput{
+		QueueUrl:    aws.String(c.DeadLetterQueueURL),
+		MessageBody: msg.Body,
+		MessageAttributes: map[string]*sqs.MessageAttributeValue{
+			"Error": {DataType: aws.String("String"), StringValue: aws.String(err.Error())},
+		},
+	}); serr != nil {
+		return fmt.Errorf("%s, dead-lettering failed: %s", err.Error(), serr.Error())
+	}
+	if derr := c.delete(ctx, msg); derr != nil {
+		return derr
+	}
+	return fmt.Errorf("dead-lettered after %d receives: %w", receives, err)
+}
+
+// process run every reaction for every record of msg
+// s3:TestEvent sent when notifications are configured has no records and is just deleted
+func (c *Consumer) process(ctx context.Context, msg *sqs.Message) error {
+	var e event
+	if err := json.Unmarshal([]byte(aws.StringValue(msg.Body)), &e); err != nil {
+		return fmt.Errorf("not an S3 event notification: %w", err)
+	}
+	for _, r := range e.Records {
+		key, err := url.QueryUnescape(r.S3.Object.Key)
+		if err != nil {
+			return err
+		}
+		record := Record{EventName: r.EventName, Bucket: r.S3.Bucket.Name, Key: key, Size: r.S3.Object.Size}
+		for _, reaction := range c.Reactions {
+			if err := reaction.React(ctx, record); err != nil {
+				return fmt.Errorf("s3://%s/%s: %w", record.Bucket, record.Key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// keepInvisible extend visibility timeout of msg until the returned func is called
+func (c *Consumer) keepInvisible(ctx context.Context, msg *sqs.Message) func() {
+	timeout := c.visibilityTimeout()
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(timeout / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				_, err := c.SQS.ChangeMessageVisibilityWithContext(ctx, &sqs.ChangeMessageVisibilityInput{
+					QueueUrl:          aws.String(c.QueueURL),
+					ReceiptHandle:     msg.ReceiptHandle,
+					VisibilityTimeout: aws.Int64(int64(timeout / time.Second)),
+				})
+				if err != nil {
+					c.errorf("message %s: extending visibility: %s", aws.StringValue(msg.MessageId), err.Error())
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// delete delete msg from the queue
+func (c *Consumer) delete(ctx context.Context, msg *sqs.Message) error {
+	_, err := c.SQS.DeleteMessageWithContext(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(c.QueueURL),
+		ReceiptHandle: msg.ReceiptHandle,
+	})
+	return err
+}
+
+// visibilityTimeout return VisibilityTimeout, at least a second
+func (c *Consumer) visibilityTimeout() time.Duration {
+	if c.VisibilityTimeout < time.Second {
+		return 30 * time.Second
+	}
+	return c.VisibilityTimeout
+}
+
+// errorf report error with Errorf when set
+func (c *Consumer) errorf(format string, args ...interface{}) {
+	if c.Errorf != nil {
+		c.Errorf(format, args...)
+	}
+}
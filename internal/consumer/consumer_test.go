@@ -0,0 +1,118 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockSQS queue of messages recording deletes, sends and visibility changes
+type mockSQS struct {
+	sqsiface.SQSAPI
+	mu         sync.Mutex
+	messages   []*sqs.Message
+	deleted    []string
+	sent       []*sqs.SendMessageInput
+	extensions int
+}
+
+func (m *mockSQS) ReceiveMessageWithContext(ctx aws.Context, in *sqs.ReceiveMessageInput, opts ...request.Option) (*sqs.ReceiveMessageOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := &sqs.ReceiveMessageOutput{Messages: m.messages}
+	m.messages = nil
+	return out, nil
+}
+
+func (m *mockSQS) DeleteMessageWithContext(ctx aws.Context, in *sqs.DeleteMessageInput, opts ...request.Option) (*sqs.DeleteMessageOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deleted = append(m.deleted, aws.StringValue(in.ReceiptHandle))
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func (m *mockSQS) SendMessageWithContext(ctx aws.Context, in *sqs.SendMessageInput, opts ...request.Option) (*sqs.SendMessageOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, in)
+	return &sqs.SendMessageOutput{}, nil
+}
+
+func (m *mockSQS) ChangeMessageVisibilityWithContext(ctx aws.Context, in *sqs.ChangeMessageVisibilityInput, opts ...request.Option) (*sqs.ChangeMessageVisibilityOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.extensions++
+	return &sqs.ChangeMessageVisibilityOutput{}, nil
+}
+
+const createdEvent = `{"Records":[{"eventName":"ObjectCreated:Put","s3":{"bucket":{"name":"bucket"},"object":{"key":"dir/a+file%21.txt","size":3}}}]}`
+
+func message(handle string, body string, receives string) *sqs.Message {
+	return &sqs.Message{
+		MessageId:     aws.String(handle),
+		ReceiptHandle: aws.String(handle),
+		Body:          aws.String(body),
+		Attributes:    map[string]*string{sqs.MessageSystemAttributeNameApproximateReceiveCount: aws.String(receives)},
+	}
+}
+
+func TestPollReactsAndDeletes(t *testing.T) {
+	m := &mockSQS{messages: []*sqs.Message{message("h1", createdEvent, "1")}}
+	var got []Record
+	c := &Consumer{SQS: m, QueueURL: "queue", Reactions: []Reaction{ReactionFunc(func(ctx context.Context, r Record) error {
+		got = append(got, r)
+		return nil
+	})}}
+	assert.NoError(t, c.Poll(context.Background()))
+	assert.Equal(t, []Record{{EventName: "ObjectCreated:Put", Bucket: "bucket", Key: "dir/a file!.txt", Size: 3}}, got)
+	assert.Equal(t, []string{"h1"}, m.deleted)
+}
+
+func TestPollKeepsFailedMessage(t *testing.T) {
+	m := &mockSQS{messages: []*sqs.Message{message("h1", createdEvent, "1")}}
+	var errs []string
+	c := &Consumer{SQS: m, QueueURL: "queue", DeadLetterQueueURL: "dlq", MaxReceives: 3,
+		Reactions: []Reaction{ReactionFunc(func(ctx context.Context, r Record) error { return errors.New("boom") })},
+		Errorf:    func(format string, args ...interface{}) { errs = append(errs, format) },
+	}
+	assert.NoError(t, c.Poll(context.Background()))
+	assert.Empty(t, m.deleted)
+	assert.Empty(t, m.sent)
+	assert.Len(t, errs, 1)
+}
+
+func TestPollDeadLetters(t *testing.T) {
+	m := &mockSQS{messages: []*sqs.Message{message("h1", createdEvent, "3"), message("h2", "not json", "3")}}
+	c := &Consumer{SQS: m, QueueURL: "queue", DeadLetterQueueURL: "dlq", MaxReceives: 3,
+		Reactions: []Reaction{ReactionFunc(func(ctx context.Context, r Record) error { return errors.New("boom") })},
+	}
+	assert.NoError(t, c.Poll(context.Background()))
+	assert.ElementsMatch(t, []string{"h1", "h2"}, m.deleted)
+	if assert.Len(t, m.sent, 2) {
+		for _, in := range m.sent {
+			assert.Equal(t, "dlq", aws.StringValue(in.QueueUrl))
+			assert.NotEmpty(t, aws.StringValue(in.MessageAttributes["Error"].StringValue))
+		}
+	}
+}
+
+func TestPollExtendsVisibility(t *testing.T) {
+	m := &mockSQS{messages: []*sqs.Message{message("h1", createdEvent, "1")}}
+	c := &Consumer{SQS: m, QueueURL: "queue", VisibilityTimeout: time.Second,
+		Reactions: []Reaction{ReactionFunc(func(ctx context.Context, r Record) error {
+			time.Sleep(1200 * time.Millisecond)
+			return nil
+		})},
+	}
+	assert.NoError(t, c.Poll(context.Background()))
+	assert.True(t, m.extensions >= 1, "extensions %d", m.extensions)
+	assert.Equal(t, []string{"h1"}, m.deleted)
+}
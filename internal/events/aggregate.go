@@ -0,0 +1,99 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// OperationSummary progress of every object of a bulk operation
+type OperationSummary struct {
+	Operation string
+	// Objects started so far
+	Objects int
+	Done    int
+	Failed  int
+	// Bytes transferred so far
+	Bytes int64
+	// Total bytes of the objects started so far, excluding unknown sizes
+	Total   int64
+	Started time.Time
+}
+
+// Rate bytes per second since Started
+func (s OperationSummary) Rate(now time.Time) float64 {
+	elapsed := now.Sub(s.Started).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Bytes) / elapsed
+}
+
+// ETA time to transfer the rest of Total at the current rate, 0 when unknown
+func (s OperationSummary) ETA(now time.Time) time.Duration {
+	rate := s.Rate(now)
+	if rate == 0 || s.Total <= s.Bytes {
+		return 0
+	}
+	return time.Duration(float64(s.Total-s.Bytes) / rate * float64(time.Second))
+}
+
+// Aggregator combine the events of the objects of an operation into an OperationSummary
+// Add may be called from any goroutine
+type Aggregator struct {
+	mu      sync.Mutex
+	summary OperationSummary
+	bytes   map[string]int64
+	totals  map[string]int64
+}
+
+// NewAggregator create Aggregator of events of operation
+func NewAggregator(operation string) *Aggregator {
+	return &Aggregator{
+		summary: OperationSummary{Operation: operation, Started: time.Now()},
+		bytes:   map[string]int64{},
+		totals:  map[string]int64{},
+	}
+}
+
+// Add count e and return the summary, false when e is not an object event of the operation
+func (a *Aggregator) Add(e Event) (OperationSummary, bool) {
+	if e.Type == Summary || e.Type == Part || e.Type == Skipped || e.Operation != a.summary.Operation {
+		return OperationSummary{}, false
+	}
+	key := e.Bucket + "/" + e.Key
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	s := &a.summary
+	switch e.Type {
+	case Started:
+		s.Objects++
+	case Progress:
+		s.Bytes += e.Bytes - a.bytes[key]
+		a.bytes[key] = e.Bytes
+		s.Total += e.Total - a.totals[key]
+		a.totals[key] = e.Total
+	case Completed:
+		s.Done++
+	case Failed:
+		s.Failed++
+	}
+	return *s, true
+}
+
+// Summary return the summary so far
+func (a *Aggregator) Summary() OperationSummary {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.summary
+}
+
+// Aggregate publish a Summary event on bus for every object event of operation
+// the returned function stops it
+func Aggregate(bus *Bus, operation string) func() {
+	a := NewAggregator(operation)
+	return bus.Subscribe(func(e Event) {
+		if s, ok := a.Add(e); ok {
+			bus.Publish(Event{Type: Summary, Operation: operation, Bytes: s.Bytes, Total: s.Total, Summary: &s})
+		}
+	})
+}
@@ -0,0 +1,77 @@
+package events
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregatorConcurrentObjects(t *testing.T) {
+	a := NewAggregator("upload")
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := fmt.Sprintf("file%d", i)
+			a.Add(Event{Type: Started, Operation: "upload", Bucket: "b", Key: key})
+			for n := int64(100); n <= 1000; n += 100 {
+				a.Add(Event{Type: Progress, Operation: "upload", Bucket: "b", Key: key, Bytes: n, Total: 1000})
+			}
+			if i%10 == 0 {
+				a.Add(Event{Type: Failed, Operation: "upload", Bucket: "b", Key: key})
+				return
+			}
+			a.Add(Event{Type: Completed, Operation: "upload", Bucket: "b", Key: key})
+		}(i)
+	}
+	wg.Wait()
+	s := a.Summary()
+	assert.Equal(t, 50, s.Objects)
+	assert.Equal(t, 45, s.Done)
+	assert.Equal(t, 5, s.Failed)
+	assert.Equal(t, int64(50000), s.Bytes)
+	assert.Equal(t, int64(50000), s.Total)
+
+	_, ok := a.Add(Event{Type: Started, Operation: "download", Key: "other"})
+	assert.False(t, ok)
+	_, ok = a.Add(Event{Type: Summary, Operation: "upload"})
+	assert.False(t, ok)
+}
+
+func TestOperationSummaryRate(t *testing.T) {
+	start := time.Now()
+	s := OperationSummary{Bytes: 1000, Total: 3000, Started: start}
+	assert.Equal(t, float64(500), s.Rate(start.Add(2*time.Second)))
+	assert.Equal(t, 4*time.Second, s.ETA(start.Add(2*time.Second)))
+	assert.Equal(t, time.Duration(0), OperationSummary{Started: start}.ETA(start.Add(time.Second)))
+}
+
+func TestAggregatePublishesSummaries(t *testing.T) {
+	b := NewBus()
+	stop := Aggregate(b, "copy")
+	var mu sync.Mutex
+	var last *OperationSummary
+	b.Subscribe(func(e Event) {
+		if e.Type == Summary {
+			mu.Lock()
+			last = e.Summary
+			mu.Unlock()
+		}
+	})
+	b.Publish(Event{Type: Started, Operation: "copy", Key: "a"})
+	b.Publish(Event{Type: Progress, Operation: "copy", Key: "a", Bytes: 10, Total: 10})
+	b.Publish(Event{Type: Completed, Operation: "copy", Key: "a"})
+	// summaries are published by the aggregating subscriber, so stop it before closing the bus
+	stop()
+	b.Close()
+	mu.Lock()
+	defer mu.Unlock()
+	if assert.NotNil(t, last) {
+		assert.Equal(t, 1, last.Done)
+		assert.Equal(t, int64(10), last.Bytes)
+	}
+}
@@ -0,0 +1,183 @@
+// Package events is a typed event bus for s3ry operations.
+//
+// Operations publish their lifecycle and transfer progress to a Bus, and every
+// front-end subscribes to render it, so core operations don't depend on presentation.
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Type kind of Event
+type Type int
+
+// Event types
+const (
+	// Started operation started
+	Started Type = iota
+	// Progress bytes transferred so far
+	Progress
+	// Completed operation succeeded
+	Completed
+	// Failed operation failed with Err
+	Failed
+	// Summary progress of every object of a bulk operation, in Summary
+	Summary
+	// Part part of a multipart upload uploaded, Bytes long, or an attempt failed with Err and is retried
+	Part
+	// Skipped operation not needed, e.g. the object was copied by an earlier run
+	Skipped
+)
+
+// String return name of Type
+func (t Type) String() string {
+	switch t {
+	case Started:
+		return "started"
+	case Progress:
+		return "progress"
+	case Completed:
+		return "completed"
+	case Failed:
+		return "failed"
+	case Summary:
+		return "summary"
+	case Part:
+		return "part"
+	case Skipped:
+		return "skipped"
+	}
+	return "unknown"
+}
+
+// Event state change of an operation
+type Event struct {
+	Type Type
+	// Operation name like "upload", "download" or "delete"
+	Operation string
+	Bucket    string
+	Key       string
+	// Bytes transferred so far
+	Bytes int64
+	// Total bytes of the transfer, 0 when unknown
+	Total int64
+	Err   error
+	Time  time.Time
+	// Summary set on Summary events
+	Summary *OperationSummary
+	// Part number of the part of Part events
+	Part int64
+}
+
+// Bus deliver published events to every subscriber
+//
+// Each subscriber has its own queue and goroutine, so events reach it in
+// publish order and a slow subscriber never blocks Publish.
+// A nil Bus discards every event.
+type Bus struct {
+	mu          sync.Mutex
+	subscribers map[int]*subscriber
+	next        int
+}
+
+// NewBus create Bus
+func NewBus() *Bus {
+	return &Bus{subscribers: map[int]*subscriber{}}
+}
+
+// Subscribe call fn for every event published after it, in order
+// the returned function unsubscribes after delivering queued events
+func (b *Bus) Subscribe(fn func(Event)) func() {
+	s := &subscriber{fn: fn, done: make(chan struct{})}
+	s.cond = sync.NewCond(&s.mu)
+	go s.run()
+
+	b.mu.Lock()
+	id := b.next
+	b.next++
+	b.subscribers[id] = s
+	b.mu.Unlock()
+
+	return func() {
+		b.mu.Lock()
+		delete(b.subscribers, id)
+		b.mu.Unlock()
+		s.close()
+	}
+}
+
+// Publish deliver e to every subscriber without waiting for them
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, s := range b.subscribers {
+		s.push(e)
+	}
+}
+
+// Close unsubscribe all subscribers after delivering queued events
+func (b *Bus) Close() {
+	b.mu.Lock()
+	subscribers := b.subscribers
+	b.subscribers = map[int]*subscriber{}
+	b.mu.Unlock()
+	for _, s := range subscribers {
+		s.close()
+	}
+}
+
+// subscriber queue of events for one subscription
+type subscriber struct {
+	fn     func(Event)
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  []Event
+	closed bool
+	done   chan struct{}
+}
+
+// push queue e
+func (s *subscriber) push(e Event) {
+	s.mu.Lock()
+	s.queue = append(s.queue, e)
+	s.mu.Unlock()
+	s.cond.Signal()
+}
+
+// run deliver queued events until closed
+func (s *subscriber) run() {
+	defer close(s.done)
+	for {
+		s.mu.Lock()
+		for len(s.queue) == 0 && !s.closed {
+			s.cond.Wait()
+		}
+		queue := s.queue
+		s.queue = nil
+		closed := s.closed
+		s.mu.Unlock()
+
+		for _, e := range queue {
+			s.fn(e)
+		}
+		if closed && len(queue) == 0 {
+			return
+		}
+	}
+}
+
+// close stop run after delivering queued events and wait for it
+func (s *subscriber) close() {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	s.cond.Signal()
+	<-s.done
+}
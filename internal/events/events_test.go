@@ -0,0 +1,91 @@
+package events
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBusDeliversInOrderToAllSubscribers(t *testing.T) {
+	bus := NewBus()
+	var mu sync.Mutex
+	received := map[string][]int64{}
+	for _, name := range []string{"tui", "web"} {
+		name := name
+		bus.Subscribe(func(e Event) {
+			mu.Lock()
+			received[name] = append(received[name], e.Bytes)
+			mu.Unlock()
+		})
+	}
+
+	var want []int64
+	for i := int64(0); i < 100; i++ {
+		bus.Publish(Event{Type: Progress, Bytes: i})
+		want = append(want, i)
+	}
+	bus.Close()
+
+	assert.Equal(t, want, received["tui"])
+	assert.Equal(t, want, received["web"])
+}
+
+func TestBusSlowSubscriberDoesNotBlockPublish(t *testing.T) {
+	bus := NewBus()
+	release := make(chan struct{})
+	var slow, fast []Type
+	bus.Subscribe(func(e Event) {
+		<-release
+		slow = append(slow, e.Type)
+	})
+	fastDone := make(chan struct{})
+	bus.Subscribe(func(e Event) {
+		fast = append(fast, e.Type)
+		if e.Type == Completed {
+			close(fastDone)
+		}
+	})
+
+	published := make(chan struct{})
+	go func() {
+		bus.Publish(Event{Type: Started})
+		for i := 0; i < 1000; i++ {
+			bus.Publish(Event{Type: Progress})
+		}
+		bus.Publish(Event{Type: Completed})
+		close(published)
+	}()
+
+	select {
+	case <-published:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Publish blocked by slow subscriber")
+	}
+	<-fastDone
+	assert.Len(t, fast, 1002)
+	assert.Empty(t, slow)
+
+	close(release)
+	bus.Close()
+	assert.Len(t, slow, 1002)
+	assert.Equal(t, Started, slow[0])
+	assert.Equal(t, Completed, slow[1001])
+}
+
+func TestUnsubscribe(t *testing.T) {
+	bus := NewBus()
+	count := 0
+	unsubscribe := bus.Subscribe(func(e Event) {
+		count++
+	})
+	bus.Publish(Event{Type: Started})
+	unsubscribe()
+	bus.Publish(Event{Type: Completed})
+	bus.Close()
+	assert.Equal(t, 1, count)
+
+	var nilBus *Bus
+	nilBus.Publish(Event{Type: Started})
+}
@@ -0,0 +1,106 @@
+package events
+
+import (
+	"sort"
+	"sync"
+)
+
+// OperationCount objects an operation changed in a run
+type OperationCount struct {
+	Operation string
+	Objects   int
+	Bytes     int64
+}
+
+// ReportError object an operation failed on
+type ReportError struct {
+	Operation string
+	Bucket    string
+	Key       string
+	Error     string
+}
+
+// Report what a run changed, grouped by operation
+type Report struct {
+	// Changed completed operations, by operation name
+	Changed []OperationCount
+	// Skipped objects which needed no change
+	Skipped int
+	Errors  []ReportError
+}
+
+// Reporter collect events of a run into a Report, safe for concurrent use
+type Reporter struct {
+	mu      sync.Mutex
+	bytes   map[string]int64
+	changed map[string]*OperationCount
+	skipped int
+	errors  []ReportError
+}
+
+// NewReporter create Reporter
+func NewReporter() *Reporter {
+	return &Reporter{bytes: map[string]int64{}, changed: map[string]*OperationCount{}}
+}
+
+// Add count e
+func (r *Reporter) Add(e Event) {
+	key := e.Operation + " " + e.Bucket + "/" + e.Key
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch e.Type {
+	case Progress:
+		r.bytes[key] = e.Bytes
+	case Completed:
+		c, ok := r.changed[e.Operation]
+		if !ok {
+			c = &OperationCount{Operation: e.Operation}
+			r.changed[e.Operation] = c
+		}
+		c.Objects++
+		c.Bytes += r.bytes[key]
+		delete(r.bytes, key)
+	case Failed:
+		msg := ""
+		if e.Err != nil {
+			msg = e.Err.Error()
+		}
+		r.errors = append(r.errors, ReportError{Operation: e.Operation, Bucket: e.Bucket, Key: e.Key, Error: msg})
+		delete(r.bytes, key)
+	case Skipped:
+		r.skipped++
+	}
+}
+
+// Report return the report so far, operations and errors sorted by name
+func (r *Reporter) Report() Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	report := Report{Skipped: r.skipped}
+	for _, c := range r.changed {
+		report.Changed = append(report.Changed, *c)
+	}
+	sort.Slice(report.Changed, func(a, b int) bool {
+		return report.Changed[a].Operation < report.Changed[b].Operation
+	})
+	report.Errors = append(report.Errors, r.errors...)
+	sort.Slice(report.Errors, func(a, b int) bool {
+		ea, eb := report.Errors[a], report.Errors[b]
+		if ea.Bucket+"/"+ea.Key != eb.Bucket+"/"+eb.Key {
+			return ea.Bucket+"/"+ea.Key < eb.Bucket+"/"+eb.Key
+		}
+		return ea.Operation < eb.Operation
+	})
+	return report
+}
+
+// Collect add every event published on bus to a new Reporter
+// the returned function stops it after the queued events and returns the Report
+func Collect(bus *Bus) func() Report {
+	r := NewReporter()
+	stop := bus.Subscribe(r.Add)
+	return func() Report {
+		stop()
+		return r.Report()
+	}
+}
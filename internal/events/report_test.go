@@ -0,0 +1,41 @@
+package events
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReporterGroupsEvents(t *testing.T) {
+	bus := NewBus()
+	report := Collect(bus)
+	publish := func(typ Type, op string, key string, n int64, err error) {
+		bus.Publish(Event{Type: typ, Operation: op, Bucket: "bucket", Key: key, Bytes: n, Err: err})
+	}
+	publish(Started, "upload", "a", 0, nil)
+	publish(Progress, "upload", "a", 5, nil)
+	publish(Progress, "upload", "a", 10, nil)
+	publish(Part, "upload", "a", 10, nil)
+	publish(Completed, "upload", "a", 0, nil)
+	publish(Started, "upload", "b", 0, nil)
+	publish(Progress, "upload", "b", 20, nil)
+	publish(Completed, "upload", "b", 0, nil)
+	publish(Started, "upload", "c", 0, nil)
+	publish(Progress, "upload", "c", 7, nil)
+	publish(Failed, "upload", "c", 0, errors.New("AccessDenied"))
+	publish(Started, "delete", "d", 0, nil)
+	publish(Completed, "delete", "d", 0, nil)
+	publish(Skipped, "copy", "e", 0, nil)
+	publish(Skipped, "copy", "f", 0, nil)
+
+	assert.Equal(t, Report{
+		Changed: []OperationCount{
+			{Operation: "delete", Objects: 1},
+			{Operation: "upload", Objects: 2, Bytes: 30},
+		},
+		Skipped: 2,
+		Errors:  []ReportError{{Operation: "upload", Bucket: "bucket", Key: "c", Error: "AccessDenied"}},
+	}, report())
+	bus.Close()
+}
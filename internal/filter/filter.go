@@ -0,0 +1,372 @@
+// Package filter parses and evaluates expressions selecting S3 objects, like
+//
+//	size > 1GB && last_modified < 2023-01-01 && storage_class == "STANDARD"
+//
+// Comparisons of a field with a literal are combined with &&, || and !, and
+// grouped with parentheses. Expressions are checked when parsed, so a typo
+// fails before any object is touched.
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Object fields of an object an expression is evaluated on
+type Object struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+	StorageClass string
+	ETag         string
+}
+
+// Fields of Object usable in expressions
+const (
+	FieldKey          = "key"
+	FieldSize         = "size"
+	FieldLastModified = "last_modified"
+	FieldStorageClass = "storage_class"
+	FieldETag         = "etag"
+)
+
+// kind type of the values of a field
+type kind int
+
+const (
+	kindString kind = iota
+	kindSize
+	kindTime
+)
+
+// fields kind of each field
+var fields = map[string]kind{
+	FieldKey:          kindString,
+	FieldSize:         kindSize,
+	FieldLastModified: kindTime,
+	FieldStorageClass: kindString,
+	FieldETag:         kindString,
+}
+
+// sizeUnits multipliers of size literals, binary like the size histogram of stats
+var sizeUnits = map[string]int64{
+	"":   1,
+	"B":  1,
+	"KB": 1 << 10,
+	"MB": 1 << 20,
+	"GB": 1 << 30,
+	"TB": 1 << 40,
+}
+
+// timeLayouts layouts of time literals, dates are midnight UTC
+var timeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"}
+
+// Error malformed expression
+type Error struct {
+	// Pos byte offset in the expression the problem was found at
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("filter: %s at position %d", e.Msg, e.Pos+1)
+}
+
+// Expr parsed expression
+type Expr struct {
+	src  string
+	root node
+}
+
+// Parse parse and check s
+func Parse(s string) (*Expr, error) {
+	p := &parser{lexer: lexer{src: s}}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tokEOF {
+		return nil, &Error{Pos: 0, Msg: "empty expression"}
+	}
+	root, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf("unexpected %s", p.tok)
+	}
+	return &Expr{src: s, root: root}, nil
+}
+
+// Match report whether o is selected by e
+func (e *Expr) Match(o Object) bool {
+	return e.root.eval(o)
+}
+
+// String return the source of e
+func (e *Expr) String() string {
+	return e.src
+}
+
+// node element of a parsed expression
+type node interface {
+	eval(o Object) bool
+}
+
+type andNode struct{ left, right node }
+
+func (n andNode) eval(o Object) bool { return n.left.eval(o) && n.right.eval(o) }
+
+type orNode struct{ left, right node }
+
+func (n orNode) eval(o Object) bool { return n.left.eval(o) || n.right.eval(o) }
+
+type notNode struct{ operand node }
+
+func (n notNode) eval(o Object) bool { return !n.operand.eval(o) }
+
+// compareNode comparison of a field with a literal
+type compareNode struct {
+	field string
+	op    string
+	str   string
+	num   int64
+	time  time.Time
+	re    *regexp.Regexp
+}
+
+func (n compareNode) eval(o Object) bool {
+	switch n.field {
+	case FieldSize:
+		return compare(n.op, cmpInt(o.Size, n.num))
+	case FieldLastModified:
+		return compare(n.op, cmpTime(o.LastModified, n.time))
+	}
+	v := map[string]string{FieldKey: o.Key, FieldStorageClass: o.StorageClass, FieldETag: strings.Trim(o.ETag, `"`)}[n.field]
+	switch n.op {
+	case "=~":
+		return n.re.MatchString(v)
+	case "!~":
+		return !n.re.MatchString(v)
+	}
+	return compare(n.op, strings.Compare(v, n.str))
+}
+
+// compare apply op to the result of a three way comparison
+func compare(op string, c int) bool {
+	switch op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	default:
+		return c >= 0
+	}
+}
+
+func cmpInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func cmpTime(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
+}
+
+// parser recursive descent parser of
+//
+//	or         = and { "||" and }
+//	and        = unary { "&&" unary }
+//	unary      = "!" unary | "(" or ")" | comparison
+//	comparison = field op literal
+type parser struct {
+	lexer
+	tok token
+}
+
+// next read the next token
+func (p *parser) next() error {
+	tok, err := p.lex()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+// errorf return Error at the current token
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return &Error{Pos: p.tok.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) or() (node, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokOp && p.tok.text == "||" {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) and() (node, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokOp && p.tok.text == "&&" {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) unary() (node, error) {
+	switch {
+	case p.tok.kind == tokOp && p.tok.text == "!":
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand}, nil
+	case p.tok.kind == tokOp && p.tok.text == "(":
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		inner, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokOp || p.tok.text != ")" {
+			return nil, p.errorf("expected ) instead of %s", p.tok)
+		}
+		return inner, p.next()
+	}
+	return p.comparison()
+}
+
+func (p *parser) comparison() (node, error) {
+	if p.tok.kind != tokIdent {
+		return nil, p.errorf("expected a field instead of %s", p.tok)
+	}
+	field := p.tok.text
+	k, ok := fields[field]
+	if !ok {
+		return nil, p.errorf("unknown field %q, use key, size, last_modified, storage_class or etag", field)
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	op := p.tok.text
+	switch {
+	case p.tok.kind != tokOp:
+		return nil, p.errorf("expected a comparison after %s instead of %s", field, p.tok)
+	case op == "=~" || op == "!~":
+		if k != kindString {
+			return nil, p.errorf("%s can't be matched with %s", field, op)
+		}
+	case op != "==" && op != "!=" && op != "<" && op != "<=" && op != ">" && op != ">=":
+		return nil, p.errorf("expected a comparison after %s instead of %s", field, p.tok)
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	switch p.tok.kind {
+	case tokIdent:
+		return nil, p.errorf("expected a value after %s instead of %s, strings are quoted", op, p.tok)
+	case tokString, tokWord:
+	default:
+		return nil, p.errorf("expected a value after %s instead of %s", op, p.tok)
+	}
+	n := compareNode{field: field, op: op}
+	switch k {
+	case kindSize:
+		num, err := parseSize(p.tok.text)
+		if err != nil {
+			return nil, p.errorf("%s", err.Error())
+		}
+		n.num = num
+	case kindTime:
+		t, err := parseTime(p.tok.text)
+		if err != nil {
+			return nil, p.errorf("%s", err.Error())
+		}
+		n.time = t
+	default:
+		if p.tok.kind != tokString {
+			return nil, p.errorf("%s is compared with a quoted string, not %s", field, p.tok)
+		}
+		n.str = p.tok.text
+		if op == "=~" || op == "!~" {
+			re, err := regexp.Compile(n.str)
+			if err != nil {
+				return nil, p.errorf("invalid regular expression: %s", err.Error())
+			}
+			n.re = re
+		}
+	}
+	return n, p.next()
+}
+
+// parseSize parse a byte count like 1024, 10MB or 1.5GB
+func parseSize(s string) (int64, error) {
+	i := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if i < 0 {
+		i = len(s)
+	}
+	unit, ok := sizeUnits[strings.ToUpper(s[i:])]
+	if !ok {
+		return 0, fmt.Errorf("invalid size %q, use bytes or a unit of B, KB, MB, GB or TB", s)
+	}
+	n, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(n * float64(unit)), nil
+}
+
+// parseTime parse a date like 2023-01-01 or an RFC 3339 time
+func parseTime(s string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, use 2006-01-02 or 2006-01-02T15:04:05Z", s)
+}
@@ -0,0 +1,77 @@
+package filter
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	old := Object{Key: "logs/2022/app.log.gz", Size: 2 << 30, LastModified: time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC), StorageClass: "STANDARD", ETag: `"abc"`}
+	small := Object{Key: "logs/2024/app.log", Size: 512, LastModified: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC), StorageClass: "GLACIER", ETag: `"def"`}
+	for expr, want := range map[string][2]bool{
+		// numeric
+		"size > 1GB":    {true, false},
+		"size <= 512":   {false, true},
+		"size == 2GB":   {true, false},
+		"size >= 1.5gb": {true, false},
+		"size < 1KB":    {false, true},
+		"size != 512B":  {true, false},
+		// dates
+		"last_modified < 2023-01-01":                  {true, false},
+		"last_modified >= 2024-01-01T12:00:00Z":       {false, true},
+		`last_modified > "2024-01-01T20:00:00+09:00"`: {false, true},
+		// strings
+		`storage_class == "STANDARD"`: {true, false},
+		`storage_class != "STANDARD"`: {false, true},
+		`key =~ "\\.gz$"`:             {true, false},
+		`key !~ "^logs/"`:             {false, false},
+		`key < "logs/2023"`:           {true, false},
+		`etag == "def"`:               {false, true},
+		// boolean combinations
+		`size > 1GB && last_modified < 2023-01-01 && storage_class == "STANDARD"`: {true, false},
+		`size > 1GB || storage_class == "GLACIER"`:                                {true, true},
+		`!(size > 1GB)`:               {false, true},
+		`!size > 1GB && key =~ "log"`: {false, true},
+		`size < 1KB || size > 1GB && storage_class == "GLACIER"`:      {false, true},
+		`(size < 1KB || size > 1GB) && storage_class == "GLACIER"`:    {false, true},
+		`(size < 1KB || size > 1GB) && !(storage_class == "GLACIER")`: {true, false},
+	} {
+		e, err := Parse(expr)
+		if !assert.NoError(t, err, expr) {
+			continue
+		}
+		assert.Equal(t, want[0], e.Match(old), expr)
+		assert.Equal(t, want[1], e.Match(small), expr)
+		assert.Equal(t, expr, e.String())
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for expr, msg := range map[string]string{
+		"":                           "empty expression at position 1",
+		"size >":                     "expected a value after > instead of end of expression at position 7",
+		"size > 1GB &&":              "expected a field instead of end of expression at position 14",
+		"size > 1GB)":                `unexpected ")" at position 11`,
+		"(size > 1GB":                "expected ) instead of end of expression at position 12",
+		"color == \"red\"":           `unknown field "color"`,
+		"size > 10XB":                `invalid size "10XB"`,
+		"size > \"big\"":             `invalid size "big"`,
+		"last_modified < 2023-13-01": `invalid time "2023-13-01"`,
+		"storage_class == STANDARD":  `expected a value after == instead of "STANDARD", strings are quoted`,
+		"storage_class == 1":         `storage_class is compared with a quoted string`,
+		"size =~ \"1\"":              "size can't be matched with =~",
+		"key =~ \"(\"":               "invalid regular expression",
+		"key == \"open":              "unterminated string at position 8",
+		"size = 1":                   `unexpected character '='`,
+		"size 1":                     `expected a comparison after size instead of "1"`,
+	} {
+		_, err := Parse(expr)
+		var ferr *Error
+		if assert.True(t, errors.As(err, &ferr), expr) {
+			assert.Contains(t, err.Error(), msg, expr)
+		}
+	}
+}
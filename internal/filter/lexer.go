@@ -0,0 +1,107 @@
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// tokenKind kind of token
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	// tokIdent field name
+	tokIdent
+	// tokOp operator or parenthesis
+	tokOp
+	// tokString quoted string, text is unquoted
+	tokString
+	// tokWord unquoted literal like 10MB or 2023-01-01
+	tokWord
+)
+
+// token lexed token at byte offset pos
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokString:
+		return strconv.Quote(t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// operators longest first, so "<=" isn't read as "<"
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "=~", "!~", "<", ">", "!", "(", ")"}
+
+// lexer split an expression into tokens
+type lexer struct {
+	src string
+	pos int
+}
+
+// lex return the next token
+func (l *lexer) lex() (token, error) {
+	for l.pos < len(l.src) && strings.ContainsRune(" \t\r\n", rune(l.src[l.pos])) {
+		l.pos++
+	}
+	start := l.pos
+	if l.pos == len(l.src) {
+		return token{kind: tokEOF, pos: start}, nil
+	}
+	c := l.src[l.pos]
+	switch {
+	case c == '"':
+		// find the closing quote, skipping escaped characters
+		end := l.pos + 1
+		for end < len(l.src) && l.src[end] != '"' {
+			if l.src[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(l.src) {
+			return token{}, &Error{Pos: start, Msg: "unterminated string"}
+		}
+		s, err := strconv.Unquote(l.src[start : end+1])
+		if err != nil {
+			return token{}, &Error{Pos: start, Msg: "invalid string " + l.src[start:end+1]}
+		}
+		l.pos = end + 1
+		return token{kind: tokString, text: s, pos: start}, nil
+	case isIdentByte(c):
+		for l.pos < len(l.src) && isIdentByte(l.src[l.pos]) {
+			l.pos++
+		}
+		return token{kind: tokIdent, text: l.src[start:l.pos], pos: start}, nil
+	case c >= '0' && c <= '9':
+		for l.pos < len(l.src) && isWordByte(l.src[l.pos]) {
+			l.pos++
+		}
+		return token{kind: tokWord, text: l.src[start:l.pos], pos: start}, nil
+	}
+	for _, op := range operators {
+		if strings.HasPrefix(l.src[l.pos:], op) {
+			l.pos += len(op)
+			return token{kind: tokOp, text: op, pos: start}, nil
+		}
+	}
+	return token{}, &Error{Pos: start, Msg: fmt.Sprintf("unexpected character %q", c)}
+}
+
+// isIdentByte check c is part of a field name, upper case letters too so an unquoted string is reported as such
+func isIdentByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
+
+// isWordByte check c continues an unquoted literal: digits, letters of units, and the separators of times
+func isWordByte(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || strings.IndexByte(".:-+", c) >= 0
+}
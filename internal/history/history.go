@@ -0,0 +1,203 @@
+// Package history records s3ry operations in a JSON lines file and queries them.
+//
+// Every front-end goes through the same operations, so one store records the
+// operations of the CLI commands, the TUI and the consumer alike.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Results of entries
+const (
+	OK     = "ok"
+	Failed = "failed"
+)
+
+// Entry one finished operation
+type Entry struct {
+	Time time.Time
+	User string
+	// Operation name like "upload", "download" or "delete"
+	Operation string
+	// Target s3:// URI of the bucket or object
+	Target string
+	// Bytes transferred, 0 for operations without a body
+	Bytes    int64
+	Duration time.Duration
+	// PartSize part size an upload with Performance.AdaptivePartSize settled on, 0 for others
+	PartSize int64 `json:",omitempty"`
+	// Result OK or Failed, with the error in Error
+	Result string
+	Error  string `json:",omitempty"`
+}
+
+// Bucket return bucket of Target
+func (e Entry) Bucket() string {
+	return strings.SplitN(strings.TrimPrefix(e.Target, "s3://"), "/", 2)[0]
+}
+
+// Filter select entries, zero fields match every entry
+type Filter struct {
+	// Since and Until range of Time, Until excluded
+	Since     time.Time
+	Until     time.Time
+	Operation string
+	Bucket    string
+}
+
+// Match check e is selected by f
+func (f Filter) Match(e Entry) bool {
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !e.Time.Before(f.Until) {
+		return false
+	}
+	if f.Bucket != "" && e.Bucket() != f.Bucket {
+		return false
+	}
+	return f.Operation == "" || e.Operation == f.Operation
+}
+
+// Dimensions entries are aggregated by
+const (
+	ByOperation = "operation"
+	ByBucket    = "bucket"
+)
+
+// Aggregate totals of the entries sharing the value of a dimension
+type Aggregate struct {
+	// Key value of the dimension, e.g. the bucket name
+	Key        string
+	Operations int
+	Failed     int
+	Bytes      int64
+	Duration   time.Duration
+}
+
+// ErrorRate failed operations per operation
+func (a Aggregate) ErrorRate() float64 {
+	if a.Operations == 0 {
+		return 0
+	}
+	return float64(a.Failed) / float64(a.Operations)
+}
+
+// Throughput bytes per second of the time spent in the operations
+func (a Aggregate) Throughput() float64 {
+	if a.Duration <= 0 {
+		return 0
+	}
+	return float64(a.Bytes) / a.Duration.Seconds()
+}
+
+// Aggregates totals of entries by dimension, sorted by key
+func Aggregates(entries []Entry, by string) ([]Aggregate, error) {
+	var key func(Entry) string
+	switch by {
+	case ByOperation:
+		key = func(e Entry) string { return e.Operation }
+	case ByBucket:
+		key = Entry.Bucket
+	default:
+		return nil, fmt.Errorf("unknown dimension %q, use %s or %s", by, ByOperation, ByBucket)
+	}
+	totals := map[string]*Aggregate{}
+	for _, e := range entries {
+		k := key(e)
+		a, ok := totals[k]
+		if !ok {
+			a = &Aggregate{Key: k}
+			totals[k] = a
+		}
+		a.Operations++
+		if e.Result == Failed {
+			a.Failed++
+		}
+		a.Bytes += e.Bytes
+		a.Duration += e.Duration
+	}
+	aggregates := make([]Aggregate, 0, len(totals))
+	for _, a := range totals {
+		aggregates = append(aggregates, *a)
+	}
+	sort.Slice(aggregates, func(i, j int) bool { return aggregates[i].Key < aggregates[j].Key })
+	return aggregates, nil
+}
+
+// Store entries appended to a JSON lines file
+// an empty path keeps no entries
+type Store struct {
+	mu   sync.Mutex
+	path string
+}
+
+// Open return Store of file path, created on the first Append
+func Open(path string) *Store {
+	return &Store{path: path}
+}
+
+// Append add e to the end of the file
+func (s *Store) Append(e Entry) error {
+	if s.path == "" {
+		return nil
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(b, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Query return entries matching f in the order they were appended
+// lines which can't be read, e.g. cut by a crash, are skipped
+func (s *Store) Query(f Filter) ([]Entry, error) {
+	if s.path == "" {
+		return nil, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		if f.Match(e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, scanner.Err()
+}
@@ -0,0 +1,104 @@
+package history
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppendQuery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "s3ry-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "history.jsonl")
+	s := Open(path)
+
+	day := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	entries := []Entry{
+		{Time: day, Operation: "upload", Target: "s3://bucket/a", Bytes: 10, Result: OK},
+		{Time: day.Add(24 * time.Hour), Operation: "download", Target: "s3://bucket/a", Bytes: 10, Result: OK},
+		{Time: day.Add(48 * time.Hour), Operation: "upload", Target: "s3://bucket/b", Result: Failed, Error: "AccessDenied"},
+	}
+	for _, e := range entries {
+		assert.NoError(t, s.Append(e))
+	}
+	// a line cut by a crash doesn't hide the others
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	f.WriteString(`{"Time":"2026-10`)
+	f.Close()
+
+	all, err := Open(path).Query(Filter{})
+	assert.NoError(t, err)
+	assert.Len(t, all, 3)
+	for i := range all {
+		assert.True(t, entries[i].Time.Equal(all[i].Time))
+		assert.Equal(t, entries[i].Target, all[i].Target)
+	}
+
+	uploads, err := s.Query(Filter{Operation: "upload"})
+	assert.NoError(t, err)
+	assert.Len(t, uploads, 2)
+	assert.Equal(t, "AccessDenied", uploads[1].Error)
+
+	// Until is excluded
+	ranged, err := s.Query(Filter{Since: day.Add(time.Hour), Until: day.Add(48 * time.Hour)})
+	assert.NoError(t, err)
+	if assert.Len(t, ranged, 1) {
+		assert.Equal(t, "download", ranged[0].Operation)
+	}
+
+	none, err := Open(filepath.Join(dir, "missing.jsonl")).Query(Filter{})
+	assert.NoError(t, err)
+	assert.Empty(t, none)
+}
+
+func TestAggregates(t *testing.T) {
+	entries := []Entry{
+		{Operation: "upload", Target: "s3://logs/a", Bytes: 100, Duration: time.Second, Result: OK},
+		{Operation: "upload", Target: "s3://data/b", Bytes: 300, Duration: time.Second, Result: OK},
+		{Operation: "delete", Target: "s3://data/c", Duration: time.Second, Result: Failed, Error: "AccessDenied"},
+		{Operation: "delete", Target: "s3://data/d", Duration: time.Second, Result: OK},
+		{Operation: "logging", Target: "s3://data", Duration: time.Second, Result: OK},
+	}
+
+	byOperation, err := Aggregates(entries, ByOperation)
+	assert.NoError(t, err)
+	assert.Equal(t, []Aggregate{
+		{Key: "delete", Operations: 2, Failed: 1, Duration: 2 * time.Second},
+		{Key: "logging", Operations: 1, Duration: time.Second},
+		{Key: "upload", Operations: 2, Bytes: 400, Duration: 2 * time.Second},
+	}, byOperation)
+	assert.Equal(t, 0.5, byOperation[0].ErrorRate())
+	assert.Equal(t, float64(200), byOperation[2].Throughput())
+
+	byBucket, err := Aggregates(entries, ByBucket)
+	assert.NoError(t, err)
+	if assert.Len(t, byBucket, 2) {
+		assert.Equal(t, "data", byBucket[0].Key)
+		assert.Equal(t, 4, byBucket[0].Operations)
+		assert.Equal(t, int64(300), byBucket[0].Bytes)
+		assert.Equal(t, 0.25, byBucket[0].ErrorRate())
+		assert.Equal(t, Aggregate{Key: "logs", Operations: 1, Bytes: 100, Duration: time.Second}, byBucket[1])
+	}
+
+	// a filter by bucket then by operation answers the error rate of deletes on it
+	var deletes []Entry
+	for _, e := range entries {
+		if (Filter{Bucket: "data", Operation: "delete"}).Match(e) {
+			deletes = append(deletes, e)
+		}
+	}
+	assert.Len(t, deletes, 2)
+
+	_, err = Aggregates(entries, "storage-class")
+	assert.Error(t, err)
+	empty, err := Aggregates(nil, ByBucket)
+	assert.NoError(t, err)
+	assert.Empty(t, empty)
+}
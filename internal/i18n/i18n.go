@@ -0,0 +1,195 @@
+// Package i18n formats numbers, byte sizes, times and durations by the conventions of a locale.
+//
+// Only the output meant for people goes through it: JSON, CSV and listings
+// stay in one format whatever the locale, so scripts keep parsing them.
+package i18n
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DurationUnits names of the units of a duration, written right after their number
+type DurationUnits struct {
+	Hour        string
+	Minute      string
+	Second      string
+	Millisecond string
+}
+
+// Locale conventions of a language and region
+type Locale struct {
+	// Tag BCP 47 language tag, e.g. ja-JP
+	Tag string
+	// Group thousands separator, empty for none
+	Group string
+	// Decimal decimal separator
+	Decimal string
+	// DateTime time.Format layout of a time
+	DateTime string
+	Units    DurationUnits
+	// DecimalSizes byte sizes in powers of 1000 (kB, MB), rather than 1024 (KiB, MiB)
+	DecimalSizes bool
+}
+
+// shortUnits units of time.Duration.String
+var shortUnits = DurationUnits{Hour: "h", Minute: "m", Second: "s", Millisecond: "ms"}
+
+// POSIX locale of C and POSIX, plain numbers and RFC 3339 times
+var POSIX = Locale{Tag: "C", Decimal: ".", DateTime: time.RFC3339, Units: shortUnits}
+
+// locales built in locales, the first of a language is used for the language alone
+var locales = []Locale{
+	{Tag: "en-US", Group: ",", Decimal: ".", DateTime: "Jan 2, 2006, 3:04:05 PM", Units: shortUnits},
+	{Tag: "en-GB", Group: ",", Decimal: ".", DateTime: "2 Jan 2006, 15:04:05", Units: shortUnits},
+	{Tag: "ja-JP", Group: ",", Decimal: ".", DateTime: "2006/01/02 15:04:05", Units: DurationUnits{Hour: "時間", Minute: "分", Second: "秒", Millisecond: "ミリ秒"}},
+	{Tag: "de-DE", Group: ".", Decimal: ",", DateTime: "02.01.2006, 15:04:05", Units: shortUnits},
+	// French groups digits with a narrow no-break space
+	{Tag: "fr-FR", Group: "\u202f", Decimal: ",", DateTime: "02/01/2006 15:04:05", Units: shortUnits},
+}
+
+// Parse return the locale of name, a language tag like ja-JP or a POSIX locale like ja_JP.UTF-8
+// a language alone, like ja, is its first built in region, and empty, C and POSIX are POSIX
+func Parse(name string) (Locale, error) {
+	tag := name
+	if i := strings.IndexAny(tag, ".@"); i >= 0 {
+		tag = tag[:i]
+	}
+	tag = strings.Replace(tag, "_", "-", -1)
+	switch tag {
+	case "", "C", "POSIX":
+		return POSIX, nil
+	}
+	for _, l := range locales {
+		if strings.EqualFold(l.Tag, tag) {
+			return l, nil
+		}
+	}
+	language := strings.SplitN(tag, "-", 2)[0]
+	for _, l := range locales {
+		if strings.EqualFold(strings.SplitN(l.Tag, "-", 2)[0], language) {
+			return l, nil
+		}
+	}
+	return Locale{}, fmt.Errorf("unknown locale %q, use one of %s or C", name, strings.Join(Tags(), ", "))
+}
+
+// Tags return the tags of the built in locales
+func Tags() []string {
+	var tags []string
+	for _, l := range locales {
+		tags = append(tags, l.Tag)
+	}
+	return tags
+}
+
+// FromEnv return the locale of the LC_ALL or LANG environment variables,
+// en-US for locales which aren't built in as the messages are English
+func FromEnv() Locale {
+	name := os.Getenv("LC_ALL")
+	if name == "" {
+		name = os.Getenv("LANG")
+	}
+	l, err := Parse(name)
+	if err != nil {
+		return locales[0]
+	}
+	return l
+}
+
+// Number return n with its digits grouped by thousands
+func (l Locale) Number(n int64) string {
+	digits := strconv.FormatInt(n, 10)
+	sign := ""
+	if n < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	if l.Group == "" || len(digits) <= 3 {
+		return sign + digits
+	}
+	var b strings.Builder
+	b.WriteString(sign)
+	head := len(digits) % 3
+	if head == 0 {
+		head = 3
+	}
+	b.WriteString(digits[:head])
+	for i := head; i < len(digits); i += 3 {
+		b.WriteString(l.Group)
+		b.WriteString(digits[i : i+3])
+	}
+	return b.String()
+}
+
+// Float return f with precision decimals
+func (l Locale) Float(f float64, precision int) string {
+	s := strconv.FormatFloat(f, 'f', precision, 64)
+	whole, fraction := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, fraction = s[:i], s[i+1:]
+	}
+	n, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return s
+	}
+	if n == 0 && strings.HasPrefix(whole, "-") {
+		whole = "-0"
+	} else {
+		whole = l.Number(n)
+	}
+	if fraction == "" {
+		return whole
+	}
+	return whole + l.Decimal + fraction
+}
+
+// Bytes return size n in the largest unit it reaches, with one decimal, e.g. 1.5 MiB, or 1.5 MB with DecimalSizes
+func (l Locale) Bytes(n int64) string {
+	base, units := 1024.0, []string{"KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+	if l.DecimalSizes {
+		base, units = 1000, []string{"kB", "MB", "GB", "TB", "PB", "EB"}
+	}
+	if n > -int64(base) && n < int64(base) {
+		return l.Number(n) + " B"
+	}
+	value, unit := float64(n)/base, units[0]
+	for _, u := range units[1:] {
+		if value > -base && value < base {
+			break
+		}
+		value, unit = value/base, u
+	}
+	return l.Float(value, 1) + " " + unit
+}
+
+// Time return t in the layout of the locale
+func (l Locale) Time(t time.Time) string {
+	return t.Format(l.DateTime)
+}
+
+// Duration return d in hours, minutes and seconds, rounded to the second,
+// or in milliseconds when shorter than a second
+func (l Locale) Duration(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign, d = "-", -d
+	}
+	if d < time.Second {
+		return sign + l.Number(int64(d.Round(time.Millisecond)/time.Millisecond)) + l.Units.Millisecond
+	}
+	d = d.Round(time.Second)
+	h, m, s := int64(d/time.Hour), int64(d%time.Hour/time.Minute), int64(d%time.Minute/time.Second)
+	var b strings.Builder
+	b.WriteString(sign)
+	if h > 0 {
+		b.WriteString(l.Number(h) + l.Units.Hour)
+	}
+	if h > 0 || m > 0 {
+		b.WriteString(strconv.FormatInt(m, 10) + l.Units.Minute)
+	}
+	b.WriteString(strconv.FormatInt(s, 10) + l.Units.Second)
+	return b.String()
+}
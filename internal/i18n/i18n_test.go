@@ -0,0 +1,81 @@
+package i18n
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mustParse(t *testing.T, name string) Locale {
+	l, err := Parse(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestParse(t *testing.T) {
+	assert.Equal(t, "ja-JP", mustParse(t, "ja_JP.UTF-8").Tag)
+	assert.Equal(t, "ja-JP", mustParse(t, "ja").Tag)
+	assert.Equal(t, "en-GB", mustParse(t, "en-gb").Tag)
+	assert.Equal(t, "de-DE", mustParse(t, "de_AT@euro").Tag)
+	assert.Equal(t, POSIX, mustParse(t, "C.UTF-8"))
+	assert.Equal(t, POSIX, mustParse(t, ""))
+	_, err := Parse("xx-YY")
+	assert.Error(t, err)
+}
+
+func TestFromEnv(t *testing.T) {
+	for _, name := range []string{"LC_ALL", "LANG"} {
+		defer os.Setenv(name, os.Getenv(name))
+	}
+	os.Setenv("LC_ALL", "")
+	os.Setenv("LANG", "ja_JP.UTF-8")
+	assert.Equal(t, "ja-JP", FromEnv().Tag)
+	os.Setenv("LC_ALL", "de_DE.UTF-8")
+	assert.Equal(t, "de-DE", FromEnv().Tag)
+	// the messages are English
+	os.Setenv("LC_ALL", "zh_CN.UTF-8")
+	assert.Equal(t, "en-US", FromEnv().Tag)
+}
+
+func TestNumber(t *testing.T) {
+	en, ja, de, fr := mustParse(t, "en-US"), mustParse(t, "ja-JP"), mustParse(t, "de-DE"), mustParse(t, "fr-FR")
+	assert.Equal(t, "1,234,567", en.Number(1234567))
+	assert.Equal(t, "1,234,567", ja.Number(1234567))
+	assert.Equal(t, "1.234.567", de.Number(1234567))
+	assert.Equal(t, "1\u202f234\u202f567", fr.Number(1234567))
+	assert.Equal(t, "1234567", POSIX.Number(1234567))
+	assert.Equal(t, "-123,456", en.Number(-123456))
+	assert.Equal(t, "999", en.Number(999))
+	assert.Equal(t, "1.234,5", de.Float(1234.5, 1))
+	assert.Equal(t, "-0.5", en.Float(-0.5, 1))
+}
+
+func TestBytes(t *testing.T) {
+	en, de := mustParse(t, "en-US"), mustParse(t, "de-DE")
+	assert.Equal(t, "94 B", en.Bytes(94))
+	assert.Equal(t, "1.5 KiB", en.Bytes(1536))
+	assert.Equal(t, "1,5 KiB", de.Bytes(1536))
+	assert.Equal(t, "5.0 GiB", en.Bytes(5<<30))
+	en.DecimalSizes = true
+	assert.Equal(t, "1.5 kB", en.Bytes(1500))
+	assert.Equal(t, "5.4 GB", en.Bytes(5<<30))
+}
+
+func TestTimeAndDuration(t *testing.T) {
+	en, ja := mustParse(t, "en-US"), mustParse(t, "ja-JP")
+	at := time.Date(2026, 10, 15, 14, 5, 9, 0, time.UTC)
+	assert.Equal(t, "Oct 15, 2026, 2:05:09 PM", en.Time(at))
+	assert.Equal(t, "2026/10/15 14:05:09", ja.Time(at))
+	assert.Equal(t, "15 Oct 2026, 14:05:09", mustParse(t, "en-GB").Time(at))
+	assert.Equal(t, "2026-10-15T14:05:09Z", POSIX.Time(at))
+
+	d := time.Hour + 2*time.Minute + 3400*time.Millisecond
+	assert.Equal(t, "1h2m3s", en.Duration(d))
+	assert.Equal(t, "1時間2分3秒", ja.Duration(d))
+	assert.Equal(t, "5s", en.Duration(5*time.Second))
+	assert.Equal(t, "250ミリ秒", ja.Duration(250*time.Millisecond))
+}
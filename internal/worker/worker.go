@@ -0,0 +1,59 @@
+// Package worker is a bounded pool of goroutines shared by s3ry operations.
+package worker
+
+import (
+	"context"
+	"sync"
+)
+
+// Task unit of work run by Pool
+type Task func(ctx context.Context)
+
+// Pool run tasks on a fixed number of goroutines
+type Pool struct {
+	ctx   context.Context
+	tasks chan Task
+	wg    sync.WaitGroup
+}
+
+// New start Pool with workers goroutines, tasks stop being started when ctx is done
+func New(ctx context.Context, workers int) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	p := &Pool{ctx: ctx, tasks: make(chan Task)}
+	for i := 0; i < workers; i++ {
+		p.wg.Add(1)
+		go p.work()
+	}
+	return p
+}
+
+// work run tasks until the pool is closed
+func (p *Pool) work() {
+	defer p.wg.Done()
+	for task := range p.tasks {
+		task(p.ctx)
+	}
+}
+
+// Submit wait for a free worker and hand task to it
+// it returns the context error without running task once ctx is done
+func (p *Pool) Submit(task Task) error {
+	if err := p.ctx.Err(); err != nil {
+		return err
+	}
+	select {
+	case p.tasks <- task:
+		return nil
+	case <-p.ctx.Done():
+		return p.ctx.Err()
+	}
+}
+
+// Wait wait for submitted tasks to finish and stop the workers
+// Submit must not be called after Wait
+func (p *Pool) Wait() {
+	close(p.tasks)
+	p.wg.Wait()
+}
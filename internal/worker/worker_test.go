@@ -0,0 +1,54 @@
+package worker
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPoolLimitsConcurrency(t *testing.T) {
+	var running, max, done int32
+	p := New(context.Background(), 3)
+	for i := 0; i < 20; i++ {
+		err := p.Submit(func(ctx context.Context) {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				m := atomic.LoadInt32(&max)
+				if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			atomic.AddInt32(&done, 1)
+		})
+		assert.NoError(t, err)
+	}
+	p.Wait()
+	assert.Equal(t, int32(20), done)
+	assert.True(t, max <= 3, "max %d", max)
+}
+
+func TestPoolStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var started int32
+	p := New(ctx, 1)
+	var err error
+	for i := 0; i < 10; i++ {
+		err = p.Submit(func(ctx context.Context) {
+			if atomic.AddInt32(&started, 1) == 2 {
+				cancel()
+			}
+		})
+		if err != nil {
+			break
+		}
+	}
+	p.Wait()
+	assert.Equal(t, context.Canceled, err)
+	assert.True(t, started < 10, "started %d", started)
+}
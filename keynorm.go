@@ -0,0 +1,305 @@
+package s3ry
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/seike460/s3ry/internal/events"
+	"github.com/seike460/s3ry/internal/worker"
+)
+
+// Problems of object keys found by AnalyzeKeys
+const (
+	// KeyLeadingSlash key starting with "/", an empty first segment
+	KeyLeadingSlash = "leading-slash"
+	// KeyEmptySegment key with "//" inside
+	KeyEmptySegment = "empty-segment"
+	// KeySpace segment starting or ending with white space
+	KeySpace = "space"
+	// KeyUpperCase key with upper case letters, reported when Normalize.Lowercase is set
+	KeyUpperCase = "upper-case"
+	// KeyCaseCollision key equal to another key except for case, which collide on case-insensitive file systems
+	KeyCaseCollision = "case-collision"
+)
+
+// NormalizeConfig rules of the normalize command, a problem whose rule is off is reported but not fixed
+type NormalizeConfig struct {
+	// CollapseSlashes remove a leading "/" and empty segments (default true)
+	CollapseSlashes bool
+	// TrimSpaces remove white space around each segment (default true)
+	TrimSpaces bool
+	// Lowercase lowercase the keys (default false)
+	Lowercase bool
+}
+
+// normalize return key cleaned by the rules of c, keeping a trailing "/" of a folder marker
+func (c NormalizeConfig) normalize(key string) string {
+	segments := strings.Split(key, "/")
+	folder := len(segments) > 1 && segments[len(segments)-1] == ""
+	if folder {
+		segments = segments[:len(segments)-1]
+	}
+	var clean []string
+	for _, segment := range segments {
+		if c.TrimSpaces {
+			segment = strings.TrimSpace(segment)
+		}
+		if segment == "" && c.CollapseSlashes {
+			continue
+		}
+		clean = append(clean, segment)
+	}
+	normalized := strings.Join(clean, "/")
+	if folder && normalized != "" {
+		normalized += "/"
+	}
+	if c.Lowercase {
+		normalized = strings.ToLower(normalized)
+	}
+	return normalized
+}
+
+// keyProblems return problems of key, except case collisions which need the other keys
+func (c NormalizeConfig) keyProblems(key string) []string {
+	var problems []string
+	if strings.HasPrefix(key, "/") {
+		problems = append(problems, KeyLeadingSlash)
+	}
+	if strings.Contains(strings.TrimPrefix(key, "/"), "//") {
+		problems = append(problems, KeyEmptySegment)
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if strings.TrimSpace(segment) != segment {
+			problems = append(problems, KeySpace)
+			break
+		}
+	}
+	if c.Lowercase && strings.ToLower(key) != key {
+		problems = append(problems, KeyUpperCase)
+	}
+	return problems
+}
+
+// KeyIssue object key with problems
+type KeyIssue struct {
+	Key string
+	// Normalized key the object is renamed to, Key when the enabled rules don't change it
+	Normalized string
+	Problems   []string
+	// Conflict another object has or would get Normalized, so the object is left as it is
+	Conflict bool
+	Size     int64 `json:"-"`
+}
+
+// KeyReport result of AnalyzeKeys
+type KeyReport struct {
+	Scanned int
+	// Issues keys with problems, sorted by key
+	Issues []KeyIssue
+}
+
+// Renames return the issues fixed by renaming the object
+func (r KeyReport) Renames() []KeyIssue {
+	var renames []KeyIssue
+	for _, issue := range r.Issues {
+		if issue.Normalized != issue.Key && !issue.Conflict {
+			renames = append(renames, issue)
+		}
+	}
+	return renames
+}
+
+// AnalyzeKeys find keys under prefix with empty segments, "//", white space around segments or differing only in case,
+// and the key each is normalized to by Normalize
+func (s S3ry) AnalyzeKeys(ctx context.Context, bucket string, prefix string) (KeyReport, error) {
+	var report KeyReport
+	s, err := s.forBucket(bucket)
+	if err != nil {
+		return report, err
+	}
+	rules := s.config().Normalize
+	sizes := map[string]int64{}
+	err = s.Svc.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(bucket),
+		Prefix:  aws.String(prefix),
+		MaxKeys: aws.Int64(s.config().Performance.ListPageSize),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, object := range page.Contents {
+			sizes[aws.StringValue(object.Key)] = aws.Int64Value(object.Size)
+		}
+		return true
+	})
+	if err != nil {
+		return report, err
+	}
+	report.Scanned = len(sizes)
+
+	byLower := map[string][]string{}
+	targets := map[string][]string{}
+	for key := range sizes {
+		byLower[strings.ToLower(key)] = append(byLower[strings.ToLower(key)], key)
+		targets[rules.normalize(key)] = append(targets[rules.normalize(key)], key)
+	}
+	for key, size := range sizes {
+		problems := rules.keyProblems(key)
+		if len(byLower[strings.ToLower(key)]) > 1 {
+			problems = append(problems, KeyCaseCollision)
+		}
+		if len(problems) == 0 {
+			continue
+		}
+		normalized := rules.normalize(key)
+		_, exists := sizes[normalized]
+		report.Issues = append(report.Issues, KeyIssue{
+			Key:        key,
+			Normalized: normalized,
+			Problems:   problems,
+			Conflict:   normalized != key && (exists || normalized == "" || len(targets[normalized]) > 1),
+			Size:       size,
+		})
+	}
+	sort.Slice(report.Issues, func(a, b int) bool {
+		return report.Issues[a].Key < report.Issues[b].Key
+	})
+	return report, nil
+}
+
+// NormalizeSummary result of RenameKeys
+type NormalizeSummary struct {
+	Renamed int
+	// Failed error of each key which could not be renamed
+	Failed map[string]error
+}
+
+// keepKeyPath request.Option sending the key as it is, the SDK otherwise cleans "//" and a leading "/" out of the path
+// the copy source is a header, so only requests to the messy key need it, like the source head of a multipart copy
+func keepKeyPath(r *request.Request) {
+	r.Config.DisableRestProtocolURICleaning = aws.Bool(true)
+}
+
+// renameObject copy key to newKey and delete key
+func (s S3ry) renameObject(ctx context.Context, bucket string, key string, newKey string, size int64) error {
+	if err := s.CopyObject(ctx, bucket, key, bucket, newKey, size); err != nil {
+		return err
+	}
+	_, err := s.Svc.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)}, keepKeyPath)
+	return err
+}
+
+// RenameKeys rename the objects of renames to their normalized key, copying them and deleting the old key,
+// Performance.Workers at once
+func (s S3ry) RenameKeys(ctx context.Context, bucket string, renames []KeyIssue) (NormalizeSummary, error) {
+	summary := NormalizeSummary{Failed: map[string]error{}}
+	s, err := s.forBucket(bucket)
+	if err != nil {
+		return summary, err
+	}
+	byKey := map[string]KeyIssue{}
+	var mu sync.Mutex
+	pool := worker.New(ctx, s.config().Performance.Workers)
+	var submitErr error
+	for _, issue := range renames {
+		issue := issue
+		byKey[issue.Key] = issue
+		submitErr = pool.Submit(func(ctx context.Context) {
+			err := s.renameObject(ctx, bucket, issue.Key, issue.Normalized, issue.Size)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				summary.Failed[issue.Key] = err
+				return
+			}
+			summary.Renamed++
+		})
+		if submitErr != nil {
+			break
+		}
+	}
+	pool.Wait()
+	if submitErr != nil {
+		return summary, submitErr
+	}
+	err = s.retryFailed(ctx, summary.Failed, func(ctx context.Context, key string) error {
+		issue := byKey[key]
+		if err := s.renameObject(ctx, bucket, issue.Key, issue.Normalized, issue.Size); err != nil {
+			return err
+		}
+		mu.Lock()
+		summary.Renamed++
+		mu.Unlock()
+		return nil
+	})
+	return summary, err
+}
+
+// Normalize print the keys with problems under s3:// URI target and rename them to their normalized key,
+// used by the normalize command. dryRun only prints them
+func Normalize(ctx context.Context, cfg *Config, target string, dryRun bool, w io.Writer) error {
+	bucket, prefix, err := ParseS3URI(target)
+	if err != nil {
+		return err
+	}
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	if !dryRun {
+		if err := s.preflight(ctx, bucket, prefix, PermListBucket, PermGetObject, PermPutObject, PermDeleteObject); err != nil {
+			return err
+		}
+	}
+	sps(i18nPrinter.Sprintf("Checking keys ..."))
+	report, err := s.AnalyzeKeys(ctx, bucket, prefix)
+	spe()
+	if err != nil {
+		return err
+	}
+	conflicts := 0
+	for _, issue := range report.Issues {
+		switch {
+		case issue.Conflict:
+			conflicts++
+			fmt.Fprintln(w, i18nPrinter.Sprintf("%q -> %q (%s) conflicts with another key, skipped", issue.Key, issue.Normalized, strings.Join(issue.Problems, ",")))
+		case issue.Normalized != issue.Key:
+			fmt.Fprintf(w, "%q -> %q (%s)\n", issue.Key, issue.Normalized, strings.Join(issue.Problems, ","))
+		default:
+			fmt.Fprintf(w, "%q (%s)\n", issue.Key, strings.Join(issue.Problems, ","))
+		}
+	}
+	renames := report.Renames()
+	if dryRun {
+		fmt.Fprintln(w, i18nPrinter.Sprintf("Scanned: %d, problems: %d, would rename: %d, conflicts: %d", report.Scanned, len(report.Issues), len(renames), conflicts))
+		return nil
+	}
+	summary := NormalizeSummary{Failed: map[string]error{}}
+	if len(renames) > 0 {
+		if err := cfg.confirmDestructive(true, i18nPrinter.Sprintf("Rename %d objects under% s? [Yy] / [Nn]", len(renames), target)); err != nil {
+			return err
+		}
+		s.Events = events.NewBus()
+		defer s.Events.Close()
+		stop := events.Aggregate(s.Events, "copy")
+		s.Events.Subscribe(spinnerSummary)
+		sps(i18nPrinter.Sprintf("Renaming objects ..."))
+		summary, err = s.RenameKeys(ctx, bucket, renames)
+		stop()
+		spe()
+	}
+	var failed []string
+	for key := range summary.Failed {
+		failed = append(failed, key)
+	}
+	sort.Strings(failed)
+	for _, key := range failed {
+		fmt.Fprintln(w, i18nPrinter.Sprintf("Failed,% s: %s", key, summary.Failed[key].Error()))
+	}
+	fmt.Fprintln(w, i18nPrinter.Sprintf("Renamed: %d, conflicts: %d, failed: %d", summary.Renamed, conflicts, len(summary.Failed)))
+	if err == nil && len(summary.Failed) > 0 {
+		err = &PartialError{Failed: len(summary.Failed), Op: "normalize"}
+	}
+	return err
+}
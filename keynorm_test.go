@@ -0,0 +1,104 @@
+package s3ry
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeKey(t *testing.T) {
+	rules := DefaultConfig().Normalize
+	for key, want := range map[string]string{
+		"logs/a.txt":          "logs/a.txt",
+		"/logs/a.txt":         "logs/a.txt",
+		"logs//2026//a.txt":   "logs/2026/a.txt",
+		"logs /a.txt ":        "logs/a.txt",
+		"logs/ 2026 /":        "logs/2026/",
+		"Logs/A.txt":          "Logs/A.txt",
+		"//":                  "",
+		"logs/with space.txt": "logs/with space.txt",
+	} {
+		assert.Equal(t, want, rules.normalize(key), key)
+	}
+	rules.TrimSpaces = false
+	assert.Equal(t, "logs /a.txt ", rules.normalize("/logs //a.txt "))
+	rules.Lowercase = true
+	assert.Equal(t, "logs /a.txt ", rules.normalize("Logs /A.txt "))
+}
+
+func TestAnalyzeKeys(t *testing.T) {
+	fake := newFakeS3("bucket")
+	for _, key := range []string{"/data/a.csv", "data//b.csv", "data/c.csv ", "data/Report.csv", "data/report.csv", "data/ok.csv", "data/d.csv", "data//d.csv"} {
+		fake.put("bucket", key, key)
+	}
+	s, srv := newTestS3ry(DefaultConfig(), fake)
+	defer srv.Close()
+
+	report, err := s.AnalyzeKeys(context.Background(), "bucket", "")
+	assert.NoError(t, err)
+	assert.Equal(t, 8, report.Scanned)
+	assert.Equal(t, []KeyIssue{
+		{Key: "/data/a.csv", Normalized: "data/a.csv", Problems: []string{KeyLeadingSlash}, Size: 11},
+		{Key: "data//b.csv", Normalized: "data/b.csv", Problems: []string{KeyEmptySegment}, Size: 11},
+		{Key: "data//d.csv", Normalized: "data/d.csv", Problems: []string{KeyEmptySegment}, Conflict: true, Size: 11},
+		{Key: "data/Report.csv", Normalized: "data/Report.csv", Problems: []string{KeyCaseCollision}, Size: 15},
+		{Key: "data/c.csv ", Normalized: "data/c.csv", Problems: []string{KeySpace}, Size: 11},
+		{Key: "data/report.csv", Normalized: "data/report.csv", Problems: []string{KeyCaseCollision}, Size: 15},
+	}, report.Issues)
+	assert.Len(t, report.Renames(), 3)
+
+	// lowercasing would merge the colliding keys
+	s.Config.Normalize.Lowercase = true
+	report, err = s.AnalyzeKeys(context.Background(), "bucket", "data/")
+	assert.NoError(t, err)
+	if assert.Len(t, report.Issues, 5) {
+		assert.Equal(t, KeyIssue{Key: "data/Report.csv", Normalized: "data/report.csv", Problems: []string{KeyUpperCase, KeyCaseCollision}, Conflict: true, Size: 15}, report.Issues[2])
+	}
+}
+
+func TestNormalizeCommand(t *testing.T) {
+	fake := newFakeS3("bucket")
+	for _, key := range []string{"/data/a.csv", "data//b.csv", "data/ c.csv", "data/ok.csv", "data/d.csv", "data//d.csv"} {
+		fake.put("bucket", key, key)
+	}
+	cfg, done := newFakeEndpoint(t, fake)
+	defer done()
+	cfg.Security.AssumeYes = true
+
+	var out bytes.Buffer
+	assert.NoError(t, Normalize(context.Background(), cfg, "s3://bucket", true, &out))
+	assert.Contains(t, out.String(), `"data//b.csv" -> "data/b.csv" (empty-segment)`)
+	assert.Len(t, fake.keys("bucket"), 6)
+	assert.Equal(t, 0, fake.count("COPY"))
+
+	out.Reset()
+	assert.NoError(t, Normalize(context.Background(), cfg, "s3://bucket", false, &out))
+	assert.Equal(t, []string{"data//d.csv", "data/a.csv", "data/b.csv", "data/c.csv", "data/d.csv", "data/ok.csv"}, fake.keys("bucket"))
+	o, ok := fake.get("bucket", "data/c.csv")
+	if assert.True(t, ok) {
+		assert.Equal(t, "data/ c.csv", string(o.data))
+	}
+	assert.Contains(t, out.String(), "Renamed: 3, conflicts: 1, failed: 0")
+}
+
+func TestNormalizeLargeObjects(t *testing.T) {
+	fake := newFakeS3("bucket")
+	for _, key := range []string{"/data/a.csv", "data//b.csv"} {
+		fake.put("bucket", key, key)
+	}
+	cfg, done := newFakeEndpoint(t, fake)
+	defer done()
+	cfg.Security.AssumeYes = true
+	// copied in parts, after heading the messy key
+	cfg.Performance.MultipartCopyThreshold = 1
+
+	var out bytes.Buffer
+	assert.NoError(t, Normalize(context.Background(), cfg, "s3://bucket", false, &out))
+	assert.Equal(t, []string{"data/a.csv", "data/b.csv"}, fake.keys("bucket"))
+	assert.Equal(t, 2, fake.count("UPLOAD_PART_COPY"))
+	o, _ := fake.get("bucket", "data/b.csv")
+	assert.Equal(t, "data//b.csv", string(o.data))
+	assert.Contains(t, out.String(), "Renamed: 2, conflicts: 0, failed: 0")
+}
@@ -0,0 +1,204 @@
+package s3ry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/seike460/s3ry/internal/events"
+)
+
+// maxKeyLength bytes of the longest object key S3 accepts
+const maxKeyLength = 1024
+
+// ErrInvalidKeyTemplate key template or a key it expanded to can't be used
+var ErrInvalidKeyTemplate = errors.New("invalid key template")
+
+// keyVariables variables of key templates
+//
+//	{date}     upload date in UTC, e.g. 2006-01-02
+//	{filename} file name, e.g. report.csv
+//	{ext}      file extension without the dot, e.g. csv
+//	{hash}     first 16 hex digits of the SHA-256 of the file
+//	{index}    position of the file among the uploaded files, from 0
+var keyVariables = map[string]bool{"date": true, "filename": true, "ext": true, "hash": true, "index": true}
+
+// KeyTemplate object key with {variable}s expanded for each uploaded file
+type KeyTemplate struct {
+	// parts literal text and variable names alternately, starting with text
+	parts []string
+}
+
+// ParseKeyTemplate parse template like "logs/{date}/{filename}"
+func ParseKeyTemplate(template string) (*KeyTemplate, error) {
+	if template == "" {
+		return nil, fmt.Errorf("%w, it is empty", ErrInvalidKeyTemplate)
+	}
+	t := &KeyTemplate{}
+	rest := template
+	for {
+		open := strings.IndexByte(rest, '{')
+		if close := strings.IndexByte(rest, '}'); close >= 0 && (open < 0 || close < open) {
+			return nil, fmt.Errorf("%w %q, } without {", ErrInvalidKeyTemplate, template)
+		}
+		if open < 0 {
+			t.parts = append(t.parts, rest)
+			return t, nil
+		}
+		close := strings.IndexByte(rest[open:], '}')
+		if close < 0 {
+			return nil, fmt.Errorf("%w %q, { without }", ErrInvalidKeyTemplate, template)
+		}
+		name := rest[open+1 : open+close]
+		if !keyVariables[name] {
+			return nil, fmt.Errorf("%w %q, unknown variable {%s}, use {date}, {filename}, {ext}, {hash} or {index}", ErrInvalidKeyTemplate, template, name)
+		}
+		t.parts = append(t.parts, rest[:open], name)
+		rest = rest[open+close+1:]
+	}
+}
+
+// Expand return key of file path, the index-th uploaded file, uploaded at now
+// hash is only read when the template uses it
+func (t *KeyTemplate) Expand(path string, index int, now time.Time, hash func() (string, error)) (string, error) {
+	var b strings.Builder
+	for i, part := range t.parts {
+		if i%2 == 0 {
+			b.WriteString(part)
+			continue
+		}
+		switch part {
+		case "date":
+			b.WriteString(now.UTC().Format("2006-01-02"))
+		case "filename":
+			b.WriteString(filepath.Base(path))
+		case "ext":
+			b.WriteString(strings.TrimPrefix(filepath.Ext(path), "."))
+		case "hash":
+			h, err := hash()
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(h)
+		case "index":
+			b.WriteString(strconv.Itoa(index))
+		}
+	}
+	return b.String(), nil
+}
+
+// checkObjectKey check key is a key S3 accepts
+func checkObjectKey(key string) error {
+	if key == "" || len(key) > maxKeyLength {
+		return fmt.Errorf("%w, key %q must be 1 to %d bytes", ErrInvalidKeyTemplate, key, maxKeyLength)
+	}
+	if !utf8.ValidString(key) {
+		return fmt.Errorf("%w, key %q is not valid UTF-8", ErrInvalidKeyTemplate, key)
+	}
+	return nil
+}
+
+// fileHash return first 16 hex digits of the SHA-256 of file path
+func fileHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16], nil
+}
+
+// templateKeys return key of every file in srcs under prefix by template, checking no two files get the same key
+func templateKeys(template *KeyTemplate, prefix string, srcs []string, now time.Time) ([]string, error) {
+	keys := make([]string, len(srcs))
+	uploaded := map[string]string{}
+	for i, src := range srcs {
+		key, err := template.Expand(src, i, now, func() (string, error) { return fileHash(src) })
+		if err != nil {
+			return nil, err
+		}
+		key = prefix + key
+		if err := checkObjectKey(key); err != nil {
+			return nil, err
+		}
+		if other, ok := uploaded[key]; ok {
+			return nil, fmt.Errorf("%w, %s and %s would both be uploaded to %s", ErrInvalidKeyTemplate, other, src, key)
+		}
+		uploaded[key] = src
+		keys[i] = key
+	}
+	return keys, nil
+}
+
+// PutFiles upload local files srcs under s3:// URI dst, a bucket or a prefix ending with /, used by put with several files
+// the key of each file under dst is template expanded for it, "{filename}" when empty,
+// and a file failing to upload doesn't stop the others
+func PutFiles(ctx context.Context, cfg *Config, srcs []string, dst string, template string) error {
+	bucket, prefix, err := ParseS3URI(dst)
+	if err != nil {
+		return err
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		return fmt.Errorf("%w %q, files are uploaded under a prefix ending with /", ErrInvalidURI, dst)
+	}
+	if template == "" {
+		template = "{filename}"
+	}
+	t, err := ParseKeyTemplate(template)
+	if err != nil {
+		return err
+	}
+	for _, src := range srcs {
+		if src == stdio {
+			return fmt.Errorf("%w, stdin has no file to expand it for", ErrInvalidKeyTemplate)
+		}
+	}
+	keys, err := templateKeys(t, prefix, srcs, time.Now())
+	if err != nil {
+		return err
+	}
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	if err := s.preflight(ctx, bucket, prefix, PermPutObject); err != nil {
+		return err
+	}
+	s.Events = events.NewBus()
+	defer s.Events.Close()
+	report := events.Collect(s.Events)
+	failed := 0
+	for i, src := range srcs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		f, err := os.Open(src)
+		if err != nil {
+			s.Events.Publish(events.Event{Type: events.Failed, Operation: "upload", Bucket: bucket, Key: keys[i], Err: err})
+			failed++
+			continue
+		}
+		_, err = s.PutStream(ctx, bucket, keys[i], f)
+		f.Close()
+		if err != nil {
+			failed++
+		}
+	}
+	if err := cfg.writeReport(report(), os.Stderr); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return &PartialError{Failed: failed, Op: "upload"}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package s3ry
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseKeyTemplate(t *testing.T) {
+	for _, template := range []string{"", "{date", "date}", "{file}", "a/{date}}", "{{date}}"} {
+		_, err := ParseKeyTemplate(template)
+		assert.Error(t, err, template)
+		assert.Equal(t, ExitUsage, ExitCode(err), template)
+	}
+	_, err := ParseKeyTemplate("logs/{date}/{index}-{hash}.{ext}")
+	assert.NoError(t, err)
+}
+
+func TestTemplateKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "s3ry-keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	srcs := []string{filepath.Join(dir, "a.csv"), filepath.Join(dir, "b.json"), filepath.Join(dir, "c")}
+	for i, data := range []string{"hello", "{}", ""} {
+		ioutil.WriteFile(srcs[i], []byte(data), 0644)
+	}
+	// 08:30 in Tokyo is the day before in UTC
+	now := time.Date(2026, 10, 15, 8, 30, 0, 0, time.FixedZone("JST", 9*60*60))
+	template, err := ParseKeyTemplate("{date}/{index}-{filename}.{ext}/{hash}")
+	assert.NoError(t, err)
+
+	keys, err := templateKeys(template, "in/", srcs, now)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		// sha256 of "hello", "{}" and ""
+		"in/2026-10-14/0-a.csv.csv/2cf24dba5fb0a30e",
+		"in/2026-10-14/1-b.json.json/44136fa355b3678a",
+		"in/2026-10-14/2-c./e3b0c44298fc1c14",
+	}, keys)
+
+	// keys clashing or too long are rejected
+	template, _ = ParseKeyTemplate("{date}")
+	_, err = templateKeys(template, "", srcs, now)
+	assert.Contains(t, err.Error(), "would both be uploaded")
+	template, _ = ParseKeyTemplate("{filename}")
+	_, err = templateKeys(template, strings.Repeat("x", 1024), srcs[:1], now)
+	assert.Equal(t, ExitUsage, ExitCode(err))
+}
+
+func TestPutFiles(t *testing.T) {
+	fake := newFakeS3("bucket")
+	cfg, done := newFakeEndpoint(t, fake)
+	defer done()
+	a := filepath.Join(cfg.Performance.TempDir, "a.txt")
+	b := filepath.Join(cfg.Performance.TempDir, "b.txt")
+	ioutil.WriteFile(a, []byte("a"), 0644)
+	ioutil.WriteFile(b, []byte("b"), 0644)
+	ctx := context.Background()
+
+	assert.NoError(t, PutFiles(ctx, cfg, []string{a, b}, "s3://bucket/up/", "{ext}/{index}-{filename}"))
+	assert.Equal(t, []string{"up/txt/0-a.txt", "up/txt/1-b.txt"}, fake.keys("bucket"))
+
+	assert.Equal(t, ExitUsage, ExitCode(PutFiles(ctx, cfg, []string{a}, "s3://bucket/key", "")))
+	assert.Equal(t, ExitUsage, ExitCode(PutFiles(ctx, cfg, []string{a, "-"}, "s3://bucket/", "")))
+	assert.Equal(t, 2, fake.count("PUT"))
+}
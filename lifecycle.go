@@ -0,0 +1,135 @@
+package s3ry
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// abortUploadsRuleID id of the lifecycle rule added by abort-rule, replaced when it is set again
+const abortUploadsRuleID = "s3ry-abort-incomplete-multipart-uploads"
+
+// AbortIncompleteUploadsRule lifecycle rule aborting multipart uploads of the whole bucket days after they were started
+// parts of an incomplete upload are stored and billed until it is aborted
+func AbortIncompleteUploadsRule(days int64) *s3.LifecycleRule {
+	return &s3.LifecycleRule{
+		ID:     aws.String(abortUploadsRuleID),
+		Status: aws.String(s3.ExpirationStatusEnabled),
+		Filter: &s3.LifecycleRuleFilter{Prefix: aws.String("")},
+		AbortIncompleteMultipartUpload: &s3.AbortIncompleteMultipartUpload{
+			DaysAfterInitiation: aws.Int64(days),
+		},
+	}
+}
+
+// hasAbortUploadsRule check rules abort incomplete multipart uploads of the whole bucket
+// a rule of a prefix or tags leaves the uploads of other keys
+func hasAbortUploadsRule(rules []*s3.LifecycleRule) bool {
+	for _, r := range rules {
+		if aws.StringValue(r.Status) != s3.ExpirationStatusEnabled || r.AbortIncompleteMultipartUpload == nil {
+			continue
+		}
+		if aws.StringValue(r.Prefix) != "" {
+			continue
+		}
+		if f := r.Filter; f != nil && (aws.StringValue(f.Prefix) != "" || f.Tag != nil || f.And != nil) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// BucketLifecycleRules get lifecycle rules of bucket, empty when it has none
+func (s S3ry) BucketLifecycleRules(ctx context.Context, bucket string) ([]*s3.LifecycleRule, error) {
+	s, err := s.forBucket(bucket)
+	if err != nil {
+		return nil, err
+	}
+	out, err := s.Svc.GetBucketLifecycleConfigurationWithContext(ctx, &s3.GetBucketLifecycleConfigurationInput{Bucket: aws.String(bucket)})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "NoSuchLifecycleConfiguration" {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out.Rules, nil
+}
+
+// PutAbortIncompleteUploadsRule add AbortIncompleteUploadsRule to the lifecycle rules of bucket, keeping the other rules
+func (s S3ry) PutAbortIncompleteUploadsRule(ctx context.Context, bucket string, days int64) (err error) {
+	done := s.track("lifecycle", bucket, "")
+	defer func() { done(err) }()
+	if days < 1 {
+		return fmt.Errorf("uploads are aborted at least 1 day after they were started, not %d", days)
+	}
+	rules, err := s.BucketLifecycleRules(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	kept := []*s3.LifecycleRule{AbortIncompleteUploadsRule(days)}
+	for _, r := range rules {
+		if aws.StringValue(r.ID) != abortUploadsRuleID {
+			kept = append(kept, r)
+		}
+	}
+	if s, err = s.forBucket(bucket); err != nil {
+		return err
+	}
+	_, err = s.Svc.PutBucketLifecycleConfigurationWithContext(ctx, &s3.PutBucketLifecycleConfigurationInput{
+		Bucket:                 aws.String(bucket),
+		LifecycleConfiguration: &s3.BucketLifecycleConfiguration{Rules: kept},
+	})
+	return err
+}
+
+// BucketsWithoutAbortRule return buckets whose lifecycle rules leave incomplete multipart uploads, every bucket when none are given
+func (s S3ry) BucketsWithoutAbortRule(ctx context.Context, buckets []string) ([]string, error) {
+	if len(buckets) == 0 {
+		out, err := s.Svc.ListBucketsWithContext(ctx, &s3.ListBucketsInput{})
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range out.Buckets {
+			buckets = append(buckets, aws.StringValue(b.Name))
+		}
+	}
+	var missing []string
+	for _, bucket := range buckets {
+		rules, err := s.BucketLifecycleRules(ctx, bucket)
+		if err != nil {
+			return missing, fmt.Errorf("%s: %w", bucket, err)
+		}
+		if !hasAbortUploadsRule(rules) {
+			missing = append(missing, bucket)
+		}
+	}
+	return missing, nil
+}
+
+// AbortRule set the rule aborting incomplete multipart uploads after days on buckets, used by the abort-rule command
+// with report the buckets lacking such a rule are printed instead, every bucket is checked when none are given
+func AbortRule(ctx context.Context, cfg *Config, buckets []string, days int64, report bool, w io.Writer) error {
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	if report {
+		missing, err := s.BucketsWithoutAbortRule(ctx, buckets)
+		for _, bucket := range missing {
+			fmt.Fprintln(w, bucket)
+		}
+		return err
+	}
+	if days == 0 {
+		days = cfg.Cleanup.AbortUploadsAfterDays
+	}
+	for _, bucket := range buckets {
+		if err := s.PutAbortIncompleteUploadsRule(ctx, bucket, days); err != nil {
+			return err
+		}
+		fmt.Fprintln(w, i18nPrinter.Sprintf("Incomplete multipart uploads of% s are aborted after %d days", bucket, days))
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package s3ry
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAbortIncompleteUploadsRule(t *testing.T) {
+	rule := AbortIncompleteUploadsRule(3)
+	assert.NoError(t, rule.Validate())
+	assert.Equal(t, "Enabled", aws.StringValue(rule.Status))
+	assert.Equal(t, "", aws.StringValue(rule.Filter.Prefix))
+	assert.Equal(t, int64(3), aws.Int64Value(rule.AbortIncompleteMultipartUpload.DaysAfterInitiation))
+	assert.Nil(t, rule.Expiration)
+	assert.True(t, hasAbortUploadsRule([]*s3.LifecycleRule{rule}))
+
+	expire := &s3.LifecycleRule{Status: aws.String("Enabled"), Filter: &s3.LifecycleRuleFilter{Prefix: aws.String("")},
+		Expiration: &s3.LifecycleExpiration{Days: aws.Int64(30)}}
+	prefixed := AbortIncompleteUploadsRule(3)
+	prefixed.Filter.Prefix = aws.String("logs/")
+	disabled := AbortIncompleteUploadsRule(3)
+	disabled.Status = aws.String("Disabled")
+	tagged := AbortIncompleteUploadsRule(3)
+	tagged.Filter = &s3.LifecycleRuleFilter{Tag: &s3.Tag{Key: aws.String("k"), Value: aws.String("v")}}
+	legacy := AbortIncompleteUploadsRule(3)
+	legacy.Filter = nil
+	legacy.Prefix = aws.String("")
+	assert.False(t, hasAbortUploadsRule(nil))
+	assert.False(t, hasAbortUploadsRule([]*s3.LifecycleRule{expire, prefixed, disabled, tagged}))
+	assert.True(t, hasAbortUploadsRule([]*s3.LifecycleRule{expire, legacy}))
+}
+
+func TestAbortRuleCommand(t *testing.T) {
+	fake := newFakeS3("with", "without", "expiring")
+	cfg, done := newFakeEndpoint(t, fake)
+	defer done()
+	ctx := context.Background()
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	expire := &s3.LifecycleRule{ID: aws.String("expire"), Status: aws.String("Enabled"), Filter: &s3.LifecycleRuleFilter{Prefix: aws.String("tmp/")},
+		Expiration: &s3.LifecycleExpiration{Days: aws.Int64(30)}}
+	_, err := s.Svc.PutBucketLifecycleConfiguration(&s3.PutBucketLifecycleConfigurationInput{
+		Bucket:                 aws.String("expiring"),
+		LifecycleConfiguration: &s3.BucketLifecycleConfiguration{Rules: []*s3.LifecycleRule{expire}},
+	})
+	assert.NoError(t, err)
+
+	var out bytes.Buffer
+	assert.NoError(t, AbortRule(ctx, cfg, []string{"with"}, 0, false, &out))
+	assert.Contains(t, out.String(), "with are aborted after 7 days")
+	rules, err := s.BucketLifecycleRules(ctx, "with")
+	assert.NoError(t, err)
+	if assert.Len(t, rules, 1) {
+		assert.Equal(t, int64(7), aws.Int64Value(rules[0].AbortIncompleteMultipartUpload.DaysAfterInitiation))
+	}
+
+	out.Reset()
+	assert.NoError(t, AbortRule(ctx, cfg, nil, 0, true, &out))
+	assert.Equal(t, "expiring\nwithout\n", out.String())
+
+	// the other rules are kept and the rule is replaced
+	assert.NoError(t, AbortRule(ctx, cfg, []string{"expiring"}, 2, false, &out))
+	assert.NoError(t, AbortRule(ctx, cfg, []string{"expiring"}, 3, false, &out))
+	rules, err = s.BucketLifecycleRules(ctx, "expiring")
+	assert.NoError(t, err)
+	if assert.Len(t, rules, 2) {
+		assert.Equal(t, int64(3), aws.Int64Value(rules[0].AbortIncompleteMultipartUpload.DaysAfterInitiation))
+		assert.Equal(t, "expire", aws.StringValue(rules[1].ID))
+	}
+	out.Reset()
+	assert.NoError(t, AbortRule(ctx, cfg, []string{"with", "without"}, 0, true, &out))
+	assert.Equal(t, "without\n", out.String())
+}
@@ -0,0 +1,147 @@
+package s3ry
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// ListError listing stopped part way, the objects listed before are returned with it
+type ListError struct {
+	// ContinuationToken resumes the listing after the last listed page, empty to start over
+	ContinuationToken string
+	Err               error
+}
+
+// Error return error of the failed page
+func (e *ListError) Error() string {
+	return "listing stopped: " + e.Err.Error()
+}
+
+// Unwrap return error of the failed page
+func (e *ListError) Unwrap() error {
+	return e.Err
+}
+
+// ListObjectItems list objects of bucket starting from continuation token, empty for the first page
+// a failed page returns the objects of the pages before it with a *ListError to resume from
+func (s S3ry) ListObjectItems(ctx context.Context, bucket string, token string) ([]PromptItems, error) {
+	items := []PromptItems{}
+	s, err := s.forBucket(bucket)
+	if err != nil {
+		return items, err
+	}
+	input := &s3.ListObjectsV2Input{
+		Bucket:  aws.String(bucket),
+		MaxKeys: aws.Int64(s.config().Performance.ListPageSize),
+	}
+	if token != "" {
+		input.ContinuationToken = aws.String(token)
+	}
+	err = s.Svc.ListObjectsV2PagesWithContext(ctx, input, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, item := range page.Contents {
+			if strings.HasSuffix(*item.Key, "/") {
+				continue
+			}
+			items = append(items, PromptItems{
+				Key:          len(items),
+				Val:          *item.Key,
+				Size:         aws.Int64Value(item.Size),
+				LastModified: aws.TimeValue(item.LastModified),
+				Tag:          "Object",
+			})
+		}
+		token = aws.StringValue(page.NextContinuationToken)
+		return true
+	})
+	if err != nil {
+		return items, &ListError{ContinuationToken: token, Err: err}
+	}
+	return items, nil
+}
+
+// ListOptions controls of ListObjectsPage, so scripts can paginate a listing themselves
+type ListOptions struct {
+	Prefix string
+	// StartAfter list the keys after this key, ignored when ContinuationToken is set
+	StartAfter string
+	// MaxKeys keys of the page, Performance.ListPageSize when 0
+	MaxKeys int64
+	// ContinuationToken NextContinuationToken of the page before, empty for the first page
+	ContinuationToken string
+}
+
+// ListPage one page of a listing
+type ListPage struct {
+	Bucket  string
+	Objects []SummaryObject
+	// NextContinuationToken fetches the next page, empty on the last page
+	NextContinuationToken string `json:",omitempty"`
+}
+
+// Print write objects of page, then the token of the next page
+func (p *ListPage) Print(w io.Writer) {
+	for _, o := range p.Objects {
+		fmt.Fprintf(w, "%s %12d %s\n", o.LastModified.Format(time.RFC3339), o.Size, o.Key)
+	}
+	if p.NextContinuationToken != "" {
+		fmt.Fprintln(w, i18nPrinter.Sprintf("Next page: --continuation-token %s", p.NextContinuationToken))
+	}
+}
+
+// ListObjectsPage list one page of the objects of bucket, only the objects selected by Filter, so a page may have fewer than MaxKeys
+func (s S3ry) ListObjectsPage(ctx context.Context, bucket string, opts ListOptions) (*ListPage, error) {
+	s, err := s.forBucket(bucket)
+	if err != nil {
+		return nil, err
+	}
+	f, err := s.config().objectFilter()
+	if err != nil {
+		return nil, err
+	}
+	input := &s3.ListObjectsV2Input{
+		Bucket:  aws.String(bucket),
+		Prefix:  aws.String(opts.Prefix),
+		MaxKeys: aws.Int64(s.config().Performance.ListPageSize),
+	}
+	if opts.MaxKeys > 0 {
+		input.MaxKeys = aws.Int64(opts.MaxKeys)
+	}
+	if opts.StartAfter != "" {
+		input.StartAfter = aws.String(opts.StartAfter)
+	}
+	if opts.ContinuationToken != "" {
+		input.ContinuationToken = aws.String(opts.ContinuationToken)
+	}
+	out, err := s.Svc.ListObjectsV2WithContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	page := &ListPage{Bucket: bucket, Objects: []SummaryObject{}, NextContinuationToken: aws.StringValue(out.NextContinuationToken)}
+	for _, o := range out.Contents {
+		if !selected(f, o) {
+			continue
+		}
+		page.Objects = append(page.Objects, SummaryObject{Key: aws.StringValue(o.Key), Size: aws.Int64Value(o.Size), LastModified: aws.TimeValue(o.LastModified)})
+	}
+	return page, nil
+}
+
+// List print one page of the objects under uri as text or json, used by the ls command
+func List(ctx context.Context, cfg *Config, uri string, opts ListOptions, output string, w io.Writer) error {
+	bucket, prefix, err := ParseS3URI(uri)
+	if err != nil {
+		return err
+	}
+	opts.Prefix = prefix
+	page, err := NewS3ryWithConfig(cfg.DefaultRegion(), cfg).ListObjectsPage(ctx, bucket, opts)
+	if err != nil {
+		return err
+	}
+	return printStats(page, output, w)
+}
@@ -0,0 +1,134 @@
+package s3ry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListObjectItemsPartialFailure(t *testing.T) {
+	fake := newFakeS3("bucket")
+	for i := 0; i < 10; i++ {
+		fake.put("bucket", fmt.Sprintf("key-%02d", i), "data")
+	}
+	fake.put("bucket", "dir/", "")
+	pages := 0
+	fake.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.URL.Query().Get("list-type") != "2" {
+			return false
+		}
+		pages++
+		if pages == 3 {
+			writeFakeError(w, http.StatusForbidden, "AccessDenied")
+			return true
+		}
+		return false
+	}
+	cfg := DefaultConfig()
+	cfg.Performance.ListPageSize = 3
+	s, srv := newTestS3ry(cfg, fake)
+	defer srv.Close()
+
+	items, err := s.ListObjectItems(context.Background(), "bucket", "")
+	lerr, ok := err.(*ListError)
+	if !assert.True(t, ok, "%v", err) {
+		return
+	}
+	assert.Contains(t, lerr.Error(), "AccessDenied")
+	// dir/ is on the first page but isn't an object
+	var keys []string
+	for _, item := range items {
+		keys = append(keys, item.Val)
+	}
+	assert.Equal(t, []string{"key-00", "key-01", "key-02", "key-03", "key-04"}, keys)
+	assert.Equal(t, int64(4), items[0].Size)
+
+	// resume from the failed page
+	items, err = s.ListObjectItems(context.Background(), "bucket", lerr.ContinuationToken)
+	assert.NoError(t, err)
+	keys = nil
+	for _, item := range items {
+		keys = append(keys, item.Val)
+	}
+	assert.Equal(t, []string{"key-05", "key-06", "key-07", "key-08", "key-09"}, keys)
+}
+
+func TestListObjectsPagesReturnsPartial(t *testing.T) {
+	fake := newFakeS3("bucket")
+	for i := 0; i < 5; i++ {
+		fake.put("bucket", fmt.Sprintf("key-%d", i), "data")
+	}
+	fake.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.URL.Query().Get("continuation-token") != "" {
+			writeFakeError(w, http.StatusForbidden, "AccessDenied")
+			return true
+		}
+		return false
+	}
+	cfg := DefaultConfig()
+	cfg.Performance.ListPageSize = 2
+	s, srv := newTestS3ry(cfg, fake)
+	defer srv.Close()
+
+	assert.Len(t, s.ListObjectsPages("bucket"), 2)
+}
+
+func TestListObjectsPage(t *testing.T) {
+	fake := newFakeS3("bucket")
+	for i := 0; i < 5; i++ {
+		fake.put("bucket", fmt.Sprintf("key-%d", i), "data")
+	}
+	fake.put("bucket", "other", "data")
+	s, srv := newTestS3ry(DefaultConfig(), fake)
+	defer srv.Close()
+	ctx := context.Background()
+	keys := func(page *ListPage) []string {
+		var keys []string
+		for _, o := range page.Objects {
+			keys = append(keys, o.Key)
+		}
+		return keys
+	}
+
+	page, err := s.ListObjectsPage(ctx, "bucket", ListOptions{Prefix: "key-", StartAfter: "key-1"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"key-2", "key-3", "key-4"}, keys(page))
+	assert.Empty(t, page.NextContinuationToken)
+
+	page, err = s.ListObjectsPage(ctx, "bucket", ListOptions{Prefix: "key-", MaxKeys: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"key-0", "key-1"}, keys(page))
+	assert.Equal(t, int64(4), page.Objects[0].Size)
+	if assert.NotEmpty(t, page.NextContinuationToken) {
+		page, err = s.ListObjectsPage(ctx, "bucket", ListOptions{Prefix: "key-", MaxKeys: 2, ContinuationToken: page.NextContinuationToken})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"key-2", "key-3"}, keys(page))
+	}
+}
+
+func TestList(t *testing.T) {
+	fake := newFakeS3("bucket")
+	fake.put("bucket", "a", "data")
+	fake.put("bucket", "b", "data")
+	cfg, done := newFakeEndpoint(t, fake)
+	defer done()
+
+	var out bytes.Buffer
+	assert.NoError(t, List(context.Background(), cfg, "s3://bucket", ListOptions{MaxKeys: 1}, "", &out))
+	assert.Contains(t, out.String(), "4 a\n")
+	assert.Contains(t, out.String(), "--continuation-token a")
+
+	out.Reset()
+	assert.NoError(t, List(context.Background(), cfg, "s3://bucket", ListOptions{StartAfter: "a"}, "json", &out))
+	var page ListPage
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &page))
+	if assert.Len(t, page.Objects, 1) {
+		assert.Equal(t, "b", page.Objects[0].Key)
+	}
+	assert.Empty(t, page.NextContinuationToken)
+}
@@ -0,0 +1,49 @@
+package s3ry
+
+import (
+	"fmt"
+
+	"github.com/seike460/s3ry/internal/i18n"
+)
+
+// Byte size units
+const (
+	// SizesBinary byte sizes in powers of 1024, KiB and MiB
+	SizesBinary = "binary"
+	// SizesDecimal byte sizes in powers of 1000, kB and MB
+	SizesDecimal = "decimal"
+)
+
+// locale formats of the numbers, sizes and times in summaries, progress and the interactive selects
+var locale = i18n.FromEnv()
+
+// SetLocale set the locale of numbers, sizes and times from the Locale and SizeUnits config,
+// an empty name takes the locale of the LC_ALL and LANG environment variables
+func SetLocale(name string, sizeUnits string) error {
+	l := i18n.FromEnv()
+	if name != "" {
+		var err error
+		if l, err = i18n.Parse(name); err != nil {
+			return err
+		}
+	}
+	switch sizeUnits {
+	case "", SizesBinary:
+	case SizesDecimal:
+		l.DecimalSizes = true
+	default:
+		return fmt.Errorf("unknown size units %q, use binary or decimal", sizeUnits)
+	}
+	locale = l
+	return nil
+}
+
+// Modified LastModified in the locale, shown by the interactive selects
+func (p PromptItems) Modified() string {
+	return locale.Time(p.LastModified.Local())
+}
+
+// SizeText Size in the locale, shown by the interactive selects
+func (p PromptItems) SizeText() string {
+	return locale.Bytes(p.Size)
+}
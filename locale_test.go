@@ -0,0 +1,42 @@
+package s3ry
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/seike460/s3ry/internal/events"
+	"github.com/seike460/s3ry/internal/i18n"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetLocale(t *testing.T) {
+	defer func(l i18n.Locale) { locale = l }(locale)
+	report := events.Report{Changed: []events.OperationCount{{Operation: "copy", Objects: 12345, Bytes: 5 << 30}}}
+	item := PromptItems{Size: 1536, LastModified: time.Date(2026, 10, 15, 14, 5, 9, 0, time.Local)}
+	cfg := DefaultConfig()
+	out := &bytes.Buffer{}
+
+	assert.NoError(t, SetLocale("en-US", ""))
+	assert.NoError(t, cfg.writeReport(report, out))
+	assert.Equal(t, "Copied: 12,345 objects, 5.0 GiB\n", out.String())
+	assert.Equal(t, "Oct 15, 2026, 2:05:09 PM", item.Modified())
+	assert.Equal(t, "1.5 KiB", item.SizeText())
+
+	assert.NoError(t, SetLocale("ja_JP.UTF-8", SizesDecimal))
+	out.Reset()
+	assert.NoError(t, cfg.writeReport(report, out))
+	assert.Equal(t, "Copied: 12,345 objects, 5.4 GB\n", out.String())
+	assert.Equal(t, "2026/10/15 14:05:09", item.Modified())
+	assert.Equal(t, "1.5 kB", item.SizeText())
+
+	assert.NoError(t, SetLocale("de-DE", SizesBinary))
+	out.Reset()
+	assert.NoError(t, cfg.writeReport(report, out))
+	assert.Equal(t, "Copied: 12.345 objects, 5,0 GiB\n", out.String())
+
+	assert.Error(t, SetLocale("xx-YY", ""))
+	assert.Error(t, SetLocale("", "metric"))
+	// a failed SetLocale keeps the locale
+	assert.Equal(t, "de-DE", locale.Tag)
+}
@@ -0,0 +1,218 @@
+package s3ry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// ErrLogTargetDenied target bucket doesn't let S3 deliver server access logs to it
+var ErrLogTargetDenied = errors.New("target bucket doesn't allow S3 to deliver access logs, grant logging.s3.amazonaws.com s3:PutObject in its policy")
+
+// Who delivers server access logs to the target bucket
+const (
+	// logDeliveryPrincipal service principal granted in the target bucket policy
+	logDeliveryPrincipal = "logging.s3.amazonaws.com"
+	// granteeLogDelivery group granted in the target bucket ACL, the legacy way
+	granteeLogDelivery = "http://acs.amazonaws.com/groups/s3/LogDelivery"
+)
+
+// policyValues string or list of strings of a policy element
+type policyValues []string
+
+// UnmarshalJSON accept "value" and ["value", ...]
+func (v *policyValues) UnmarshalJSON(b []byte) error {
+	var one string
+	if err := json.Unmarshal(b, &one); err == nil {
+		*v = policyValues{one}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return err
+	}
+	*v = list
+	return nil
+}
+
+// has check v holds value, or a * wildcard of it
+func (v policyValues) has(value string) bool {
+	for _, s := range v {
+		if s == value || s == "*" || (strings.HasSuffix(s, "*") && strings.HasPrefix(value, strings.TrimSuffix(s, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// policyStatement statement of a bucket policy, only what log delivery needs
+type policyStatement struct {
+	Effect    string
+	Principal struct {
+		Service policyValues
+	}
+	Action policyValues
+}
+
+// allowsLogDelivery check bucket policy document lets the logging service put objects
+func allowsLogDelivery(policy string) bool {
+	var doc struct {
+		Statement json.RawMessage
+	}
+	if json.Unmarshal([]byte(policy), &doc) != nil {
+		return false
+	}
+	var statements []policyStatement
+	if json.Unmarshal(doc.Statement, &statements) != nil {
+		var one policyStatement
+		if json.Unmarshal(doc.Statement, &one) != nil {
+			return false
+		}
+		statements = []policyStatement{one}
+	}
+	for _, st := range statements {
+		if st.Effect == "Allow" && st.Principal.Service.has(logDeliveryPrincipal) && st.Action.has("s3:PutObject") {
+			return true
+		}
+	}
+	return false
+}
+
+// checkLogTarget check S3 can deliver access logs of bucket to target, by its policy or its ACL
+// logs are only delivered to a bucket in the same region
+func (s S3ry) checkLogTarget(ctx context.Context, bucket string, target string) error {
+	region, err := s.BucketRegion(bucket)
+	if err != nil {
+		return err
+	}
+	targetRegion, err := s.BucketRegion(target)
+	if err != nil {
+		return err
+	}
+	if region != targetRegion {
+		return fmt.Errorf("target bucket %s is in %s, logs of %s are only delivered to a bucket in %s", target, targetRegion, bucket, region)
+	}
+	t, err := s.forBucket(target)
+	if err != nil {
+		return err
+	}
+	policy, err := t.Svc.GetBucketPolicyWithContext(ctx, &s3.GetBucketPolicyInput{Bucket: aws.String(target)})
+	if aerr, ok := err.(awserr.Error); err != nil && (!ok || aerr.Code() != "NoSuchBucketPolicy") {
+		return err
+	}
+	if err == nil && allowsLogDelivery(aws.StringValue(policy.Policy)) {
+		return nil
+	}
+	acl, err := t.Svc.GetBucketAclWithContext(ctx, &s3.GetBucketAclInput{Bucket: aws.String(target)})
+	if err != nil {
+		return err
+	}
+	for _, g := range acl.Grants {
+		if g.Grantee == nil || aws.StringValue(g.Grantee.URI) != granteeLogDelivery {
+			continue
+		}
+		if p := aws.StringValue(g.Permission); p == s3.PermissionWrite || p == s3.PermissionFullControl {
+			return nil
+		}
+	}
+	return ErrLogTargetDenied
+}
+
+// BucketLogging get server access logging of bucket, nil when it is disabled
+func (s S3ry) BucketLogging(ctx context.Context, bucket string) (*s3.LoggingEnabled, error) {
+	s, err := s.forBucket(bucket)
+	if err != nil {
+		return nil, err
+	}
+	out, err := s.Svc.GetBucketLoggingWithContext(ctx, &s3.GetBucketLoggingInput{Bucket: aws.String(bucket)})
+	if err != nil {
+		return nil, err
+	}
+	return out.LoggingEnabled, nil
+}
+
+// PutBucketLogging deliver server access logs of bucket under prefix of target, disabling logging when target is empty
+// target is checked to accept the logs first
+func (s S3ry) PutBucketLogging(ctx context.Context, bucket string, target string, prefix string) (err error) {
+	done := s.track("logging", bucket, "")
+	defer func() { done(err) }()
+	status := &s3.BucketLoggingStatus{}
+	if target != "" {
+		if err := s.checkLogTarget(ctx, bucket, target); err != nil {
+			return err
+		}
+		status.LoggingEnabled = &s3.LoggingEnabled{TargetBucket: aws.String(target), TargetPrefix: aws.String(prefix)}
+	}
+	if s, err = s.forBucket(bucket); err != nil {
+		return err
+	}
+	_, err = s.Svc.PutBucketLoggingWithContext(ctx, &s3.PutBucketLoggingInput{Bucket: aws.String(bucket), BucketLoggingStatus: status})
+	return err
+}
+
+// BucketsWithoutLogging return buckets whose server access logging is disabled, every bucket when none are given
+func (s S3ry) BucketsWithoutLogging(ctx context.Context, buckets []string) ([]string, error) {
+	if len(buckets) == 0 {
+		out, err := s.Svc.ListBucketsWithContext(ctx, &s3.ListBucketsInput{})
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range out.Buckets {
+			buckets = append(buckets, aws.StringValue(b.Name))
+		}
+	}
+	var missing []string
+	for _, bucket := range buckets {
+		logging, err := s.BucketLogging(ctx, bucket)
+		if err != nil {
+			return missing, fmt.Errorf("%s: %w", bucket, err)
+		}
+		if logging == nil {
+			missing = append(missing, bucket)
+		}
+	}
+	return missing, nil
+}
+
+// printLogging write server access logging of bucket
+func printLogging(w io.Writer, bucket string, logging *s3.LoggingEnabled) {
+	if logging == nil {
+		fmt.Fprintln(w, i18nPrinter.Sprintf("Access logging of% s is disabled", bucket))
+		return
+	}
+	fmt.Fprintln(w, i18nPrinter.Sprintf("Access logs of% s are delivered to s3://%s/%s", bucket, aws.StringValue(logging.TargetBucket), aws.StringValue(logging.TargetPrefix)))
+}
+
+// Logging show server access logging of buckets, or change it, used by the logging command
+// target enables it with logs under prefix and disable disables it, report prints the buckets with it disabled instead,
+// every bucket when none are given
+func Logging(ctx context.Context, cfg *Config, buckets []string, target string, prefix string, disable bool, report bool, w io.Writer) error {
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	if report {
+		missing, err := s.BucketsWithoutLogging(ctx, buckets)
+		for _, bucket := range missing {
+			fmt.Fprintln(w, bucket)
+		}
+		return err
+	}
+	for _, bucket := range buckets {
+		if target != "" || disable {
+			if err := s.PutBucketLogging(ctx, bucket, target, prefix); err != nil {
+				return err
+			}
+		}
+		logging, err := s.BucketLogging(ctx, bucket)
+		if err != nil {
+			return err
+		}
+		printLogging(w, bucket, logging)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package s3ry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllowsLogDelivery(t *testing.T) {
+	assert.True(t, allowsLogDelivery(`{"Statement": [{"Effect": "Allow", "Principal": {"Service": "logging.s3.amazonaws.com"},
+		"Action": "s3:PutObject", "Resource": "arn:aws:s3:::logs/*"}]}`))
+	assert.True(t, allowsLogDelivery(`{"Statement": {"Effect": "Allow", "Principal": {"Service": ["cloudtrail.amazonaws.com", "logging.s3.amazonaws.com"]},
+		"Action": ["s3:Put*"]}}`))
+	assert.False(t, allowsLogDelivery(`{"Statement": [{"Effect": "Deny", "Principal": {"Service": "logging.s3.amazonaws.com"}, "Action": "s3:PutObject"}]}`))
+	assert.False(t, allowsLogDelivery(`{"Statement": [{"Effect": "Allow", "Principal": {"Service": "logging.s3.amazonaws.com"}, "Action": "s3:GetObject"}]}`))
+	assert.False(t, allowsLogDelivery(`{"Statement": [{"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject"}]}`))
+	assert.False(t, allowsLogDelivery(`not a policy`))
+}
+
+func TestBucketLoggingRoundTrip(t *testing.T) {
+	fake := newFakeS3("app", "denied", "by-policy", "by-acl")
+	cfg, done := newFakeEndpoint(t, fake)
+	defer done()
+	ctx := context.Background()
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	_, err := s.Svc.PutBucketPolicy(&s3.PutBucketPolicyInput{Bucket: aws.String("by-policy"), Policy: aws.String(
+		`{"Statement": [{"Effect": "Allow", "Principal": {"Service": "logging.s3.amazonaws.com"}, "Action": "s3:PutObject"}]}`)})
+	assert.NoError(t, err)
+	fake.acls["by-acl"] = "log-delivery-write"
+
+	logging, err := s.BucketLogging(ctx, "app")
+	assert.NoError(t, err)
+	assert.Nil(t, logging)
+
+	err = s.PutBucketLogging(ctx, "app", "denied", "app/")
+	assert.True(t, errors.Is(err, ErrLogTargetDenied))
+	assert.Equal(t, 0, fake.count("PUT_LOGGING"))
+
+	for _, target := range []string{"by-policy", "by-acl"} {
+		assert.NoError(t, s.PutBucketLogging(ctx, "app", target, "app/"))
+		logging, err = s.BucketLogging(ctx, "app")
+		assert.NoError(t, err)
+		if assert.NotNil(t, logging) {
+			assert.Equal(t, target, aws.StringValue(logging.TargetBucket))
+			assert.Equal(t, "app/", aws.StringValue(logging.TargetPrefix))
+		}
+	}
+
+	assert.NoError(t, s.PutBucketLogging(ctx, "app", "", ""))
+	logging, err = s.BucketLogging(ctx, "app")
+	assert.NoError(t, err)
+	assert.Nil(t, logging)
+}
+
+func TestLoggingCommand(t *testing.T) {
+	fake := newFakeS3("app", "logs", "web")
+	cfg, done := newFakeEndpoint(t, fake)
+	defer done()
+	ctx := context.Background()
+	fake.acls["logs"] = "log-delivery-write"
+
+	var out bytes.Buffer
+	assert.NoError(t, Logging(ctx, cfg, []string{"app"}, "logs", "app/", false, false, &out))
+	assert.Contains(t, out.String(), "app are delivered to s3://logs/app/")
+
+	out.Reset()
+	assert.NoError(t, Logging(ctx, cfg, nil, "", "", false, true, &out))
+	assert.Equal(t, "logs\nweb\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, Logging(ctx, cfg, []string{"app", "web"}, "", "", false, false, &out))
+	assert.Contains(t, out.String(), "app are delivered to s3://logs/app/")
+	assert.Contains(t, out.String(), "web is disabled")
+
+	out.Reset()
+	assert.NoError(t, Logging(ctx, cfg, []string{"app"}, "", "", true, false, &out))
+	assert.Contains(t, out.String(), "app is disabled")
+}
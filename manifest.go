@@ -0,0 +1,86 @@
+package s3ry
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// downloadRecord object version written to a local file by get --skip-existing
+type downloadRecord struct {
+	Bucket string
+	Key    string
+	ETag   string
+	// Size and ModTime of the local file when it was written, a file changed since is downloaded again
+	Size    int64
+	ModTime time.Time
+}
+
+// downloadManifest downloaded object versions by absolute local path, kept across runs
+// an empty path keeps the manifest in memory only
+type downloadManifest struct {
+	path    string
+	records map[string]downloadRecord
+}
+
+// defaultDownloadManifestPath return path of the download manifest next to the config file
+func defaultDownloadManifestPath() string {
+	return configDirFile("downloads.json")
+}
+
+// loadDownloadManifest load manifest from path
+func loadDownloadManifest(path string) *downloadManifest {
+	m := &downloadManifest{path: path, records: map[string]downloadRecord{}}
+	if path != "" {
+		if b, err := ioutil.ReadFile(path); err == nil {
+			json.Unmarshal(b, &m.records)
+		}
+	}
+	return m
+}
+
+// unchanged check filename holds the object version etag, as written by an earlier download
+func (m *downloadManifest) unchanged(filename string, bucket string, key string, etag string) bool {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return false
+	}
+	r, ok := m.records[abs]
+	if !ok || r.Bucket != bucket || r.Key != key || r.ETag != etag {
+		return false
+	}
+	info, err := os.Stat(abs)
+	return err == nil && info.Size() == r.Size && info.ModTime().Equal(r.ModTime)
+}
+
+// record remember filename holds the object version etag
+// like the upload journal it is best effort, a failed write only downloads the object again
+func (m *downloadManifest) record(filename string, bucket string, key string, etag string) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return
+	}
+	m.records[abs] = downloadRecord{Bucket: bucket, Key: key, ETag: etag, Size: info.Size(), ModTime: info.ModTime()}
+	m.save()
+}
+
+// save write manifest
+func (m *downloadManifest) save() {
+	if m.path == "" {
+		return
+	}
+	b, err := json.Marshal(m.records)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(m.path), 0700); err != nil {
+		return
+	}
+	ioutil.WriteFile(m.path, b, 0600)
+}
@@ -0,0 +1,68 @@
+package s3ry
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSkipExisting(t *testing.T) {
+	fake := newFakeS3("bucket")
+	fake.put("bucket", "key", "v1")
+	cfg, done := newFakeEndpoint(t, fake)
+	defer done()
+	cfg.Performance.SkipExisting = true
+	cfg.Security.ConfirmDestructive = ConfirmNever
+	dst := filepath.Join(cfg.Performance.TempDir, "key")
+	ctx := context.Background()
+
+	assert.NoError(t, Get(ctx, cfg, "s3://bucket/key", dst))
+	assert.Equal(t, 1, fake.count("GET"))
+
+	// unchanged object is not transferred again
+	assert.NoError(t, Get(ctx, cfg, "s3://bucket/key", dst))
+	assert.Equal(t, 1, fake.count("GET"))
+
+	// changed object is
+	fake.put("bucket", "key", "v2")
+	assert.NoError(t, Get(ctx, cfg, "s3://bucket/key", dst))
+	assert.Equal(t, 2, fake.count("GET"))
+	b, _ := ioutil.ReadFile(dst)
+	assert.Equal(t, "v2", string(b))
+
+	// and so is a local file changed since
+	ioutil.WriteFile(dst, []byte("edited"), 0644)
+	assert.NoError(t, Get(ctx, cfg, "s3://bucket/key", dst))
+	assert.Equal(t, 3, fake.count("GET"))
+	b, _ = ioutil.ReadFile(dst)
+	assert.Equal(t, "v2", string(b))
+
+	// without SkipExisting every get downloads
+	cfg.Performance.SkipExisting = false
+	assert.NoError(t, Get(ctx, cfg, "s3://bucket/key", dst))
+	assert.Equal(t, 4, fake.count("GET"))
+}
+
+func TestDownloadManifestPersists(t *testing.T) {
+	dir, err := ioutil.TempDir("", "s3ry-manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "file")
+	ioutil.WriteFile(file, []byte("data"), 0644)
+	path := filepath.Join(dir, "downloads.json")
+
+	m := loadDownloadManifest(path)
+	assert.False(t, m.unchanged(file, "bucket", "key", "etag"))
+	m.record(file, "bucket", "key", "etag")
+
+	m = loadDownloadManifest(path)
+	assert.True(t, m.unchanged(file, "bucket", "key", "etag"))
+	assert.False(t, m.unchanged(file, "bucket", "key", "other"))
+	assert.False(t, m.unchanged(file, "bucket", "other", "etag"))
+}
@@ -0,0 +1,255 @@
+package s3ry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/seike460/s3ry/internal/worker"
+)
+
+// SelectBuckets return the sorted names of the buckets matching path.Match pattern, e.g. "logs-*", "*" for every bucket
+func (s S3ry) SelectBuckets(ctx context.Context, pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid bucket pattern %q: %w", pattern, err)
+	}
+	out, err := s.Svc.ListBucketsWithContext(ctx, &s3.ListBucketsInput{})
+	if err != nil {
+		return nil, err
+	}
+	var buckets []string
+	for _, b := range out.Buckets {
+		if ok, _ := path.Match(pattern, aws.StringValue(b.Name)); ok {
+			buckets = append(buckets, aws.StringValue(b.Name))
+		}
+	}
+	sort.Strings(buckets)
+	return buckets, nil
+}
+
+// forEachBucket run fn for every bucket, Performance.BucketWorkers at once, returning the error of each bucket fn failed for
+// each fn still runs its own operation on Performance.Workers
+func (s S3ry) forEachBucket(ctx context.Context, buckets []string, fn func(ctx context.Context, bucket string) error) (map[string]error, error) {
+	failed := map[string]error{}
+	var mu sync.Mutex
+	pool := worker.New(ctx, s.config().Performance.BucketWorkers)
+	var submitErr error
+	for _, bucket := range buckets {
+		bucket := bucket
+		submitErr = pool.Submit(func(ctx context.Context) {
+			if err := fn(ctx, bucket); err != nil {
+				mu.Lock()
+				failed[bucket] = err
+				mu.Unlock()
+			}
+		})
+		if submitErr != nil {
+			break
+		}
+	}
+	pool.Wait()
+	return failed, submitErr
+}
+
+// printBucketFailures write the error of each bucket of failed, sorted by bucket
+func printBucketFailures(w io.Writer, failed map[string]error) {
+	var buckets []string
+	for bucket := range failed {
+		buckets = append(buckets, bucket)
+	}
+	sort.Strings(buckets)
+	for _, bucket := range buckets {
+		fmt.Fprintln(w, i18nPrinter.Sprintf("Failed,% s: %s", bucket, failed[bucket].Error()))
+	}
+}
+
+// MultiBucketSummary BucketSummary of many buckets and their total
+type MultiBucketSummary struct {
+	// Buckets summary of each bucket, sorted by bucket
+	Buckets []*BucketSummary
+	// Total totals of every bucket, without a bucket name
+	Total *BucketSummary
+	// Failed error of each bucket which could not be summarized
+	Failed map[string]error `json:"-"`
+}
+
+// BucketSummaries summarize the buckets, Performance.BucketWorkers at once
+func (s S3ry) BucketSummaries(ctx context.Context, buckets []string) (*MultiBucketSummary, error) {
+	summaries := &MultiBucketSummary{Total: newBucketSummary("", "")}
+	var mu sync.Mutex
+	failed, err := s.forEachBucket(ctx, buckets, func(ctx context.Context, bucket string) error {
+		summary, err := s.BucketSummary(ctx, bucket, "")
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		summaries.Buckets = append(summaries.Buckets, summary)
+		return nil
+	})
+	summaries.Failed = failed
+	sort.Slice(summaries.Buckets, func(a, b int) bool {
+		return summaries.Buckets[a].Bucket < summaries.Buckets[b].Bucket
+	})
+	for _, summary := range summaries.Buckets {
+		summaries.Total.merge(summary)
+		summaries.Total.IncompleteUploads += summary.IncompleteUploads
+		if summaries.Total.Time.IsZero() || summary.Time.Before(summaries.Total.Time) {
+			summaries.Total.Time = summary.Time
+		}
+	}
+	if summaries.Total.Objects > 0 {
+		summaries.Total.AverageSize = summaries.Total.Bytes / summaries.Total.Objects
+	}
+	return summaries, err
+}
+
+// Print write the objects and bytes of each bucket, then the summary of every bucket
+func (m *MultiBucketSummary) Print(w io.Writer) {
+	fmt.Fprintf(w, "%-40s %12s %16s %12s\n", "bucket", "objects", "bytes", "uploads")
+	for _, b := range m.Buckets {
+		fmt.Fprintf(w, "%-40s %12d %16d %12d\n", b.Bucket, b.Objects, b.Bytes, b.IncompleteUploads)
+	}
+	printBucketFailures(w, m.Failed)
+	fmt.Fprintln(w, i18nPrinter.Sprintf("Total of %d buckets:", len(m.Buckets)))
+	m.Total.Print(w)
+}
+
+// StatsBuckets print the summary of every bucket matching pattern and their total, used by stats --all-buckets and --buckets
+func StatsBuckets(ctx context.Context, cfg *Config, pattern string, output string, w io.Writer) error {
+	if output != "" && output != "json" {
+		return fmt.Errorf("unknown output %q, use json", output)
+	}
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	buckets, err := s.SelectBuckets(ctx, pattern)
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		sps(i18nPrinter.Sprintf("Listing %d buckets ...", len(buckets)))
+	}
+	summaries, err := s.BucketSummaries(ctx, buckets)
+	if output == "" {
+		spe()
+	}
+	if err != nil {
+		return err
+	}
+	if err := printStats(summaries, output, w); err != nil {
+		return err
+	}
+	if len(summaries.Failed) > 0 {
+		return &PartialError{Failed: len(summaries.Failed), Op: "stats"}
+	}
+	return nil
+}
+
+// BucketACLScan ACLScanSummary of one bucket of a MultiBucketACLScan
+type BucketACLScan struct {
+	Bucket string
+	ACLScanSummary
+}
+
+// MultiBucketACLScan result of ScanBucketsACLs
+type MultiBucketACLScan struct {
+	// Buckets scan of each bucket, sorted by bucket
+	Buckets []BucketACLScan
+	Scanned int
+	Exposed int
+	// Failed error of each bucket which could not be scanned
+	Failed map[string]error `json:"-"`
+}
+
+// ScanBucketsACLs run ScanObjectACLs on every object of the buckets, Performance.BucketWorkers buckets at once
+// limit and rate apply to each bucket
+func (s S3ry) ScanBucketsACLs(ctx context.Context, buckets []string, limit int64, rate int) (*MultiBucketACLScan, error) {
+	scan := &MultiBucketACLScan{}
+	var mu sync.Mutex
+	failed, err := s.forEachBucket(ctx, buckets, func(ctx context.Context, bucket string) error {
+		summary, err := s.ScanObjectACLs(ctx, bucket, "", limit, rate)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		scan.Buckets = append(scan.Buckets, BucketACLScan{Bucket: bucket, ACLScanSummary: summary})
+		return nil
+	})
+	scan.Failed = failed
+	sort.Slice(scan.Buckets, func(a, b int) bool {
+		return scan.Buckets[a].Bucket < scan.Buckets[b].Bucket
+	})
+	for _, b := range scan.Buckets {
+		scan.Scanned += b.Scanned
+		scan.Exposed += len(b.Exposed)
+	}
+	return scan, err
+}
+
+// ScanACLBuckets print objects exposed by their ACL in every bucket matching pattern, used by acl-scan --all-buckets and --buckets
+func ScanACLBuckets(ctx context.Context, cfg *Config, pattern string, limit int64, output string, w io.Writer) error {
+	if output != "" && output != "json" {
+		return fmt.Errorf("unknown output %q, use json", output)
+	}
+	if limit == 0 {
+		limit = cfg.Security.ACLScanLimit
+	}
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	buckets, err := s.SelectBuckets(ctx, pattern)
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		sps(i18nPrinter.Sprintf("Checking object ACLs of %d buckets ...", len(buckets)))
+	}
+	scan, err := s.ScanBucketsACLs(ctx, buckets, limit, cfg.Security.ACLScanRate)
+	if output == "" {
+		spe()
+	}
+	if err != nil {
+		return err
+	}
+	failures := len(scan.Failed)
+	for _, b := range scan.Buckets {
+		failures += len(b.Failed)
+	}
+	if output == "json" {
+		b, err := json.MarshalIndent(scan, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, string(b))
+	} else {
+		for _, b := range scan.Buckets {
+			for _, o := range b.Exposed {
+				fmt.Fprintf(w, "s3://%s/%s\n", b.Bucket, o.Key)
+				for _, g := range o.Grants {
+					fmt.Fprintln(w, "  "+g)
+				}
+			}
+			var failed []string
+			for key := range b.Failed {
+				failed = append(failed, key)
+			}
+			sort.Strings(failed)
+			for _, key := range failed {
+				fmt.Fprintln(w, i18nPrinter.Sprintf("Failed, s3://%s/%s: %s", b.Bucket, key, b.Failed[key].Error()))
+			}
+			if b.Truncated {
+				fmt.Fprintln(w, i18nPrinter.Sprintf("Stopped% s at the limit of %d objects", b.Bucket, limit))
+			}
+		}
+		printBucketFailures(w, scan.Failed)
+		fmt.Fprintln(w, i18nPrinter.Sprintf("Buckets: %d, scanned: %d, exposed: %d", len(scan.Buckets), scan.Scanned, scan.Exposed))
+	}
+	if failures > 0 {
+		return &PartialError{Failed: failures, Op: "scan"}
+	}
+	return nil
+}
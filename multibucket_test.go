@@ -0,0 +1,118 @@
+package s3ry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectBuckets(t *testing.T) {
+	fake := newFakeS3("logs-prod", "logs-dev", "data")
+	s, srv := newTestS3ry(DefaultConfig(), fake)
+	defer srv.Close()
+
+	buckets, err := s.SelectBuckets(context.Background(), "*")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"data", "logs-dev", "logs-prod"}, buckets)
+	buckets, err = s.SelectBuckets(context.Background(), "logs-*")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"logs-dev", "logs-prod"}, buckets)
+	_, err = s.SelectBuckets(context.Background(), "[")
+	assert.Error(t, err)
+}
+
+func TestScanBucketsACLs(t *testing.T) {
+	buckets := []string{"a", "b", "c", "d", "e"}
+	fake := newFakeS3(buckets...)
+	for _, bucket := range buckets {
+		fake.put(bucket, "private", "data")
+		fake.put(bucket, "public", "data")
+		o, _ := fake.get(bucket, "public")
+		o.acl = ACLPublicRead
+	}
+	// count the buckets with a request in flight, each request taking long enough for the others to overlap
+	var mu sync.Mutex
+	inFlight := map[string]int{}
+	var most int
+	fake.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		bucket := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)[0]
+		if bucket == "" {
+			return false
+		}
+		mu.Lock()
+		inFlight[bucket]++
+		if len(inFlight) > most {
+			most = len(inFlight)
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		if inFlight[bucket]--; inFlight[bucket] == 0 {
+			delete(inFlight, bucket)
+		}
+		mu.Unlock()
+		return false
+	}
+	cfg, done := newFakeEndpoint(t, fake)
+	defer done()
+	cfg.Performance.BucketWorkers = 2
+
+	var out bytes.Buffer
+	assert.NoError(t, ScanACLBuckets(context.Background(), cfg, "*", 0, "json", &out))
+	var scan MultiBucketACLScan
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &scan))
+	assert.Equal(t, 10, scan.Scanned)
+	assert.Equal(t, 5, scan.Exposed)
+	if assert.Len(t, scan.Buckets, 5) {
+		for i, b := range scan.Buckets {
+			assert.Equal(t, buckets[i], b.Bucket)
+			assert.Equal(t, 2, b.Scanned)
+			assert.Equal(t, []ExposedObject{{Key: "public", Grants: []string{"http://acs.amazonaws.com/groups/global/AllUsers: READ"}}}, b.Exposed)
+		}
+	}
+	assert.Equal(t, 2, most)
+
+	out.Reset()
+	assert.NoError(t, ScanACLBuckets(context.Background(), cfg, "[ab]", 0, "", &out))
+	assert.Contains(t, out.String(), "s3://a/public\n")
+	assert.Contains(t, out.String(), "s3://b/public\n")
+	assert.NotContains(t, out.String(), "s3://c/")
+}
+
+func TestStatsBuckets(t *testing.T) {
+	fake := newFakeS3("a", "b", "c")
+	fake.put("a", "x", "12345")
+	fake.put("a", "dir/y", "1")
+	fake.put("b", "z", "123")
+	cfg, done := newFakeEndpoint(t, fake)
+	defer done()
+
+	var out bytes.Buffer
+	assert.NoError(t, StatsBuckets(context.Background(), cfg, "*", "json", &out))
+	var summaries MultiBucketSummary
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &summaries))
+	if assert.Len(t, summaries.Buckets, 3) {
+		assert.Equal(t, "a", summaries.Buckets[0].Bucket)
+		assert.Equal(t, int64(2), summaries.Buckets[0].Objects)
+		assert.Equal(t, int64(6), summaries.Buckets[0].Bytes)
+		assert.Equal(t, int64(0), summaries.Buckets[2].Objects)
+	}
+	assert.Equal(t, int64(3), summaries.Total.Objects)
+	assert.Equal(t, int64(9), summaries.Total.Bytes)
+	assert.Equal(t, int64(3), summaries.Total.AverageSize)
+	if assert.Len(t, summaries.Total.Largest, 3) {
+		assert.Equal(t, "x", summaries.Total.Largest[0].Key)
+	}
+
+	out.Reset()
+	assert.NoError(t, StatsBuckets(context.Background(), cfg, "b", "", &out))
+	assert.Contains(t, out.String(), "Total of 1 buckets")
+	assert.NotContains(t, out.String(), "\na ")
+}
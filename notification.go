@@ -0,0 +1,257 @@
+package s3ry
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// notificationEvents event types accepted by bucket notifications
+var notificationEvents = []string{
+	s3.EventS3ReducedRedundancyLostObject,
+	s3.EventS3ObjectCreated,
+	s3.EventS3ObjectCreatedPut,
+	s3.EventS3ObjectCreatedPost,
+	s3.EventS3ObjectCreatedCopy,
+	s3.EventS3ObjectCreatedCompleteMultipartUpload,
+	s3.EventS3ObjectRemoved,
+	s3.EventS3ObjectRemovedDelete,
+	s3.EventS3ObjectRemovedDeleteMarkerCreated,
+	s3.EventS3ObjectRestore,
+	s3.EventS3ObjectRestorePost,
+	s3.EventS3ObjectRestoreCompleted,
+	s3.EventS3Replication,
+	s3.EventS3ReplicationOperationFailedReplication,
+	s3.EventS3ReplicationOperationNotTracked,
+	s3.EventS3ReplicationOperationMissedThreshold,
+	s3.EventS3ReplicationOperationReplicatedAfterThreshold,
+}
+
+// NotificationRule event to target rule of a bucket notification
+type NotificationRule struct {
+	ID string
+	// Target ARN of an SQS queue, SNS topic or Lambda function
+	Target string
+	Events []string
+	// Prefix and Suffix filter object keys, empty matches every key
+	Prefix string
+	Suffix string
+}
+
+// targetService return service of ARN, one of sqs, sns and lambda
+func targetService(arn string) (string, error) {
+	// arn:partition:service:region:account-id:resource
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" || parts[1] == "" || parts[3] == "" || parts[5] == "" {
+		return "", fmt.Errorf("invalid ARN %q", arn)
+	}
+	if len(parts[4]) != 12 || strings.Trim(parts[4], "0123456789") != "" {
+		return "", fmt.Errorf("invalid account id in ARN %q", arn)
+	}
+	switch parts[2] {
+	case "sqs", "sns":
+	case "lambda":
+		if !strings.HasPrefix(parts[5], "function:") {
+			return "", fmt.Errorf("ARN %q is not a Lambda function", arn)
+		}
+	default:
+		return "", fmt.Errorf("ARN %q is not an SQS queue, SNS topic or Lambda function", arn)
+	}
+	return parts[2], nil
+}
+
+// Validate check target ARN and event types
+func (r NotificationRule) Validate() error {
+	if _, err := targetService(r.Target); err != nil {
+		return err
+	}
+	if len(r.Events) == 0 {
+		return fmt.Errorf("no event types")
+	}
+	for _, e := range r.Events {
+		known := false
+		for _, n := range notificationEvents {
+			if e == n {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown event type %q", e)
+		}
+	}
+	return nil
+}
+
+// filter return key filter of rule, nil when it matches every key
+func (r NotificationRule) filter() *s3.NotificationConfigurationFilter {
+	var rules []*s3.FilterRule
+	if r.Prefix != "" {
+		rules = append(rules, &s3.FilterRule{Name: aws.String(s3.FilterRuleNamePrefix), Value: aws.String(r.Prefix)})
+	}
+	if r.Suffix != "" {
+		rules = append(rules, &s3.FilterRule{Name: aws.String(s3.FilterRuleNameSuffix), Value: aws.String(r.Suffix)})
+	}
+	if rules == nil {
+		return nil
+	}
+	return &s3.NotificationConfigurationFilter{Key: &s3.KeyFilter{FilterRules: rules}}
+}
+
+// add append rule to the configuration of its target service
+func (r NotificationRule) add(c *s3.NotificationConfiguration) error {
+	service, err := targetService(r.Target)
+	if err != nil {
+		return err
+	}
+	var id *string
+	if r.ID != "" {
+		id = aws.String(r.ID)
+	}
+	events := aws.StringSlice(r.Events)
+	switch service {
+	case "sqs":
+		c.QueueConfigurations = append(c.QueueConfigurations, &s3.QueueConfiguration{Id: id, QueueArn: aws.String(r.Target), Events: events, Filter: r.filter()})
+	case "sns":
+		c.TopicConfigurations = append(c.TopicConfigurations, &s3.TopicConfiguration{Id: id, TopicArn: aws.String(r.Target), Events: events, Filter: r.filter()})
+	case "lambda":
+		c.LambdaFunctionConfigurations = append(c.LambdaFunctionConfigurations, &s3.LambdaFunctionConfiguration{Id: id, LambdaFunctionArn: aws.String(r.Target), Events: events, Filter: r.filter()})
+	}
+	return nil
+}
+
+// notificationRules flatten configuration into rules
+func notificationRules(c *s3.NotificationConfiguration) []NotificationRule {
+	var rules []NotificationRule
+	rule := func(id *string, target *string, events []*string, filter *s3.NotificationConfigurationFilter) {
+		r := NotificationRule{ID: aws.StringValue(id), Target: aws.StringValue(target), Events: aws.StringValueSlice(events)}
+		if filter != nil && filter.Key != nil {
+			for _, f := range filter.Key.FilterRules {
+				switch strings.ToLower(aws.StringValue(f.Name)) {
+				case s3.FilterRuleNamePrefix:
+					r.Prefix = aws.StringValue(f.Value)
+				case s3.FilterRuleNameSuffix:
+					r.Suffix = aws.StringValue(f.Value)
+				}
+			}
+		}
+		rules = append(rules, r)
+	}
+	for _, q := range c.QueueConfigurations {
+		rule(q.Id, q.QueueArn, q.Events, q.Filter)
+	}
+	for _, t := range c.TopicConfigurations {
+		rule(t.Id, t.TopicArn, t.Events, t.Filter)
+	}
+	for _, l := range c.LambdaFunctionConfigurations {
+		rule(l.Id, l.LambdaFunctionArn, l.Events, l.Filter)
+	}
+	return rules
+}
+
+// Notifications get event notification rules of bucket
+func (s S3ry) Notifications(ctx context.Context, bucket string) ([]NotificationRule, error) {
+	s, err := s.forBucket(bucket)
+	if err != nil {
+		return nil, err
+	}
+	out, err := s.Svc.GetBucketNotificationConfigurationWithContext(ctx, &s3.GetBucketNotificationConfigurationRequest{Bucket: aws.String(bucket)})
+	if err != nil {
+		return nil, err
+	}
+	return notificationRules(out), nil
+}
+
+// AddNotification add event notification rule to bucket keeping the existing rules
+func (s S3ry) AddNotification(ctx context.Context, bucket string, rule NotificationRule) (err error) {
+	done := s.track("notification", bucket, "")
+	defer func() { done(err) }()
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	if s, err = s.forBucket(bucket); err != nil {
+		return err
+	}
+	current, err := s.Svc.GetBucketNotificationConfigurationWithContext(ctx, &s3.GetBucketNotificationConfigurationRequest{Bucket: aws.String(bucket)})
+	if err != nil {
+		return err
+	}
+	if err := rule.add(current); err != nil {
+		return err
+	}
+	_, err = s.Svc.PutBucketNotificationConfigurationWithContext(ctx, &s3.PutBucketNotificationConfigurationInput{
+		Bucket:                    aws.String(bucket),
+		NotificationConfiguration: current,
+	})
+	return err
+}
+
+// AskNotificationRule guided input of a NotificationRule, asking again until it is valid
+func AskNotificationRule(in io.Reader, out io.Writer) (NotificationRule, error) {
+	scanner := bufio.NewScanner(in)
+	ended := false
+	ask := func(label string, def string) string {
+		if def != "" {
+			label += " [" + def + "]"
+		}
+		fmt.Fprint(out, label+": ")
+		if !scanner.Scan() {
+			ended = true
+			return def
+		}
+		if answer := strings.TrimSpace(scanner.Text()); answer != "" {
+			return answer
+		}
+		return def
+	}
+	for {
+		rule := NotificationRule{}
+		rule.Target = ask(i18nPrinter.Sprintf("Target ARN (SQS queue, SNS topic or Lambda function)"), "")
+		events := ask(i18nPrinter.Sprintf("Event types, comma separated"), s3.EventS3ObjectCreated)
+		for _, e := range strings.Split(events, ",") {
+			rule.Events = append(rule.Events, strings.TrimSpace(e))
+		}
+		rule.Prefix = ask(i18nPrinter.Sprintf("Key prefix filter (empty for every key)"), "")
+		rule.Suffix = ask(i18nPrinter.Sprintf("Key suffix filter (empty for every key)"), "")
+		if err := scanner.Err(); err != nil {
+			return rule, err
+		}
+		err := rule.Validate()
+		if err == nil {
+			return rule, nil
+		}
+		if ended {
+			return rule, err
+		}
+		fmt.Fprintln(out, err.Error())
+	}
+}
+
+// Notification list event notifications of bucket, or add one with the guided flow, used by the notifications command
+func Notification(ctx context.Context, cfg *Config, bucket string, add bool, in io.Reader, out io.Writer) error {
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	if add {
+		rule, err := AskNotificationRule(in, out)
+		if err != nil {
+			return err
+		}
+		if err := s.AddNotification(ctx, bucket, rule); err != nil {
+			return err
+		}
+		fmt.Fprintln(out, i18nPrinter.Sprintf("Added notification,% s", rule.Target))
+		return nil
+	}
+	rules, err := s.Notifications(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	for _, r := range rules {
+		fmt.Fprintf(out, "%s %s %s prefix=%q suffix=%q\n", r.ID, r.Target, strings.Join(r.Events, ","), r.Prefix, r.Suffix)
+	}
+	return nil
+}
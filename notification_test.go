@@ -0,0 +1,76 @@
+package s3ry
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testQueueARN = "arn:aws:sqs:ap-northeast-1:123456789012:s3ry-events"
+
+func TestAddNotificationRoundTrip(t *testing.T) {
+	fake := newFakeS3("bucket")
+	s, srv := newTestS3ry(DefaultConfig(), fake)
+	defer srv.Close()
+
+	rules, err := s.Notifications(context.Background(), "bucket")
+	assert.NoError(t, err)
+	assert.Empty(t, rules)
+
+	queue := NotificationRule{ID: "queue", Target: testQueueARN, Events: []string{"s3:ObjectCreated:*"}, Prefix: "logs/", Suffix: ".gz"}
+	lambda := NotificationRule{ID: "lambda", Target: "arn:aws:lambda:ap-northeast-1:123456789012:function:thumbnail", Events: []string{"s3:ObjectCreated:Put", "s3:ObjectRemoved:*"}}
+	topic := NotificationRule{ID: "topic", Target: "arn:aws:sns:ap-northeast-1:123456789012:s3ry", Events: []string{"s3:ObjectRestore:Completed"}}
+	for _, rule := range []NotificationRule{queue, lambda, topic} {
+		assert.NoError(t, s.AddNotification(context.Background(), "bucket", rule))
+	}
+	rules, err = s.Notifications(context.Background(), "bucket")
+	assert.NoError(t, err)
+	assert.Equal(t, []NotificationRule{queue, topic, lambda}, rules)
+}
+
+func TestNotificationRuleValidate(t *testing.T) {
+	valid := NotificationRule{Target: testQueueARN, Events: []string{"s3:ObjectCreated:*"}}
+	assert.NoError(t, valid.Validate())
+
+	tests := map[string]NotificationRule{
+		"not an ARN":       {Target: "s3ry-events", Events: valid.Events},
+		"bad account":      {Target: "arn:aws:sqs:ap-northeast-1:1234:s3ry-events", Events: valid.Events},
+		"no region":        {Target: "arn:aws:sqs::123456789012:s3ry-events", Events: valid.Events},
+		"unsupported":      {Target: "arn:aws:s3:ap-northeast-1:123456789012:bucket", Events: valid.Events},
+		"lambda alias":     {Target: "arn:aws:lambda:ap-northeast-1:123456789012:layer:x", Events: valid.Events},
+		"no events":        {Target: testQueueARN},
+		"unknown event":    {Target: testQueueARN, Events: []string{"s3:ObjectCreated:Rename"}},
+		"missing s3 scope": {Target: testQueueARN, Events: []string{"ObjectCreated:*"}},
+	}
+	for name, rule := range tests {
+		assert.Error(t, rule.Validate(), name)
+	}
+}
+
+func TestAddNotificationRejectsInvalidRule(t *testing.T) {
+	fake := newFakeS3("bucket")
+	s, srv := newTestS3ry(DefaultConfig(), fake)
+	defer srv.Close()
+
+	assert.Error(t, s.AddNotification(context.Background(), "bucket", NotificationRule{Target: "arn:aws:sqs:x", Events: []string{"s3:ObjectCreated:*"}}))
+	assert.Equal(t, 0, fake.count("PUT_NOTIFICATION"))
+}
+
+func TestAskNotificationRule(t *testing.T) {
+	// the first answer has an unknown event type and is asked again
+	in := strings.NewReader(strings.Join([]string{
+		testQueueARN, "s3:ObjectCreated:Rename", "", "",
+		testQueueARN, "", "images/", ".png",
+	}, "\n") + "\n")
+	var out bytes.Buffer
+	rule, err := AskNotificationRule(in, &out)
+	assert.NoError(t, err)
+	assert.Equal(t, NotificationRule{Target: testQueueARN, Events: []string{"s3:ObjectCreated:*"}, Prefix: "images/", Suffix: ".png"}, rule)
+	assert.Contains(t, out.String(), `unknown event type "s3:ObjectCreated:Rename"`)
+
+	_, err = AskNotificationRule(strings.NewReader("bad\n"), &out)
+	assert.Error(t, err)
+}
@@ -0,0 +1,145 @@
+package s3ry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// When commands are notified about
+const (
+	NotifyAlways  = "always"
+	NotifySuccess = "success"
+	NotifyFailure = "failure"
+)
+
+// webhookTimeout time limit of posting a notification, the command is already done
+const webhookTimeout = 10 * time.Second
+
+// NotifyConfig settings of notifications when a long command finishes
+type NotifyConfig struct {
+	// After notify commands running at least this long, 0 never notifies (default 0)
+	After Duration `min:"0s"`
+	// On always, success or failure (default always)
+	On string `enum:"always,success,failure"`
+	// Bell ring the terminal bell with the result on stderr (default true)
+	Bell bool
+	// Desktop show a desktop notification with notify-send or osascript (default false)
+	Desktop bool
+	// Webhook URL the result is posted to as JSON (default none)
+	Webhook string `json:",omitempty"`
+	// WebhookFormat json posts CommandNotice, slack a Slack incoming webhook message (default json)
+	WebhookFormat string `enum:"json,slack"`
+}
+
+// CommandNotice result of a finished command, posted to NotifyConfig.Webhook
+type CommandNotice struct {
+	Command  string
+	Result   string
+	Duration time.Duration
+	Error    string `json:",omitempty"`
+}
+
+// String format notice as one line
+func (n CommandNotice) String() string {
+	line := fmt.Sprintf("s3ry %s %s in %s", n.Command, n.Result, n.Duration.Round(time.Second))
+	if n.Error != "" {
+		line += ": " + n.Error
+	}
+	return line
+}
+
+// desktopCommands commands showing a desktop notification by GOOS, the first one found is used
+var desktopCommands = map[string]func(title string, message string) []string{
+	"darwin": func(title string, message string) []string {
+		return []string{"osascript", "-e", fmt.Sprintf("display notification %q with title %q", message, title)}
+	},
+	"linux": func(title string, message string) []string {
+		return []string{"notify-send", title, message}
+	},
+}
+
+// showDesktopNotification show message as a desktop notification, replaced in tests
+var showDesktopNotification = func(title string, message string) error {
+	command, ok := desktopCommands[runtime.GOOS]
+	if !ok {
+		return fmt.Errorf("no desktop notifications on %s", runtime.GOOS)
+	}
+	args := command(title, message)
+	if _, err := exec.LookPath(args[0]); err != nil {
+		return err
+	}
+	return exec.Command(args[0], args[1:]...).Run()
+}
+
+// notify send notice of a command which ran for notice.Duration to every configured channel, w is the terminal
+// notifications are best effort, a failed one is only reported on w
+func (c NotifyConfig) notify(notice CommandNotice, w io.Writer) {
+	if c.After <= 0 || notice.Duration < time.Duration(c.After) {
+		return
+	}
+	failed := notice.Error != ""
+	if (c.On == NotifySuccess && failed) || (c.On == NotifyFailure && !failed) {
+		return
+	}
+	if c.Bell {
+		fmt.Fprintln(w, "\a"+notice.String())
+	}
+	if c.Desktop {
+		if err := showDesktopNotification("s3ry", notice.String()); err != nil {
+			fmt.Fprintln(w, i18nPrinter.Sprintf("Desktop notification failed: %s", err.Error()))
+		}
+	}
+	if c.Webhook != "" {
+		if err := c.post(notice); err != nil {
+			fmt.Fprintln(w, i18nPrinter.Sprintf("Webhook notification failed: %s", err.Error()))
+		}
+	}
+}
+
+// post send notice to Webhook in WebhookFormat
+func (c NotifyConfig) post(notice CommandNotice) error {
+	var body interface{} = notice
+	if c.WebhookFormat == "slack" {
+		body = map[string]string{"text": notice.String()}
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: webhookTimeout}
+	resp, err := client.Post(c.Webhook, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
+
+// CommandNotifier return func notifying by Notify that command started now finished with err
+// only the first call notifies, so it may be both deferred and called before exiting
+func (c *Config) CommandNotifier(command string) func(err error) {
+	start := time.Now()
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() {
+			notice := CommandNotice{Command: command, Result: "finished", Duration: time.Since(start)}
+			if err != nil {
+				notice.Result = "failed"
+				notice.Error = err.Error()
+			}
+			// stdout may be the object of get -
+			c.Notify.notify(notice, os.Stderr)
+		})
+	}
+}
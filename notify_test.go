@@ -0,0 +1,99 @@
+package s3ry
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotifyLongCommand(t *testing.T) {
+	var posted []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		var body map[string]interface{}
+		json.Unmarshal(b, &body)
+		posted = append(posted, body)
+	}))
+	defer srv.Close()
+	var desktop []string
+	defer func(show func(string, string) error) { showDesktopNotification = show }(showDesktopNotification)
+	showDesktopNotification = func(title string, message string) error {
+		desktop = append(desktop, message)
+		return nil
+	}
+	c := DefaultConfig().Notify
+	c.After = Duration(time.Minute)
+	c.Desktop = true
+	c.Webhook = srv.URL
+
+	var out bytes.Buffer
+	c.notify(CommandNotice{Command: "cp", Result: "finished", Duration: 2 * time.Minute}, &out)
+	assert.Equal(t, "\as3ry cp finished in 2m0s\n", out.String())
+	assert.Equal(t, []string{"s3ry cp finished in 2m0s"}, desktop)
+	if assert.Len(t, posted, 1) {
+		assert.Equal(t, "cp", posted[0]["Command"])
+		assert.Equal(t, "finished", posted[0]["Result"])
+	}
+
+	// a short command isn't notified
+	out.Reset()
+	c.notify(CommandNotice{Command: "cp", Result: "finished", Duration: 59 * time.Second}, &out)
+	assert.Empty(t, out.String())
+	assert.Len(t, desktop, 1)
+	assert.Len(t, posted, 1)
+
+	c.WebhookFormat = "slack"
+	c.On = NotifyFailure
+	c.notify(CommandNotice{Command: "cp", Result: "finished", Duration: time.Hour}, &out)
+	assert.Len(t, posted, 1)
+	c.notify(CommandNotice{Command: "cp", Result: "failed", Duration: time.Hour, Error: "2 objects failed to copy"}, &out)
+	if assert.Len(t, posted, 2) {
+		assert.Equal(t, map[string]interface{}{"text": "s3ry cp failed in 1h0m0s: 2 objects failed to copy"}, posted[1])
+	}
+}
+
+func TestNotifyDisabledByDefault(t *testing.T) {
+	var out bytes.Buffer
+	DefaultConfig().Notify.notify(CommandNotice{Command: "cp", Result: "finished", Duration: 24 * time.Hour}, &out)
+	assert.Empty(t, out.String())
+}
+
+func TestNotifyFailures(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	defer func(show func(string, string) error) { showDesktopNotification = show }(showDesktopNotification)
+	showDesktopNotification = func(title string, message string) error {
+		return errors.New("no display")
+	}
+	c := NotifyConfig{After: Duration(time.Second), On: NotifyAlways, Desktop: true, Webhook: srv.URL}
+
+	var out bytes.Buffer
+	c.notify(CommandNotice{Command: "get", Result: "finished", Duration: time.Minute}, &out)
+	assert.Contains(t, out.String(), "Desktop notification failed: no display")
+	assert.Contains(t, out.String(), "Webhook notification failed: webhook responded 500")
+}
+
+func TestCommandNotifierNotifiesOnce(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer srv.Close()
+	cfg := DefaultConfig()
+	cfg.Notify = NotifyConfig{After: Duration(time.Nanosecond), On: NotifyAlways, Webhook: srv.URL}
+
+	finished := cfg.CommandNotifier("cp")
+	time.Sleep(time.Millisecond)
+	finished(errors.New("boom"))
+	finished(nil)
+	assert.Equal(t, 1, calls)
+}
@@ -0,0 +1,35 @@
+package s3ry
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/seike460/s3ry/internal/filter"
+)
+
+// ValidateFilter check expression parses as a Filter, so a command fails before touching any object
+func ValidateFilter(expression string) error {
+	_, err := filter.Parse(expression)
+	return err
+}
+
+// objectFilter parse Filter, nil when it is empty
+func (c *Config) objectFilter() (*filter.Expr, error) {
+	if c.Filter == "" {
+		return nil, nil
+	}
+	return filter.Parse(c.Filter)
+}
+
+// selected report whether object of a listing is selected by f, every object is selected by nil
+func selected(f *filter.Expr, object *s3.Object) bool {
+	if f == nil {
+		return true
+	}
+	return f.Match(filter.Object{
+		Key:          aws.StringValue(object.Key),
+		Size:         aws.Int64Value(object.Size),
+		LastModified: aws.TimeValue(object.LastModified),
+		StorageClass: aws.StringValue(object.StorageClass),
+		ETag:         aws.StringValue(object.ETag),
+	})
+}
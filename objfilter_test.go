@@ -0,0 +1,73 @@
+package s3ry
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seike460/s3ry/internal/filter"
+	"github.com/stretchr/testify/assert"
+)
+
+func newFilterFake() *fakeS3 {
+	fake := newFakeS3("src", "dst")
+	fake.put("src", "p/big-old", strings.Repeat("x", 2048))
+	fake.put("src", "p/big-new", strings.Repeat("x", 2048))
+	fake.put("src", "p/small-old", "x")
+	fake.put("src", "p/archived", strings.Repeat("x", 2048))
+	for _, key := range []string{"p/big-old", "p/small-old", "p/archived"} {
+		o, _ := fake.get("src", key)
+		o.lastModified = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	o, _ := fake.get("src", "p/archived")
+	o.header.Set("X-Amz-Storage-Class", "GLACIER")
+	return fake
+}
+
+func TestFilterListing(t *testing.T) {
+	fake := newFilterFake()
+	cfg := DefaultConfig()
+	cfg.Filter = `size > 1KB && last_modified < 2023-01-01 && storage_class == "STANDARD"`
+	s, srv := newTestS3ry(cfg, fake)
+	defer srv.Close()
+	ctx := context.Background()
+
+	page, err := s.ListObjectsPage(ctx, "src", ListOptions{})
+	assert.NoError(t, err)
+	if assert.Len(t, page.Objects, 1) {
+		assert.Equal(t, "p/big-old", page.Objects[0].Key)
+	}
+
+	cfg.Filter = `size > 1KB && storage_class != "GLACIER" || key =~ "small"`
+	summary, err := s.CopyPrefix(ctx, "src", "p/", "dst", "q/")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, summary.Copied)
+	assert.Equal(t, []string{"q/big-new", "q/big-old", "q/small-old"}, fake.keys("dst"))
+	cfg.Filter = `!(storage_class == "GLACIER") && last_modified < 2023-01-01`
+	tagged, err := s.TagPrefix(ctx, "src", "p/", TagChange{Set: map[string]string{"old": "yes"}}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []TaggedObject{
+		{Key: "p/big-old", Tags: map[string]string{"old": "yes"}},
+		{Key: "p/small-old", Tags: map[string]string{"old": "yes"}},
+	}, tagged.Tagged)
+}
+
+func TestFilterMalformed(t *testing.T) {
+	fake := newFilterFake()
+	cfg := DefaultConfig()
+	cfg.Filter = "size > 1KB &&"
+	s, srv := newTestS3ry(cfg, fake)
+	defer srv.Close()
+
+	var ferr *filter.Error
+	assert.True(t, errors.As(ValidateFilter(cfg.Filter), &ferr))
+	_, err := s.CopyPrefix(context.Background(), "src", "p/", "dst", "q/")
+	assert.True(t, errors.As(err, &ferr))
+	_, err = s.ListObjectsPage(context.Background(), "src", ListOptions{})
+	assert.True(t, errors.As(err, &ferr))
+	// nothing is listed with a malformed filter
+	assert.Equal(t, 0, fake.count("LIST"))
+	assert.Empty(t, fake.keys("dst"))
+}
@@ -0,0 +1,274 @@
+package s3ry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/seike460/s3ry/internal/events"
+	"github.com/seike460/s3ry/internal/worker"
+)
+
+// maxObjectTags tags an object can have
+const maxObjectTags = 10
+
+// ErrTooManyObjectTags change would leave an object with more tags than S3 allows
+var ErrTooManyObjectTags = fmt.Errorf("an object can have at most %d tags", maxObjectTags)
+
+// TagChange change of the tags of objects
+type TagChange struct {
+	// Set tags added or changed, or every tag of the objects when Replace is set
+	Set map[string]string
+	// Remove keys of tags removed
+	Remove []string
+	// Replace drop the current tags, leaving only Set
+	Replace bool
+}
+
+// ParseTagChange parse key=value pairs to set and keys to remove into TagChange, checking the tags
+func ParseTagChange(set []string, remove []string, replace bool) (TagChange, error) {
+	change := TagChange{Set: map[string]string{}, Remove: remove, Replace: replace}
+	for _, kv := range set {
+		i := strings.Index(kv, "=")
+		if i < 0 {
+			return change, fmt.Errorf("tag %q must be key=value", kv)
+		}
+		if err := checkTag(kv[:i], kv[i+1:]); err != nil {
+			return change, err
+		}
+		change.Set[kv[:i]] = kv[i+1:]
+	}
+	if len(change.Set) == 0 && len(change.Remove) == 0 && !change.Replace {
+		return change, errors.New("no tags to set or remove")
+	}
+	if len(change.Set) > maxObjectTags {
+		return change, fmt.Errorf("%w, %d are set", ErrTooManyObjectTags, len(change.Set))
+	}
+	return change, nil
+}
+
+// apply return tags changed by c
+func (c TagChange) apply(tags map[string]string) map[string]string {
+	changed := map[string]string{}
+	if !c.Replace {
+		for k, v := range tags {
+			changed[k] = v
+		}
+	}
+	for _, k := range c.Remove {
+		delete(changed, k)
+	}
+	for k, v := range c.Set {
+		changed[k] = v
+	}
+	return changed
+}
+
+// ObjectTags get tags of object
+func (s S3ry) ObjectTags(ctx context.Context, bucket string, key string) (map[string]string, error) {
+	tags := map[string]string{}
+	s, err := s.forBucket(bucket)
+	if err != nil {
+		return tags, err
+	}
+	out, err := s.Svc.GetObjectTaggingWithContext(ctx, &s3.GetObjectTaggingInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil {
+		return tags, err
+	}
+	for _, t := range out.TagSet {
+		tags[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+	}
+	return tags, nil
+}
+
+// TagObject apply change to the tags of object, returning its new tags and whether they changed
+// the tags are only computed when dryRun is set, and an object left with more than maxObjectTags is not changed
+func (s S3ry) TagObject(ctx context.Context, bucket string, key string, change TagChange, dryRun bool) (map[string]string, bool, error) {
+	current, err := s.ObjectTags(ctx, bucket, key)
+	if err != nil {
+		return nil, false, err
+	}
+	tags := change.apply(current)
+	if len(tags) > maxObjectTags {
+		return tags, false, fmt.Errorf("%w, it would have %d", ErrTooManyObjectTags, len(tags))
+	}
+	if reflect.DeepEqual(tags, current) || dryRun {
+		return tags, !reflect.DeepEqual(tags, current), nil
+	}
+	return tags, true, s.putObjectTags(ctx, bucket, key, tags)
+}
+
+// putObjectTags replace tags of object, removing them all when tags is empty
+func (s S3ry) putObjectTags(ctx context.Context, bucket string, key string, tags map[string]string) (err error) {
+	done := s.track("tag", bucket, key)
+	defer func() { done(err) }()
+	if s, err = s.forBucket(bucket); err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		_, err = s.Svc.DeleteObjectTaggingWithContext(ctx, &s3.DeleteObjectTaggingInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+		return err
+	}
+	tagging := &s3.Tagging{}
+	for _, k := range sortedKeys(tags) {
+		tagging.TagSet = append(tagging.TagSet, &s3.Tag{Key: aws.String(k), Value: aws.String(tags[k])})
+	}
+	_, err = s.Svc.PutObjectTaggingWithContext(ctx, &s3.PutObjectTaggingInput{Bucket: aws.String(bucket), Key: aws.String(key), Tagging: tagging})
+	return err
+}
+
+// TaggedObject object whose tags are changed by TagPrefix
+type TaggedObject struct {
+	Key  string
+	Tags map[string]string
+}
+
+// TagSummary result of TagPrefix
+type TagSummary struct {
+	// Tagged objects whose tags were changed, or would be by a dry run
+	Tagged []TaggedObject
+	// Unchanged objects already tagged as asked
+	Unchanged int
+	// OverLimit keys of objects which would have more than maxObjectTags, left unchanged
+	OverLimit []string
+	// Failed error of each key whose tags could not be changed
+	Failed map[string]error
+}
+
+// TagPrefix apply change to every object under prefix selected by Filter, Performance.Workers at once
+// dryRun only reports the objects which would change
+func (s S3ry) TagPrefix(ctx context.Context, bucket string, prefix string, change TagChange, dryRun bool) (TagSummary, error) {
+	summary := TagSummary{Failed: map[string]error{}}
+	s, err := s.forBucket(bucket)
+	if err != nil {
+		return summary, err
+	}
+	f, err := s.config().objectFilter()
+	if err != nil {
+		return summary, err
+	}
+
+	var mu sync.Mutex
+	pool := worker.New(ctx, s.config().Performance.Workers)
+	var submitErr error
+	err = s.Svc.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(bucket),
+		Prefix:  aws.String(prefix),
+		MaxKeys: aws.Int64(s.config().Performance.ListPageSize),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, object := range page.Contents {
+			if !selected(f, object) {
+				continue
+			}
+			key := aws.StringValue(object.Key)
+			submitErr = pool.Submit(func(ctx context.Context) {
+				tags, changed, err := s.TagObject(ctx, bucket, key, change, dryRun)
+				mu.Lock()
+				defer mu.Unlock()
+				switch {
+				case errors.Is(err, ErrTooManyObjectTags):
+					summary.OverLimit = append(summary.OverLimit, key)
+				case err != nil:
+					summary.Failed[key] = err
+				case changed:
+					summary.Tagged = append(summary.Tagged, TaggedObject{Key: key, Tags: tags})
+				default:
+					summary.Unchanged++
+				}
+			})
+			if submitErr != nil {
+				return false
+			}
+		}
+		return true
+	})
+	pool.Wait()
+	if submitErr == nil && err == nil {
+		err = s.retryFailed(ctx, summary.Failed, func(ctx context.Context, key string) error {
+			tags, changed, err := s.TagObject(ctx, bucket, key, change, dryRun)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if changed {
+				summary.Tagged = append(summary.Tagged, TaggedObject{Key: key, Tags: tags})
+			} else {
+				summary.Unchanged++
+			}
+			return nil
+		})
+	}
+	sort.Slice(summary.Tagged, func(a, b int) bool {
+		return summary.Tagged[a].Key < summary.Tagged[b].Key
+	})
+	sort.Strings(summary.OverLimit)
+	if submitErr != nil {
+		return summary, submitErr
+	}
+	return summary, err
+}
+
+// formatTags format tags as sorted key=value pairs separated by commas
+func formatTags(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for _, k := range sortedKeys(tags) {
+		pairs = append(pairs, k+"="+tags[k])
+	}
+	return strings.Join(pairs, ",")
+}
+
+// TagObjects change the tags of every object under s3:// URI target, used by the tag-objects command
+// dryRun prints the objects which would change and their new tags without changing them
+func TagObjects(ctx context.Context, cfg *Config, target string, change TagChange, dryRun bool, w io.Writer) error {
+	bucket, prefix, err := ParseS3URI(target)
+	if err != nil {
+		return err
+	}
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	s.Events = events.NewBus()
+	defer s.Events.Close()
+	stop := events.Aggregate(s.Events, "tag")
+	s.Events.Subscribe(spinnerSummary)
+	sps(i18nPrinter.Sprintf("Tagging objects ..."))
+	summary, err := s.TagPrefix(ctx, bucket, prefix, change, dryRun)
+	stop()
+	spe()
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		for _, o := range summary.Tagged {
+			fmt.Fprintf(w, "%s %s\n", o.Key, formatTags(o.Tags))
+		}
+	}
+	for _, key := range summary.OverLimit {
+		fmt.Fprintln(w, i18nPrinter.Sprintf("Over the limit of %d tags, unchanged,% s", maxObjectTags, key))
+	}
+	var failed []string
+	for key := range summary.Failed {
+		failed = append(failed, key)
+	}
+	sort.Strings(failed)
+	for _, key := range failed {
+		fmt.Fprintln(w, i18nPrinter.Sprintf("Failed,% s: %s", key, summary.Failed[key].Error()))
+	}
+	if dryRun {
+		fmt.Fprintln(w, i18nPrinter.Sprintf("Would tag: %d, unchanged: %d, over the limit: %d, failed: %d",
+			len(summary.Tagged), summary.Unchanged, len(summary.OverLimit), len(summary.Failed)))
+	} else {
+		fmt.Fprintln(w, i18nPrinter.Sprintf("Tagged: %d, unchanged: %d, over the limit: %d, failed: %d",
+			len(summary.Tagged), summary.Unchanged, len(summary.OverLimit), len(summary.Failed)))
+	}
+	if failures := len(summary.OverLimit) + len(summary.Failed); failures > 0 {
+		return &PartialError{Failed: failures, Op: "tag"}
+	}
+	return nil
+}
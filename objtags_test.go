@@ -0,0 +1,121 @@
+package s3ry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTagChangeApply(t *testing.T) {
+	current := map[string]string{"team": "storage", "env": "prod"}
+
+	add, err := ParseTagChange([]string{"cost-center=1234", "team=data"}, nil, false)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "data", "env": "prod", "cost-center": "1234"}, add.apply(current))
+
+	remove, err := ParseTagChange(nil, []string{"env", "missing"}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "storage"}, remove.apply(current))
+
+	replace, err := ParseTagChange([]string{"cost-center=1234"}, nil, true)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"cost-center": "1234"}, replace.apply(current))
+	// replacing with nothing removes every tag
+	none, err := ParseTagChange(nil, nil, true)
+	assert.NoError(t, err)
+	assert.Empty(t, none.apply(current))
+	assert.Equal(t, map[string]string{"team": "storage", "env": "prod"}, current)
+
+	_, err = ParseTagChange(nil, nil, false)
+	assert.Error(t, err)
+	_, err = ParseTagChange([]string{"team"}, nil, false)
+	assert.Error(t, err)
+	_, err = ParseTagChange([]string{"aws:team=x"}, nil, false)
+	assert.Error(t, err)
+	var eleven []string
+	for i := 0; i < 11; i++ {
+		eleven = append(eleven, fmt.Sprintf("k%d=v", i))
+	}
+	_, err = ParseTagChange(eleven, nil, false)
+	assert.True(t, errors.Is(err, ErrTooManyObjectTags))
+}
+
+func TestTagPrefix(t *testing.T) {
+	fake := newFakeS3("bucket")
+	fake.put("bucket", "logs/a", "a")
+	fake.put("bucket", "logs/b", "b")
+	fake.put("bucket", "logs/full", "f")
+	fake.put("bucket", "other", "o")
+	s, srv := newTestS3ry(DefaultConfig(), fake)
+	defer srv.Close()
+	ctx := context.Background()
+
+	var nine []string
+	for i := 0; i < 9; i++ {
+		nine = append(nine, fmt.Sprintf("k%d=v", i))
+	}
+	full, _ := ParseTagChange(append(nine, "team=storage"), nil, false)
+	_, _, err := s.TagObject(ctx, "bucket", "logs/full", full, false)
+	assert.NoError(t, err)
+	_, _, err = s.TagObject(ctx, "bucket", "logs/b", TagChange{Set: map[string]string{"team": "storage"}}, false)
+	assert.NoError(t, err)
+
+	change, _ := ParseTagChange([]string{"cost-center=1234"}, nil, false)
+	summary, err := s.TagPrefix(ctx, "bucket", "logs/", change, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []TaggedObject{
+		{Key: "logs/a", Tags: map[string]string{"cost-center": "1234"}},
+		{Key: "logs/b", Tags: map[string]string{"cost-center": "1234", "team": "storage"}},
+	}, summary.Tagged)
+	assert.Equal(t, []string{"logs/full"}, summary.OverLimit)
+	// a dry run changes nothing
+	assert.Equal(t, 2, fake.count("PUT_OBJECT_TAGGING"))
+
+	summary, err = s.TagPrefix(ctx, "bucket", "logs/", change, false)
+	assert.NoError(t, err)
+	assert.Len(t, summary.Tagged, 2)
+	assert.Equal(t, 4, fake.count("PUT_OBJECT_TAGGING"))
+	tags, err := s.ObjectTags(ctx, "bucket", "logs/b")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"cost-center": "1234", "team": "storage"}, tags)
+	tags, err = s.ObjectTags(ctx, "bucket", "other")
+	assert.NoError(t, err)
+	assert.Empty(t, tags)
+
+	// tagged objects are unchanged the second time
+	summary, err = s.TagPrefix(ctx, "bucket", "logs/", change, false)
+	assert.NoError(t, err)
+	assert.Empty(t, summary.Tagged)
+	assert.Equal(t, 2, summary.Unchanged)
+
+	remove, _ := ParseTagChange(nil, []string{"cost-center", "team"}, false)
+	summary, err = s.TagPrefix(ctx, "bucket", "logs/", remove, false)
+	assert.NoError(t, err)
+	assert.Len(t, summary.Tagged, 3)
+	assert.Equal(t, 2, fake.count("DELETE_OBJECT_TAGGING"))
+	tags, err = s.ObjectTags(ctx, "bucket", "logs/full")
+	assert.NoError(t, err)
+	assert.Len(t, tags, 9)
+}
+
+func TestTagObjectsCommand(t *testing.T) {
+	fake := newFakeS3("bucket")
+	fake.put("bucket", "logs/a", "a")
+	cfg, done := newFakeEndpoint(t, fake)
+	defer done()
+
+	change, _ := ParseTagChange([]string{"team=storage"}, nil, true)
+	var out bytes.Buffer
+	assert.NoError(t, TagObjects(context.Background(), cfg, "s3://bucket/logs/", change, true, &out))
+	assert.Contains(t, out.String(), "logs/a team=storage\n")
+	assert.Equal(t, 0, fake.count("PUT_OBJECT_TAGGING"))
+
+	out.Reset()
+	assert.NoError(t, TagObjects(context.Background(), cfg, "s3://bucket/logs/", change, false, &out))
+	assert.NotContains(t, out.String(), "logs/a team=storage")
+	assert.Equal(t, 1, fake.count("PUT_OBJECT_TAGGING"))
+}
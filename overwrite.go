@@ -0,0 +1,82 @@
+package s3ry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Policies for uploads overwriting an object of a bucket without versioning
+const (
+	OverwriteAllow  = "allow"
+	OverwriteBackup = "backup"
+	OverwriteRefuse = "refuse"
+	OverwritePrompt = "prompt"
+)
+
+// ErrObjectExists upload would overwrite an object and Upload.Overwrite refuses it
+var ErrObjectExists = errors.New("object exists and Upload.Overwrite is refuse")
+
+// backupKey return key of the backup of key taken at t, under prefix and the time so backups never collide
+func backupKey(prefix string, key string, t time.Time) string {
+	return prefix + t.UTC().Format("20060102T150405.000Z") + "/" + key
+}
+
+// bucketVersioned check versioning of bucket is enabled, so an overwritten object stays as a noncurrent version
+func (s S3ry) bucketVersioned(ctx context.Context, bucket string) (bool, error) {
+	out, err := s.Svc.GetBucketVersioningWithContext(ctx, &s3.GetBucketVersioningInput{Bucket: aws.String(bucket)})
+	if err != nil {
+		return false, err
+	}
+	return aws.StringValue(out.Status) == s3.BucketVersioningStatusEnabled, nil
+}
+
+// guardOverwrite apply Upload.Overwrite before uploading to key, the full key with the bucket Prefix,
+// asking with ask for prompt; s is a client of the bucket region
+// nothing is done when the object doesn't exist or versioning keeps the overwritten object anyway
+func (s S3ry) guardOverwrite(ctx context.Context, bucket string, key string, ask askFunc) error {
+	policy := s.config().Upload.Overwrite
+	if policy == "" || policy == OverwriteAllow {
+		return nil
+	}
+	head, err := s.Svc.HeadObjectWithContext(ctx, &s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if aerr, ok := err.(awserr.Error); ok && (aerr.Code() == "NotFound" || aerr.Code() == s3.ErrCodeNoSuchKey) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	versioned, err := s.bucketVersioned(ctx, bucket)
+	if err != nil || versioned {
+		return err
+	}
+	target := "s3://" + bucket + "/" + key
+	switch policy {
+	case OverwriteRefuse:
+		return fmt.Errorf("%w: %s", ErrObjectExists, target)
+	case OverwritePrompt:
+		if s.config().Security.AssumeYes {
+			return nil
+		}
+		yes, err := ask(i18nPrinter.Sprintf("The object exists and the bucket has no versioning, it can't be recovered. Overwrite? Object:% s, [Yy] / [Nn]", target))
+		if err != nil {
+			return err
+		}
+		if !yes {
+			return ErrCancelled
+		}
+		return nil
+	}
+	backup := backupKey(s.config().Upload.BackupPrefix, key, time.Now())
+	if err := s.CopyObject(ctx, bucket, key, bucket, backup, aws.Int64Value(head.ContentLength)); err != nil {
+		return fmt.Errorf("backup of %s before overwriting it: %w", target, err)
+	}
+	fmt.Fprintln(os.Stderr, i18nPrinter.Sprintf("Backed up before overwriting,% s", "s3://"+bucket+"/"+backup))
+	return nil
+}
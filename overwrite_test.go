@@ -0,0 +1,209 @@
+package s3ry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackupKey(t *testing.T) {
+	at := time.Date(2020, 8, 1, 12, 30, 0, 5e6, time.UTC)
+	assert.Equal(t, ".s3ry-backup/20200801T123000.005Z/dir/a.txt", backupKey(".s3ry-backup/", "dir/a.txt", at))
+}
+
+func TestGuardOverwriteBackup(t *testing.T) {
+	fake := newFakeS3("bucket", "versioned")
+	fake.versioned["versioned"] = true
+	fake.put("bucket", "a.txt", "old")
+	fake.put("versioned", "a.txt", "old")
+	cfg := DefaultConfig()
+	cfg.Upload.Overwrite = OverwriteBackup
+	s, srv := newTestS3ry(cfg, fake)
+	defer srv.Close()
+
+	_, err := s.PutStream(context.Background(), "bucket", "a.txt", bytes.NewBufferString("new"))
+	assert.NoError(t, err)
+	var backup string
+	for _, key := range fake.keys("bucket") {
+		if strings.HasPrefix(key, ".s3ry-backup/") && strings.HasSuffix(key, "/a.txt") {
+			backup = key
+		}
+	}
+	if assert.NotEmpty(t, backup) {
+		o, _ := fake.get("bucket", backup)
+		assert.Equal(t, "old", string(o.data))
+	}
+	o, _ := fake.get("bucket", "a.txt")
+	assert.Equal(t, "new", string(o.data))
+
+	// new objects and versioned buckets need no backup
+	_, err = s.PutStream(context.Background(), "bucket", "b.txt", bytes.NewBufferString("new"))
+	assert.NoError(t, err)
+	_, err = s.PutStream(context.Background(), "versioned", "a.txt", bytes.NewBufferString("new"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fake.count("COPY"))
+	assert.Len(t, fake.keys("versioned"), 1)
+}
+
+func TestGuardOverwriteRefuse(t *testing.T) {
+	fake := newFakeS3("bucket")
+	fake.put("bucket", "a.txt", "old")
+	cfg := DefaultConfig()
+	cfg.Upload.Overwrite = OverwriteRefuse
+	s, srv := newTestS3ry(cfg, fake)
+	defer srv.Close()
+
+	_, err := s.PutStream(context.Background(), "bucket", "a.txt", bytes.NewBufferString("new"))
+	assert.True(t, errors.Is(err, ErrObjectExists))
+	o, _ := fake.get("bucket", "a.txt")
+	assert.Equal(t, "old", string(o.data))
+
+	_, err = s.PutStream(context.Background(), "bucket", "b.txt", bytes.NewBufferString("new"))
+	assert.NoError(t, err)
+}
+
+func TestGuardOverwritePrompt(t *testing.T) {
+	fake := newFakeS3("bucket")
+	fake.put("bucket", "a.txt", "old")
+	cfg := DefaultConfig()
+	cfg.Upload.Overwrite = OverwritePrompt
+	s, srv := newTestS3ry(cfg, fake)
+	defer srv.Close()
+
+	defer func(c func(string) bool) { confirm = c }(confirm)
+	var asked []string
+	answer := false
+	confirm = func(message string) bool {
+		asked = append(asked, message)
+		return answer
+	}
+	_, err := s.PutStream(context.Background(), "bucket", "a.txt", bytes.NewBufferString("new"))
+	assert.Equal(t, ErrCancelled, err)
+	o, _ := fake.get("bucket", "a.txt")
+	assert.Equal(t, "old", string(o.data))
+
+	answer = true
+	_, err = s.PutStream(context.Background(), "bucket", "a.txt", bytes.NewBufferString("new"))
+	assert.NoError(t, err)
+	o, _ = fake.get("bucket", "a.txt")
+	assert.Equal(t, "new", string(o.data))
+	if assert.Len(t, asked, 2) {
+		assert.Contains(t, asked[0], "s3://bucket/a.txt")
+	}
+
+	cfg.Security.AssumeYes = true
+	_, err = s.PutStream(context.Background(), "bucket", "a.txt", bytes.NewBufferString("newer"))
+	assert.NoError(t, err)
+	assert.Len(t, asked, 2)
+}
+
+func TestGuardOverwritePromptOneAtATime(t *testing.T) {
+	fake := newFakeS3("bucket")
+	files := map[string]string{}
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		files[name] = "new"
+		fake.put("bucket", "up/"+name, "old")
+	}
+	cfg, done := newFakeEndpoint(t, fake)
+	defer done()
+	cfg.Upload.Overwrite = OverwritePrompt
+	cfg.Performance.Workers = 4
+	cfg.Performance.BatchRetries = 2
+	cfg.Performance.BatchRetryBackoff = Duration(time.Millisecond)
+	dir := newManifestDir(t, files)
+	defer os.RemoveAll(dir)
+
+	defer func(c func(string) bool) { confirm = c }(confirm)
+	var asking, overlapped, asked int32
+	confirm = func(message string) bool {
+		if atomic.AddInt32(&asking, 1) > 1 {
+			atomic.StoreInt32(&overlapped, 1)
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&asking, -1)
+		atomic.AddInt32(&asked, 1)
+		return false
+	}
+	err := PutDirectory(context.Background(), cfg, dir, "s3://bucket/up/")
+	assert.Equal(t, ExitPartial, ExitCode(err))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&overlapped))
+	// a declined object is final, the retries don't ask again
+	assert.Equal(t, int32(8), atomic.LoadInt32(&asked))
+	o, _ := fake.get("bucket", "up/a")
+	assert.Equal(t, "old", string(o.data))
+}
+
+func TestGuardOverwriteBucketPrefix(t *testing.T) {
+	fake := newFakeS3("bucket")
+	fake.put("bucket", "incoming/a.txt", "old")
+	cfg := DefaultConfig()
+	cfg.Upload.Overwrite = OverwriteRefuse
+	cfg.Buckets = map[string]BucketConfig{"bucket": {Prefix: "incoming/"}}
+	s, srv := newTestS3ry(cfg, fake)
+	defer srv.Close()
+
+	// the object checked is the one the upload writes, under the prefix
+	_, err := s.PutStream(context.Background(), "bucket", "a.txt", bytes.NewBufferString("new"))
+	assert.True(t, errors.Is(err, ErrObjectExists))
+	o, _ := fake.get("bucket", "incoming/a.txt")
+	assert.Equal(t, "old", string(o.data))
+}
+
+func TestGuardOverwritePromptStdin(t *testing.T) {
+	fake := newFakeS3("bucket")
+	fake.put("bucket", "a.txt", "old")
+	cfg := DefaultConfig()
+	cfg.Upload.Overwrite = OverwritePrompt
+	s, srv := newTestS3ry(cfg, fake)
+	defer srv.Close()
+
+	defer func(c func(string) bool) { confirm = c }(confirm)
+	confirm = func(string) bool {
+		t.Error("asked on stdin, which is the body")
+		return false
+	}
+	defer func(a askFunc) { askTerminal = a }(askTerminal)
+	var asked []string
+	askTerminal = func(message string) (bool, error) {
+		asked = append(asked, message)
+		return true, nil
+	}
+	defer func(f *os.File) { os.Stdin = f }(os.Stdin)
+	put := func(body string) error {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		go func() {
+			w.Write([]byte(body))
+			w.Close()
+		}()
+		os.Stdin = r
+		_, err = s.PutStream(context.Background(), "bucket", "a.txt", os.Stdin)
+		return err
+	}
+
+	assert.NoError(t, put("new"))
+	assert.Len(t, asked, 1)
+	o, _ := fake.get("bucket", "a.txt")
+	assert.Equal(t, "new", string(o.data))
+
+	// without a terminal it fails rather than reading the answer from the body
+	askTerminal = func(string) (bool, error) { return false, errNoTerminal }
+	assert.Equal(t, errNoTerminal, put("newer"))
+	o, _ = fake.get("bucket", "a.txt")
+	assert.Equal(t, "new", string(o.data))
+
+	cfg.Security.AssumeYes = true
+	assert.NoError(t, put("newer"))
+	o, _ = fake.get("bucket", "a.txt")
+	assert.Equal(t, "newer", string(o.data))
+}
@@ -0,0 +1,191 @@
+package s3ry
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/private/checksum"
+	"github.com/aws/aws-sdk-go/private/protocol"
+	"github.com/aws/aws-sdk-go/private/protocol/restxml"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Object ownership of a bucket, who owns the objects written to it
+const (
+	// OwnershipBucketOwnerEnforced ACLs are disabled and the bucket owner owns every object, recommended by AWS
+	OwnershipBucketOwnerEnforced = "BucketOwnerEnforced"
+	// OwnershipBucketOwnerPreferred the bucket owner owns objects written with the bucket-owner-full-control ACL
+	OwnershipBucketOwnerPreferred = "BucketOwnerPreferred"
+	// OwnershipObjectWriter the writer owns the object, the default of buckets without ownership controls
+	OwnershipObjectWriter = "ObjectWriter"
+)
+
+// The SDK version s3ry uses predates the ownership controls API, so its operations are defined here
+// they are sent path-style, which S3 accepts for every bucket
+
+// ownershipControlsRule rule of the ownership controls of a bucket
+type ownershipControlsRule struct {
+	_               struct{} `type:"structure"`
+	ObjectOwnership *string  `type:"string" required:"true"`
+}
+
+// ownershipControls ownership controls of a bucket
+type ownershipControls struct {
+	_     struct{}                 `type:"structure"`
+	Rules []*ownershipControlsRule `locationName:"Rule" type:"list" flattened:"true" required:"true"`
+}
+
+// getOwnershipControlsInput input of GetBucketOwnershipControls
+type getOwnershipControlsInput struct {
+	_      struct{} `type:"structure"`
+	Bucket *string  `location:"uri" locationName:"Bucket" type:"string" required:"true"`
+}
+
+// getOwnershipControlsOutput output of GetBucketOwnershipControls
+type getOwnershipControlsOutput struct {
+	_                 struct{}           `type:"structure" payload:"OwnershipControls"`
+	OwnershipControls *ownershipControls `type:"structure"`
+}
+
+// putOwnershipControlsInput input of PutBucketOwnershipControls
+type putOwnershipControlsInput struct {
+	_                 struct{}           `type:"structure" payload:"OwnershipControls"`
+	Bucket            *string            `location:"uri" locationName:"Bucket" type:"string" required:"true"`
+	OwnershipControls *ownershipControls `locationName:"OwnershipControls" type:"structure" required:"true" xmlURI:"http://s3.amazonaws.com/doc/2006-03-01/"`
+}
+
+// BucketOwnership get object ownership of bucket, OwnershipObjectWriter when it has no ownership controls
+func (s S3ry) BucketOwnership(ctx context.Context, bucket string) (string, error) {
+	s, err := s.forBucket(bucket)
+	if err != nil {
+		return "", err
+	}
+	out := &getOwnershipControlsOutput{}
+	req := s.Svc.NewRequest(&request.Operation{
+		Name:       "GetBucketOwnershipControls",
+		HTTPMethod: "GET",
+		HTTPPath:   "/{Bucket}?ownershipControls",
+	}, &getOwnershipControlsInput{Bucket: aws.String(bucket)}, out)
+	req.SetContext(ctx)
+	err = req.Send()
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "OwnershipControlsNotFoundError" {
+		return OwnershipObjectWriter, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if out.OwnershipControls == nil || len(out.OwnershipControls.Rules) == 0 {
+		return OwnershipObjectWriter, nil
+	}
+	return aws.StringValue(out.OwnershipControls.Rules[0].ObjectOwnership), nil
+}
+
+// PutBucketOwnership set object ownership of bucket
+func (s S3ry) PutBucketOwnership(ctx context.Context, bucket string, ownership string) (err error) {
+	done := s.track("ownership", bucket, "")
+	defer func() { done(err) }()
+	switch ownership {
+	case OwnershipBucketOwnerEnforced, OwnershipBucketOwnerPreferred, OwnershipObjectWriter:
+	default:
+		return fmt.Errorf("unknown object ownership %q", ownership)
+	}
+	if s, err = s.forBucket(bucket); err != nil {
+		return err
+	}
+	req := s.Svc.NewRequest(&request.Operation{
+		Name:       "PutBucketOwnershipControls",
+		HTTPMethod: "PUT",
+		HTTPPath:   "/{Bucket}?ownershipControls",
+	}, &putOwnershipControlsInput{
+		Bucket:            aws.String(bucket),
+		OwnershipControls: &ownershipControls{Rules: []*ownershipControlsRule{{ObjectOwnership: aws.String(ownership)}}},
+	}, nil)
+	req.Handlers.Unmarshal.Swap(restxml.UnmarshalHandler.Name, protocol.UnmarshalDiscardBodyHandler)
+	req.Handlers.Build.PushBackNamed(request.NamedHandler{Name: "contentMd5Handler", Fn: checksum.AddBodyContentMD5Handler})
+	req.SetContext(ctx)
+	return req.Send()
+}
+
+// BucketsNotOwnerEnforced return buckets whose ACLs are still enabled, every bucket when none are given
+func (s S3ry) BucketsNotOwnerEnforced(ctx context.Context, buckets []string) ([]string, error) {
+	if len(buckets) == 0 {
+		out, err := s.Svc.ListBucketsWithContext(ctx, &s3.ListBucketsInput{})
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range out.Buckets {
+			buckets = append(buckets, aws.StringValue(b.Name))
+		}
+	}
+	var enabled []string
+	for _, bucket := range buckets {
+		ownership, err := s.BucketOwnership(ctx, bucket)
+		if err != nil {
+			return enabled, fmt.Errorf("%s: %w", bucket, err)
+		}
+		if ownership != OwnershipBucketOwnerEnforced {
+			enabled = append(enabled, bucket)
+		}
+	}
+	return enabled, nil
+}
+
+// confirmEnforce ask before disabling the ACLs of bucket, naming the grants that stop applying
+func (s S3ry) confirmEnforce(ctx context.Context, bucket string) error {
+	if s.config().Security.AssumeYes {
+		return nil
+	}
+	acl, err := s.Svc.GetBucketAclWithContext(ctx, &s3.GetBucketAclInput{Bucket: aws.String(bucket)})
+	if err != nil {
+		return err
+	}
+	others := 0
+	for _, g := range acl.Grants {
+		if g.Grantee == nil || acl.Owner == nil || aws.StringValue(g.Grantee.ID) != aws.StringValue(acl.Owner.ID) {
+			others++
+		}
+	}
+	if !confirm(i18nPrinter.Sprintf("WARNING: BucketOwnerEnforced disables ACLs, %d grants of the bucket to others and every object ACL stop applying, "+
+		"and writes with ACLs other than bucket-owner-full-control fail. Apply? Bucket:% s, [Yy] / [Nn]", others, bucket)) {
+		return ErrCancelled
+	}
+	return nil
+}
+
+// Ownership show object ownership of buckets, or disable their ACLs, used by the ownership command
+// enforce applies BucketOwnerEnforced after asking, report prints the buckets whose ACLs are enabled instead,
+// every bucket when none are given
+func Ownership(ctx context.Context, cfg *Config, buckets []string, enforce bool, report bool, w io.Writer) error {
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	if report {
+		enabled, err := s.BucketsNotOwnerEnforced(ctx, buckets)
+		for _, bucket := range enabled {
+			fmt.Fprintln(w, bucket)
+		}
+		return err
+	}
+	for _, bucket := range buckets {
+		if enforce {
+			b, err := s.forBucket(bucket)
+			if err != nil {
+				return err
+			}
+			if err := b.confirmEnforce(ctx, bucket); err != nil {
+				return err
+			}
+			if err := s.PutBucketOwnership(ctx, bucket, OwnershipBucketOwnerEnforced); err != nil {
+				return err
+			}
+		}
+		ownership, err := s.BucketOwnership(ctx, bucket)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s %s\n", bucket, ownership)
+	}
+	return nil
+}
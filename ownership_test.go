@@ -0,0 +1,67 @@
+package s3ry
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBucketOwnershipRoundTrip(t *testing.T) {
+	fake := newFakeS3("bucket")
+	s, srv := newTestS3ry(DefaultConfig(), fake)
+	defer srv.Close()
+	ctx := context.Background()
+
+	// a bucket without ownership controls
+	ownership, err := s.BucketOwnership(ctx, "bucket")
+	assert.NoError(t, err)
+	assert.Equal(t, OwnershipObjectWriter, ownership)
+
+	assert.NoError(t, s.PutBucketOwnership(ctx, "bucket", OwnershipBucketOwnerEnforced))
+	assert.Contains(t, string(fake.ownership["bucket"]), "<Rule><ObjectOwnership>BucketOwnerEnforced</ObjectOwnership></Rule>")
+	ownership, err = s.BucketOwnership(ctx, "bucket")
+	assert.NoError(t, err)
+	assert.Equal(t, OwnershipBucketOwnerEnforced, ownership)
+
+	assert.Error(t, s.PutBucketOwnership(ctx, "bucket", "Everyone"))
+	_, err = s.BucketOwnership(ctx, "missing")
+	assert.Error(t, err)
+}
+
+func TestOwnershipCommand(t *testing.T) {
+	fake := newFakeS3("private", "public")
+	cfg, done := newFakeEndpoint(t, fake)
+	defer done()
+	ctx := context.Background()
+	fake.acls["public"] = "public-read"
+
+	var out bytes.Buffer
+	assert.NoError(t, Ownership(ctx, cfg, nil, false, true, &out))
+	assert.Equal(t, "private\npublic\n", out.String())
+
+	// enforcing warns about the grants that stop applying
+	defer func(c func(string) bool) { confirm = c }(confirm)
+	var asked string
+	confirm = func(message string) bool {
+		asked = message
+		return false
+	}
+	out.Reset()
+	assert.Equal(t, ErrCancelled, Ownership(ctx, cfg, []string{"public"}, true, false, &out))
+	assert.Contains(t, asked, "1 grants")
+	assert.Equal(t, 0, fake.count("PUT_OWNERSHIP"))
+
+	confirm = func(message string) bool { return true }
+	assert.NoError(t, Ownership(ctx, cfg, []string{"public"}, true, false, &out))
+	assert.Equal(t, "public BucketOwnerEnforced\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, Ownership(ctx, cfg, nil, false, true, &out))
+	assert.Equal(t, "private\n", out.String())
+
+	// read-only mode rejects it
+	cfg.Security.ReadOnly = true
+	assert.Error(t, Ownership(ctx, cfg, []string{"private"}, true, false, &out))
+}
@@ -0,0 +1,271 @@
+package s3ry
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/seike460/s3ry/internal/worker"
+)
+
+// Part sizes tried by Performance.AdaptivePartSize, from the S3 minimum up to a size whose concurrently
+// uploaded parts still fit in memory
+const (
+	minAdaptivePartSize = s3manager.MinUploadPartSize
+	maxAdaptivePartSize = 256 * 1024 * 1024
+)
+
+// tunerSamples parts of a size measured before partTuner compares it with its neighbours
+const tunerSamples = 3
+
+// tunerTolerance how much faster a neighbouring size must be to move to it, so noise doesn't move the size back and forth
+const tunerTolerance = 0.05
+
+// tunerChange how much slower than its best the current size gets before the network is taken as changed,
+// dropping the throughput of the other sizes so they are measured again
+const tunerChange = 0.25
+
+// partRate throughput of the recent parts of one size
+type partRate struct {
+	n int
+	// rate bytes per second, averaged over the last tunerSamples parts
+	rate float64
+	// best highest rate
+	best float64
+}
+
+// partTuner choose the part size of an adaptive upload from the throughput of the parts uploaded so far
+//
+// It climbs between sizes twice apart: once tunerSamples parts of the current
+// size are measured, it moves to a neighbouring size measured faster, or tries a
+// neighbour not measured yet, the larger one first as it needs fewer requests.
+// With both neighbours slower it stays, measuring on, and once the current size
+// gets much slower than it was every size is measured again from there.
+type partTuner struct {
+	mu    sync.Mutex
+	size  int64
+	min   int64
+	max   int64
+	rates map[int64]*partRate
+}
+
+// newPartTuner create partTuner starting at size, kept between min and max
+func newPartTuner(size int64, min int64, max int64) *partTuner {
+	return &partTuner{size: size, min: min, max: max, rates: map[int64]*partRate{}}
+}
+
+// next return the size of the next part
+func (t *partTuner) next() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.size
+}
+
+// observe record that a part of size took d to upload
+func (t *partTuner) observe(size int64, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if d <= 0 {
+		d = time.Nanosecond
+	}
+	r := t.rates[size]
+	if r == nil {
+		r = &partRate{}
+		t.rates[size] = r
+	}
+	rate := float64(size) / d.Seconds()
+	r.n++
+	window := r.n
+	if window > tunerSamples {
+		window = tunerSamples
+	}
+	r.rate += (rate - r.rate) / float64(window)
+	if r.rate > r.best {
+		r.best = r.rate
+	}
+	if size != t.size {
+		return
+	}
+	if r.rate < r.best*(1-tunerChange) {
+		t.rates = map[int64]*partRate{size: {n: 1, rate: rate, best: rate}}
+		return
+	}
+	if r.n >= tunerSamples {
+		t.decide()
+	}
+}
+
+// neighbours return the larger and the smaller size next to the current one, within min and max
+func (t *partTuner) neighbours() []int64 {
+	var sizes []int64
+	if up := t.size * 2; t.size < t.max {
+		if up > t.max {
+			up = t.max
+		}
+		sizes = append(sizes, up)
+	}
+	if down := t.size / 2; t.size > t.min {
+		if down < t.min {
+			down = t.min
+		}
+		sizes = append(sizes, down)
+	}
+	return sizes
+}
+
+// decide move to the fastest measured neighbour, or to one not measured yet
+func (t *partTuner) decide() {
+	best, bestRate := t.size, t.rates[t.size].rate*(1+tunerTolerance)
+	for _, size := range t.neighbours() {
+		if r := t.rates[size]; r != nil && r.n >= tunerSamples && r.rate > bestRate {
+			best, bestRate = size, r.rate
+		}
+	}
+	if best != t.size {
+		t.size = best
+		return
+	}
+	for _, size := range t.neighbours() {
+		if t.rates[size] == nil {
+			t.size = size
+			return
+		}
+	}
+}
+
+// readPart read up to size bytes of r, fewer only at its end
+func readPart(r io.Reader, size int64) ([]byte, error) {
+	buf := make([]byte, size)
+	n, err := io.ReadFull(r, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	return buf[:n], err
+}
+
+// upload upload input with the s3manager uploader, or in parts sized by a partTuner with Performance.AdaptivePartSize,
+// recording the part size it settled on in the history
+func (s S3ry) upload(ctx context.Context, operation string, input *s3manager.UploadInput) error {
+	bucket, key := aws.StringValue(input.Bucket), aws.StringValue(input.Key)
+	if !s.config().Performance.AdaptivePartSize {
+		_, err := s.uploader(operation, bucket, key).UploadWithContext(ctx, input)
+		return err
+	}
+	size, err := s.uploadAdaptive(ctx, operation, input)
+	s.history.partSize(operation, bucket, key, size)
+	return err
+}
+
+// uploadAdaptive upload input in parts sized by a partTuner, s3manager.DefaultUploadConcurrency at once,
+// returning the part size the tuner settled on
+// a body shorter than the first part is uploaded by the s3manager uploader in one request, returning 0
+func (s S3ry) uploadAdaptive(ctx context.Context, operation string, input *s3manager.UploadInput) (int64, error) {
+	bucket, key := aws.StringValue(input.Bucket), aws.StringValue(input.Key)
+	tuner := newPartTuner(minAdaptivePartSize, minAdaptivePartSize, maxAdaptivePartSize)
+	want := tuner.next()
+	part, err := readPart(input.Body, want)
+	if err != nil {
+		return 0, err
+	}
+	if int64(len(part)) < want {
+		input.Body = bytes.NewReader(part)
+		_, err := s.uploader(operation, bucket, key).UploadWithContext(ctx, input)
+		return 0, err
+	}
+	create := &s3.CreateMultipartUploadInput{}
+	awsutil.Copy(create, input)
+	upload, err := s.Svc.CreateMultipartUploadWithContext(ctx, create)
+	if err != nil {
+		return 0, err
+	}
+
+	client := s.partClient(operation, bucket, key)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var mu sync.Mutex
+	var parts []*s3.CompletedPart
+	var uploadErr error
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if uploadErr == nil {
+			uploadErr = err
+		}
+		cancel()
+	}
+	pool := worker.New(ctx, s3manager.DefaultUploadConcurrency)
+	for number := int64(1); ; number++ {
+		if number > s3manager.MaxUploadParts {
+			fail(fmt.Errorf("upload of %s exceeds %d parts", key, s3manager.MaxUploadParts))
+			break
+		}
+		body, number := part, number
+		err := pool.Submit(func(ctx context.Context) {
+			start := time.Now()
+			out, err := client.UploadPartWithContext(ctx, &s3.UploadPartInput{
+				Bucket:               input.Bucket,
+				Key:                  input.Key,
+				UploadId:             upload.UploadId,
+				PartNumber:           aws.Int64(number),
+				Body:                 bytes.NewReader(body),
+				SSECustomerAlgorithm: input.SSECustomerAlgorithm,
+				SSECustomerKey:       input.SSECustomerKey,
+				SSECustomerKeyMD5:    input.SSECustomerKeyMD5,
+				RequestPayer:         input.RequestPayer,
+			})
+			if err != nil {
+				fail(err)
+				return
+			}
+			tuner.observe(int64(len(body)), time.Since(start))
+			mu.Lock()
+			parts = append(parts, &s3.CompletedPart{ETag: out.ETag, PartNumber: aws.Int64(number)})
+			mu.Unlock()
+		})
+		if err != nil {
+			fail(err)
+			break
+		}
+		if int64(len(part)) < want {
+			break
+		}
+		want = tuner.next()
+		if part, err = readPart(input.Body, want); err != nil {
+			fail(err)
+			break
+		}
+		if len(part) == 0 {
+			break
+		}
+	}
+	pool.Wait()
+	if uploadErr == nil {
+		sort.Slice(parts, func(a, b int) bool {
+			return aws.Int64Value(parts[a].PartNumber) < aws.Int64Value(parts[b].PartNumber)
+		})
+		_, uploadErr = s.Svc.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket:          input.Bucket,
+			Key:             input.Key,
+			UploadId:        upload.UploadId,
+			MultipartUpload: &s3.CompletedMultipartUpload{Parts: parts},
+			RequestPayer:    input.RequestPayer,
+		})
+	}
+	if uploadErr != nil {
+		// the upload may have been aborted by ctx, so abort without it
+		s.Svc.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+			Bucket:   input.Bucket,
+			Key:      input.Key,
+			UploadId: upload.UploadId,
+		})
+	}
+	return tuner.next(), uploadErr
+}
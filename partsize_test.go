@@ -0,0 +1,106 @@
+package s3ry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seike460/s3ry/internal/history"
+	"github.com/stretchr/testify/assert"
+)
+
+// simulatedNetwork time to upload a part of size: a fixed latency per request, then bandwidth bytes per second,
+// which drops to slowBandwidth for parts larger than slowAbove and further the larger they are,
+// like a proxy buffering large requests
+type simulatedNetwork struct {
+	latency       time.Duration
+	bandwidth     float64
+	slowAbove     int64
+	slowBandwidth float64
+}
+
+func (n simulatedNetwork) upload(size int64) time.Duration {
+	bandwidth := n.bandwidth
+	if n.slowAbove > 0 && size > n.slowAbove {
+		bandwidth = n.slowBandwidth * float64(n.slowAbove) / float64(size)
+	}
+	return n.latency + time.Duration(float64(size)/bandwidth*float64(time.Second))
+}
+
+// tune run a tuner starting at start for parts uploaded over network, returning the size it ends at
+func tune(start int64, network simulatedNetwork, parts int) int64 {
+	tuner := newPartTuner(start, minAdaptivePartSize, maxAdaptivePartSize)
+	for i := 0; i < parts; i++ {
+		size := tuner.next()
+		tuner.observe(size, network.upload(size))
+	}
+	return tuner.next()
+}
+
+func TestPartTunerConverges(t *testing.T) {
+	mib := int64(1024 * 1024)
+	// 20MiB parts are the fastest, smaller ones spend their time on latency and larger ones are slowed down
+	peak := simulatedNetwork{latency: 50 * time.Millisecond, bandwidth: 100e6, slowAbove: 20 * mib, slowBandwidth: 20e6}
+	assert.Equal(t, 20*mib, tune(minAdaptivePartSize, peak, 100))
+	assert.Equal(t, 20*mib, tune(80*mib, peak, 100))
+
+	// with a high latency every larger part is faster, up to the largest
+	assert.Equal(t, int64(maxAdaptivePartSize), tune(minAdaptivePartSize, simulatedNetwork{latency: time.Second, bandwidth: 100e6}, 100))
+
+	// the network slowing down large parts later moves the tuner back
+	tuner := newPartTuner(minAdaptivePartSize, minAdaptivePartSize, maxAdaptivePartSize)
+	fast := simulatedNetwork{latency: time.Second, bandwidth: 100e6}
+	for i := 0; i < 100; i++ {
+		size := tuner.next()
+		tuner.observe(size, fast.upload(size))
+	}
+	slow := simulatedNetwork{latency: time.Second, bandwidth: 100e6, slowAbove: 40 * mib, slowBandwidth: 1e6}
+	for i := 0; i < 100; i++ {
+		size := tuner.next()
+		tuner.observe(size, slow.upload(size))
+	}
+	assert.Equal(t, 32*mib, tuner.next())
+}
+
+func TestAdaptivePartSizeUpload(t *testing.T) {
+	fake := newFakeS3("bucket")
+	cfg, done := newFakeEndpoint(t, fake)
+	defer done()
+	cfg.Performance.AdaptivePartSize = true
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	ctx := context.Background()
+
+	data := make([]byte, 3*minAdaptivePartSize+11)
+	rand.New(rand.NewSource(1)).Read(data)
+	n, err := s.PutStream(ctx, "bucket", "large.bin", bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(data)), n)
+	assert.Equal(t, 4, fake.count("UPLOAD_PART"))
+	o, _ := fake.get("bucket", "large.bin")
+	assert.True(t, bytes.Equal(data, o.data))
+
+	// a body shorter than a part is sent in one request
+	_, err = s.PutStream(ctx, "bucket", "small.txt", strings.NewReader("small"))
+	assert.NoError(t, err)
+	o, _ = fake.get("bucket", "small.txt")
+	assert.Equal(t, "small", string(o.data))
+	assert.Equal(t, 4, fake.count("UPLOAD_PART"))
+
+	var out bytes.Buffer
+	assert.NoError(t, History(cfg, "", "", "upload", "", "", "json", &out))
+	var entries []history.Entry
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var e history.Entry
+		assert.NoError(t, json.Unmarshal([]byte(line), &e))
+		entries = append(entries, e)
+	}
+	if assert.Len(t, entries, 2) {
+		// the parts finishing before the last one is read may already have moved the size
+		assert.True(t, entries[0].PartSize >= minAdaptivePartSize)
+		assert.Equal(t, int64(0), entries[1].PartSize)
+	}
+}
@@ -0,0 +1,327 @@
+package s3ry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/seike460/s3ry/internal/events"
+	"github.com/seike460/s3ry/internal/worker"
+)
+
+// PlanVersion version of the plan format written by cp --plan, apply rejects other versions
+const PlanVersion = 1
+
+// Actions of a plan
+const (
+	// PlanCopy copy the object to DestinationKey
+	PlanCopy = "copy"
+)
+
+// Reasons of plan actions
+const (
+	// ReasonNew the destination object doesn't exist
+	ReasonNew = "new"
+	// ReasonOverwrite the destination object exists and is replaced
+	ReasonOverwrite = "overwrite"
+)
+
+// ErrStalePlan objects changed since the plan was made, so it is rejected as a whole
+var ErrStalePlan = errors.New("plan is stale")
+
+// PlanAction one change of a plan and the state it was planned against
+type PlanAction struct {
+	Action string
+	Reason string
+	// Key key of the source object
+	Key            string
+	DestinationKey string
+	Size           int64
+	// ETag of the source object when planned
+	ETag string
+	// DestinationETag ETag of the destination object when planned, empty when it didn't exist
+	DestinationETag string `json:",omitempty"`
+}
+
+// Plan changes of a command reviewed before apply runs them, like cp --recursive --plan
+type Plan struct {
+	Version int
+	Created time.Time
+	// Command planned command, e.g. cp
+	Command string
+	// Source and Destination s3:// URIs of the prefixes
+	Source      string
+	Destination string
+	// Filter expression selecting the source objects, empty for every object
+	Filter  string `json:",omitempty"`
+	Actions []PlanAction
+}
+
+// listObjects return the objects under prefix by key
+func (s S3ry) listObjects(ctx context.Context, bucket string, prefix string) (map[string]*s3.Object, error) {
+	objects := map[string]*s3.Object{}
+	s, err := s.forBucket(bucket)
+	if err != nil {
+		return nil, err
+	}
+	err = s.Svc.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(bucket),
+		Prefix:  aws.String(prefix),
+		MaxKeys: aws.Int64(s.config().Performance.ListPageSize),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, object := range page.Contents {
+			objects[aws.StringValue(object.Key)] = object
+		}
+		return true
+	})
+	return objects, err
+}
+
+// CopyPlan plan copying every object under srcPrefix selected by Filter to dstPrefix like CopyPrefix, without copying
+func (s S3ry) CopyPlan(ctx context.Context, srcBucket string, srcPrefix string, dstBucket string, dstPrefix string) (*Plan, error) {
+	f, err := s.config().objectFilter()
+	if err != nil {
+		return nil, err
+	}
+	plan := &Plan{
+		Version:     PlanVersion,
+		Created:     time.Now().UTC(),
+		Command:     "cp",
+		Source:      "s3://" + srcBucket + "/" + srcPrefix,
+		Destination: "s3://" + dstBucket + "/" + dstPrefix,
+		Filter:      s.config().Filter,
+	}
+	sources, err := s.listObjects(ctx, srcBucket, srcPrefix)
+	if err != nil {
+		return nil, err
+	}
+	destinations, err := s.listObjects(ctx, dstBucket, dstPrefix)
+	if err != nil {
+		return nil, err
+	}
+	for key, object := range sources {
+		if !selected(f, object) {
+			continue
+		}
+		action := PlanAction{
+			Action:         PlanCopy,
+			Reason:         ReasonNew,
+			Key:            key,
+			DestinationKey: destinationKey(key, srcPrefix, dstPrefix),
+			Size:           aws.Int64Value(object.Size),
+			ETag:           aws.StringValue(object.ETag),
+		}
+		if dst, ok := destinations[action.DestinationKey]; ok {
+			action.Reason = ReasonOverwrite
+			action.DestinationETag = aws.StringValue(dst.ETag)
+		}
+		plan.Actions = append(plan.Actions, action)
+	}
+	sort.Slice(plan.Actions, func(a, b int) bool {
+		return plan.Actions[a].Key < plan.Actions[b].Key
+	})
+	return plan, nil
+}
+
+// StaleActions return why each action of plan no longer matches the objects, empty when it can be applied as planned
+func (s S3ry) StaleActions(ctx context.Context, plan *Plan) ([]string, error) {
+	srcBucket, srcPrefix, err := ParseS3URI(plan.Source)
+	if err != nil {
+		return nil, err
+	}
+	dstBucket, dstPrefix, err := ParseS3URI(plan.Destination)
+	if err != nil {
+		return nil, err
+	}
+	sources, err := s.listObjects(ctx, srcBucket, srcPrefix)
+	if err != nil {
+		return nil, err
+	}
+	destinations, err := s.listObjects(ctx, dstBucket, dstPrefix)
+	if err != nil {
+		return nil, err
+	}
+	var stale []string
+	for _, action := range plan.Actions {
+		src, ok := sources[action.Key]
+		switch {
+		case !ok:
+			stale = append(stale, i18nPrinter.Sprintf("%s was deleted", action.Key))
+		case aws.StringValue(src.ETag) != action.ETag:
+			stale = append(stale, i18nPrinter.Sprintf("%s was changed", action.Key))
+		}
+		dst, ok := destinations[action.DestinationKey]
+		switch {
+		case !ok && action.DestinationETag != "":
+			stale = append(stale, i18nPrinter.Sprintf("destination %s was deleted", action.DestinationKey))
+		case ok && action.DestinationETag == "":
+			stale = append(stale, i18nPrinter.Sprintf("destination %s was created", action.DestinationKey))
+		case ok && aws.StringValue(dst.ETag) != action.DestinationETag:
+			stale = append(stale, i18nPrinter.Sprintf("destination %s was changed", action.DestinationKey))
+		}
+	}
+	return stale, nil
+}
+
+// ApplyPlan run the actions of plan, Performance.Workers at once, once StaleActions finds every object as planned
+// and every key is under the prefixes of the plan
+// objects which failed are copied again by Performance.BatchRetries once the others are done
+func (s S3ry) ApplyPlan(ctx context.Context, plan *Plan) (CopySummary, error) {
+	summary := CopySummary{Failed: map[string]error{}}
+	if plan.Version != PlanVersion {
+		return summary, fmt.Errorf("unsupported plan version %d, expected %d", plan.Version, PlanVersion)
+	}
+	if plan.Command != "cp" {
+		return summary, fmt.Errorf("unsupported plan command %q", plan.Command)
+	}
+	srcBucket, srcPrefix, err := ParseS3URI(plan.Source)
+	if err != nil {
+		return summary, err
+	}
+	dstBucket, dstPrefix, err := ParseS3URI(plan.Destination)
+	if err != nil {
+		return summary, err
+	}
+	// StaleActions only sees the objects under the prefixes, so a plan edited to reach further is rejected
+	for _, action := range plan.Actions {
+		if action.Action != PlanCopy {
+			return summary, fmt.Errorf("unsupported plan action %q", action.Action)
+		}
+		if !strings.HasPrefix(action.Key, srcPrefix) {
+			return summary, fmt.Errorf("invalid plan: %s is not under %s", action.Key, plan.Source)
+		}
+		if !strings.HasPrefix(action.DestinationKey, dstPrefix) {
+			return summary, fmt.Errorf("invalid plan: destination %s is not under %s", action.DestinationKey, plan.Destination)
+		}
+	}
+	stale, err := s.StaleActions(ctx, plan)
+	if err != nil {
+		return summary, err
+	}
+	if len(stale) > 0 {
+		return summary, fmt.Errorf("%w, plan again: %s", ErrStalePlan, strings.Join(stale, ", "))
+	}
+
+	byKey := map[string]PlanAction{}
+	var mu sync.Mutex
+	pool := worker.New(ctx, s.config().Performance.Workers)
+	var submitErr error
+	for _, action := range plan.Actions {
+		action := action
+		byKey[action.Key] = action
+		submitErr = pool.Submit(func(ctx context.Context) {
+			err := s.CopyObject(ctx, srcBucket, action.Key, dstBucket, action.DestinationKey, action.Size)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				summary.Failed[action.Key] = err
+				return
+			}
+			summary.Copied++
+			summary.Bytes += action.Size
+		})
+		if submitErr != nil {
+			break
+		}
+	}
+	pool.Wait()
+	if submitErr != nil {
+		return summary, submitErr
+	}
+	err = s.retryFailed(ctx, summary.Failed, func(ctx context.Context, key string) error {
+		action := byKey[key]
+		if err := s.CopyObject(ctx, srcBucket, action.Key, dstBucket, action.DestinationKey, action.Size); err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		summary.Copied++
+		summary.Bytes += action.Size
+		return nil
+	})
+	return summary, err
+}
+
+// PlanCopyPrefix write the plan of cp --recursive from s3:// URI src to dst as JSON, used by cp --plan
+func PlanCopyPrefix(ctx context.Context, cfg *Config, src string, dst string, w io.Writer) error {
+	srcBucket, srcPrefix, err := ParseS3URI(src)
+	if err != nil {
+		return err
+	}
+	dstBucket, dstPrefix, err := ParseS3URI(dst)
+	if err != nil {
+		return err
+	}
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	plan, err := s.CopyPlan(ctx, srcBucket, srcPrefix, dstBucket, dstPrefix)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, string(b))
+	return nil
+}
+
+// Apply run the plan in file path, or stdin when it is "-", written by cp --plan, used by the apply command
+// a plan whose objects changed since it was made is rejected before anything runs,
+// and a plan read from stdin is confirmed on the terminal
+func Apply(ctx context.Context, cfg *Config, path string, w io.Writer) error {
+	var b []byte
+	var err error
+	ask := askStdin
+	if path == stdio {
+		b, err = ioutil.ReadAll(os.Stdin)
+		// stdin was the plan, so ask on the terminal
+		ask = cfg.askFor(os.Stdin)
+	} else {
+		b, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return err
+	}
+	plan := &Plan{}
+	if err := json.Unmarshal(b, plan); err != nil {
+		return fmt.Errorf("invalid plan %s: %w", path, err)
+	}
+	if err := cfg.confirmDestructiveWith(true, i18nPrinter.Sprintf("Apply %d actions of the plan from% s to% s? [Yy] / [Nn]", len(plan.Actions), plan.Source, plan.Destination), ask); err != nil {
+		return err
+	}
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	s.Events = events.NewBus()
+	defer s.Events.Close()
+	stop := events.Aggregate(s.Events, "copy")
+	s.Events.Subscribe(spinnerSummary)
+	sps(i18nPrinter.Sprintf("Applying plan ..."))
+	summary, err := s.ApplyPlan(ctx, plan)
+	stop()
+	spe()
+	var failed []string
+	for key := range summary.Failed {
+		failed = append(failed, key)
+	}
+	sort.Strings(failed)
+	for _, key := range failed {
+		fmt.Fprintln(w, i18nPrinter.Sprintf("Failed,% s: %s", key, summary.Failed[key].Error()))
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, i18nPrinter.Sprintf("Copied: %s, size: %s, failed: %d", locale.Number(int64(summary.Copied)), locale.Bytes(summary.Bytes), len(summary.Failed)))
+	if len(summary.Failed) > 0 {
+		return &PartialError{Failed: len(summary.Failed), Op: "apply"}
+	}
+	return nil
+}
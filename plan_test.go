@@ -0,0 +1,138 @@
+package s3ry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writePlan write the plan of copying src to dst to a file, returning its path
+func writePlan(t *testing.T, cfg *Config, src string, dst string) string {
+	var out bytes.Buffer
+	assert.NoError(t, PlanCopyPrefix(context.Background(), cfg, src, dst, &out))
+	path := filepath.Join(cfg.Performance.TempDir, "plan.json")
+	assert.NoError(t, ioutil.WriteFile(path, out.Bytes(), 0644))
+	return path
+}
+
+func TestPlanCopyAndApply(t *testing.T) {
+	fake := newFakeS3("src", "dst")
+	fake.put("src", "data/a", "a")
+	fake.put("src", "data/b", "new b")
+	fake.put("dst", "copy/b", "old b")
+	cfg, done := newFakeEndpoint(t, fake)
+	defer done()
+	ctx := context.Background()
+	defer func(c func(string) bool) { confirm = c }(confirm)
+	confirm = func(string) bool { return true }
+
+	path := writePlan(t, cfg, "s3://src/data/", "s3://dst/copy/")
+	b, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	plan := &Plan{}
+	assert.NoError(t, json.Unmarshal(b, plan))
+	assert.Equal(t, PlanVersion, plan.Version)
+	if assert.Len(t, plan.Actions, 2) {
+		assert.Equal(t, PlanAction{Action: PlanCopy, Reason: ReasonNew, Key: "data/a", DestinationKey: "copy/a", Size: 1, ETag: plan.Actions[0].ETag}, plan.Actions[0])
+		assert.Equal(t, ReasonOverwrite, plan.Actions[1].Reason)
+		assert.NotEmpty(t, plan.Actions[1].DestinationETag)
+	}
+	// planning copies nothing
+	assert.Equal(t, 0, fake.count("COPY"))
+
+	var out bytes.Buffer
+	assert.NoError(t, Apply(ctx, cfg, path, &out))
+	assert.Contains(t, out.String(), "Copied: 2")
+	assert.Equal(t, []string{"copy/a", "copy/b"}, fake.keys("dst"))
+	o, _ := fake.get("dst", "copy/b")
+	assert.Equal(t, "new b", string(o.data))
+}
+
+func TestApplyRejectsStalePlan(t *testing.T) {
+	fake := newFakeS3("src", "dst")
+	fake.put("src", "data/a", "a")
+	fake.put("src", "data/b", "b")
+	cfg, done := newFakeEndpoint(t, fake)
+	defer done()
+	ctx := context.Background()
+	defer func(c func(string) bool) { confirm = c }(confirm)
+	confirm = func(string) bool { return true }
+
+	path := writePlan(t, cfg, "s3://src/data/", "s3://dst/copy/")
+	fake.put("src", "data/a", "changed")
+	fake.put("dst", "copy/b", "created")
+	err := Apply(ctx, cfg, path, ioutil.Discard)
+	assert.True(t, errors.Is(err, ErrStalePlan))
+	assert.Equal(t, ExitDiffer, ExitCode(err))
+	assert.Contains(t, err.Error(), "data/a was changed")
+	assert.Contains(t, err.Error(), "destination copy/b was created")
+	// nothing of a stale plan runs
+	assert.Equal(t, 0, fake.count("COPY"))
+	assert.Equal(t, []string{"copy/b"}, fake.keys("dst"))
+
+	// a new plan sees the changes
+	path = writePlan(t, cfg, "s3://src/data/", "s3://dst/copy/")
+	assert.NoError(t, Apply(ctx, cfg, path, ioutil.Discard))
+	o, _ := fake.get("dst", "copy/a")
+	assert.Equal(t, "changed", string(o.data))
+}
+
+func TestApplyRejectsKeysOutsidePlan(t *testing.T) {
+	fake := newFakeS3("src", "dst")
+	fake.put("src", "data/a", "a")
+	cfg, done := newFakeEndpoint(t, fake)
+	defer done()
+	cfg.Security.AssumeYes = true
+
+	path := writePlan(t, cfg, "s3://src/data/", "s3://dst/copy/")
+	b, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	plan := &Plan{}
+	assert.NoError(t, json.Unmarshal(b, plan))
+	plan.Actions[0].DestinationKey = "elsewhere/a"
+	b, err = json.Marshal(plan)
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(path, b, 0644))
+
+	err = Apply(context.Background(), cfg, path, ioutil.Discard)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "elsewhere/a is not under s3://dst/copy/")
+	}
+	assert.Equal(t, 0, fake.count("COPY"))
+}
+
+func TestApplyPlanFromStdin(t *testing.T) {
+	fake := newFakeS3("src", "dst")
+	fake.put("src", "data/a", "a")
+	cfg, done := newFakeEndpoint(t, fake)
+	defer done()
+	defer func(c func(string) bool) { confirm = c }(confirm)
+	confirm = func(string) bool {
+		t.Error("asked on stdin, which was the plan")
+		return false
+	}
+	defer func(a askFunc) { askTerminal = a }(askTerminal)
+	var asked []string
+	askTerminal = func(message string) (bool, error) {
+		asked = append(asked, message)
+		return true, nil
+	}
+
+	path := writePlan(t, cfg, "s3://src/data/", "s3://dst/copy/")
+	defer func(f *os.File) { os.Stdin = f }(os.Stdin)
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	defer f.Close()
+	os.Stdin = f
+
+	assert.NoError(t, Apply(context.Background(), cfg, "-", ioutil.Discard))
+	assert.Len(t, asked, 1)
+	assert.Equal(t, []string{"copy/a"}, fake.keys("dst"))
+}
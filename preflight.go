@@ -0,0 +1,116 @@
+package s3ry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Permissions checked by Preflight
+const (
+	PermListBucket   = "s3:ListBucket"
+	PermGetObject    = "s3:GetObject"
+	PermPutObject    = "s3:PutObject"
+	PermDeleteObject = "s3:DeleteObject"
+)
+
+// preflightProbeKey name of the empty object Preflight writes and deletes under the prefix to check PutObject
+const preflightProbeKey = ".s3ry-preflight"
+
+// PermissionError permissions found missing by Preflight
+type PermissionError struct {
+	Bucket string
+	Prefix string
+	// Missing actions in the order they were checked
+	Missing []string
+}
+
+// Error format PermissionError
+func (e *PermissionError) Error() string {
+	return fmt.Sprintf("missing permissions on s3://%s/%s: %s", e.Bucket, e.Prefix, strings.Join(e.Missing, ", "))
+}
+
+// accessDenied check err is a request S3 denied, HEAD responses have no body so their code is the status text
+func accessDenied(err error) bool {
+	var aerr awserr.Error
+	return errors.As(err, &aerr) && (aerr.Code() == "AccessDenied" || aerr.Code() == "Forbidden")
+}
+
+// Preflight check the caller may do actions on the objects under prefix of bucket before a bulk operation starts,
+// returning the missing ones as *PermissionError.
+// it lists one object, reads the head of it, writes an empty probe object and deletes it, so what it checks is
+// what S3 grants with every identity and bucket policy; an action not checkable without objects, like GetObject
+// under an empty prefix, passes
+func (s S3ry) Preflight(ctx context.Context, bucket string, prefix string, actions ...string) error {
+	s, err := s.forBucket(bucket)
+	if err != nil {
+		return err
+	}
+	wanted := map[string]bool{}
+	for _, action := range actions {
+		wanted[action] = true
+	}
+	var missing []string
+	if wanted[PermListBucket] || wanted[PermGetObject] {
+		out, err := s.Svc.ListObjectsV2WithContext(ctx, &s3.ListObjectsV2Input{
+			Bucket:  aws.String(bucket),
+			Prefix:  aws.String(prefix),
+			MaxKeys: aws.Int64(1),
+		})
+		switch {
+		case accessDenied(err):
+			if wanted[PermListBucket] {
+				missing = append(missing, PermListBucket)
+			}
+		case err != nil:
+			return err
+		case wanted[PermGetObject] && len(out.Contents) > 0:
+			_, err := s.Svc.HeadObjectWithContext(ctx, &s3.HeadObjectInput{Bucket: aws.String(bucket), Key: out.Contents[0].Key})
+			if accessDenied(err) {
+				missing = append(missing, PermGetObject)
+			} else if err != nil {
+				return err
+			}
+		}
+	}
+	probe := aws.String(prefix + preflightProbeKey)
+	written := false
+	if wanted[PermPutObject] {
+		_, err := s.Svc.PutObjectWithContext(ctx, &s3.PutObjectInput{Bucket: aws.String(bucket), Key: probe, Body: strings.NewReader("")})
+		if accessDenied(err) {
+			missing = append(missing, PermPutObject)
+		} else if err != nil {
+			return err
+		}
+		written = err == nil
+	}
+	// deleting a missing key succeeds, so DeleteObject is checked without writing the probe first
+	if wanted[PermDeleteObject] || written {
+		_, err := s.Svc.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{Bucket: aws.String(bucket), Key: probe})
+		switch {
+		case accessDenied(err) && wanted[PermDeleteObject]:
+			missing = append(missing, PermDeleteObject)
+		case err != nil && !accessDenied(err):
+			return err
+		}
+	}
+	if len(missing) > 0 {
+		return &PermissionError{Bucket: bucket, Prefix: prefix, Missing: missing}
+	}
+	return nil
+}
+
+// preflight run Preflight when Security.Preflight is set
+func (s S3ry) preflight(ctx context.Context, bucket string, prefix string, actions ...string) error {
+	if !s.config().Security.Preflight {
+		return nil
+	}
+	sps(i18nPrinter.Sprintf("Checking permissions ..."))
+	defer spe()
+	return s.Preflight(ctx, bucket, prefix, actions...)
+}
@@ -0,0 +1,80 @@
+package s3ry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// denyFake hook of fakeS3 denying requests with method to keys of bucket starting with prefix
+func denyFake(bucket string, prefix string, methods ...string) func(w http.ResponseWriter, r *http.Request) bool {
+	return func(w http.ResponseWriter, r *http.Request) bool {
+		for _, method := range methods {
+			if r.Method == method && strings.HasPrefix(r.URL.Path, "/"+bucket+"/"+prefix) && r.URL.Query().Get("list-type") == "" {
+				writeFakeError(w, http.StatusForbidden, "AccessDenied")
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	fake := newFakeS3("bucket")
+	fake.put("bucket", "data/a", "a")
+	cfg, done := newFakeEndpoint(t, fake)
+	defer done()
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	ctx := context.Background()
+	all := []string{PermListBucket, PermGetObject, PermPutObject, PermDeleteObject}
+
+	assert.NoError(t, s.Preflight(ctx, "bucket", "data/", all...))
+	assert.Equal(t, []string{"data/a"}, fake.keys("bucket"))
+	assert.Equal(t, 1, fake.count("PUT"))
+
+	fake.hook = denyFake("bucket", "data/", http.MethodHead, http.MethodPut, http.MethodDelete)
+	err := s.Preflight(ctx, "bucket", "data/", all...)
+	var permission *PermissionError
+	if assert.True(t, errors.As(err, &permission)) {
+		assert.Equal(t, []string{PermGetObject, PermPutObject, PermDeleteObject}, permission.Missing)
+	}
+	assert.Equal(t, ExitAuth, ExitCode(err))
+
+	// only the asked actions are checked
+	assert.NoError(t, s.Preflight(ctx, "bucket", "data/", PermListBucket))
+}
+
+func TestPreflightBeforeCopy(t *testing.T) {
+	fake := newFakeS3("src", "dst")
+	fake.put("src", "data/a", "a")
+	fake.put("src", "data/b", "b")
+	cfg, done := newFakeEndpoint(t, fake)
+	defer done()
+	cfg.Security.Preflight = true
+	ctx := context.Background()
+	defer func(c func(string) bool) { confirm = c }(confirm)
+	asked := false
+	confirm = func(string) bool {
+		asked = true
+		return true
+	}
+
+	fake.hook = denyFake("dst", "copy/", http.MethodPut)
+	err := Copy(ctx, cfg, "s3://src/data/", "s3://dst/copy/", true, false)
+	var permission *PermissionError
+	if assert.True(t, errors.As(err, &permission)) {
+		assert.Equal(t, "dst", permission.Bucket)
+		assert.Equal(t, []string{PermPutObject}, permission.Missing)
+	}
+	assert.False(t, asked)
+	assert.Equal(t, 0, fake.count("COPY"))
+
+	fake.hook = nil
+	assert.NoError(t, Copy(ctx, cfg, "s3://src/data/", "s3://dst/copy/", true, false))
+	assert.True(t, asked)
+	assert.Equal(t, []string{"copy/a", "copy/b"}, fake.keys("dst"))
+}
@@ -0,0 +1,131 @@
+package s3ry
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/briandowns/spinner"
+)
+
+// Progress styles
+const (
+	// ProgressBar redraw progress in place with a spinner, for terminals
+	ProgressBar = "bar"
+	// ProgressPlain print a progress line every RefreshInterval, for logs and CI
+	ProgressPlain = "plain"
+	// ProgressNone print no progress, only the final summary
+	ProgressNone = "none"
+)
+
+// ProgressConfig settings of progress output
+type ProgressConfig struct {
+	// Style one of bar, plain and none (default bar), the --progress and --quiet flags override it
+	Style string `enum:"bar,plain,none"`
+	// RefreshInterval time between progress updates (default 100ms)
+	RefreshInterval Duration `min:"10ms"`
+}
+
+// progressReporter render the progress of the running operation in a style
+type progressReporter struct {
+	mu       sync.Mutex
+	style    string
+	interval time.Duration
+	out      io.Writer
+	spinner  *spinner.Spinner
+	// now current time, replaced in tests
+	now     func() time.Time
+	label   string
+	printed time.Time
+}
+
+// newProgressReporter create progressReporter writing to out
+func newProgressReporter(cfg ProgressConfig, out io.Writer) *progressReporter {
+	interval := time.Duration(cfg.RefreshInterval)
+	if interval <= 0 {
+		interval = 100 * time.Millisecond
+	}
+	style := cfg.Style
+	if style == "" {
+		style = ProgressBar
+	}
+	sp := spinner.New(spinner.CharSets[34], interval)
+	sp.Writer = out
+	return &progressReporter{style: style, interval: interval, out: out, spinner: sp, now: time.Now}
+}
+
+// reporter progress output of the process
+var reporter = newProgressReporter(ProgressConfig{}, os.Stdout)
+
+// SetProgress set how progress is shown, from the Progress config and flags
+func SetProgress(cfg ProgressConfig) error {
+	switch cfg.Style {
+	case "", ProgressBar, ProgressPlain, ProgressNone:
+	default:
+		return fmt.Errorf("unknown progress style %q, use bar, plain or none", cfg.Style)
+	}
+	reporter = newProgressReporter(cfg, os.Stdout)
+	return nil
+}
+
+// start show label and start showing progress
+func (p *progressReporter) start(label string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.label = label
+	p.printed = time.Time{}
+	if p.style == ProgressNone {
+		return
+	}
+	fmt.Fprintln(p.out, label)
+	if p.style == ProgressBar {
+		p.spinner.Lock()
+		p.spinner.Suffix = ""
+		p.spinner.Unlock()
+		p.spinner.Start()
+	}
+}
+
+// update show suffix as the progress, plain lines are printed at most every interval
+func (p *progressReporter) update(suffix string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch p.style {
+	case ProgressBar:
+		// the spinner redraws every interval
+		p.spinner.Lock()
+		p.spinner.Suffix = suffix
+		p.spinner.Unlock()
+	case ProgressPlain:
+		now := p.now()
+		if now.Sub(p.printed) < p.interval {
+			return
+		}
+		p.printed = now
+		fmt.Fprintln(p.out, p.label+suffix)
+	}
+}
+
+// println print a line between progress updates
+func (p *progressReporter) println(line string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.style == ProgressBar {
+		p.spinner.Lock()
+		defer p.spinner.Unlock()
+		fmt.Fprintln(p.out, "\r"+line)
+		return
+	}
+	fmt.Fprintln(p.out, line)
+}
+
+// stop stop showing progress
+func (p *progressReporter) stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.style == ProgressBar {
+		p.spinner.Stop()
+	}
+}
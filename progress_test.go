@@ -0,0 +1,74 @@
+package s3ry
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seike460/s3ry/internal/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgressNonePrintsNothing(t *testing.T) {
+	out := &bytes.Buffer{}
+	p := newProgressReporter(ProgressConfig{Style: ProgressNone}, out)
+	p.start("Copying objects ...")
+	for i := 0; i < 10; i++ {
+		p.update(" 1 / 10 objects")
+	}
+	p.stop()
+	assert.Empty(t, out.String())
+}
+
+func TestProgressPlainRefreshInterval(t *testing.T) {
+	out := &bytes.Buffer{}
+	p := newProgressReporter(ProgressConfig{Style: ProgressPlain, RefreshInterval: Duration(time.Second)}, out)
+	now := time.Unix(0, 0)
+	p.now = func() time.Time { return now }
+
+	p.start("Copying objects ...")
+	for i := 0; i < 10; i++ {
+		p.update(" 1 bytes")
+		now = now.Add(300 * time.Millisecond)
+	}
+	p.stop()
+	// updates every 300ms are printed at 0s, 1.2s and 2.4s
+	assert.Equal(t, "Copying objects ...\n"+strings.Repeat("Copying objects ... 1 bytes\n", 3), out.String())
+}
+
+func TestProgressBarRefreshInterval(t *testing.T) {
+	p := newProgressReporter(ProgressConfig{RefreshInterval: Duration(250 * time.Millisecond)}, &bytes.Buffer{})
+	assert.Equal(t, ProgressBar, p.style)
+	assert.Equal(t, 250*time.Millisecond, p.spinner.Delay)
+}
+
+func TestQuietCopyPrintsOnlySummary(t *testing.T) {
+	fake := newFakeS3("src", "dst")
+	for _, key := range []string{"a", "b", "c"} {
+		fake.put("src", "dir/"+key, key)
+	}
+	cfg, done := newFakeEndpoint(t, fake)
+	defer done()
+	cfg.Security.ConfirmDestructive = ConfirmNever
+
+	saved := reporter
+	defer func() { reporter = saved }()
+	out := &bytes.Buffer{}
+	reporter = newProgressReporter(ProgressConfig{Style: ProgressNone}, out)
+
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	s.Events = events.NewBus()
+	s.Events.Subscribe(spinnerProgress)
+	stop := events.Aggregate(s.Events, "copy")
+	s.Events.Subscribe(spinnerSummary)
+	sps("Copying objects ...")
+	summary, err := s.CopyPrefix(context.Background(), "src", "dir/", "dst", "dir/")
+	stop()
+	s.Events.Close()
+	spe()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, summary.Copied)
+	assert.Empty(t, out.String())
+}
@@ -0,0 +1,159 @@
+package s3ry
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/manifoldco/promptui"
+)
+
+// SelectStats bytes S3 Select scanned, processed and returned
+type SelectStats struct {
+	Scanned   int64
+	Processed int64
+	Returned  int64
+}
+
+// selectInput return InputSerialization for key by its extension, false when S3 Select can't read it
+// .csv, .json, .jsonl and .parquet are supported, optionally compressed with .gz or .bz2
+func selectInput(key string) (*s3.InputSerialization, bool) {
+	name := strings.ToLower(path.Base(key))
+	input := &s3.InputSerialization{CompressionType: aws.String(s3.CompressionTypeNone)}
+	switch path.Ext(name) {
+	case ".gz":
+		input.CompressionType = aws.String(s3.CompressionTypeGzip)
+		name = strings.TrimSuffix(name, ".gz")
+	case ".bz2":
+		input.CompressionType = aws.String(s3.CompressionTypeBzip2)
+		name = strings.TrimSuffix(name, ".bz2")
+	}
+	switch path.Ext(name) {
+	case ".csv":
+		input.CSV = &s3.CSVInput{FileHeaderInfo: aws.String(s3.FileHeaderInfoUse)}
+	case ".json":
+		input.JSON = &s3.JSONInput{Type: aws.String(s3.JSONTypeDocument)}
+	case ".jsonl", ".ndjson":
+		input.JSON = &s3.JSONInput{Type: aws.String(s3.JSONTypeLines)}
+	case ".parquet":
+		if aws.StringValue(input.CompressionType) != s3.CompressionTypeNone {
+			// parquet is compressed internally
+			return nil, false
+		}
+		input.Parquet = &s3.ParquetInput{}
+	default:
+		return nil, false
+	}
+	return input, true
+}
+
+// Selectable check S3 Select can query key
+func Selectable(key string) bool {
+	_, ok := selectInput(key)
+	return ok
+}
+
+// SelectObject run S3 Select expression against object and write the records to w
+// CSV objects are read with their header line, so columns can be referred by name
+// results are written as CSV for CSV objects and as JSON lines otherwise
+func (s S3ry) SelectObject(ctx context.Context, bucket string, key string, expression string, w io.Writer) (stats SelectStats, err error) {
+	done := s.track("select", bucket, key)
+	defer func() { done(err) }()
+	input, ok := selectInput(key)
+	if !ok {
+		return stats, fmt.Errorf("S3 Select can't query %s, it must be CSV, JSON or Parquet", key)
+	}
+	output := &s3.OutputSerialization{JSON: &s3.JSONOutput{}}
+	if input.CSV != nil {
+		output = &s3.OutputSerialization{CSV: &s3.CSVOutput{}}
+	}
+	if s, err = s.forBucket(bucket); err != nil {
+		return stats, err
+	}
+	req := &s3.SelectObjectContentInput{
+		Bucket:              aws.String(bucket),
+		Key:                 aws.String(key),
+		Expression:          aws.String(expression),
+		ExpressionType:      aws.String(s3.ExpressionTypeSql),
+		InputSerialization:  input,
+		OutputSerialization: output,
+	}
+	get := &s3.GetObjectInput{}
+	if err := s.config().Encryption.applyDownload(get); err != nil {
+		return stats, err
+	}
+	req.SSECustomerAlgorithm, req.SSECustomerKey = get.SSECustomerAlgorithm, get.SSECustomerKey
+	resp, err := s.Svc.SelectObjectContentWithContext(ctx, req)
+	if err != nil {
+		return stats, err
+	}
+	stream := resp.GetStream()
+	defer stream.Close()
+
+	var written int64
+	publish := s.progress("select", bucket, key, 0)
+	for event := range stream.Events() {
+		switch e := event.(type) {
+		case *s3.RecordsEvent:
+			n, err := w.Write(e.Payload)
+			if err != nil {
+				return stats, err
+			}
+			written += int64(n)
+			publish(written)
+		case *s3.StatsEvent:
+			if e.Details != nil {
+				stats = SelectStats{
+					Scanned:   aws.Int64Value(e.Details.BytesScanned),
+					Processed: aws.Int64Value(e.Details.BytesProcessed),
+					Returned:  aws.Int64Value(e.Details.BytesReturned),
+				}
+			}
+		}
+	}
+	return stats, stream.Err()
+}
+
+// Select run S3 Select expression against s3:// URI target and write the records to out, used by the select command
+// with output the records are written to that file instead
+func Select(ctx context.Context, cfg *Config, target string, expression string, output string) error {
+	bucket, key, err := ParseS3URI(target)
+	if err != nil {
+		return err
+	}
+	w := io.Writer(os.Stdout)
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	_, err = s.SelectObject(ctx, bucket, key, expression, w)
+	return err
+}
+
+// QueryObject ask S3 Select expression and print the records of object, used by the TUI
+func (s S3ry) QueryObject(bucket string, key string) error {
+	prompt := promptui.Prompt{
+		Label:   i18nPrinter.Sprintf("SQL expression"),
+		Default: "SELECT * FROM s3object s LIMIT 10",
+	}
+	expression, err := prompt.Run()
+	if err != nil {
+		return err
+	}
+	stats, err := s.SelectObject(context.Background(), bucket, key, expression, os.Stdout)
+	if err != nil {
+		return err
+	}
+	fmt.Println(i18nPrinter.Sprintf("Scanned% d bytes, returned% d bytes", stats.Scanned, stats.Returned))
+	return nil
+}
@@ -0,0 +1,86 @@
+package s3ry
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/private/protocol/eventstream"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeSelectEvent encode S3 Select event message
+func writeSelectEvent(enc *eventstream.Encoder, eventType string, payload []byte) error {
+	msg := eventstream.Message{Payload: payload}
+	msg.Headers.Set(":message-type", eventstream.StringValue("event"))
+	msg.Headers.Set(":event-type", eventstream.StringValue(eventType))
+	return enc.Encode(msg)
+}
+
+func TestSelectObject(t *testing.T) {
+	var request struct {
+		Expression         string
+		InputSerialization struct {
+			CompressionType string
+			CSV             *struct{ FileHeaderInfo string }
+		}
+	}
+	s, srv := newTestS3ry(DefaultConfig(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			// region lookup
+			w.Write([]byte(`<LocationConstraint/>`))
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		xml.Unmarshal(body, &request)
+		w.Header().Set("Content-Type", "application/vnd.amazon.eventstream")
+		enc := eventstream.NewEncoder(w)
+		writeSelectEvent(enc, "Records", []byte("alice,30\n"))
+		writeSelectEvent(enc, "Progress", []byte(`<Progress><BytesScanned>10</BytesScanned></Progress>`))
+		writeSelectEvent(enc, "Records", []byte("bob,40\n"))
+		writeSelectEvent(enc, "Stats", []byte(`<Stats><BytesScanned>100</BytesScanned><BytesProcessed>100</BytesProcessed><BytesReturned>16</BytesReturned></Stats>`))
+		writeSelectEvent(enc, "End", nil)
+	}))
+	defer srv.Close()
+
+	var out bytes.Buffer
+	stats, err := s.SelectObject(context.Background(), "bucket", "data/users.csv.gz", "SELECT name, age FROM s3object s WHERE s.age > 20", &out)
+	assert.NoError(t, err)
+	assert.Equal(t, "alice,30\nbob,40\n", out.String())
+	assert.Equal(t, SelectStats{Scanned: 100, Processed: 100, Returned: 16}, stats)
+	assert.Equal(t, "SELECT name, age FROM s3object s WHERE s.age > 20", request.Expression)
+	assert.Equal(t, "GZIP", request.InputSerialization.CompressionType)
+	if assert.NotNil(t, request.InputSerialization.CSV) {
+		assert.Equal(t, "USE", request.InputSerialization.CSV.FileHeaderInfo)
+	}
+}
+
+func TestSelectObjectRejectsUnsupported(t *testing.T) {
+	requests := 0
+	s, srv := newTestS3ry(DefaultConfig(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer srv.Close()
+
+	_, err := s.SelectObject(context.Background(), "bucket", "image.png", "SELECT * FROM s3object", ioutil.Discard)
+	assert.Error(t, err)
+	assert.Equal(t, 0, requests)
+}
+
+func TestSelectable(t *testing.T) {
+	for key, want := range map[string]bool{
+		"a.csv":          true,
+		"dir/a.CSV.gz":   true,
+		"a.json":         true,
+		"logs.jsonl.bz2": true,
+		"a.parquet":      true,
+		"a.parquet.gz":   false,
+		"a.txt":          false,
+		"csv":            false,
+	} {
+		assert.Equal(t, want, Selectable(key), key)
+	}
+}
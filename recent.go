@@ -0,0 +1,152 @@
+package s3ry
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// maxRecent length of each recently used list
+const maxRecent = 10
+
+// RecentObject recently used object
+type RecentObject struct {
+	Bucket string
+	Key    string
+}
+
+// recentList most recently used buckets and objects, shown first in selections
+// an empty path keeps the list in memory only, a nil list records nothing
+type recentList struct {
+	mu      sync.Mutex
+	path    string
+	Buckets []string
+	Objects []RecentObject
+}
+
+// defaultRecentPath return path of the recent list next to the config file
+func defaultRecentPath() string {
+	return configDirFile("recent.json")
+}
+
+// newRecentList load recent list from path
+func newRecentList(path string) *recentList {
+	r := &recentList{path: path}
+	if path != "" {
+		if b, err := ioutil.ReadFile(path); err == nil {
+			json.Unmarshal(b, r)
+		}
+	}
+	return r
+}
+
+// touchBucket move bucket to the top
+func (r *recentList) touchBucket(bucket string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	buckets := []string{bucket}
+	for _, b := range r.Buckets {
+		if b != bucket && len(buckets) < maxRecent {
+			buckets = append(buckets, b)
+		}
+	}
+	r.Buckets = buckets
+	r.save()
+}
+
+// touchObject move object to the top
+func (r *recentList) touchObject(bucket string, key string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	object := RecentObject{Bucket: bucket, Key: key}
+	objects := []RecentObject{object}
+	for _, o := range r.Objects {
+		if o != object && len(objects) < maxRecent {
+			objects = append(objects, o)
+		}
+	}
+	r.Objects = objects
+	r.save()
+}
+
+// buckets return recent buckets, most recent first
+func (r *recentList) buckets() []string {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.Buckets...)
+}
+
+// objects return recent keys of bucket, most recent first
+func (r *recentList) objects(bucket string) []string {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var keys []string
+	for _, o := range r.Objects {
+		if o.Bucket == bucket {
+			keys = append(keys, o.Key)
+		}
+	}
+	return keys
+}
+
+// save write recent list, caller must hold mu
+// the list is only a convenience, so a failed write is ignored
+func (r *recentList) save() {
+	if r.path == "" {
+		return
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0700); err != nil {
+		return
+	}
+	ioutil.WriteFile(r.path, b, 0600)
+}
+
+// prioritizeRecent move items found in recent to the top in the order of recent
+func prioritizeRecent(items []PromptItems, recent []string) []PromptItems {
+	if len(recent) == 0 {
+		return items
+	}
+	index := map[string]int{}
+	for i, val := range recent {
+		index[val] = i
+	}
+	top := make([]PromptItems, len(recent))
+	found := make([]bool, len(recent))
+	var rest []PromptItems
+	for _, item := range items {
+		if i, ok := index[item.Val]; ok && !found[i] {
+			top[i], found[i] = item, true
+			continue
+		}
+		rest = append(rest, item)
+	}
+	sorted := make([]PromptItems, 0, len(items))
+	for i, item := range top {
+		if found[i] {
+			sorted = append(sorted, item)
+		}
+	}
+	sorted = append(sorted, rest...)
+	for i := range sorted {
+		sorted[i].Key = i
+	}
+	return sorted
+}
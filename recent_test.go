@@ -0,0 +1,77 @@
+package s3ry
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecentListOrder(t *testing.T) {
+	r := newRecentList("")
+	r.touchBucket("a")
+	r.touchBucket("b")
+	r.touchBucket("a")
+	assert.Equal(t, []string{"a", "b"}, r.buckets())
+
+	r.touchObject("a", "x")
+	r.touchObject("b", "x")
+	r.touchObject("a", "y")
+	r.touchObject("a", "x")
+	assert.Equal(t, []string{"x", "y"}, r.objects("a"))
+	assert.Equal(t, []string{"x"}, r.objects("b"))
+	assert.Len(t, r.Objects, 3)
+}
+
+func TestRecentListCap(t *testing.T) {
+	r := newRecentList("")
+	for i := 0; i < maxRecent+5; i++ {
+		r.touchBucket(fmt.Sprintf("bucket-%d", i))
+		r.touchObject("bucket", fmt.Sprintf("key-%d", i))
+	}
+	assert.Len(t, r.buckets(), maxRecent)
+	assert.Equal(t, fmt.Sprintf("bucket-%d", maxRecent+4), r.buckets()[0])
+	assert.Len(t, r.objects("bucket"), maxRecent)
+
+	var nilList *recentList
+	nilList.touchBucket("a")
+	assert.Nil(t, nilList.buckets())
+}
+
+func TestRecentListPersists(t *testing.T) {
+	dir, err := ioutil.TempDir("", "s3ry")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "s3ry", "recent.json")
+	newRecentList(path).touchBucket("a")
+	r := newRecentList(path)
+	r.touchObject("a", "key")
+	r = newRecentList(path)
+	assert.Equal(t, []string{"a"}, r.buckets())
+	assert.Equal(t, []string{"key"}, r.objects("a"))
+}
+
+func TestPrioritizeRecent(t *testing.T) {
+	items := []PromptItems{{Key: 0, Val: "a"}, {Key: 1, Val: "b"}, {Key: 2, Val: "c"}, {Key: 3, Val: "d"}}
+	sorted := prioritizeRecent(items, []string{"c", "gone", "a"})
+	assert.Equal(t, []PromptItems{{Key: 0, Val: "c"}, {Key: 1, Val: "a"}, {Key: 2, Val: "b"}, {Key: 3, Val: "d"}}, sorted)
+	assert.Equal(t, items, prioritizeRecent(items, nil))
+}
+
+func TestListObjectsRecentFirst(t *testing.T) {
+	fake := newFakeS3("bucket")
+	fake.put("bucket", "old.txt", "old")
+	fake.put("bucket", "new.txt", "new")
+	s, srv := newTestS3ry(DefaultConfig(), fake)
+	defer srv.Close()
+
+	s.recent.touchObject("bucket", "old.txt")
+	items := s.ListObjects("bucket")
+	if assert.Len(t, items, 2) {
+		assert.Equal(t, "old.txt", items[0].Val)
+	}
+}
@@ -0,0 +1,72 @@
+package s3ry
+
+import (
+	"io"
+	"regexp"
+)
+
+// redacted replacement of sensitive values
+const redacted = "[REDACTED]"
+
+// defaultRedactPatterns sensitive values always masked in log output
+// the first group of a pattern is kept, so parameter names stay readable
+var defaultRedactPatterns = []string{
+	// access key ids of long-term and temporary credentials
+	`\b()(?:AKIA|ASIA)[A-Z0-9]{16}\b`,
+	// presigned URL signatures, credentials and tokens
+	`(?i)((?:X-Amz-Signature|X-Amz-Credential|X-Amz-Security-Token|Signature|AWSAccessKeyId)=)[^&\s"']+`,
+	// Authorization headers
+	`(?i)(Bearer\s+)[A-Za-z0-9\-._~+/]+=*`,
+	`(?i)(AWS4-HMAC-SHA256\s+)[^\r\n"]+`,
+	// secrets in credentials files and JSON
+	`(?i)((?:aws_secret_access_key|aws_session_token|SecretAccessKey|SessionToken)["']?\s*[:=]\s*["']?)[^\s"',]+`,
+}
+
+// Redactor mask sensitive values in text
+type Redactor struct {
+	patterns []*regexp.Regexp
+}
+
+// NewRedactor create Redactor of the default patterns and extra
+func NewRedactor(extra []string) (*Redactor, error) {
+	r := &Redactor{}
+	for _, p := range append(append([]string{}, defaultRedactPatterns...), extra...) {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, err
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// Redact mask every match in s
+func (r *Redactor) Redact(s string) string {
+	for _, re := range r.patterns {
+		repl := redacted
+		if re.NumSubexp() > 0 {
+			repl = "${1}" + redacted
+		}
+		s = re.ReplaceAllString(s, repl)
+	}
+	return s
+}
+
+// Writer wrap w to redact everything written
+// the log package writes each line at once, so matches are never split across writes
+func (r *Redactor) Writer(w io.Writer) io.Writer {
+	return &redactWriter{r: r, w: w}
+}
+
+type redactWriter struct {
+	r *Redactor
+	w io.Writer
+}
+
+// Write redact p and write it, reporting len(p) so callers don't see a short write
+func (rw *redactWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(rw.w, rw.r.Redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,255 @@
+package s3ry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/seike460/s3ry/internal/events"
+	"github.com/seike460/s3ry/internal/worker"
+)
+
+// ReencryptSummary result of ReencryptPrefix
+type ReencryptSummary struct {
+	Reencrypted int
+	Bytes       int64
+	// Unchanged objects already encrypted as asked
+	Unchanged int
+	// Skipped objects re-encrypted before an interruption, by the checkpoint resumed from
+	Skipped int
+	// Failed error of each key which could not be re-encrypted
+	Failed map[string]error `json:"-"`
+}
+
+// checkReencryption check enc can be applied to existing objects
+// SSE-C objects can't be read without their old key, and an empty mode would copy them with the bucket default
+func checkReencryption(enc EncryptionConfig) error {
+	switch enc.Mode {
+	case "":
+		return errors.New("no encryption to re-encrypt with, set the mode with --sse")
+	case SSEModeC:
+		return errors.New("objects can't be re-encrypted with SSE-C, their old keys are needed to read them")
+	}
+	_, err := enc.fields()
+	return err
+}
+
+// encryptedWith check object of head is already encrypted by enc
+// a KMS key given by alias can't be compared to the key ARN of the object, so it never matches
+func encryptedWith(head *s3.HeadObjectOutput, enc EncryptionConfig) bool {
+	sse := aws.StringValue(head.ServerSideEncryption)
+	switch enc.Mode {
+	case SSEModeS3:
+		return sse == s3.ServerSideEncryptionAes256
+	case SSEModeKMS:
+		if sse != s3.ServerSideEncryptionAwsKms {
+			return false
+		}
+		key := aws.StringValue(head.SSEKMSKeyId)
+		return enc.KMSKeyID == "" || key == enc.KMSKeyID || strings.HasSuffix(key, ":key/"+enc.KMSKeyID)
+	}
+	return false
+}
+
+// ReencryptObject copy object onto itself with the encryption of its bucket, keeping its metadata and storage class
+// it returns false without copying when the object is already encrypted that way
+func (s S3ry) ReencryptObject(ctx context.Context, bucket string, key string) (copied bool, err error) {
+	if s, err = s.forBucket(bucket); err != nil {
+		return false, err
+	}
+	enc := s.config().Encryption
+	if err := checkReencryption(enc); err != nil {
+		return false, err
+	}
+	head, err := s.Svc.HeadObjectWithContext(ctx, &s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil {
+		return false, err
+	}
+	if encryptedWith(head, enc) {
+		return false, nil
+	}
+	done := s.track("reencrypt", bucket, key)
+	defer func() { done(err) }()
+	size := aws.Int64Value(head.ContentLength)
+	if size > s.config().Performance.MultipartCopyThreshold {
+		return true, s.copyParts(ctx, s, bucket, key, bucket, key, size)
+	}
+	input := &s3.CopyObjectInput{
+		Bucket:     aws.String(bucket),
+		Key:        aws.String(key),
+		CopySource: aws.String(copySource(bucket, key)),
+		// S3 stores the copy as STANDARD unless told otherwise
+		StorageClass: head.StorageClass,
+	}
+	if err := enc.applyCopy(input, head.SSECustomerAlgorithm != nil); err != nil {
+		return false, err
+	}
+	_, err = s.Svc.CopyObjectWithContext(ctx, input)
+	return true, err
+}
+
+// ReencryptPrefix re-encrypt every object under prefix by ReencryptObject
+// objects are re-encrypted concurrently by Performance.Workers and like CopyPrefix the progress is saved
+// to Checkpoints, so re-encrypting the same prefix again after an interruption resumes from the last checkpoint
+func (s S3ry) ReencryptPrefix(ctx context.Context, bucket string, prefix string) (ReencryptSummary, error) {
+	summary := ReencryptSummary{Failed: map[string]error{}}
+	s, err := s.forBucket(bucket)
+	if err != nil {
+		return summary, err
+	}
+	enc := s.config().Encryption
+	if err := checkReencryption(enc); err != nil {
+		return summary, err
+	}
+	job := "reencrypt s3://" + bucket + "/" + prefix + " " + enc.Mode + " " + enc.KMSKeyID
+	checkpoint, err := s.loadCheckpoint(job)
+	if err != nil {
+		return summary, err
+	}
+
+	var mu sync.Mutex
+	// sizes size of each failed key, to retry it
+	sizes := map[string]int64{}
+	pool := worker.New(ctx, s.config().Performance.Workers)
+	var submitErr error
+	input := &s3.ListObjectsV2Input{
+		Bucket:  aws.String(bucket),
+		Prefix:  aws.String(prefix),
+		MaxKeys: aws.Int64(s.config().Performance.ListPageSize),
+	}
+	if checkpoint.ContinuationToken != "" {
+		input.ContinuationToken = aws.String(checkpoint.ContinuationToken)
+	}
+	for {
+		var page *s3.ListObjectsV2Output
+		page, err = s.Svc.ListObjectsV2WithContext(ctx, input)
+		if err != nil {
+			break
+		}
+		var wg sync.WaitGroup
+		for _, object := range page.Contents {
+			key := aws.StringValue(object.Key)
+			size := aws.Int64Value(object.Size)
+			mu.Lock()
+			done := checkpoint.Processed[key]
+			if done {
+				summary.Skipped++
+				s.Events.Publish(events.Event{Type: events.Skipped, Operation: "reencrypt", Bucket: bucket, Key: key})
+			}
+			mu.Unlock()
+			if done {
+				continue
+			}
+			wg.Add(1)
+			submitErr = pool.Submit(func(ctx context.Context) {
+				defer wg.Done()
+				copied, err := s.ReencryptObject(ctx, bucket, key)
+				mu.Lock()
+				defer mu.Unlock()
+				switch {
+				case err != nil:
+					summary.Failed[key] = err
+					sizes[key] = size
+					return
+				case copied:
+					summary.Reencrypted++
+					summary.Bytes += size
+				default:
+					summary.Unchanged++
+				}
+				checkpoint.Processed[key] = true
+				if len(checkpoint.Processed)%checkpointEvery == 0 {
+					s.saveCheckpoint(job, checkpoint)
+				}
+			})
+			if submitErr != nil {
+				wg.Done()
+				break
+			}
+		}
+		wg.Wait()
+		if submitErr != nil || ctx.Err() != nil {
+			s.saveCheckpoint(job, checkpoint)
+			break
+		}
+		if !aws.BoolValue(page.IsTruncated) {
+			break
+		}
+		checkpoint = &Checkpoint{ContinuationToken: aws.StringValue(page.NextContinuationToken), Processed: map[string]bool{}}
+		s.saveCheckpoint(job, checkpoint)
+		input.ContinuationToken = page.NextContinuationToken
+	}
+	pool.Wait()
+	if submitErr != nil {
+		return summary, submitErr
+	}
+	if err != nil {
+		return summary, err
+	}
+	if err := ctx.Err(); err != nil {
+		return summary, err
+	}
+	s.deleteCheckpoint(job)
+	err = s.retryFailed(ctx, summary.Failed, func(ctx context.Context, key string) error {
+		copied, err := s.ReencryptObject(ctx, bucket, key)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if copied {
+			summary.Reencrypted++
+			summary.Bytes += sizes[key]
+		} else {
+			summary.Unchanged++
+		}
+		return nil
+	})
+	return summary, err
+}
+
+// Reencrypt re-encrypt the objects under s3:// URI target with the encryption of the flags or the config,
+// used by the reencrypt command
+func Reencrypt(ctx context.Context, cfg *Config, target string, w io.Writer) error {
+	bucket, prefix, err := ParseS3URI(target)
+	if err != nil {
+		return err
+	}
+	if err := checkReencryption(cfg.bucketConfig(bucket).Encryption); err != nil {
+		return err
+	}
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	if err := s.preflight(ctx, bucket, prefix, PermListBucket, PermGetObject, PermPutObject); err != nil {
+		return err
+	}
+	if err := cfg.confirmDestructive(true, i18nPrinter.Sprintf("Copy every object under% s onto itself to re-encrypt it? Object ACLs are reset, [Yy] / [Nn]", target)); err != nil {
+		return err
+	}
+	s.Events = events.NewBus()
+	defer s.Events.Close()
+	stop := events.Aggregate(s.Events, "reencrypt")
+	report := events.Collect(s.Events)
+	s.Events.Subscribe(spinnerSummary)
+	sps(i18nPrinter.Sprintf("Re-encrypting objects ..."))
+	if dir := defaultCheckpointDir(); dir != "" {
+		s.Checkpoints = FileCheckpointStore{Dir: dir}
+	}
+	summary, err := s.ReencryptPrefix(ctx, bucket, prefix)
+	stop()
+	spe()
+	if werr := cfg.writeReport(report(), os.Stdout); werr != nil && err == nil {
+		err = werr
+	}
+	fmt.Fprintln(w, i18nPrinter.Sprintf("Re-encrypted: %d (%d bytes), already encrypted: %d, failed: %d, skipped: %d",
+		summary.Reencrypted, summary.Bytes, summary.Unchanged, len(summary.Failed), summary.Skipped))
+	if err == nil && len(summary.Failed) > 0 {
+		err = &PartialError{Failed: len(summary.Failed), Op: "reencrypt"}
+	}
+	return err
+}
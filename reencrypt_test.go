@@ -0,0 +1,135 @@
+package s3ry
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReencryptPrefix(t *testing.T) {
+	fake := newFakeS3("bucket")
+	newKey := "arn:aws:kms:ap-northeast-1:123456789012:key/new"
+	objects := map[string]map[string]string{
+		"p/plain":  {},
+		"p/sse-s3": {"X-Amz-Server-Side-Encryption": "AES256"},
+		"p/old":    {"X-Amz-Server-Side-Encryption": "aws:kms", "X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id": "arn:aws:kms:ap-northeast-1:123456789012:key/old"},
+		"p/new":    {"X-Amz-Server-Side-Encryption": "aws:kms", "X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id": newKey},
+		"p/ia":     {"X-Amz-Storage-Class": "STANDARD_IA", "X-Amz-Meta-Owner": "me"},
+	}
+	for key, headers := range objects {
+		fake.put("bucket", key, "data")
+		o, _ := fake.get("bucket", key)
+		for k, v := range headers {
+			o.header.Set(k, v)
+		}
+	}
+	cfg := DefaultConfig()
+	cfg.Flags.Encryption = EncryptionConfig{Mode: SSEModeKMS, KMSKeyID: "new"}
+	s, srv := newTestS3ry(cfg, fake)
+	defer srv.Close()
+
+	summary, err := s.ReencryptPrefix(context.Background(), "bucket", "p/")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, summary.Reencrypted)
+	assert.Equal(t, int64(16), summary.Bytes)
+	assert.Equal(t, 1, summary.Unchanged)
+	assert.Equal(t, 4, fake.count("COPY"))
+	for key := range objects {
+		o, _ := fake.get("bucket", key)
+		assert.Equal(t, "aws:kms", o.header.Get("X-Amz-Server-Side-Encryption"), key)
+	}
+	o, _ := fake.get("bucket", "p/ia")
+	assert.Equal(t, "STANDARD_IA", o.header.Get("X-Amz-Storage-Class"))
+	assert.Equal(t, "me", o.header.Get("X-Amz-Meta-Owner"))
+
+	// SSE-C sources can't be read and an empty mode has nothing to apply
+	cfg.Flags.Encryption = EncryptionConfig{Mode: SSEModeC, CustomerKey: "MDEyMzQ1Njc4OTAxMjM0NTY3ODkwMTIzNDU2Nzg5MDE="}
+	_, err = s.ReencryptPrefix(context.Background(), "bucket", "p/")
+	assert.Error(t, err)
+	cfg.Flags.Encryption = EncryptionConfig{}
+	_, err = s.ReencryptObject(context.Background(), "bucket", "p/plain")
+	assert.Error(t, err)
+}
+
+func TestEncryptedWith(t *testing.T) {
+	fake := newFakeS3("bucket")
+	s, srv := newTestS3ry(DefaultConfig(), fake)
+	defer srv.Close()
+	fake.put("bucket", "key", "data")
+	o, _ := fake.get("bucket", "key")
+	o.header.Set("X-Amz-Server-Side-Encryption", "aws:kms")
+	o.header.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", "arn:aws:kms:ap-northeast-1:123456789012:key/1234")
+	head, err := s.Svc.HeadObject(&s3.HeadObjectInput{Bucket: aws.String("bucket"), Key: aws.String("key")})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, encryptedWith(head, EncryptionConfig{Mode: SSEModeKMS}))
+	assert.True(t, encryptedWith(head, EncryptionConfig{Mode: SSEModeKMS, KMSKeyID: "1234"}))
+	assert.True(t, encryptedWith(head, EncryptionConfig{Mode: SSEModeKMS, KMSKeyID: "arn:aws:kms:ap-northeast-1:123456789012:key/1234"}))
+	assert.False(t, encryptedWith(head, EncryptionConfig{Mode: SSEModeKMS, KMSKeyID: "alias/mine"}))
+	assert.False(t, encryptedWith(head, EncryptionConfig{Mode: SSEModeS3}))
+}
+
+func TestReencryptPrefixResumesFromCheckpoint(t *testing.T) {
+	fake := newFakeS3("bucket")
+	for i := 0; i < 15; i++ {
+		fake.put("bucket", fmt.Sprintf("p/%02d", i), "x")
+	}
+	ctx, crash := context.WithCancel(context.Background())
+	defer crash()
+	copies := 0
+	fake.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Header.Get("X-Amz-Copy-Source") == "" {
+			return false
+		}
+		copies++
+		if copies == 13 {
+			// crash in the middle of the second page
+			crash()
+			w.WriteHeader(http.StatusBadRequest)
+			return true
+		}
+		return false
+	}
+	cfg := DefaultConfig()
+	cfg.Flags.Encryption = EncryptionConfig{Mode: SSEModeS3}
+	cfg.Performance.Workers = 1
+	cfg.Performance.ListPageSize = 10
+	s, srv := newTestS3ry(cfg, fake)
+	defer srv.Close()
+	store := &memoryCheckpointStore{checkpoints: map[string]Checkpoint{}}
+	s.Checkpoints = store
+
+	summary, err := s.ReencryptPrefix(ctx, "bucket", "p/")
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 12, summary.Reencrypted)
+
+	copies = 0
+	summary, err = s.ReencryptPrefix(context.Background(), "bucket", "p/")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, summary.Skipped)
+	assert.Equal(t, 3, summary.Reencrypted)
+	assert.Equal(t, 3, copies)
+	assert.Empty(t, store.checkpoints)
+}
+
+func TestReencrypt(t *testing.T) {
+	fake := newFakeS3("bucket")
+	fake.put("bucket", "a", "data")
+	cfg, done := newFakeEndpoint(t, fake)
+	defer done()
+	cfg.Security.AssumeYes = true
+	cfg.Progress.Style = ProgressNone
+
+	var out bytes.Buffer
+	assert.Error(t, Reencrypt(context.Background(), cfg, "s3://bucket/", &out))
+	cfg.Flags.Encryption = EncryptionConfig{Mode: SSEModeS3}
+	assert.NoError(t, Reencrypt(context.Background(), cfg, "s3://bucket/", &out))
+	assert.Contains(t, out.String(), "Re-encrypted: 1 (4 bytes)")
+}
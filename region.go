@@ -0,0 +1,119 @@
+package s3ry
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// regionCache bucket name to region
+type regionCache struct {
+	mu      sync.Mutex
+	regions map[string]string
+}
+
+// newRegionCache create regionCache
+func newRegionCache() *regionCache {
+	return &regionCache{regions: map[string]string{}}
+}
+
+// get return cached region of bucket
+func (c *regionCache) get(bucket string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	region, ok := c.regions[bucket]
+	return region, ok
+}
+
+// set cache region of bucket
+func (c *regionCache) set(bucket string, region string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.regions[bucket] = region
+}
+
+// regionTimeout time limit of discovering the region of a bucket
+// it runs before the operation and has no context of its own, so a stuck connection can't hang it
+const regionTimeout = 30 * time.Second
+
+// BucketRegion return region of bucket, discovered once and cached
+// the region of an access point ARN is the one in the ARN
+func (s S3ry) BucketRegion(bucket string) (string, error) {
+	if isAccessPointARN(bucket) {
+		return accessPointRegion(bucket)
+	}
+	if region, ok := s.regions.get(bucket); ok {
+		return region, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), regionTimeout)
+	defer cancel()
+	var region string
+	out, err := s.Svc.GetBucketLocationWithContext(ctx, &s3.GetBucketLocationInput{Bucket: aws.String(bucket)})
+	if err == nil {
+		region = s3.NormalizeBucketLocation(aws.StringValue(out.LocationConstraint))
+	} else {
+		// GetBucketLocation needs its own permission, HeadBucket only needs access to the bucket
+		region, err = s3manager.GetBucketRegion(ctx, s.Sess, bucket, aws.StringValue(s.Sess.Config.Region))
+		if err != nil {
+			return "", err
+		}
+	}
+	s.regions.set(bucket, region)
+	return region, nil
+}
+
+// forBucket return S3ry whose client uses the region of bucket and whose Config has the bucket settings
+func (s S3ry) forBucket(bucket string) (S3ry, error) {
+	region, err := s.BucketRegion(bucket)
+	if err != nil {
+		return s, err
+	}
+	s.Config = s.config().forBucket(bucket)
+	signing := s.config().signingRegion(bucket)
+	if region == aws.StringValue(s.Sess.Config.Region) && signing == s.signing {
+		return s, nil
+	}
+	if region != aws.StringValue(s.Sess.Config.Region) {
+		s.Sess = s.Sess.Copy(&aws.Config{Region: aws.String(region)})
+	}
+	s.Svc = s.config().newS3Client(s.Sess)
+	s.signing = signing
+	if signing != "" {
+		s.Svc.Handlers.Sign.PushFrontNamed(signingRegionHandler(signing))
+	}
+	return s, nil
+}
+
+// signingRegion return region requests to bucket are signed for, empty to sign for the region of the client
+// BucketConfig.SigningRegion overrides the region of an access point ARN, e.g. for a proxy in another region
+func (c *Config) signingRegion(bucket string) string {
+	if region := c.bucketConfig(bucket).SigningRegion; region != "" {
+		return region
+	}
+	if ap, err := ParseAccessPointARN(bucket); err == nil && !ap.MultiRegion {
+		return ap.Region
+	}
+	return ""
+}
+
+// signingRegionHandler sign requests for region
+// it runs first in Sign, after Build where the SDK sets the signing region of access points from the endpoint
+func signingRegionHandler(region string) request.NamedHandler {
+	return request.NamedHandler{
+		Name: "s3ry.SigningRegionHandler",
+		Fn: func(r *request.Request) {
+			r.ClientInfo.SigningRegion = region
+		},
+	}
+}
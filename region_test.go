@@ -0,0 +1,54 @@
+package s3ry
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOperationUsesDiscoveredRegion(t *testing.T) {
+	locationRequests := 0
+	var authorization string
+	cfg := DefaultConfig()
+	cfg.Security.ConfirmDestructive = ConfirmNever
+	s, srv := newTestS3ry(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.URL.Query()["location"]; ok {
+			locationRequests++
+			w.Write([]byte(`<LocationConstraint xmlns="http://s3.amazonaws.com/doc/2006-03-01/">eu-west-1</LocationConstraint>`))
+			return
+		}
+		ioutil.ReadAll(r.Body)
+		authorization = r.Header.Get("Authorization")
+	}))
+	defer srv.Close()
+
+	f, err := ioutil.TempFile("", "s3ry")
+	assert.NoError(t, err)
+	f.Close()
+	defer os.Remove(f.Name())
+
+	assert.NoError(t, s.UploadObject("eu-bucket", f.Name()))
+	assert.True(t, strings.Contains(authorization, "/eu-west-1/s3/aws4_request"), authorization)
+	assert.NoError(t, s.DeleteObject("eu-bucket", f.Name()))
+	assert.True(t, strings.Contains(authorization, "/eu-west-1/s3/aws4_request"), authorization)
+	assert.Equal(t, 1, locationRequests)
+
+	region, err := s.BucketRegion("eu-bucket")
+	assert.NoError(t, err)
+	assert.Equal(t, "eu-west-1", region)
+}
+
+func TestBucketRegionNormalizesLocation(t *testing.T) {
+	s, srv := newTestS3ry(DefaultConfig(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<LocationConstraint xmlns="http://s3.amazonaws.com/doc/2006-03-01/"/>`))
+	}))
+	defer srv.Close()
+
+	region, err := s.BucketRegion("us-bucket")
+	assert.NoError(t, err)
+	assert.Equal(t, "us-east-1", region)
+}
@@ -0,0 +1,64 @@
+package s3ry
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/seike460/s3ry/internal/events"
+)
+
+// SummaryConfig settings of the summary of what bulk commands like cp --recursive changed
+type SummaryConfig struct {
+	// Format text or json (default text), the --summary flag overrides it
+	Format string `enum:"text,json"`
+	// File also write the summary as JSON to this file, e.g. for CI artifacts (default none)
+	// the --summary-file flag overrides it
+	File string `json:",omitempty"`
+}
+
+// reportVerbs past tense of operations in text summaries
+var reportVerbs = map[string]string{
+	"upload":   "Uploaded",
+	"download": "Downloaded",
+	"copy":     "Copied",
+	"delete":   "Deleted",
+}
+
+// writeReport print report to w in Summary.Format and write it to Summary.File
+func (c *Config) writeReport(report events.Report, w io.Writer) error {
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if c.Summary.File != "" {
+		if err := ioutil.WriteFile(c.Summary.File, append(b, '\n'), 0644); err != nil {
+			return err
+		}
+	}
+	if c.Summary.Format == "json" {
+		fmt.Fprintln(w, string(b))
+		return nil
+	}
+	for _, op := range report.Changed {
+		verb, ok := reportVerbs[op.Operation]
+		if !ok {
+			verb = op.Operation
+		}
+		fmt.Fprintln(w, i18nPrinter.Sprintf("%s: %s objects, %s", i18nPrinter.Sprintf(verb), locale.Number(int64(op.Objects)), locale.Bytes(op.Bytes)))
+	}
+	if report.Skipped > 0 {
+		fmt.Fprintln(w, i18nPrinter.Sprintf("Skipped: %s objects", locale.Number(int64(report.Skipped))))
+	}
+	if len(report.Changed) == 0 && report.Skipped == 0 && len(report.Errors) == 0 {
+		fmt.Fprintln(w, i18nPrinter.Sprintf("Nothing changed"))
+	}
+	if len(report.Errors) > 0 {
+		fmt.Fprintln(w, i18nPrinter.Sprintf("Failed: %d objects", len(report.Errors)))
+		for _, e := range report.Errors {
+			fmt.Fprintln(w, i18nPrinter.Sprintf("  %s s3://%s/%s: %s", e.Operation, e.Bucket, e.Key, e.Error))
+		}
+	}
+	return nil
+}
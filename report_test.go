@@ -0,0 +1,75 @@
+package s3ry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/seike460/s3ry/internal/events"
+	"github.com/seike460/s3ry/internal/i18n"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteReport(t *testing.T) {
+	report := events.Report{
+		Changed: []events.OperationCount{{Operation: "copy", Objects: 2, Bytes: 10}, {Operation: "tag", Objects: 1}},
+		Skipped: 3,
+		Errors:  []events.ReportError{{Operation: "copy", Bucket: "bucket", Key: "k", Error: "AccessDenied"}},
+	}
+	defer func(l i18n.Locale) { locale = l }(locale)
+	locale = i18n.POSIX
+	cfg := DefaultConfig()
+	out := &bytes.Buffer{}
+	assert.NoError(t, cfg.writeReport(report, out))
+	assert.Equal(t, `Copied: 2 objects, 10 B
+tag: 1 objects, 0 B
+Skipped: 3 objects
+Failed: 1 objects
+  copy s3://bucket/k: AccessDenied
+`, out.String())
+
+	out.Reset()
+	assert.NoError(t, cfg.writeReport(events.Report{}, out))
+	assert.Equal(t, "Nothing changed\n", out.String())
+
+	dir, err := ioutil.TempDir("", "s3ry-report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfg.Summary = SummaryConfig{Format: "json", File: filepath.Join(dir, "summary.json")}
+	out.Reset()
+	assert.NoError(t, cfg.writeReport(report, out))
+	var printed, written events.Report
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &printed))
+	b, err := ioutil.ReadFile(cfg.Summary.File)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(b, &written))
+	assert.Equal(t, report, printed)
+	assert.Equal(t, report, written)
+}
+
+func TestPutFilesReport(t *testing.T) {
+	fake := newFakeS3("bucket")
+	cfg, done := newFakeEndpoint(t, fake)
+	defer done()
+	a := filepath.Join(cfg.Performance.TempDir, "a.txt")
+	ioutil.WriteFile(a, []byte("hello"), 0644)
+	cfg.Summary.File = filepath.Join(cfg.Performance.TempDir, "summary.json")
+
+	err := PutFiles(context.Background(), cfg, []string{a, filepath.Join(cfg.Performance.TempDir, "missing")}, "s3://bucket/", "")
+	assert.Equal(t, ExitPartial, ExitCode(err))
+	b, err := ioutil.ReadFile(cfg.Summary.File)
+	assert.NoError(t, err)
+	var report events.Report
+	assert.NoError(t, json.Unmarshal(b, &report))
+	assert.Equal(t, []events.OperationCount{{Operation: "upload", Objects: 1, Bytes: 5}}, report.Changed)
+	if assert.Len(t, report.Errors, 1) {
+		assert.Equal(t, "missing", report.Errors[0].Key)
+	}
+	assert.Equal(t, []string{"a.txt"}, fake.keys("bucket"))
+}
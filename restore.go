@@ -0,0 +1,284 @@
+package s3ry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/seike460/s3ry/internal/consumer"
+)
+
+// errCodeRestoreInProgress error code of restoring an object already being restored
+const errCodeRestoreInProgress = "RestoreAlreadyInProgress"
+
+// PendingRestore restore requested by s3ry and not yet completed
+type PendingRestore struct {
+	Bucket string
+	Key    string
+	// Callback URL to POST to or shell command to run when the object is restored, empty for none
+	Callback  string
+	Requested time.Time
+}
+
+// restoreJournal restores waiting to complete, kept across runs
+// an empty path keeps the journal in memory only
+type restoreJournal struct {
+	mu       sync.Mutex
+	path     string
+	restores map[string]PendingRestore
+}
+
+// defaultRestoresPath return path of the pending restores next to the config file
+func defaultRestoresPath() string {
+	return configDirFile("restores.json")
+}
+
+// newRestoreJournal load journal from path
+func newRestoreJournal(path string) *restoreJournal {
+	j := &restoreJournal{path: path, restores: map[string]PendingRestore{}}
+	if path != "" {
+		if b, err := ioutil.ReadFile(path); err == nil {
+			json.Unmarshal(b, &j.restores)
+		}
+	}
+	return j
+}
+
+// add record restore
+func (j *restoreJournal) add(r PendingRestore) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.restores[r.Bucket+"/"+r.Key] = r
+	j.save()
+}
+
+// remove forget restore of object
+func (j *restoreJournal) remove(bucket string, key string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, ok := j.restores[bucket+"/"+key]; !ok {
+		return
+	}
+	delete(j.restores, bucket+"/"+key)
+	j.save()
+}
+
+// get return pending restore of object
+func (j *restoreJournal) get(bucket string, key string) (PendingRestore, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	r, ok := j.restores[bucket+"/"+key]
+	return r, ok
+}
+
+// list return pending restores in the order they were requested
+func (j *restoreJournal) list() []PendingRestore {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var restores []PendingRestore
+	for _, r := range j.restores {
+		restores = append(restores, r)
+	}
+	sort.Slice(restores, func(a, b int) bool {
+		return restores[a].Requested.Before(restores[b].Requested)
+	})
+	return restores
+}
+
+// save write journal, caller must hold mu
+// like the upload journal it is best effort, a failed write only loses callbacks
+func (j *restoreJournal) save() {
+	if j.path == "" {
+		return
+	}
+	b, err := json.Marshal(j.restores)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(j.path), 0700); err != nil {
+		return
+	}
+	ioutil.WriteFile(j.path, b, 0600)
+}
+
+// RestoreObject request a temporary copy of an archived object for days, tier is Standard, Bulk or Expedited
+// the restore is tracked until it completes, then callback is invoked when not empty
+func (s S3ry) RestoreObject(ctx context.Context, bucket string, key string, days int64, tier string, callback string) (err error) {
+	done := s.track("restore", bucket, key)
+	defer func() { done(err) }()
+	if s, err = s.forBucket(bucket); err != nil {
+		return err
+	}
+	_, err = s.Svc.RestoreObjectWithContext(ctx, &s3.RestoreObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		RestoreRequest: &s3.RestoreRequest{
+			Days:                 aws.Int64(days),
+			GlacierJobParameters: &s3.GlacierJobParameters{Tier: aws.String(tier)},
+		},
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == errCodeRestoreInProgress {
+		// requested before, keep waiting for it with the new callback
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+	s.restores.add(PendingRestore{Bucket: bucket, Key: key, Callback: callback, Requested: time.Now()})
+	return nil
+}
+
+// restored check x-amz-restore header reports a completed restore
+func restored(header string) bool {
+	return strings.Contains(header, `ongoing-request="false"`)
+}
+
+// CheckRestores check every pending restore and complete the restored ones
+// a restore whose callback failed stays pending, so it is retried by the next check
+func (s S3ry) CheckRestores(ctx context.Context) ([]PendingRestore, error) {
+	var completed []PendingRestore
+	var lastErr error
+	for _, r := range s.restores.list() {
+		b, err := s.forBucket(r.Bucket)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		head, err := b.Svc.HeadObjectWithContext(ctx, &s3.HeadObjectInput{Bucket: aws.String(r.Bucket), Key: aws.String(r.Key)})
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "NotFound" {
+			// deleted while restoring, nothing will complete
+			s.restores.remove(r.Bucket, r.Key)
+			continue
+		}
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if !restored(aws.StringValue(head.Restore)) {
+			continue
+		}
+		if err := s.completeRestore(ctx, r); err != nil {
+			lastErr = err
+			continue
+		}
+		completed = append(completed, r)
+	}
+	return completed, lastErr
+}
+
+// completeRestore invoke callback of r and forget it
+func (s S3ry) completeRestore(ctx context.Context, r PendingRestore) error {
+	if err := s.restoreCallback(ctx, r); err != nil {
+		return fmt.Errorf("callback of s3://%s/%s: %w", r.Bucket, r.Key, err)
+	}
+	s.restores.remove(r.Bucket, r.Key)
+	return nil
+}
+
+// restoreCallback POST r as JSON to an http(s) Callback, or run it with sh
+// commands get the object in S3RY_BUCKET, S3RY_KEY and S3RY_URI
+func (s S3ry) restoreCallback(ctx context.Context, r PendingRestore) error {
+	if r.Callback == "" {
+		return nil
+	}
+	if strings.HasPrefix(r.Callback, "http://") || strings.HasPrefix(r.Callback, "https://") {
+		body, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequest(http.MethodPost, r.Callback, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := s.config().HTTP.newHTTPClient().Do(req.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		io.Copy(ioutil.Discard, resp.Body)
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("%s returned %s", r.Callback, resp.Status)
+		}
+		return nil
+	}
+	cmd := exec.CommandContext(ctx, "sh", "-c", r.Callback)
+	cmd.Env = append(os.Environ(),
+		"S3RY_BUCKET="+r.Bucket,
+		"S3RY_KEY="+r.Key,
+		"S3RY_URI=s3://"+r.Bucket+"/"+r.Key,
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// restoredReaction complete the pending restore of the object on ObjectRestore:Completed events
+func restoredReaction(s *S3ry, rc ReactionConfig) (consumer.Reaction, error) {
+	return consumer.ReactionFunc(func(ctx context.Context, r consumer.Record) error {
+		if !strings.HasPrefix(r.EventName, "ObjectRestore:Completed") {
+			return nil
+		}
+		pending, ok := s.restores.get(r.Bucket, r.Key)
+		if !ok {
+			return nil
+		}
+		return s.completeRestore(ctx, pending)
+	}), nil
+}
+
+// Restore request restore of s3:// URI target and track it, used by the restore command
+func Restore(ctx context.Context, cfg *Config, target string, days int64, tier string, callback string, w io.Writer) error {
+	bucket, key, err := ParseS3URI(target)
+	if err != nil {
+		return err
+	}
+	if key == "" || strings.HasSuffix(key, "/") {
+		return fmt.Errorf("%w %q, it needs an object key", ErrInvalidURI, target)
+	}
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	if err := s.RestoreObject(ctx, bucket, key, days, tier, callback); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, i18nPrinter.Sprintf("Restore requested,% s", target))
+	return nil
+}
+
+// WaitRestores check pending restores every interval until none is left, used by restore --wait
+func WaitRestores(ctx context.Context, cfg *Config, interval time.Duration, w io.Writer) error {
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	for {
+		completed, err := s.CheckRestores(ctx)
+		for _, r := range completed {
+			fmt.Fprintln(w, i18nPrinter.Sprintf("Restored,% s", "s3://"+r.Bucket+"/"+r.Key))
+		}
+		if err != nil {
+			fmt.Fprintln(w, err.Error())
+		}
+		pending := s.restores.list()
+		if len(pending) == 0 {
+			return nil
+		}
+		fmt.Fprintln(w, i18nPrinter.Sprintf("Waiting for %d restores ...", len(pending)))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package s3ry
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/seike460/s3ry/internal/consumer"
+	"github.com/stretchr/testify/assert"
+)
+
+// finishRestore make the fake report the restore of key as completed
+func (f *fakeS3) finishRestore(bucket string, key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.buckets[bucket][key].header.Set("X-Amz-Restore", `ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"`)
+}
+
+func TestCheckRestoresFiresCallbacks(t *testing.T) {
+	fake := newFakeS3("bucket")
+	fake.put("bucket", "hook.bin", "a")
+	fake.put("bucket", "cmd.bin", "b")
+	s, srv := newTestS3ry(DefaultConfig(), fake)
+	defer srv.Close()
+
+	var posted []PendingRestore
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var restore PendingRestore
+		json.NewDecoder(r.Body).Decode(&restore)
+		posted = append(posted, restore)
+	}))
+	defer hook.Close()
+	dir, err := ioutil.TempDir("", "s3ry")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "restored")
+
+	ctx := context.Background()
+	assert.NoError(t, s.RestoreObject(ctx, "bucket", "hook.bin", 1, "Standard", hook.URL))
+	assert.NoError(t, s.RestoreObject(ctx, "bucket", "cmd.bin", 1, "Bulk", `echo "$S3RY_URI" > `+out))
+	// requesting again only replaces the callback
+	assert.NoError(t, s.RestoreObject(ctx, "bucket", "cmd.bin", 1, "Bulk", `echo "$S3RY_URI" > `+out))
+	assert.Equal(t, 3, fake.count("RESTORE"))
+
+	completed, err := s.CheckRestores(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, completed)
+	assert.Len(t, s.restores.list(), 2)
+
+	fake.finishRestore("bucket", "hook.bin")
+	completed, err = s.CheckRestores(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, completed, 1) && assert.Len(t, posted, 1) {
+		assert.Equal(t, "hook.bin", posted[0].Key)
+	}
+	assert.Len(t, s.restores.list(), 1)
+
+	fake.finishRestore("bucket", "cmd.bin")
+	completed, err = s.CheckRestores(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, completed, 1)
+	b, err := ioutil.ReadFile(out)
+	assert.NoError(t, err)
+	assert.Equal(t, "s3://bucket/cmd.bin\n", string(b))
+	assert.Empty(t, s.restores.list())
+	assert.Len(t, posted, 1)
+}
+
+func TestCheckRestoresRetriesFailedCallback(t *testing.T) {
+	fake := newFakeS3("bucket")
+	fake.put("bucket", "key", "a")
+	s, srv := newTestS3ry(DefaultConfig(), fake)
+	defer srv.Close()
+	status := http.StatusInternalServerError
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer hook.Close()
+
+	ctx := context.Background()
+	assert.NoError(t, s.RestoreObject(ctx, "bucket", "key", 1, "Standard", hook.URL))
+	fake.finishRestore("bucket", "key")
+	_, err := s.CheckRestores(ctx)
+	assert.Error(t, err)
+	assert.Len(t, s.restores.list(), 1)
+
+	status = http.StatusOK
+	completed, err := s.CheckRestores(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, completed, 1)
+}
+
+func TestRestoreJournalPersists(t *testing.T) {
+	dir, err := ioutil.TempDir("", "s3ry")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "restores.json")
+
+	newRestoreJournal(path).add(PendingRestore{Bucket: "bucket", Key: "key", Callback: "true"})
+	j := newRestoreJournal(path)
+	if assert.Len(t, j.list(), 1) {
+		assert.Equal(t, "true", j.list()[0].Callback)
+	}
+	j.remove("bucket", "key")
+	assert.Empty(t, newRestoreJournal(path).list())
+}
+
+func TestRestoredReaction(t *testing.T) {
+	s, srv := newTestS3ry(DefaultConfig(), newFakeS3("bucket"))
+	defer srv.Close()
+	s.restores.add(PendingRestore{Bucket: "bucket", Key: "key", Callback: "true"})
+	reaction, err := s.reaction(ReactionConfig{Type: "restored"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, reaction.React(context.Background(), consumer.Record{EventName: "ObjectCreated:Put", Bucket: "bucket", Key: "key"}))
+	assert.Len(t, s.restores.list(), 1)
+	assert.NoError(t, reaction.React(context.Background(), consumer.Record{EventName: "ObjectRestore:Completed", Bucket: "bucket", Key: "key"}))
+	assert.Empty(t, s.restores.list())
+}
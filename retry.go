@@ -0,0 +1,68 @@
+package s3ry
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/seike460/s3ry/internal/worker"
+)
+
+// retryFailed run retry again for each key of failed, up to Performance.BatchRetries rounds, waiting
+// Performance.BatchRetryBackoff before the first round and twice as long before each next one
+// keys which succeed are removed from failed and the others keep their last error, so only failed work is repeated
+// keys declined or refused as existing objects are final, retrying would only ask or refuse again
+func (s S3ry) retryFailed(ctx context.Context, failed map[string]error, retry func(ctx context.Context, key string) error) error {
+	backoff := time.Duration(s.config().Performance.BatchRetryBackoff)
+	for round := 1; round <= s.config().Performance.BatchRetries; round++ {
+		keys := make([]string, 0, len(failed))
+		for key, err := range failed {
+			if !errors.Is(err, ErrCancelled) && !errors.Is(err, ErrObjectExists) {
+				keys = append(keys, key)
+			}
+		}
+		if len(keys) == 0 {
+			break
+		}
+		sort.Strings(keys)
+		reporter.println(i18nPrinter.Sprintf("Retrying %d failed objects (%d/%d) after %s", len(keys), round, s.config().Performance.BatchRetries, backoff))
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+		backoff *= 2
+
+		var mu sync.Mutex
+		pool := worker.New(ctx, s.config().Performance.Workers)
+		var submitErr error
+		for _, key := range keys {
+			key := key
+			submitErr = pool.Submit(func(ctx context.Context) {
+				err := retry(ctx, key)
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					failed[key] = err
+					return
+				}
+				delete(failed, key)
+			})
+			if submitErr != nil {
+				break
+			}
+		}
+		pool.Wait()
+		if submitErr != nil {
+			return submitErr
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
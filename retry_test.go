@@ -0,0 +1,118 @@
+package s3ry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyHook hook of fakeS3 failing the first failures requests of method to each of paths with AccessDenied,
+// which the SDK doesn't retry by itself
+func flakyHook(method string, failures map[string]int) (func(w http.ResponseWriter, r *http.Request) bool, func(string) int) {
+	var mu sync.Mutex
+	attempts := map[string]int{}
+	hook := func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != method || r.URL.RawQuery != "" {
+			return false
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		attempts[r.URL.Path]++
+		if attempts[r.URL.Path] <= failures[r.URL.Path] {
+			writeFakeError(w, http.StatusForbidden, "AccessDenied")
+			return true
+		}
+		return false
+	}
+	return hook, func(path string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return attempts[path]
+	}
+}
+
+func TestCopyPrefixRetriesFailed(t *testing.T) {
+	fake := newFakeS3("src", "dst")
+	for _, key := range []string{"a", "b", "c", "d"} {
+		fake.put("src", "p/"+key, key)
+	}
+	// b succeeds on the first retry and d on the second
+	hook, attempts := flakyHook(http.MethodPut, map[string]int{"/dst/q/b": 1, "/dst/q/d": 2})
+	fake.hook = hook
+	cfg := DefaultConfig()
+	cfg.Performance.BatchRetries = 2
+	cfg.Performance.BatchRetryBackoff = Duration(time.Millisecond)
+	s, srv := newTestS3ry(cfg, fake)
+	defer srv.Close()
+
+	summary, err := s.CopyPrefix(context.Background(), "src", "p/", "dst", "q/")
+	assert.NoError(t, err)
+	assert.Empty(t, summary.Failed)
+	assert.Equal(t, 4, summary.Copied)
+	assert.Equal(t, int64(4), summary.Bytes)
+	assert.Len(t, fake.keys("dst"), 4)
+	// objects copied the first time aren't copied again
+	assert.Equal(t, 1, attempts("/dst/q/a"))
+	assert.Equal(t, 1, attempts("/dst/q/c"))
+	assert.Equal(t, 2, attempts("/dst/q/b"))
+	assert.Equal(t, 3, attempts("/dst/q/d"))
+}
+
+func TestCopyPrefixRetriesExhausted(t *testing.T) {
+	fake := newFakeS3("src", "dst")
+	fake.put("src", "p/a", "a")
+	fake.put("src", "p/b", "b")
+	hook, attempts := flakyHook(http.MethodPut, map[string]int{"/dst/q/b": 5})
+	fake.hook = hook
+	cfg := DefaultConfig()
+	cfg.Performance.BatchRetryBackoff = Duration(time.Millisecond)
+	s, srv := newTestS3ry(cfg, fake)
+	defer srv.Close()
+
+	// no retries by default
+	summary, err := s.CopyPrefix(context.Background(), "src", "p/", "dst", "q/")
+	assert.NoError(t, err)
+	assert.Len(t, summary.Failed, 1)
+	assert.Equal(t, 1, attempts("/dst/q/b"))
+
+	cfg.Performance.BatchRetries = 2
+	summary, err = s.CopyPrefix(context.Background(), "src", "p/", "dst", "q/")
+	assert.NoError(t, err)
+	if assert.Len(t, summary.Failed, 1) {
+		assert.Contains(t, summary.Failed["p/b"].Error(), "AccessDenied")
+	}
+	assert.Equal(t, 4, attempts("/dst/q/b"))
+}
+
+func TestPutDirectoryRetriesFailed(t *testing.T) {
+	fake := newFakeS3("bucket")
+	// b fails in the first upload and in the first attempt of the second one
+	hook, attempts := flakyHook(http.MethodPut, map[string]int{"/bucket/up/b": 2})
+	fake.hook = hook
+	cfg, done := newFakeEndpoint(t, fake)
+	defer done()
+	dir := newManifestDir(t, map[string]string{"a": "a", "b": "b"})
+	defer os.RemoveAll(dir)
+
+	err := PutDirectory(context.Background(), cfg, dir, "s3://bucket/up/")
+	var partial *PartialError
+	if assert.True(t, errors.As(err, &partial)) {
+		assert.Equal(t, 1, partial.Failed)
+	}
+
+	cfg.Performance.BatchRetries = 1
+	cfg.Performance.BatchRetryBackoff = Duration(time.Millisecond)
+	assert.NoError(t, PutDirectory(context.Background(), cfg, dir, "s3://bucket/up/"))
+	assert.Equal(t, 2, attempts("/bucket/up/a"))
+	assert.Equal(t, 3, attempts("/bucket/up/b"))
+	o, ok := fake.get("bucket", "up/b")
+	if assert.True(t, ok) {
+		assert.Equal(t, "b", string(o.data))
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/manifoldco/promptui"
+	"github.com/seike460/s3ry/internal/events"
 )
 
 // S3ry Service Client Operator
@@ -22,6 +23,19 @@ type S3ry struct {
 	Sess   *session.Session
 	Svc    *s3.S3
 	Bucket string
+	Config *Config
+	// Events receives operation events, nil discards them
+	Events *events.Bus
+	// Checkpoints saves progress of bulk jobs to resume them, nil disables it
+	Checkpoints CheckpointStore
+
+	regions  *regionCache
+	journal  *uploadJournal
+	restores *restoreJournal
+	recent   *recentList
+	history  *historyRecorder
+	// signing region Svc signs for instead of its own, set by forBucket
+	signing string
 }
 
 // ApNortheastOne Japan Region String
@@ -29,15 +43,20 @@ const ApNortheastOne = "ap-northeast-1"
 
 // SelectBucketAndRegion get Region and Bucket
 func SelectBucketAndRegion() (string, string) {
+	return SelectBucketAndRegionWithConfig(DefaultConfig())
+}
+
+// SelectBucketAndRegionWithConfig get Region and Bucket using Config
+func SelectBucketAndRegionWithConfig(cfg *Config) (string, string) {
 
 	// for Bucket Search
-	s3ry := NewS3ry(ApNortheastOne)
+	s3ry := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
 	// show Bucket List & select
 	buckets := s3ry.ListBuckets()
 	selectBucket := s3ry.SelectItem(i18nPrinter.Sprintf("Which bucket do you use?"), buckets)
-	ctx := context.Background()
+	s3ry.recent.touchBucket(selectBucket)
 	// Get bucket's region
-	region, err := s3manager.GetBucketRegion(ctx, s3ry.Sess, selectBucket, ApNortheastOne)
+	region, err := s3ry.BucketRegion(selectBucket)
 	if err != nil {
 		awsErrorPrint(err)
 	}
@@ -46,13 +65,34 @@ func SelectBucketAndRegion() (string, string) {
 
 // NewS3ry Create New S3ry struct
 func NewS3ry(region string) *S3ry {
-	sess := session.Must(session.NewSession(&aws.Config{
-		Region: aws.String(region)},
-	))
-	svc := s3.New(sess)
+	return NewS3ryWithConfig(region, DefaultConfig())
+}
+
+// NewS3ryWithConfig Create New S3ry struct using Config, panics on a config CheckConfig rejects
+func NewS3ryWithConfig(region string, cfg *Config) *S3ry {
+	sess := session.Must(cfg.newSession(region))
+	sess.Handlers.Validate.PushFrontNamed(readOnlyHandler(cfg))
+	journal := newUploadJournal(defaultJournalDir())
+	sess.Handlers.Complete.PushBackNamed(journal.handler())
+	checksum, verifyChecksum := checksumHandlers(cfg)
+	// the body is only set by the service build handlers, so add it right before signing
+	sess.Handlers.Sign.PushFrontNamed(checksum)
+	sess.Handlers.Unmarshal.PushBackNamed(verifyChecksum)
+	rejectedMD5, verifyMD5 := contentMD5Handlers(cfg)
+	sess.Handlers.AfterRetry.PushBackNamed(rejectedMD5)
+	sess.Handlers.Unmarshal.PushBackNamed(verifyMD5)
+	svc := cfg.newS3Client(sess)
 	s := &S3ry{
-		Sess: sess,
-		Svc:  svc,
+		Sess:     sess,
+		Svc:      svc,
+		Config:   cfg,
+		regions:  newRegionCache(),
+		journal:  journal,
+		restores: newRestoreJournal(defaultRestoresPath()),
+		recent:   newRecentList(defaultRecentPath()),
+	}
+	if cfg.Logging.History {
+		s.history = newHistoryRecorder(defaultHistoryPath())
 	}
 	return s
 }
@@ -64,6 +104,14 @@ func (s S3ry) ListOperation() []PromptItems {
 		{Key: 1, Val: i18nPrinter.Sprintf("upload")},
 		{Key: 2, Val: i18nPrinter.Sprintf("delete object")},
 		{Key: 3, Val: i18nPrinter.Sprintf("create object list")},
+		{Key: 4, Val: i18nPrinter.Sprintf("change object ACL")},
+		{Key: 5, Val: i18nPrinter.Sprintf("query object")},
+		{Key: 6, Val: i18nPrinter.Sprintf("edit bucket tags")},
+		{Key: 7, Val: i18nPrinter.Sprintf("copy object URI")},
+	}
+	if s.readOnly() {
+		// hide destructive operations
+		items = []PromptItems{items[0], items[3], items[5], items[7]}
 	}
 	return items
 }
@@ -81,25 +129,18 @@ func (s S3ry) ListBuckets() []PromptItems {
 		items = append(items, PromptItems{Key: key, Val: *val.Name, Tag: "Bucket"})
 	}
 	spe()
-	return items
+	return prioritizeRecent(items, s.recent.buckets())
 }
 
 // ListObjectsPages return ListObjectsPages for PromptItems
+// when listing fails part way the objects listed before are returned
 func (s S3ry) ListObjectsPages(bucket string) []PromptItems {
 	sps(i18nPrinter.Sprintf("Searching for objects ..."))
-	items := []PromptItems{}
-	key := 0
-	err := s.Svc.ListObjectsPages(&s3.ListObjectsInput{Bucket: aws.String(bucket)},
-		func(listObjects *s3.ListObjectsOutput, lastPage bool) bool {
-			for _, item := range listObjects.Contents {
-				if strings.HasSuffix(*item.Key, "/") == false {
-					items = append(items, PromptItems{Key: key, Val: *item.Key, LastModified: *item.LastModified, Tag: "Object"})
-					key++
-				}
-			}
-			return !lastPage
-		})
-	if err != nil {
+	items, err := s.ListObjectItems(context.Background(), bucket, "")
+	if lerr, ok := err.(*ListError); ok && len(items) > 0 {
+		spe()
+		fmt.Println(i18nPrinter.Sprintf("Listing stopped after% d objects:% s", len(items), lerr.Err.Error()))
+	} else if err != nil {
 		awsErrorPrint(err)
 	}
 	// @todo 並び替えオプションをフラグをグローバルにもたせて、KeyでもSort出来るようにする
@@ -114,29 +155,51 @@ func (s S3ry) ListObjectsPages(bucket string) []PromptItems {
 func (s S3ry) ListObjects(bucket string) []PromptItems {
 	items := S3ry.ListObjectsPages(s, bucket)
 	fmt.Println(i18nPrinter.Sprintf("Number of objects: "), len(items))
-	return items
+	return prioritizeRecent(items, s.recent.objects(bucket))
 }
 
 // GetObject get Object from S3 bucket
-func (s S3ry) GetObject(bucket string, objectKey string) {
-	sps(i18nPrinter.Sprintf("Downloading object ..."))
-	filename := filepath.Base(objectKey)
-	file, err := os.Create(filename)
-	if err != nil {
-		awsErrorPrint(err)
+func (s S3ry) GetObject(bucket string, objectKey string) (err error) {
+	done := s.track("download", bucket, objectKey)
+	defer func() { done(err) }()
+	if s, err = s.forBucket(bucket); err != nil {
+		return err
 	}
-	defer file.Close()
+	sps(i18nPrinter.Sprintf("Downloading object ..."))
+	defer spe()
 	inputGet := &s3.GetObjectInput{
 		Bucket: aws.String(bucket),
 		Key:    aws.String(objectKey),
 	}
-	downloader := s3manager.NewDownloader(s.Sess)
-	result, err := downloader.Download(file, inputGet)
+	if err := s.config().Encryption.applyDownload(inputGet); err != nil {
+		return err
+	}
+	filename, err := loadKeyNames(defaultKeyNamesPath()).localName(s.config(), "", objectKey, os.Stdout)
 	if err != nil {
-		awsErrorPrint(err)
+		return err
+	}
+	head, err := s.Svc.HeadObject(&s3.HeadObjectInput{
+		Bucket:               inputGet.Bucket,
+		Key:                  inputGet.Key,
+		SSECustomerAlgorithm: inputGet.SSECustomerAlgorithm,
+		SSECustomerKey:       inputGet.SSECustomerKey,
+	})
+	if err != nil {
+		return err
+	}
+	var result int64
+	if size := aws.Int64Value(head.ContentLength); size > s.config().Performance.MultipartDownloadThreshold {
+		result, err = s.downloadRanges(context.Background(), inputGet, size, aws.StringValue(head.ETag), filename)
+	} else {
+		result, err = s.downloadStream(inputGet, filename)
 	}
+	if err != nil {
+		return err
+	}
+	s.recent.touchObject(bucket, objectKey)
 	spe()
 	fmt.Println(i18nPrinter.Sprintf("File downloaded,% s,% d bytes", filename, result))
+	return nil
 }
 
 // ListUpload return ListUpload for PromptItems
@@ -150,45 +213,74 @@ func (s S3ry) ListUpload(bucket string) []PromptItems {
 }
 
 // UploadObject put Object in S3 bucket
-func (s S3ry) UploadObject(bucket string, selectUpload string) {
-	sps(i18nPrinter.Sprintf("Uploading object ..."))
-	uploadObject := selectUpload
-	uploader := s3manager.NewUploader(s.Sess)
-	f, err := os.Open(uploadObject)
+func (s S3ry) UploadObject(bucket string, selectUpload string) (err error) {
+	done := s.track("upload", bucket, selectUpload)
+	defer func() { done(err) }()
+	// a file downloaded under a sanitized name is uploaded to its original key name
+	uploadObject := strings.TrimSuffix(selectUpload, filepath.Base(selectUpload)) + loadKeyNames(defaultKeyNamesPath()).original(selectUpload)
+	input := &s3manager.UploadInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(uploadObject),
+	}
+	bc := s.config().bucketConfig(bucket)
+	if err := bc.applyUpload(input); err != nil {
+		return err
+	}
+	s.config().applyExpiry(input, time.Now())
+	if err := confirmACL(bc.ACL, "s3://"+bucket+"/"+aws.StringValue(input.Key), askStdin); err != nil {
+		return err
+	}
+	f, err := os.Open(selectUpload)
 	if err != nil {
-		awsErrorPrint(err)
+		return err
 	}
 	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := s.checkUploadSize(uploadObject, info.Size()); err != nil {
+		return err
+	}
+	if input.ContentType == nil {
+		head, err := sniff(f)
+		if err != nil {
+			return err
+		}
+		if t := s.config().detectContentType(uploadObject, head); t != "" {
+			input.ContentType = aws.String(t)
+		}
+	}
+	if s, err = s.forBucket(bucket); err != nil {
+		return err
+	}
+	if err := s.guardOverwrite(context.Background(), bucket, aws.StringValue(input.Key), askStdin); err != nil {
+		return err
+	}
+	sps(i18nPrinter.Sprintf("Uploading object ..."))
+	defer spe()
+	input.Body = &progressReader{r: f, publish: s.progress("upload", bucket, uploadObject, info.Size())}
 
-	_, err = uploader.Upload(&s3manager.UploadInput{
-		Bucket: aws.String(bucket),
-		Key:    aws.String(uploadObject),
-		Body:   f,
-	})
+	err = s.upload(context.Background(), "upload", input)
 	if err != nil {
-		awsErrorPrint(err)
+		return err
 	}
+	s.recent.touchObject(bucket, aws.StringValue(input.Key))
 	spe()
 	fmt.Println(i18nPrinter.Sprintf("Uploaded file,% s", uploadObject))
+	return nil
 }
 
 // SelectItem select PromptItems using promptui
 func (s S3ry) SelectItem(label string, items []PromptItems) string {
-	detail := "{{\"Selection Value\" | faint }} {{ .Val }}"
-
+	details := [][2]string{{"Selection Value", ".Val"}}
 	for _, item := range items {
 		if item.Tag == "Object" {
-			detail = "{{\"Selection Value:\" | faint }} {{ .Val }}\n{{\"LastModified:\" | faint }} {{ .LastModified }}"
+			details = [][2]string{{"Selection Value:", ".Val"}, {"LastModified:", ".Modified"}, {"Size:", ".SizeText"}}
 		}
 		break
 	}
-	templates := &promptui.SelectTemplates{
-		Label:    "{{ . }}",
-		Active:   "->{{ .Val | red }}",
-		Inactive: "{{ .Val | cyan }}",
-		Selected: i18nPrinter.Sprintf("\"Selection Value:\" {{ .Val | red | cyan }}"),
-		Details:  detail,
-	}
+	templates := s.config().theme().selectTemplates(details)
 
 	searcher := func(input string, index int) bool {
 		item := items[index]
@@ -214,16 +306,25 @@ func (s S3ry) SelectItem(label string, items []PromptItems) string {
 }
 
 // DeleteObject delete Object from S3 bucket
-func (s S3ry) DeleteObject(bucket string, item string) {
+func (s S3ry) DeleteObject(bucket string, item string) (err error) {
+	done := s.track("delete", bucket, item)
+	defer func() { done(err) }()
+	if err := s.config().confirmDestructive(false, i18nPrinter.Sprintf("Delete the object? Object:% s, [Yy] / [Nn]", "s3://"+bucket+"/"+item)); err != nil {
+		return err
+	}
+	if s, err = s.forBucket(bucket); err != nil {
+		return err
+	}
 	input := &s3.DeleteObjectInput{
 		Bucket: aws.String(bucket),
 		Key:    aws.String(item),
 	}
-	_, err := s.Svc.DeleteObject(input)
+	_, err = s.Svc.DeleteObject(input)
 	if err != nil {
-		awsErrorPrint(err)
+		return err
 	}
 	fmt.Printf("File deleted")
+	return nil
 }
 
 // SaveObjectList create S3 ObjectList And SaveList
@@ -247,8 +348,16 @@ func (s S3ry) SaveObjectList(bucket string) {
 
 // Operations for Another package
 func Operations(region string, bucket string) {
-	s := NewS3ry(region)
+	OperationsWithConfig(DefaultConfig(), region, bucket)
+}
+
+// OperationsWithConfig Operations using Config
+func OperationsWithConfig(cfg *Config, region string, bucket string) {
+	s := NewS3ryWithConfig(region, cfg)
 	s.Bucket = bucket
+	s.Events = events.NewBus()
+	s.Events.Subscribe(spinnerProgress)
+	defer s.Events.Close()
 	// show Bucket List & select
 	operations := s.ListOperation()
 	selectOperation := s.SelectItem(i18nPrinter.Sprintf("What are you doing?"), operations)
@@ -257,20 +366,56 @@ func Operations(region string, bucket string) {
 	case i18nPrinter.Sprintf("upload"):
 		uploadItem := s.ListUpload(s.Bucket)
 		selectUpload := s.SelectItem(i18nPrinter.Sprintf("Which file do you upload?"), uploadItem)
-		s.UploadObject(s.Bucket, selectUpload)
+		if err := s.UploadObject(s.Bucket, selectUpload); err != nil {
+			awsErrorPrint(err)
+		}
 	case i18nPrinter.Sprintf("create object list"):
 		s.SaveObjectList(s.Bucket)
+	case i18nPrinter.Sprintf("change object ACL"):
+		items := s.ListObjectsPages(s.Bucket)
+		item := s.SelectItem(i18nPrinter.Sprintf("Which object do you change the ACL of?"), items)
+		if err := s.ChangeObjectACL(s.Bucket, item); err != nil {
+			awsErrorPrint(err)
+		}
+	case i18nPrinter.Sprintf("copy object URI"):
+		items := s.ListObjectsPages(s.Bucket)
+		item := s.SelectItem(i18nPrinter.Sprintf("Which object's URI do you copy?"), items)
+		s.printObjectURIs(s.Bucket, item, os.Stdout)
+	case i18nPrinter.Sprintf("edit bucket tags"):
+		if err := s.EditBucketTags(s.Bucket); err != nil {
+			awsErrorPrint(err)
+		}
+	case i18nPrinter.Sprintf("query object"):
+		var items []PromptItems
+		for _, item := range s.ListObjectsPages(s.Bucket) {
+			if Selectable(item.Val) {
+				item.Key = len(items)
+				items = append(items, item)
+			}
+		}
+		if len(items) == 0 {
+			fmt.Println(i18nPrinter.Sprintf("No CSV, JSON or Parquet objects"))
+			return
+		}
+		item := s.SelectItem(i18nPrinter.Sprintf("Which object do you query?"), items)
+		if err := s.QueryObject(s.Bucket, item); err != nil {
+			awsErrorPrint(err)
+		}
 	case i18nPrinter.Sprintf("delete object"):
 		items := s.ListObjectsPages(s.Bucket)
 		item := s.SelectItem(i18nPrinter.Sprintf("Which files do you want to delete?"), items)
-		s.DeleteObject(s.Bucket, item)
+		if err := s.DeleteObject(s.Bucket, item); err != nil {
+			awsErrorPrint(err)
+		}
 	default:
 		// show Object List & select
 		items := s.ListObjects(s.Bucket)
 		selectObject := s.SelectItem(i18nPrinter.Sprintf("Which file do you want to download?"), items)
 		// check File
-		checkLocalExists(selectObject)
+		s.checkLocalExists(selectObject)
 		// GetObject
-		s.GetObject(s.Bucket, selectObject)
+		if err := s.GetObject(s.Bucket, selectObject); err != nil {
+			awsErrorPrint(err)
+		}
 	}
 }
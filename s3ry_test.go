@@ -1,11 +1,60 @@
 package s3ry
 
 import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"testing"
 
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/seike460/s3ry/internal/events"
+	"github.com/seike460/s3ry/internal/i18n"
 	"github.com/stretchr/testify/assert"
 )
 
+// newTestS3ry create S3ry which sends requests to handler
+func newTestS3ry(cfg *Config, handler http.Handler) (*S3ry, *httptest.Server) {
+	srv := httptest.NewTLSServer(handler)
+	s := NewS3ryWithConfig(ApNortheastOne, cfg)
+	s.Sess.Config.
+		WithEndpoint(srv.URL).
+		WithHTTPClient(srv.Client()).
+		WithS3ForcePathStyle(true).
+		WithCredentials(credentials.NewStaticCredentials("AKID", "SECRET", ""))
+	s.Svc = s.config().newS3Client(s.Sess)
+	// keep the upload journal, recent list and history out of the user's config dir
+	s.journal.dir = ""
+	s.restores.path = ""
+	s.recent.path = ""
+	s.history = nil
+	return s, srv
+}
+
+// newFakeEndpoint serve handler over HTTP and return Config using it as Endpoint, for the package level commands
+// the config dir and temp dir point to a new directory until the returned func is called
+func newFakeEndpoint(t *testing.T, handler http.Handler) (*Config, func()) {
+	home, err := ioutil.TempDir("", "s3ry-home")
+	assert.NoError(t, err)
+	xdg := os.Getenv("XDG_CONFIG_HOME")
+	os.Setenv("XDG_CONFIG_HOME", home)
+	srv := httptest.NewServer(handler)
+	cfg := DefaultConfig()
+	cfg.AWS.Endpoint = srv.URL
+	cfg.AWS.AccessKeyID = "AKID"
+	cfg.AWS.SecretAccessKey = "SECRET"
+	cfg.Performance.TempDir = home
+	// output doesn't depend on the locale of the environment
+	saved := locale
+	locale = i18n.POSIX
+	return cfg, func() {
+		locale = saved
+		srv.Close()
+		os.Setenv("XDG_CONFIG_HOME", xdg)
+		os.RemoveAll(home)
+	}
+}
+
 func TestNewS3ry(t *testing.T) {
 	s := NewS3ry(ApNortheastOne)
 	operations := s.ListOperation()
@@ -17,10 +66,61 @@ func TestNewS3ry(t *testing.T) {
 	items := s.ListObjectsPages("seike460-gotest")
 	selectObject := s.ListObjects("seike460-gotest")
 	s.DeleteObject("seike460-gotest", "testUploadFile")
-	checkLocalExists("testNothingsFile")
+	s.checkLocalExists("testNothingsFile")
 	assert.NotNil(t, operations)
 	assert.NotNil(t, buckets)
 	assert.NotNil(t, uploadItem)
 	assert.NotNil(t, items)
 	assert.NotNil(t, selectObject)
 }
+
+func TestUploadObjectPublishesEvents(t *testing.T) {
+	s, srv := newTestS3ry(DefaultConfig(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+	s.Events = events.NewBus()
+	var received []events.Event
+	s.Events.Subscribe(func(e events.Event) {
+		received = append(received, e)
+	})
+
+	f, err := ioutil.TempFile("", "s3ry")
+	assert.NoError(t, err)
+	f.WriteString("0123456789")
+	f.Close()
+	defer os.Remove(f.Name())
+
+	assert.NoError(t, s.UploadObject("bucket", f.Name()))
+	s.Events.Close()
+
+	if assert.True(t, len(received) >= 3) {
+		assert.Equal(t, events.Started, received[0].Type)
+		last := received[len(received)-2]
+		assert.Equal(t, events.Progress, last.Type)
+		assert.Equal(t, int64(10), last.Bytes)
+		assert.Equal(t, int64(10), last.Total)
+		assert.Equal(t, events.Completed, received[len(received)-1].Type)
+		assert.Equal(t, "upload", received[0].Operation)
+	}
+}
+
+func TestDeleteObjectPublishesFailed(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Security.ReadOnly = true
+	s, srv := newTestS3ry(cfg, http.NotFoundHandler())
+	defer srv.Close()
+	s.Events = events.NewBus()
+	var received []events.Event
+	s.Events.Subscribe(func(e events.Event) {
+		received = append(received, e)
+	})
+
+	err := s.DeleteObject("bucket", "key")
+	s.Events.Close()
+
+	if assert.Len(t, received, 2) {
+		assert.Equal(t, events.Failed, received[1].Type)
+		assert.Equal(t, err, received[1].Err)
+	}
+}
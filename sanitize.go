@@ -0,0 +1,150 @@
+package s3ry
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// KeySanitizer map the last segment of an object key to a file name safe on the local file system
+type KeySanitizer func(name string) string
+
+// defaultKeySanitizer name of the sanitizer used when KeySanitizer is empty
+const defaultKeySanitizer = "portable"
+
+// keySanitizers sanitizers by KeySanitizer
+var keySanitizers = map[string]KeySanitizer{
+	defaultKeySanitizer: sanitizePortable,
+	"none":              func(name string) string { return name },
+}
+
+// RegisterKeySanitizer add sanitizer usable as KeySanitizer, e.g. one for a file system with other rules
+func RegisterKeySanitizer(name string, sanitizer KeySanitizer) {
+	keySanitizers[name] = sanitizer
+}
+
+// windowsReserved device names Windows refuses as file names, with any extension
+var windowsReserved = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// sanitizePortable replace characters illegal on Windows, macOS or Linux with _ and rename reserved names,
+// so the same key downloads to the same name everywhere
+func sanitizePortable(name string) string {
+	b := []rune(name)
+	for i, r := range b {
+		if r < 0x20 || strings.ContainsRune(`<>:"/\|?*`, r) {
+			b[i] = '_'
+		}
+	}
+	name = string(b)
+	// Windows drops trailing dots and spaces
+	if trimmed := strings.TrimRight(name, ". "); trimmed != name {
+		name = trimmed + strings.Repeat("_", len(name)-len(trimmed))
+	}
+	base := strings.ToUpper(strings.SplitN(name, ".", 2)[0])
+	if windowsReserved[base] {
+		name = "_" + name
+	}
+	if name == "" {
+		name = "_"
+	}
+	return name
+}
+
+// sanitizeKeyName return local file name of object key with the configured sanitizer
+func (c *Config) sanitizeKeyName(key string) (string, error) {
+	name := c.KeySanitizer
+	if name == "" {
+		name = defaultKeySanitizer
+	}
+	sanitizer, ok := keySanitizers[name]
+	if !ok {
+		return "", fmt.Errorf("KeySanitizer: unknown sanitizer %q", name)
+	}
+	return sanitizer(path.Base(key)), nil
+}
+
+// keyNames original key names of downloaded files whose name was sanitized, by absolute local path
+// uploads use them, so a file downloaded and uploaded again keeps its key
+type keyNames struct {
+	path  string
+	names map[string]string
+}
+
+// defaultKeyNamesPath return path of the key names next to the config file
+func defaultKeyNamesPath() string {
+	return configDirFile("keynames.json")
+}
+
+// loadKeyNames load key names from path, an empty path keeps them in memory only
+func loadKeyNames(path string) *keyNames {
+	n := &keyNames{path: path, names: map[string]string{}}
+	if path != "" {
+		if b, err := ioutil.ReadFile(path); err == nil {
+			json.Unmarshal(b, &n.names)
+		}
+	}
+	return n
+}
+
+// localName return file name in dir for object key, recording the original name when it was sanitized
+// a name another key was already sanitized to is reported to w, as the downloads would overwrite each other
+func (n *keyNames) localName(cfg *Config, dir string, key string, w io.Writer) (string, error) {
+	original := path.Base(key)
+	name, err := cfg.sanitizeKeyName(key)
+	if err != nil {
+		return "", err
+	}
+	local := filepath.Join(dir, name)
+	abs, err := filepath.Abs(local)
+	if err != nil {
+		return "", err
+	}
+	other, ok := n.names[abs]
+	if ok && other != original {
+		fmt.Fprintln(w, i18nPrinter.Sprintf("Warning: %s and %s are both downloaded to %s", other, original, local))
+	}
+	if name == original {
+		if ok {
+			delete(n.names, abs)
+			n.save()
+		}
+		return local, nil
+	}
+	n.names[abs] = original
+	n.save()
+	return local, nil
+}
+
+// original return key name of local file, the name it was downloaded from when it was sanitized
+func (n *keyNames) original(file string) string {
+	if abs, err := filepath.Abs(file); err == nil {
+		if name, ok := n.names[abs]; ok {
+			return name
+		}
+	}
+	return filepath.Base(file)
+}
+
+// save write key names, like the download manifest it is best effort
+func (n *keyNames) save() {
+	if n.path == "" {
+		return
+	}
+	b, err := json.Marshal(n.names)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(n.path), 0700); err != nil {
+		return
+	}
+	ioutil.WriteFile(n.path, b, 0600)
+}
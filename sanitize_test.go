@@ -0,0 +1,95 @@
+package s3ry
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitizePortable(t *testing.T) {
+	tests := map[string]string{
+		"report.csv":    "report.csv",
+		"12:00:00.log":  "12_00_00.log",
+		`back\slash`:    "back_slash",
+		`a<b>c"d|e?f*g`: "a_b_c_d_e_f_g",
+		"tab\there":     "tab_here",
+		"CON":           "_CON",
+		"nul.txt":       "_nul.txt",
+		"com1.tar.gz":   "_com1.tar.gz",
+		"console":       "console",
+		"trailing. . ":  "trailing____",
+		"日本語:ファイル.txt":  "日本語_ファイル.txt",
+	}
+	for name, want := range tests {
+		assert.Equal(t, want, sanitizePortable(name), name)
+	}
+}
+
+func TestKeyNamesReversible(t *testing.T) {
+	dir, err := ioutil.TempDir("", "s3ry-keynames")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	cfg := DefaultConfig()
+	path := filepath.Join(dir, "keynames.json")
+	names := loadKeyNames(path)
+
+	var warnings bytes.Buffer
+	local, err := names.localName(cfg, dir, "logs/12:00.log", &warnings)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "12_00.log"), local)
+	local, err = names.localName(cfg, dir, "logs/plain.log", &warnings)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "plain.log"), local)
+	assert.Empty(t, warnings.String())
+
+	// kept across runs
+	names = loadKeyNames(path)
+	assert.Equal(t, "12:00.log", names.original(filepath.Join(dir, "12_00.log")))
+	assert.Equal(t, "plain.log", names.original(filepath.Join(dir, "plain.log")))
+
+	_, err = names.localName(cfg, dir, "logs/12?00.log", &warnings)
+	assert.NoError(t, err)
+	assert.Contains(t, warnings.String(), "12:00.log and 12?00.log are both downloaded to")
+	assert.Equal(t, "12?00.log", names.original(filepath.Join(dir, "12_00.log")))
+
+	cfg.KeySanitizer = "none"
+	local, err = names.localName(cfg, dir, "logs/a:b", &warnings)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "a:b"), local)
+	cfg.KeySanitizer = "unknown"
+	_, err = names.localName(cfg, dir, "logs/a:b", &warnings)
+	assert.Error(t, err)
+
+	RegisterKeySanitizer("upper", strings.ToUpper)
+	cfg.KeySanitizer = "upper"
+	local, err = names.localName(cfg, dir, "logs/a.txt", &warnings)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "A.TXT"), local)
+	assert.Equal(t, "a.txt", names.original(local))
+}
+
+func TestGetIntoDirectoryAndPutBack(t *testing.T) {
+	fake := newFakeS3("bucket")
+	fake.put("bucket", "in/2020-01-01T00:00:00.json", "{}")
+	cfg, done := newFakeEndpoint(t, fake)
+	defer done()
+	dir, err := ioutil.TempDir("", "s3ry-download")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, Get(context.Background(), cfg, "s3://bucket/in/2020-01-01T00:00:00.json", dir))
+	local := filepath.Join(dir, "2020-01-01T00_00_00.json")
+	b, err := ioutil.ReadFile(local)
+	assert.NoError(t, err)
+	assert.Equal(t, "{}", string(b))
+
+	assert.NoError(t, Put(context.Background(), cfg, local, "s3://bucket/out/"))
+	_, ok := fake.get("bucket", "out/2020-01-01T00:00:00.json")
+	assert.True(t, ok)
+}
@@ -0,0 +1,124 @@
+package s3ry
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// ErrCodeReadOnly error code for operations rejected in read-only mode
+const ErrCodeReadOnly = "ReadOnly"
+
+// ErrUploadTooLarge upload rejected by Security.MaxUploadBytes
+var ErrUploadTooLarge = errors.New("file is larger than Security.MaxUploadBytes")
+
+// ErrCancelled operation declined by the user
+var ErrCancelled = errors.New("cancelled")
+
+// Security.ConfirmDestructive levels
+const (
+	// ConfirmNever never ask
+	ConfirmNever = "never"
+	// ConfirmBulkOnly ask before operations on many objects, like cp --recursive
+	ConfirmBulkOnly = "bulk-only"
+	// ConfirmAlways ask before every delete or overwrite
+	ConfirmAlways = "always"
+)
+
+// mutatingPrefixes S3 API operation name prefixes which modify S3
+var mutatingPrefixes = []string{
+	"Abort",
+	"Complete",
+	"Copy",
+	"Create",
+	"Delete",
+	"Put",
+	"Restore",
+	"Upload",
+}
+
+// isMutating check S3 API operation modifies S3
+func isMutating(operation string) bool {
+	for _, prefix := range mutatingPrefixes {
+		if strings.HasPrefix(operation, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// readOnlyHandler reject mutating requests before they are sent
+// it is installed on the session, so every client created from it is covered
+func readOnlyHandler(cfg *Config) request.NamedHandler {
+	return request.NamedHandler{
+		Name: "s3ry.ReadOnlyHandler",
+		Fn: func(r *request.Request) {
+			if cfg.Security.ReadOnly && isMutating(r.Operation.Name) {
+				r.Error = awserr.New(ErrCodeReadOnly, r.Operation.Name+" is disabled in read-only mode", nil)
+			}
+		},
+	}
+}
+
+// readOnly check S3ry is in read-only mode
+func (s S3ry) readOnly() bool {
+	return s.config().Security.ReadOnly
+}
+
+// confirmDestructive ask message before deleting or overwriting by Security.ConfirmDestructive
+// bulk is set for operations on many objects; every destructive path calls it
+func (c *Config) confirmDestructive(bulk bool, message string) error {
+	return c.confirmDestructiveWith(bulk, message, askStdin)
+}
+
+// confirmDestructiveWith confirmDestructive asking with ask, for commands whose stdin carries data
+func (c *Config) confirmDestructiveWith(bulk bool, message string, ask askFunc) error {
+	security := c.Security
+	// read-only mode rejects the operation anyway
+	if security.AssumeYes || security.ReadOnly {
+		return nil
+	}
+	switch security.ConfirmDestructive {
+	case ConfirmNever:
+		return nil
+	case ConfirmBulkOnly:
+		if !bulk {
+			return nil
+		}
+	}
+	yes, err := ask(message)
+	if err != nil {
+		return err
+	}
+	if !yes {
+		return ErrCancelled
+	}
+	return nil
+}
+
+// confirmOverwrite ask before overwriting local file filename when it exists
+func (c *Config) confirmOverwrite(filename string) error {
+	if _, err := os.Stat(filename); err != nil {
+		return nil
+	}
+	return c.confirmDestructive(false, i18nPrinter.Sprintf("The file exists. Overwrite? File name:% s, [Yy] / [Nn]", filename))
+}
+
+// checkUploadSize enforce Security.MaxUploadBytes and ask above Security.WarnUploadBytes
+// every upload of a file calls it before transferring, uploads of unknown size are limited by limitedReader
+func (s S3ry) checkUploadSize(name string, size int64) error {
+	security := s.config().Security
+	if security.MaxUploadBytes > 0 && size > security.MaxUploadBytes {
+		return fmt.Errorf("%s is %d bytes, limit is %d bytes: %w", name, size, security.MaxUploadBytes, ErrUploadTooLarge)
+	}
+	if security.WarnUploadBytes > 0 && size > security.WarnUploadBytes {
+		if !confirm(i18nPrinter.Sprintf("The file is large. Upload? File name:% s,% d bytes, [Yy] / [Nn]", name, size)) {
+			return ErrCancelled
+		}
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+package s3ry
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadOnlyBlocksMutatingOperations(t *testing.T) {
+	requests := 0
+	cfg := DefaultConfig()
+	cfg.Security.ReadOnly = true
+	s, srv := newTestS3ry(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			requests++
+		}
+	}))
+	defer srv.Close()
+
+	f, err := ioutil.TempFile("", "s3ry")
+	assert.NoError(t, err)
+	f.Close()
+	defer os.Remove(f.Name())
+
+	assertReadOnly := func(err error) {
+		aerr, ok := err.(awserr.Error)
+		if assert.True(t, ok, "%v", err) {
+			assert.Equal(t, ErrCodeReadOnly, aerr.Code())
+		}
+	}
+	assertReadOnly(s.UploadObject("bucket", f.Name()))
+	assertReadOnly(s.DeleteObject("bucket", "key"))
+	_, err = s.Svc.CopyObject(&s3.CopyObjectInput{
+		Bucket:     aws.String("bucket"),
+		Key:        aws.String("dst"),
+		CopySource: aws.String("bucket/src"),
+	})
+	assertReadOnly(err)
+	_, err = s.Svc.PutObjectTagging(&s3.PutObjectTaggingInput{
+		Bucket:  aws.String("bucket"),
+		Key:     aws.String("key"),
+		Tagging: &s3.Tagging{TagSet: []*s3.Tag{}},
+	})
+	assertReadOnly(err)
+	assert.Equal(t, 0, requests)
+
+	for _, item := range s.ListOperation() {
+		assert.NotEqual(t, "upload", item.Val)
+		assert.NotEqual(t, "delete object", item.Val)
+		assert.NotEqual(t, "change object ACL", item.Val)
+	}
+}
+
+func TestReadOnlyAllowsReadOperations(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Security.ReadOnly = true
+	s, srv := newTestS3ry(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<ListBucketResult><Name>bucket</Name><IsTruncated>false</IsTruncated>` +
+			`<Contents><Key>a.txt</Key><LastModified>2020-01-01T00:00:00.000Z</LastModified><Size>1</Size></Contents>` +
+			`</ListBucketResult>`))
+	}))
+	defer srv.Close()
+
+	items := s.ListObjectsPages("bucket")
+	assert.Len(t, items, 1)
+	assert.Equal(t, "a.txt", items[0].Val)
+}
+
+func TestUploadObjectSizeLimits(t *testing.T) {
+	fake := newFakeS3("bucket")
+	cfg := DefaultConfig()
+	cfg.Security.MaxUploadBytes = 10
+	cfg.Security.WarnUploadBytes = 5
+	s, srv := newTestS3ry(cfg, fake)
+	defer srv.Close()
+
+	var asked []string
+	answer := true
+	defer func(c func(string) bool) { confirm = c }(confirm)
+	confirm = func(message string) bool {
+		asked = append(asked, message)
+		return answer
+	}
+
+	write := func(size int) string {
+		f, err := ioutil.TempFile("", "s3ry")
+		assert.NoError(t, err)
+		f.Write(make([]byte, size))
+		f.Close()
+		return f.Name()
+	}
+
+	// under the warning threshold uploads without asking
+	small := write(5)
+	defer os.Remove(small)
+	assert.NoError(t, s.UploadObject("bucket", small))
+	assert.Empty(t, asked)
+
+	// over the warning threshold asks and uploads when confirmed
+	large := write(8)
+	defer os.Remove(large)
+	assert.NoError(t, s.UploadObject("bucket", large))
+	assert.Len(t, asked, 1)
+
+	answer = false
+	err := s.UploadObject("bucket", large)
+	assert.Equal(t, ErrCancelled, err)
+	assert.Len(t, asked, 2)
+
+	// over the limit is rejected before transferring
+	tooLarge := write(11)
+	defer os.Remove(tooLarge)
+	err = s.UploadObject("bucket", tooLarge)
+	assert.True(t, errors.Is(err, ErrUploadTooLarge), "%v", err)
+	assert.Len(t, asked, 2)
+	assert.Equal(t, 2, fake.count("PUT"))
+	_, ok := fake.get("bucket", tooLarge)
+	assert.False(t, ok)
+}
+
+func TestConfirmDestructive(t *testing.T) {
+	var asked int
+	defer func(c func(string) bool) { confirm = c }(confirm)
+	confirm = func(message string) bool {
+		asked++
+		return false
+	}
+
+	for _, c := range []struct {
+		level     string
+		assumeYes bool
+		single    bool
+		bulk      bool
+	}{
+		{level: ConfirmNever},
+		{level: ConfirmBulkOnly, bulk: true},
+		{level: ConfirmAlways, single: true, bulk: true},
+		{level: ConfirmAlways, assumeYes: true},
+	} {
+		cfg := DefaultConfig()
+		cfg.Security.ConfirmDestructive = c.level
+		cfg.Security.AssumeYes = c.assumeYes
+		for _, bulk := range []bool{false, true} {
+			asked = 0
+			expected := c.single
+			if bulk {
+				expected = c.bulk
+			}
+			err := cfg.confirmDestructive(bulk, "delete?")
+			if expected {
+				assert.Equal(t, 1, asked, "%s bulk %v", c.level, bulk)
+				assert.Equal(t, ErrCancelled, err)
+			} else {
+				assert.Equal(t, 0, asked, "%s bulk %v", c.level, bulk)
+				assert.NoError(t, err)
+			}
+		}
+	}
+}
+
+func TestDestructiveOperationsAsk(t *testing.T) {
+	fake := newFakeS3("bucket")
+	fake.put("bucket", "key", "data")
+	cfg, done := newFakeEndpoint(t, fake)
+	defer done()
+
+	var asked []string
+	answer := false
+	defer func(c func(string) bool) { confirm = c }(confirm)
+	confirm = func(message string) bool {
+		asked = append(asked, message)
+		return answer
+	}
+
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	assert.Equal(t, ErrCancelled, s.DeleteObject("bucket", "key"))
+	_, ok := fake.get("bucket", "key")
+	assert.True(t, ok)
+
+	dst := filepath.Join(cfg.Performance.TempDir, "key")
+	assert.NoError(t, ioutil.WriteFile(dst, []byte("local"), 0600))
+	assert.Equal(t, ErrCancelled, Get(context.Background(), cfg, "s3://bucket/key", dst))
+	b, _ := ioutil.ReadFile(dst)
+	assert.Equal(t, "local", string(b))
+
+	assert.Equal(t, ErrCancelled, Copy(context.Background(), cfg, "s3://bucket/", "s3://bucket/copy/", true, false))
+	assert.Equal(t, 0, fake.count("COPY"))
+	assert.Len(t, asked, 3)
+
+	// --yes skips every question
+	cfg.Security.AssumeYes = true
+	assert.NoError(t, Get(context.Background(), cfg, "s3://bucket/key", dst))
+	assert.NoError(t, s.DeleteObject("bucket", "key"))
+	assert.Len(t, asked, 3)
+	_, ok = fake.get("bucket", "key")
+	assert.False(t, ok)
+}
+
+func TestPutFileSizeLimits(t *testing.T) {
+	fake := newFakeS3("bucket")
+	cfg, done := newFakeEndpoint(t, fake)
+	defer done()
+	cfg.Security.MaxUploadBytes = 10
+	cfg.Security.WarnUploadBytes = 5
+	ctx := context.Background()
+
+	var asked []string
+	defer func(c func(string) bool) { confirm = c }(confirm)
+	confirm = func(message string) bool {
+		asked = append(asked, message)
+		return false
+	}
+	write := func(name string, size int) string {
+		path := filepath.Join(cfg.Performance.TempDir, name)
+		assert.NoError(t, ioutil.WriteFile(path, make([]byte, size), 0644))
+		return path
+	}
+
+	// put, put of several files and put --recursive ask above the warning threshold
+	assert.Equal(t, ErrCancelled, Put(ctx, cfg, write("large", 8), "s3://bucket/large"))
+	assert.Len(t, asked, 1)
+	err := PutFiles(ctx, cfg, []string{write("small", 3), write("large", 8)}, "s3://bucket/in/", "")
+	assert.Equal(t, ExitPartial, ExitCode(err))
+	assert.Len(t, asked, 2)
+	assert.Equal(t, []string{"in/small"}, fake.keys("bucket"))
+	dir := filepath.Join(cfg.Performance.TempDir, "dir")
+	assert.NoError(t, os.Mkdir(dir, 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "large"), make([]byte, 8), 0644))
+	err = PutDirectory(ctx, cfg, dir, "s3://bucket/dir/")
+	assert.Equal(t, ExitPartial, ExitCode(err))
+	assert.Len(t, asked, 3)
+
+	// over the limit nothing is sent
+	err = Put(ctx, cfg, write("huge", 11), "s3://bucket/huge")
+	assert.True(t, errors.Is(err, ErrUploadTooLarge), "%v", err)
+	assert.Len(t, asked, 3)
+	_, ok := fake.get("bucket", "huge")
+	assert.False(t, ok)
+	assert.Equal(t, 0, fake.count("CREATE_MULTIPART"))
+}
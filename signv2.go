@@ -0,0 +1,127 @@
+package s3ry
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Signature versions of S3 requests
+const (
+	SignatureV4 = "v4"
+	// SignatureV2 for legacy S3 compatible storage only, AWS rejects it
+	SignatureV2 = "v2"
+)
+
+// signV2Subresources query parameters which are part of the signed resource in signature version 2
+var signV2Subresources = map[string]bool{
+	"acl": true, "cors": true, "delete": true, "lifecycle": true, "location": true, "logging": true,
+	"notification": true, "partNumber": true, "policy": true, "requestPayment": true, "restore": true,
+	"select": true, "select-type": true, "tagging": true, "torrent": true, "uploadId": true, "uploads": true,
+	"versionId": true, "versioning": true, "versions": true, "website": true,
+	"response-cache-control": true, "response-content-disposition": true, "response-content-encoding": true,
+	"response-content-language": true, "response-content-type": true, "response-expires": true,
+}
+
+// checkSignatureVersion check SignatureVersion can be used with Endpoint
+func (c AWSConfig) checkSignatureVersion() error {
+	if c.SignatureVersion == SignatureV2 && c.Endpoint == "" {
+		return fmt.Errorf("signature version v2 needs a custom Endpoint, AWS only accepts v4")
+	}
+	return nil
+}
+
+// newS3Client create S3 client of sess signing requests with AWS.SignatureVersion
+func (c *Config) newS3Client(sess *session.Session) *s3.S3 {
+	svc := s3.New(sess)
+	if c.AWS.SignatureVersion == SignatureV2 && c.AWS.checkSignatureVersion() == nil {
+		svc.Handlers.Sign.Swap(v4.SignRequestHandler.Name, signV2Handler)
+	}
+	return svc
+}
+
+// signV2Handler sign S3 requests with signature version 2, the Authorization "AWS key:signature" header
+var signV2Handler = request.NamedHandler{
+	Name: "s3ry.SignV2Handler",
+	Fn: func(r *request.Request) {
+		if r.Config.Credentials == credentials.AnonymousCredentials {
+			return
+		}
+		creds, err := r.Config.Credentials.Get()
+		if err != nil {
+			r.Error = err
+			return
+		}
+		h := r.HTTPRequest.Header
+		h.Del("X-Amz-Date")
+		h.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+		if creds.SessionToken != "" {
+			h.Set("X-Amz-Security-Token", creds.SessionToken)
+		}
+		mac := hmac.New(sha1.New, []byte(creds.SecretAccessKey))
+		mac.Write([]byte(stringToSignV2(r.HTTPRequest)))
+		h.Set("Authorization", "AWS "+creds.AccessKeyID+":"+base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	},
+}
+
+// stringToSignV2 return string to sign of req in signature version 2
+// requests are path-style with a custom Endpoint, so the path starts with the bucket
+func stringToSignV2(req *http.Request) string {
+	h := req.Header
+	var b strings.Builder
+	b.WriteString(req.Method + "\n")
+	b.WriteString(h.Get("Content-MD5") + "\n")
+	b.WriteString(h.Get("Content-Type") + "\n")
+	b.WriteString(h.Get("Date") + "\n")
+
+	var amz []string
+	for name := range h {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-amz-") {
+			amz = append(amz, lower)
+		}
+	}
+	sort.Strings(amz)
+	for _, name := range amz {
+		var values []string
+		for _, v := range h[http.CanonicalHeaderKey(name)] {
+			values = append(values, strings.TrimSpace(v))
+		}
+		b.WriteString(name + ":" + strings.Join(values, ",") + "\n")
+	}
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	b.WriteString(path)
+	query := req.URL.Query()
+	var subresources []string
+	for name := range query {
+		if signV2Subresources[name] {
+			subresources = append(subresources, name)
+		}
+	}
+	sort.Strings(subresources)
+	for i, name := range subresources {
+		sep := "&"
+		if i == 0 {
+			sep = "?"
+		}
+		b.WriteString(sep + name)
+		if v := query.Get(name); v != "" {
+			b.WriteString("=" + v)
+		}
+	}
+	return b.String()
+}
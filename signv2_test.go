@@ -0,0 +1,97 @@
+package s3ry
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// signV2 return signature of string to sign of r with secret
+func signV2(r *http.Request, secret string) string {
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(stringToSignV2(r)))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestStringToSignV2(t *testing.T) {
+	// the GET example of the S3 signature version 2 documentation
+	r, _ := http.NewRequest(http.MethodGet, "http://storage.example.com/johnsmith/photos/puppy.jpg", nil)
+	r.Header.Set("Date", "Tue, 27 Mar 2007 19:36:42 +0000")
+	assert.Equal(t, "GET\n\n\nTue, 27 Mar 2007 19:36:42 +0000\n/johnsmith/photos/puppy.jpg", stringToSignV2(r))
+	assert.Equal(t, "bWq2s1WEIj+Ydj0vQ697zp+IXMU=", signV2(r, "wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY"))
+
+	// amz headers are sorted and subresources are part of the resource, other parameters are not
+	r, _ = http.NewRequest(http.MethodPut, "http://storage.example.com/bucket/a%20b?uploadId=u1&partNumber=2&x-id=UploadPart", nil)
+	r.Header.Set("Date", "Tue, 27 Mar 2007 19:36:42 +0000")
+	r.Header.Set("Content-Type", "text/plain")
+	r.Header.Set("X-Amz-Meta-Owner", " me ")
+	r.Header.Set("X-Amz-Acl", "private")
+	assert.Equal(t, "PUT\n\ntext/plain\nTue, 27 Mar 2007 19:36:42 +0000\nx-amz-acl:private\nx-amz-meta-owner:me\n/bucket/a%20b?partNumber=2&uploadId=u1", stringToSignV2(r))
+}
+
+func TestSignatureVersion(t *testing.T) {
+	for _, version := range []string{SignatureV2, SignatureV4, ""} {
+		t.Run(version, func(t *testing.T) {
+			fake := newFakeS3("bucket")
+			fake.put("bucket", "key", "data")
+			var authorizations []string
+			var valid []bool
+			fake.hook = func(w http.ResponseWriter, r *http.Request) bool {
+				authorizations = append(authorizations, r.Header.Get("Authorization"))
+				valid = append(valid, "AWS AKID:"+signV2(r, "SECRET") == r.Header.Get("Authorization"))
+				return false
+			}
+			cfg, done := newFakeEndpoint(t, fake)
+			defer done()
+			cfg.AWS.SignatureVersion = version
+			s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+
+			_, err := s.ListObjectItems(context.Background(), "bucket", "")
+			assert.NoError(t, err)
+			if !assert.NotEmpty(t, authorizations) {
+				return
+			}
+			for i, authorization := range authorizations {
+				if version == SignatureV2 {
+					assert.True(t, strings.HasPrefix(authorization, "AWS AKID:"), authorization)
+					assert.True(t, valid[i])
+				} else {
+					assert.True(t, strings.HasPrefix(authorization, "AWS4-HMAC-SHA256 Credential=AKID/"), authorization)
+				}
+			}
+		})
+	}
+}
+
+func TestSignatureV2NeedsEndpoint(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.AWS.SignatureVersion = SignatureV2
+	_, err := cfg.newSession(ApNortheastOne)
+	assert.Error(t, err)
+
+	dir, err := ioutil.TempDir("", "s3ry")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"AWS": {"SignatureVersion": "v2"}}`), 0600))
+	cfg, err = LoadConfig(path)
+	if errs, ok := err.(ConfigErrors); assert.True(t, ok, "%v", err) && assert.Len(t, errs, 1) {
+		assert.Equal(t, "AWS.SignatureVersion", errs[0].Field)
+		assert.Equal(t, 1, errs[0].Line)
+	}
+	assert.Equal(t, SignatureV4, cfg.AWS.SignatureVersion)
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"AWS": {"Endpoint": "http://127.0.0.1:9000", "SignatureVersion": "v2"}}`), 0600))
+	cfg, err = LoadConfig(path)
+	assert.NoError(t, err)
+	assert.Equal(t, SignatureV2, cfg.AWS.SignatureVersion)
+}
@@ -0,0 +1,323 @@
+package s3ry
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sso"
+	"github.com/aws/aws-sdk-go/service/sso/ssoiface"
+	"github.com/aws/aws-sdk-go/service/ssooidc"
+	"github.com/aws/aws-sdk-go/service/ssooidc/ssooidciface"
+)
+
+// ssoProviderName ProviderName of SSO credentials
+const ssoProviderName = "SSOProvider"
+
+// deviceCodeGrantType grant type of the device authorization flow
+const deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// ErrNotSSOProfile profile has no sso_start_url
+var ErrNotSSOProfile = errors.New("not an SSO profile")
+
+// ssoProfile sso_* settings of a shared config profile
+type ssoProfile struct {
+	StartURL  string
+	Region    string
+	AccountID string
+	RoleName  string
+}
+
+// sharedConfigFilename return path of the AWS shared config file, AWS_CONFIG_FILE when set
+func sharedConfigFilename() string {
+	if path := os.Getenv("AWS_CONFIG_FILE"); path != "" {
+		return path
+	}
+	return filepath.Join(userHomeDir(), ".aws", "config")
+}
+
+// userHomeDir return home directory of the user
+func userHomeDir() string {
+	home, _ := os.UserHomeDir()
+	return home
+}
+
+// readINISection return key values of section of the INI file at path, nil when missing
+func readINISection(path string, section string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var values map[string]string
+	inSection := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			inSection = strings.TrimSpace(line[1:len(line)-1]) == section
+			if inSection && values == nil {
+				values = map[string]string{}
+			}
+			continue
+		}
+		if kv := strings.SplitN(line, "=", 2); inSection && len(kv) == 2 {
+			values[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+	}
+	return values, scanner.Err()
+}
+
+// loadSSOProfile read sso_* settings of profile from the shared config file at path
+func loadSSOProfile(path string, profile string) (ssoProfile, error) {
+	section := "profile " + profile
+	if profile == "default" {
+		section = "default"
+	}
+	values, err := readINISection(path, section)
+	if err != nil {
+		return ssoProfile{}, err
+	}
+	p := ssoProfile{
+		StartURL:  values["sso_start_url"],
+		Region:    values["sso_region"],
+		AccountID: values["sso_account_id"],
+		RoleName:  values["sso_role_name"],
+	}
+	if p.StartURL == "" {
+		return p, ErrNotSSOProfile
+	}
+	if p.Region == "" || p.AccountID == "" || p.RoleName == "" {
+		return p, fmt.Errorf("profile %s: sso_start_url needs sso_region, sso_account_id and sso_role_name", profile)
+	}
+	return p, nil
+}
+
+// ssoToken SSO access token cached in the format of the AWS CLI
+type ssoToken struct {
+	StartURL    string `json:"startUrl"`
+	Region      string `json:"region"`
+	AccessToken string `json:"accessToken"`
+	ExpiresAt   string `json:"expiresAt"`
+}
+
+// expiresAt return expiry of the token, zero when unreadable
+func (t ssoToken) expiresAt() time.Time {
+	// older AWS CLI versions write UTC instead of Z
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05UTC"} {
+		if at, err := time.Parse(layout, t.ExpiresAt); err == nil {
+			return at
+		}
+	}
+	return time.Time{}
+}
+
+// ssoProvider credentials of an SSO profile
+// the role credentials are requested with the cached SSO token, logging in with the
+// device authorization flow when the token is missing or expired
+type ssoProvider struct {
+	credentials.Expiry
+	mu      sync.Mutex
+	profile ssoProfile
+	// cacheDir directory of cached tokens, ~/.aws/sso/cache like the AWS CLI
+	cacheDir string
+	oidc     ssooidciface.SSOOIDCAPI
+	sso      ssoiface.SSOAPI
+	// out receives the verification URL and code
+	out   io.Writer
+	sleep func(time.Duration)
+	// tokenExpires expiry of the SSO token the last credentials were requested with
+	tokenExpires time.Time
+	// warned tokenExpires a warning was written for
+	warned time.Time
+}
+
+// newSSOProvider create ssoProvider of profile using the SSO endpoints of its region
+func newSSOProvider(c *Config, profile ssoProfile, out io.Writer) (*ssoProvider, error) {
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String(profile.Region),
+		HTTPClient:  c.HTTP.newHTTPClient(),
+		Credentials: credentials.AnonymousCredentials,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ssoProvider{
+		profile:  profile,
+		cacheDir: filepath.Join(userHomeDir(), ".aws", "sso", "cache"),
+		oidc:     ssooidc.New(sess),
+		sso:      sso.New(sess),
+		out:      out,
+		sleep:    time.Sleep,
+	}, nil
+}
+
+// tokenPath return path of the cached token, named by the start URL like the AWS CLI
+func (p *ssoProvider) tokenPath() string {
+	sum := sha1.Sum([]byte(p.profile.StartURL))
+	return filepath.Join(p.cacheDir, hex.EncodeToString(sum[:])+".json")
+}
+
+// cachedToken return access token of the cache, empty when missing or expired
+func (p *ssoProvider) cachedToken() string {
+	b, err := ioutil.ReadFile(p.tokenPath())
+	if err != nil {
+		return ""
+	}
+	var t ssoToken
+	if err := json.Unmarshal(b, &t); err != nil {
+		return ""
+	}
+	// leave a minute to use the token
+	if time.Now().Add(time.Minute).After(t.expiresAt()) {
+		return ""
+	}
+	p.tokenExpires = t.expiresAt()
+	return t.AccessToken
+}
+
+// Login get a new SSO token with the device authorization flow and cache it
+func (p *ssoProvider) Login(ctx context.Context) (string, error) {
+	client, err := p.oidc.RegisterClientWithContext(ctx, &ssooidc.RegisterClientInput{
+		ClientName: aws.String("s3ry"),
+		ClientType: aws.String("public"),
+	})
+	if err != nil {
+		return "", err
+	}
+	auth, err := p.oidc.StartDeviceAuthorizationWithContext(ctx, &ssooidc.StartDeviceAuthorizationInput{
+		ClientId:     client.ClientId,
+		ClientSecret: client.ClientSecret,
+		StartUrl:     aws.String(p.profile.StartURL),
+	})
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintln(p.out, i18nPrinter.Sprintf("Open %s in a browser and confirm the code %s", aws.StringValue(auth.VerificationUriComplete), aws.StringValue(auth.UserCode)))
+
+	interval := time.Duration(aws.Int64Value(auth.Interval)) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	for {
+		token, err := p.oidc.CreateTokenWithContext(ctx, &ssooidc.CreateTokenInput{
+			ClientId:     client.ClientId,
+			ClientSecret: client.ClientSecret,
+			DeviceCode:   auth.DeviceCode,
+			GrantType:    aws.String(deviceCodeGrantType),
+		})
+		if aerr, ok := err.(awserr.Error); ok {
+			switch aerr.Code() {
+			case ssooidc.ErrCodeAuthorizationPendingException:
+				p.sleep(interval)
+				continue
+			case ssooidc.ErrCodeSlowDownException:
+				interval += 5 * time.Second
+				p.sleep(interval)
+				continue
+			}
+		}
+		if err != nil {
+			return "", err
+		}
+		cached := ssoToken{
+			StartURL:    p.profile.StartURL,
+			Region:      p.profile.Region,
+			AccessToken: aws.StringValue(token.AccessToken),
+			ExpiresAt:   time.Now().UTC().Add(time.Duration(aws.Int64Value(token.ExpiresIn)) * time.Second).Format(time.RFC3339),
+		}
+		b, err := json.Marshal(cached)
+		if err != nil {
+			return "", err
+		}
+		if err := os.MkdirAll(p.cacheDir, 0700); err != nil {
+			return "", err
+		}
+		p.tokenExpires = cached.expiresAt()
+		// the token grants access to every account of the user
+		return cached.AccessToken, ioutil.WriteFile(p.tokenPath(), b, 0600)
+	}
+}
+
+// Retrieve get role credentials with the cached SSO token, logging in when needed
+func (p *ssoProvider) Retrieve() (credentials.Value, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ctx := context.Background()
+	token := p.cachedToken()
+	if token == "" {
+		var err error
+		if token, err = p.Login(ctx); err != nil {
+			return credentials.Value{ProviderName: ssoProviderName}, err
+		}
+	}
+	out, err := p.roleCredentials(ctx, token)
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == sso.ErrCodeUnauthorizedException {
+		// the token was revoked before its expiry
+		if token, err = p.Login(ctx); err == nil {
+			out, err = p.roleCredentials(ctx, token)
+		}
+	}
+	if err != nil {
+		return credentials.Value{ProviderName: ssoProviderName}, err
+	}
+	creds := out.RoleCredentials
+	expires := time.Unix(0, aws.Int64Value(creds.Expiration)*int64(time.Millisecond))
+	p.SetExpiration(expires, time.Minute)
+	// refreshing them after the token expires waits for the user to log in, which a long unattended operation can't
+	if !p.tokenExpires.IsZero() && p.tokenExpires.Before(expires) && !p.warned.Equal(p.tokenExpires) {
+		p.warned = p.tokenExpires
+		fmt.Fprintln(p.out, i18nPrinter.Sprintf("The SSO login expires at %s, credentials can't be refreshed after %s without logging in again; run s3ry login --sso before long operations",
+			p.tokenExpires.Format(time.RFC3339), expires.Format(time.RFC3339)))
+	}
+	return credentials.Value{
+		AccessKeyID:     aws.StringValue(creds.AccessKeyId),
+		SecretAccessKey: aws.StringValue(creds.SecretAccessKey),
+		SessionToken:    aws.StringValue(creds.SessionToken),
+		ProviderName:    ssoProviderName,
+	}, nil
+}
+
+// roleCredentials request credentials of the profile role
+func (p *ssoProvider) roleCredentials(ctx context.Context, token string) (*sso.GetRoleCredentialsOutput, error) {
+	return p.sso.GetRoleCredentialsWithContext(ctx, &sso.GetRoleCredentialsInput{
+		AccessToken: aws.String(token),
+		AccountId:   aws.String(p.profile.AccountID),
+		RoleName:    aws.String(p.profile.RoleName),
+	})
+}
+
+// SSOLogin log in to the SSO profile and cache the token, used by the login command
+func SSOLogin(ctx context.Context, cfg *Config, profile string, w io.Writer) error {
+	p, err := loadSSOProfile(sharedConfigFilename(), profile)
+	if err != nil {
+		return err
+	}
+	provider, err := newSSOProvider(cfg, p, w)
+	if err != nil {
+		return err
+	}
+	if _, err := provider.Login(ctx); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, i18nPrinter.Sprintf("Logged in to %s", p.StartURL))
+	return nil
+}
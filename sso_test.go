@@ -0,0 +1,215 @@
+package s3ry
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sso"
+	"github.com/aws/aws-sdk-go/service/sso/ssoiface"
+	"github.com/aws/aws-sdk-go/service/ssooidc"
+	"github.com/aws/aws-sdk-go/service/ssooidc/ssooidciface"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockOIDC device authorization granting token after pending polls
+type mockOIDC struct {
+	ssooidciface.SSOOIDCAPI
+	pending int
+	polls   int
+	logins  int
+	token   string
+}
+
+func (m *mockOIDC) RegisterClientWithContext(ctx aws.Context, in *ssooidc.RegisterClientInput, opts ...request.Option) (*ssooidc.RegisterClientOutput, error) {
+	return &ssooidc.RegisterClientOutput{ClientId: aws.String("client"), ClientSecret: aws.String("secret")}, nil
+}
+
+func (m *mockOIDC) StartDeviceAuthorizationWithContext(ctx aws.Context, in *ssooidc.StartDeviceAuthorizationInput, opts ...request.Option) (*ssooidc.StartDeviceAuthorizationOutput, error) {
+	m.logins++
+	return &ssooidc.StartDeviceAuthorizationOutput{
+		DeviceCode:              aws.String("device"),
+		UserCode:                aws.String("ABCD-EFGH"),
+		VerificationUriComplete: aws.String("https://device.sso.example.com/?user_code=ABCD-EFGH"),
+		Interval:                aws.Int64(1),
+	}, nil
+}
+
+func (m *mockOIDC) CreateTokenWithContext(ctx aws.Context, in *ssooidc.CreateTokenInput, opts ...request.Option) (*ssooidc.CreateTokenOutput, error) {
+	m.polls++
+	if m.polls <= m.pending {
+		return nil, awserr.New(ssooidc.ErrCodeAuthorizationPendingException, "pending", nil)
+	}
+	return &ssooidc.CreateTokenOutput{AccessToken: aws.String(m.token), ExpiresIn: aws.Int64(3600)}, nil
+}
+
+// mockSSO role credentials for valid tokens
+type mockSSO struct {
+	ssoiface.SSOAPI
+	valid    string
+	requests int
+	expiry   time.Time
+}
+
+func (m *mockSSO) GetRoleCredentialsWithContext(ctx aws.Context, in *sso.GetRoleCredentialsInput, opts ...request.Option) (*sso.GetRoleCredentialsOutput, error) {
+	m.requests++
+	if aws.StringValue(in.AccessToken) != m.valid {
+		return nil, awserr.New(sso.ErrCodeUnauthorizedException, "invalid token", nil)
+	}
+	return &sso.GetRoleCredentialsOutput{RoleCredentials: &sso.RoleCredentials{
+		AccessKeyId:     aws.String("AKID-" + aws.StringValue(in.RoleName)),
+		SecretAccessKey: aws.String("SECRET"),
+		SessionToken:    aws.String("SESSION"),
+		Expiration:      aws.Int64(m.expiry.UnixNano() / int64(time.Millisecond)),
+	}}, nil
+}
+
+func newTestSSOProvider(t *testing.T, oidc *mockOIDC, svc *mockSSO) (*ssoProvider, *bytes.Buffer, func()) {
+	dir, err := ioutil.TempDir("", "s3ry-sso")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &bytes.Buffer{}
+	p := &ssoProvider{
+		profile:  ssoProfile{StartURL: "https://example.awsapps.com/start", Region: "us-east-1", AccountID: "123456789012", RoleName: "ReadOnly"},
+		cacheDir: filepath.Join(dir, "sso", "cache"),
+		oidc:     oidc,
+		sso:      svc,
+		out:      out,
+		sleep:    func(time.Duration) {},
+	}
+	return p, out, func() { os.RemoveAll(dir) }
+}
+
+func TestLoadSSOProfile(t *testing.T) {
+	f, err := ioutil.TempFile("", "s3ry-aws-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`[default]
+region = ap-northeast-1
+
+[profile dev]
+sso_start_url = https://example.awsapps.com/start
+sso_region = us-east-1
+sso_account_id = 123456789012
+sso_role_name = ReadOnly
+
+[profile partial]
+sso_start_url = https://example.awsapps.com/start
+`)
+	f.Close()
+
+	p, err := loadSSOProfile(f.Name(), "dev")
+	assert.NoError(t, err)
+	assert.Equal(t, ssoProfile{StartURL: "https://example.awsapps.com/start", Region: "us-east-1", AccountID: "123456789012", RoleName: "ReadOnly"}, p)
+	_, err = loadSSOProfile(f.Name(), "default")
+	assert.Equal(t, ErrNotSSOProfile, err)
+	_, err = loadSSOProfile(f.Name(), "missing")
+	assert.Equal(t, ErrNotSSOProfile, err)
+	_, err = loadSSOProfile(f.Name(), "partial")
+	assert.Error(t, err)
+}
+
+func TestSSOProviderLogsInWithoutCachedToken(t *testing.T) {
+	oidc := &mockOIDC{pending: 2, token: "token1"}
+	svc := &mockSSO{valid: "token1", expiry: time.Now().Add(time.Hour)}
+	p, out, cleanup := newTestSSOProvider(t, oidc, svc)
+	defer cleanup()
+
+	v, err := credentials.NewCredentials(p).Get()
+	assert.NoError(t, err)
+	assert.Equal(t, "AKID-ReadOnly", v.AccessKeyID)
+	assert.Equal(t, "SESSION", v.SessionToken)
+	assert.Equal(t, 3, oidc.polls)
+	assert.Contains(t, out.String(), "ABCD-EFGH")
+
+	// the token is cached for the AWS CLI and later runs
+	b, err := ioutil.ReadFile(filepath.Join(p.cacheDir, "e8be5486177c5b5392bd9aa76563515b29358e6e.json"))
+	assert.NoError(t, err)
+	var cached ssoToken
+	assert.NoError(t, json.Unmarshal(b, &cached))
+	assert.Equal(t, "token1", cached.AccessToken)
+	assert.True(t, cached.expiresAt().After(time.Now().Add(59*time.Minute)))
+
+	again, _, cleanupAgain := newTestSSOProvider(t, &mockOIDC{}, svc)
+	defer cleanupAgain()
+	again.cacheDir = p.cacheDir
+	_, err = credentials.NewCredentials(again).Get()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, again.oidc.(*mockOIDC).logins)
+}
+
+func TestSSOProviderRefresh(t *testing.T) {
+	oidc := &mockOIDC{token: "token2"}
+	svc := &mockSSO{valid: "token2", expiry: time.Now().Add(30 * time.Second)}
+	p, _, cleanup := newTestSSOProvider(t, oidc, svc)
+	defer cleanup()
+	os.MkdirAll(p.cacheDir, 0700)
+	expired, _ := json.Marshal(ssoToken{StartURL: p.profile.StartURL, AccessToken: "old", ExpiresAt: "2020-01-01T00:00:00UTC"})
+	ioutil.WriteFile(p.tokenPath(), expired, 0600)
+
+	creds := credentials.NewCredentials(p)
+	_, err := creds.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, oidc.logins)
+	// the role credentials expire within the refresh window, so they are requested again
+	assert.True(t, creds.IsExpired())
+	_, err = creds.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, svc.requests)
+	assert.Equal(t, 1, oidc.logins)
+}
+
+func TestSSOProviderRevokedToken(t *testing.T) {
+	oidc := &mockOIDC{token: "new"}
+	svc := &mockSSO{valid: "new", expiry: time.Now().Add(time.Hour)}
+	p, _, cleanup := newTestSSOProvider(t, oidc, svc)
+	defer cleanup()
+	os.MkdirAll(p.cacheDir, 0700)
+	revoked, _ := json.Marshal(ssoToken{StartURL: p.profile.StartURL, AccessToken: "revoked", ExpiresAt: time.Now().Add(time.Hour).UTC().Format(time.RFC3339)})
+	ioutil.WriteFile(p.tokenPath(), revoked, 0600)
+
+	_, err := credentials.NewCredentials(p).Get()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, oidc.logins)
+	assert.Equal(t, 2, svc.requests)
+}
+
+func TestSSOProviderWarnsBeforeLoginExpires(t *testing.T) {
+	svc := &mockSSO{valid: "token", expiry: time.Now().Add(time.Hour)}
+	p, out, cleanup := newTestSSOProvider(t, &mockOIDC{}, svc)
+	defer cleanup()
+	os.MkdirAll(p.cacheDir, 0700)
+	cached, _ := json.Marshal(ssoToken{StartURL: p.profile.StartURL, AccessToken: "token", ExpiresAt: time.Now().Add(30 * time.Minute).UTC().Format(time.RFC3339)})
+	ioutil.WriteFile(p.tokenPath(), cached, 0600)
+
+	creds := credentials.NewCredentials(p)
+	_, err := creds.Get()
+	assert.NoError(t, err)
+	assert.Contains(t, out.String(), "s3ry login")
+	// warned once per login
+	creds.Expire()
+	_, err = creds.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(out.String(), "s3ry login"))
+
+	// the login outlasting the credentials needs no warning
+	out.Reset()
+	svc.expiry = time.Now().Add(20 * time.Minute)
+	p.warned = time.Time{}
+	creds.Expire()
+	_, err = creds.Get()
+	assert.NoError(t, err)
+	assert.Empty(t, out.String())
+}
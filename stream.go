@@ -0,0 +1,258 @@
+package s3ry
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// stdio path of stdin and stdout in the put and get commands
+const stdio = "-"
+
+// limitedReader fail with ErrUploadTooLarge once more than max bytes were read
+type limitedReader struct {
+	r    io.Reader
+	max  int64
+	read int64
+}
+
+// Read read from r counting bytes
+func (l *limitedReader) Read(b []byte) (int, error) {
+	n, err := l.r.Read(b)
+	l.read += int64(n)
+	if l.max > 0 && l.read > l.max {
+		return n, fmt.Errorf("upload is larger than %d bytes: %w", l.max, ErrUploadTooLarge)
+	}
+	return n, err
+}
+
+// PutStream upload r to bucket key, returning the bytes uploaded
+// the size is unknown, so the body is uploaded in parts as it is read, e.g. from stdin
+func (s S3ry) PutStream(ctx context.Context, bucket string, key string, r io.Reader) (n int64, err error) {
+	return s.putStream(ctx, bucket, key, r, nil)
+}
+
+// putStream upload r to bucket key with user metadata, returning the bytes uploaded
+func (s S3ry) putStream(ctx context.Context, bucket string, key string, r io.Reader, metadata map[string]*string) (n int64, err error) {
+	done := s.track("upload", bucket, key)
+	defer func() { done(err) }()
+	input := &s3manager.UploadInput{
+		Bucket:   aws.String(bucket),
+		Key:      aws.String(key),
+		Metadata: metadata,
+	}
+	bc := s.config().bucketConfig(bucket)
+	if err := bc.applyUpload(input); err != nil {
+		return 0, err
+	}
+	s.config().applyExpiry(input, time.Now())
+	// the answers can't be read from stdin when it is the body
+	ask := s.config().askFor(r)
+	if err := confirmACL(bc.ACL, "s3://"+bucket+"/"+aws.StringValue(input.Key), ask); err != nil {
+		return 0, err
+	}
+	br := bufio.NewReaderSize(r, sniffLen)
+	if input.ContentType == nil {
+		head, err := br.Peek(sniffLen)
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		if t := s.config().detectContentType(key, head); t != "" {
+			input.ContentType = aws.String(t)
+		}
+	}
+	if s, err = s.forBucket(bucket); err != nil {
+		return 0, err
+	}
+	// the size of a file is checked before uploading, other readers are limited as they are read, e.g. stdin
+	max := s.config().Security.MaxUploadBytes
+	if f, ok := r.(*os.File); ok {
+		info, err := f.Stat()
+		if err != nil {
+			return 0, err
+		}
+		if info.Mode().IsRegular() {
+			if err := s.checkUploadSize(f.Name(), info.Size()); err != nil {
+				return 0, err
+			}
+			max = 0
+		}
+	}
+	if err := s.guardOverwrite(ctx, bucket, aws.StringValue(input.Key), ask); err != nil {
+		return 0, err
+	}
+	body := &limitedReader{r: br, max: max}
+	input.Body = &progressReader{r: body, publish: s.progress("upload", bucket, key, 0)}
+	if s.config().Upload.Compress && input.ContentEncoding == nil {
+		compressed := gzipReader(input.Body)
+		defer compressed.Close()
+		input.Body = compressed
+		input.ContentEncoding = aws.String(encodingGzip)
+	}
+	if err := s.upload(ctx, "upload", input); err != nil {
+		if failure, ok := err.(s3manager.MultiUploadFailure); ok {
+			// the uploader aborts with ctx, which fails once ctx is done
+			s.Svc.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+				Bucket:   input.Bucket,
+				Key:      input.Key,
+				UploadId: aws.String(failure.UploadID()),
+			})
+		}
+		return body.read, err
+	}
+	s.recent.touchObject(bucket, aws.StringValue(input.Key))
+	return body.read, nil
+}
+
+// GetStream download object to w in order, returning the bytes written
+func (s S3ry) GetStream(ctx context.Context, bucket string, key string, w io.Writer) (n int64, err error) {
+	n, _, err = s.getStream(ctx, bucket, key, w)
+	return n, err
+}
+
+// getStream download object to w in order, returning the bytes written and the user metadata
+func (s S3ry) getStream(ctx context.Context, bucket string, key string, w io.Writer) (n int64, metadata map[string]*string, err error) {
+	done := s.track("download", bucket, key)
+	defer func() { done(err) }()
+	if s, err = s.forBucket(bucket); err != nil {
+		return 0, nil, err
+	}
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	if err := s.config().Encryption.applyDownload(input); err != nil {
+		return 0, nil, err
+	}
+	out, err := s.Svc.GetObjectWithContext(ctx, input)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer out.Body.Close()
+	body := io.Reader(&progressReader{r: out.Body, publish: s.progress("download", bucket, key, aws.Int64Value(out.ContentLength))})
+	if s.config().Upload.Decompress {
+		if body, err = decodingReader(aws.StringValue(out.ContentEncoding), body); err != nil {
+			return 0, nil, err
+		}
+	}
+	n, err = io.Copy(w, body)
+	if err != nil {
+		return n, nil, err
+	}
+	s.recent.touchObject(bucket, key)
+	return n, out.Metadata, nil
+}
+
+// Put upload local file src, or stdin when it is "-", to s3:// URI dst, used by the put command
+// a dst ending with / gets the file name appended, the original key name of a file downloaded under a sanitized name
+// messages go to stderr, so stdout stays clean for pipelines
+func Put(ctx context.Context, cfg *Config, src string, dst string) error {
+	bucket, key, err := ParseS3URI(dst)
+	if err != nil {
+		return err
+	}
+	r := io.Reader(os.Stdin)
+	if src != stdio {
+		f, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+		if key == "" || strings.HasSuffix(key, "/") {
+			key += loadKeyNames(defaultKeyNamesPath()).original(src)
+		}
+	}
+	if key == "" || strings.HasSuffix(key, "/") {
+		return fmt.Errorf("%w %q, it needs an object key for stdin", ErrInvalidURI, dst)
+	}
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	n, err := s.PutStream(ctx, bucket, key, r)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, i18nPrinter.Sprintf("Uploaded,% s,% d bytes", "s3://"+bucket+"/"+key, n))
+	return nil
+}
+
+// Get download s3:// URI src to local file dst, or stdout when it is "-", used by the get command
+// a dst which is a directory gets the key name appended, sanitized by KeySanitizer
+// messages go to stderr, so stdout stays clean for pipelines
+// with Performance.SkipExisting a dst holding the same object version from an earlier download is kept,
+// with Upload.PreserveMode dst gets the mode stored by a directory upload,
+// and with Upload.Decompress an object stored with Content-Encoding gzip or deflate is written decoded
+func Get(ctx context.Context, cfg *Config, src string, dst string) error {
+	bucket, key, err := ParseS3URI(src)
+	if err != nil {
+		return err
+	}
+	if key == "" || strings.HasSuffix(key, "/") {
+		return fmt.Errorf("%w %q, it needs an object key", ErrInvalidURI, src)
+	}
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	if dst == stdio {
+		_, err := s.GetStream(ctx, bucket, key, os.Stdout)
+		return err
+	}
+	if info, err := os.Stat(dst); (err == nil && info.IsDir()) || strings.HasSuffix(dst, string(os.PathSeparator)) {
+		if dst, err = loadKeyNames(defaultKeyNamesPath()).localName(cfg, dst, key, os.Stderr); err != nil {
+			return err
+		}
+	}
+	var manifest *downloadManifest
+	var etag string
+	if cfg.Performance.SkipExisting {
+		b, err := s.forBucket(bucket)
+		if err != nil {
+			return err
+		}
+		d, err := b.headDigest(ctx, bucket, key)
+		if err != nil {
+			return err
+		}
+		etag = d.ETag
+		manifest = loadDownloadManifest(defaultDownloadManifestPath())
+		if manifest.unchanged(dst, bucket, key, etag) {
+			fmt.Fprintln(os.Stderr, i18nPrinter.Sprintf("Skipped, unchanged since the last download,% s", dst))
+			return nil
+		}
+	}
+	if err := cfg.confirmOverwrite(dst); err != nil {
+		return err
+	}
+	// write to a partial file, so a failed download doesn't leave a truncated dst
+	file, err := cfg.createPartial(dst)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	n, metadata, err := s.getStream(ctx, bucket, key, file)
+	if err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := commitPartial(file.Name(), dst); err != nil {
+		return err
+	}
+	if mode, ok := metadataMode(metadata); ok && cfg.Upload.PreserveMode {
+		if err := os.Chmod(dst, mode); err != nil {
+			return err
+		}
+	}
+	if manifest != nil {
+		manifest.record(dst, bucket, key, etag)
+	}
+	fmt.Fprintln(os.Stderr, i18nPrinter.Sprintf("File downloaded,% s,% d bytes", dst, n))
+	return nil
+}
@@ -0,0 +1,164 @@
+package s3ry
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPutStreamGetStream(t *testing.T) {
+	fake := newFakeS3("bucket")
+	s, srv := newTestS3ry(DefaultConfig(), fake)
+	defer srv.Close()
+
+	// larger than a part, so the unknown size body is uploaded in parts
+	data := make([]byte, 12*1024*1024+7)
+	rand.New(rand.NewSource(1)).Read(data)
+	n, err := s.PutStream(context.Background(), "bucket", "stream.bin", ioutil.NopCloser(bytes.NewReader(data)))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(data)), n)
+	assert.True(t, fake.count("UPLOAD_PART") > 1)
+
+	var out bytes.Buffer
+	n, err = s.GetStream(context.Background(), "bucket", "stream.bin", &out)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(data)), n)
+	assert.True(t, bytes.Equal(data, out.Bytes()))
+}
+
+func TestPutStreamDetectsContentTypeAndLimit(t *testing.T) {
+	fake := newFakeS3("bucket")
+	cfg := DefaultConfig()
+	cfg.Security.MaxUploadBytes = 10
+	s, srv := newTestS3ry(cfg, fake)
+	defer srv.Close()
+
+	_, err := s.PutStream(context.Background(), "bucket", "page", bytes.NewBufferString("<html></html>"))
+	if assert.Error(t, err) {
+		// s3manager wraps the body error, so only its message is kept
+		assert.Contains(t, err.Error(), ErrUploadTooLarge.Error())
+	}
+
+	cfg.Security.MaxUploadBytes = 0
+	_, err = s.PutStream(context.Background(), "bucket", "page", bytes.NewBufferString("<html></html>"))
+	assert.NoError(t, err)
+	o, _ := fake.get("bucket", "page")
+	assert.Equal(t, "text/html; charset=utf-8", o.header.Get("Content-Type"))
+}
+
+func TestPutStreamCompressGetStreamDecompress(t *testing.T) {
+	fake := newFakeS3("bucket")
+	// the HTTP client of the config, which doesn't decode gzip by itself
+	cfg, done := newFakeEndpoint(t, fake)
+	defer done()
+	cfg.Upload.Compress = true
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	ctx := context.Background()
+
+	data := bytes.Repeat([]byte("hello, compressed world\n"), 1000)
+	n, err := s.PutStream(ctx, "bucket", "hello.txt", bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(data)), n)
+	o, _ := fake.get("bucket", "hello.txt")
+	assert.Equal(t, "gzip", o.header.Get("Content-Encoding"))
+	// the content type is detected from the file, not the compressed body
+	assert.Equal(t, "text/plain; charset=utf-8", o.header.Get("Content-Type"))
+	assert.True(t, len(o.data) < len(data))
+
+	var raw bytes.Buffer
+	_, err = s.GetStream(ctx, "bucket", "hello.txt", &raw)
+	assert.NoError(t, err)
+	assert.Equal(t, o.data, raw.Bytes())
+
+	cfg.Upload.Decompress = true
+	var out bytes.Buffer
+	n, err = s.GetStream(ctx, "bucket", "hello.txt", &out)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(data)), n)
+	assert.Equal(t, data, out.Bytes())
+
+	// objects without an encoding are written as they are
+	fake.put("bucket", "plain.txt", "plain")
+	out.Reset()
+	_, err = s.GetStream(ctx, "bucket", "plain.txt", &out)
+	assert.NoError(t, err)
+	assert.Equal(t, "plain", out.String())
+}
+
+func TestDecodingReaderDeflate(t *testing.T) {
+	var b bytes.Buffer
+	zw := zlib.NewWriter(&b)
+	zw.Write([]byte("deflated"))
+	zw.Close()
+	r, err := decodingReader("Deflate", &b)
+	assert.NoError(t, err)
+	decoded, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "deflated", string(decoded))
+}
+
+// pipeStdio replace stdin with in and stdout with a pipe while f runs, returning what f wrote
+func pipeStdio(t *testing.T, in []byte, f func()) []byte {
+	stdin, stdout := os.Stdin, os.Stdout
+	defer func() { os.Stdin, os.Stdout = stdin, stdout }()
+	inR, inW, err := os.Pipe()
+	assert.NoError(t, err)
+	outR, outW, err := os.Pipe()
+	assert.NoError(t, err)
+	go func() {
+		inW.Write(in)
+		inW.Close()
+	}()
+	out := make(chan []byte)
+	go func() {
+		b, _ := ioutil.ReadAll(outR)
+		out <- b
+	}()
+	os.Stdin, os.Stdout = inR, outW
+	f()
+	outW.Close()
+	inR.Close()
+	return <-out
+}
+
+func TestPutGetPipes(t *testing.T) {
+	fake := newFakeS3("bucket")
+	cfg, done := newFakeEndpoint(t, fake)
+	defer done()
+	home := cfg.Performance.TempDir
+
+	data := make([]byte, 6*1024*1024)
+	rand.New(rand.NewSource(2)).Read(data)
+	out := pipeStdio(t, data, func() {
+		assert.NoError(t, Put(context.Background(), cfg, "-", "s3://bucket/piped.bin"))
+	})
+	assert.Empty(t, out)
+	o, ok := fake.get("bucket", "piped.bin")
+	if assert.True(t, ok) {
+		assert.True(t, bytes.Equal(data, o.data))
+	}
+
+	out = pipeStdio(t, nil, func() {
+		assert.NoError(t, Get(context.Background(), cfg, "s3://bucket/piped.bin", "-"))
+	})
+	assert.True(t, bytes.Equal(data, out))
+
+	dst := filepath.Join(home, "piped.bin")
+	assert.NoError(t, Get(context.Background(), cfg, "s3://bucket/piped.bin", dst))
+	b, err := ioutil.ReadFile(dst)
+	assert.NoError(t, err)
+	assert.True(t, bytes.Equal(data, b))
+
+	assert.NoError(t, Put(context.Background(), cfg, dst, "s3://bucket/dir/"))
+	_, ok = fake.get("bucket", "dir/piped.bin")
+	assert.True(t, ok)
+
+	assert.Equal(t, ExitUsage, ExitCode(Put(context.Background(), cfg, "-", "s3://bucket/dir/")))
+}
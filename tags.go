@@ -0,0 +1,195 @@
+package s3ry
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/manifoldco/promptui"
+)
+
+// maxBucketTags tags a bucket can have
+const maxBucketTags = 50
+
+// checkTag check key and value of a tag against the S3 tag constraints
+func checkTag(key string, value string) error {
+	if key == "" || utf8.RuneCountInString(key) > 128 {
+		return fmt.Errorf("tag key %q must be 1 to 128 characters", key)
+	}
+	if utf8.RuneCountInString(value) > 256 {
+		return fmt.Errorf("tag value of %q must be at most 256 characters", key)
+	}
+	if strings.HasPrefix(strings.ToLower(key), "aws:") {
+		return fmt.Errorf("tag key %q uses the reserved aws: prefix", key)
+	}
+	for _, s := range []string{key, value} {
+		for _, r := range s {
+			if !unicode.IsLetter(r) && !unicode.IsNumber(r) && !unicode.IsSpace(r) && !strings.ContainsRune("+-=._:/@", r) {
+				return fmt.Errorf("tag %q contains %q, only letters, numbers, spaces and + - = . _ : / @ are allowed", key, r)
+			}
+		}
+	}
+	return nil
+}
+
+// CheckTags check tags fit a bucket
+func CheckTags(tags map[string]string) error {
+	if len(tags) > maxBucketTags {
+		return fmt.Errorf("%d tags, a bucket can have at most %d", len(tags), maxBucketTags)
+	}
+	for k, v := range tags {
+		if err := checkTag(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BucketTags get tags of bucket, empty when it has none
+func (s S3ry) BucketTags(ctx context.Context, bucket string) (map[string]string, error) {
+	tags := map[string]string{}
+	s, err := s.forBucket(bucket)
+	if err != nil {
+		return tags, err
+	}
+	out, err := s.Svc.GetBucketTaggingWithContext(ctx, &s3.GetBucketTaggingInput{Bucket: aws.String(bucket)})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "NoSuchTagSet" {
+		return tags, nil
+	}
+	if err != nil {
+		return tags, err
+	}
+	for _, t := range out.TagSet {
+		tags[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+	}
+	return tags, nil
+}
+
+// PutBucketTags replace tags of bucket, removing them all when tags is empty
+func (s S3ry) PutBucketTags(ctx context.Context, bucket string, tags map[string]string) (err error) {
+	done := s.track("tags", bucket, "")
+	defer func() { done(err) }()
+	if err := CheckTags(tags); err != nil {
+		return err
+	}
+	if s, err = s.forBucket(bucket); err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		_, err = s.Svc.DeleteBucketTaggingWithContext(ctx, &s3.DeleteBucketTaggingInput{Bucket: aws.String(bucket)})
+		return err
+	}
+	tagging := &s3.Tagging{}
+	for _, k := range sortedKeys(tags) {
+		tagging.TagSet = append(tagging.TagSet, &s3.Tag{Key: aws.String(k), Value: aws.String(tags[k])})
+	}
+	_, err = s.Svc.PutBucketTaggingWithContext(ctx, &s3.PutBucketTaggingInput{Bucket: aws.String(bucket), Tagging: tagging})
+	return err
+}
+
+// sortedKeys return keys of m in order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// printTags write tags as key=value lines
+func printTags(w io.Writer, tags map[string]string) {
+	for _, k := range sortedKeys(tags) {
+		fmt.Fprintf(w, "%s=%s\n", k, tags[k])
+	}
+}
+
+// Tags show tags of bucket, or change them when set or remove are given, used by the tags command
+// set are key=value pairs added to the current tags, remove are keys removed from them
+func Tags(ctx context.Context, cfg *Config, bucket string, set []string, remove []string, w io.Writer) error {
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	tags, err := s.BucketTags(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	if len(set) == 0 && len(remove) == 0 {
+		printTags(w, tags)
+		return nil
+	}
+	for _, kv := range set {
+		i := strings.Index(kv, "=")
+		if i < 0 {
+			return fmt.Errorf("tag %q must be key=value", kv)
+		}
+		tags[kv[:i]] = kv[i+1:]
+	}
+	for _, k := range remove {
+		delete(tags, k)
+	}
+	if err := s.PutBucketTags(ctx, bucket, tags); err != nil {
+		return err
+	}
+	printTags(w, tags)
+	return nil
+}
+
+// EditBucketTags show tags of bucket and ask key=value pairs to set until an empty answer, used by the TUI
+// a key= answer removes the key
+func (s S3ry) EditBucketTags(bucket string) error {
+	ctx := context.Background()
+	tags, err := s.BucketTags(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	fmt.Println(i18nPrinter.Sprintf("Current tags of% s", bucket))
+	printTags(os.Stdout, tags)
+	changed := false
+	for {
+		prompt := promptui.Prompt{
+			Label: i18nPrinter.Sprintf("Tag key=value (key= removes it, empty to save)"),
+			Validate: func(input string) error {
+				if input == "" {
+					return nil
+				}
+				i := strings.Index(input, "=")
+				if i < 0 {
+					return fmt.Errorf("key=value")
+				}
+				if i == len(input)-1 {
+					return nil
+				}
+				return checkTag(input[:i], input[i+1:])
+			},
+		}
+		answer, err := prompt.Run()
+		if err != nil {
+			return err
+		}
+		if answer == "" {
+			break
+		}
+		i := strings.Index(answer, "=")
+		if i == len(answer)-1 {
+			delete(tags, answer[:i])
+		} else {
+			tags[answer[:i]] = answer[i+1:]
+		}
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	if err := s.PutBucketTags(ctx, bucket, tags); err != nil {
+		return err
+	}
+	fmt.Println(i18nPrinter.Sprintf("Saved tags of% s", bucket))
+	return nil
+}
@@ -0,0 +1,75 @@
+package s3ry
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBucketTagsRoundTrip(t *testing.T) {
+	fake := newFakeS3("bucket")
+	s, srv := newTestS3ry(DefaultConfig(), fake)
+	defer srv.Close()
+	ctx := context.Background()
+
+	tags, err := s.BucketTags(ctx, "bucket")
+	assert.NoError(t, err)
+	assert.Empty(t, tags)
+
+	want := map[string]string{"team": "storage", "cost-center": "1234", "empty": ""}
+	assert.NoError(t, s.PutBucketTags(ctx, "bucket", want))
+	tags, err = s.BucketTags(ctx, "bucket")
+	assert.NoError(t, err)
+	assert.Equal(t, want, tags)
+
+	assert.NoError(t, s.PutBucketTags(ctx, "bucket", map[string]string{}))
+	assert.Equal(t, 1, fake.count("DELETE_TAGGING"))
+	tags, err = s.BucketTags(ctx, "bucket")
+	assert.NoError(t, err)
+	assert.Empty(t, tags)
+}
+
+func TestTagsCommand(t *testing.T) {
+	fake := newFakeS3("bucket")
+	cfg, done := newFakeEndpoint(t, fake)
+	defer done()
+	ctx := context.Background()
+
+	var out bytes.Buffer
+	assert.NoError(t, Tags(ctx, cfg, "bucket", []string{"a=1", "b=x=y"}, nil, &out))
+	assert.Equal(t, "a=1\nb=x=y\n", out.String())
+	out.Reset()
+	assert.NoError(t, Tags(ctx, cfg, "bucket", []string{"c=3"}, []string{"a"}, &out))
+	assert.Equal(t, "b=x=y\nc=3\n", out.String())
+	out.Reset()
+	assert.NoError(t, Tags(ctx, cfg, "bucket", nil, nil, &out))
+	assert.Equal(t, "b=x=y\nc=3\n", out.String())
+	assert.Error(t, Tags(ctx, cfg, "bucket", []string{"novalue"}, nil, &out))
+}
+
+func TestCheckTags(t *testing.T) {
+	tags := map[string]string{}
+	for i := 0; i < maxBucketTags; i++ {
+		tags[fmt.Sprintf("key%d", i)] = "value"
+	}
+	assert.NoError(t, CheckTags(tags))
+	tags["one-too-many"] = "value"
+	assert.Error(t, CheckTags(tags))
+
+	assert.NoError(t, CheckTags(map[string]string{"Project Name": "s3ry/cli @v1 +x=y"}))
+	assert.Error(t, CheckTags(map[string]string{"": "v"}))
+	assert.Error(t, CheckTags(map[string]string{strings.Repeat("k", 129): "v"}))
+	assert.Error(t, CheckTags(map[string]string{"k": strings.Repeat("v", 257)}))
+	assert.Error(t, CheckTags(map[string]string{"aws:createdBy": "v"}))
+	assert.Error(t, CheckTags(map[string]string{"k": "semi;colon"}))
+
+	fake := newFakeS3("bucket")
+	s, srv := newTestS3ry(DefaultConfig(), fake)
+	defer srv.Close()
+	assert.Error(t, s.PutBucketTags(context.Background(), "bucket", tags))
+	assert.Equal(t, 0, fake.count("PUT_TAGGING"))
+}
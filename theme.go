@@ -0,0 +1,76 @@
+package s3ry
+
+import (
+	"strings"
+
+	"github.com/manifoldco/promptui"
+)
+
+// Theme colors and symbols of the interactive selects
+// colors are promptui template functions separated by "|", e.g. "red" or "bold|underline", empty for plain text
+type Theme struct {
+	// Pointer symbol in front of the highlighted item
+	Pointer string
+	// Active color of the highlighted item
+	Active string
+	// Inactive color of the other items
+	Inactive string
+	// Selected color of the chosen item
+	Selected string
+	// Label color of the detail labels
+	Label string
+}
+
+// defaultTheme name of the theme used when Theme is empty or unknown
+const defaultTheme = "default"
+
+// themes themes by Theme
+var themes = map[string]Theme{
+	defaultTheme:    {Pointer: "->", Active: "red", Inactive: "cyan", Selected: "red|cyan", Label: "faint"},
+	"dark":          {Pointer: "->", Active: "yellow|bold", Inactive: "white", Selected: "yellow", Label: "faint"},
+	"light":         {Pointer: "->", Active: "blue|bold", Inactive: "black", Selected: "blue", Label: "faint"},
+	"high-contrast": {Pointer: ">>", Active: "bold|underline", Inactive: "", Selected: "bold", Label: ""},
+	"plain":         {Pointer: ">"},
+}
+
+// RegisterTheme add theme usable as Theme
+func RegisterTheme(name string, theme Theme) {
+	themes[name] = theme
+}
+
+// theme return the configured theme, the themes of the config file first, the default one when it is unknown
+func (c *Config) theme() Theme {
+	if t, ok := c.Themes[c.Theme]; ok {
+		return t
+	}
+	if t, ok := themes[c.Theme]; ok {
+		return t
+	}
+	return themes[defaultTheme]
+}
+
+// styled return template printing value with colors
+func styled(value string, colors string) string {
+	pipeline := value
+	for _, color := range strings.Split(colors, "|") {
+		if color = strings.TrimSpace(color); color != "" {
+			pipeline += " | " + color
+		}
+	}
+	return "{{ " + pipeline + " }}"
+}
+
+// selectTemplates return templates of a select showing detail labelled fields of the item
+func (t Theme) selectTemplates(details [][2]string) *promptui.SelectTemplates {
+	var detail []string
+	for _, d := range details {
+		detail = append(detail, styled(`"`+d[0]+`"`, t.Label)+" {{ "+d[1]+" }}")
+	}
+	return &promptui.SelectTemplates{
+		Label:    "{{ . }}",
+		Active:   t.Pointer + styled(".Val", t.Active),
+		Inactive: styled(".Val", t.Inactive),
+		Selected: i18nPrinter.Sprintf("\"Selection Value:\" ") + styled(".Val", t.Selected),
+		Details:  strings.Join(detail, "\n"),
+	}
+}
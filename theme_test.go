@@ -0,0 +1,47 @@
+package s3ry
+
+import (
+	"bytes"
+	"testing"
+	"text/template"
+
+	"github.com/manifoldco/promptui"
+	"github.com/stretchr/testify/assert"
+)
+
+// renderTemplate render promptui template text with item
+func renderTemplate(t *testing.T, text string, item interface{}) string {
+	tpl, err := template.New("").Funcs(promptui.FuncMap).Parse(text)
+	if !assert.NoError(t, err) {
+		return ""
+	}
+	var b bytes.Buffer
+	assert.NoError(t, tpl.Execute(&b, item))
+	return b.String()
+}
+
+func TestTheme(t *testing.T) {
+	item := PromptItems{Val: "bucket"}
+	cfg := DefaultConfig()
+	details := [][2]string{{"Selection Value", ".Val"}}
+	active := func() string {
+		return renderTemplate(t, cfg.theme().selectTemplates(details).Active, item)
+	}
+
+	assert.Equal(t, "->\x1b[31mbucket\x1b[0m", active())
+	assert.Equal(t, "\x1b[2mSelection Value\x1b[0m bucket", renderTemplate(t, cfg.theme().selectTemplates(details).Details, item))
+
+	cfg.Theme = "high-contrast"
+	assert.Equal(t, ">>\x1b[4m\x1b[1mbucket\x1b[0m", active())
+	assert.Equal(t, "bucket", renderTemplate(t, cfg.theme().selectTemplates(details).Inactive, item))
+
+	cfg.Theme = "plain"
+	assert.Equal(t, ">bucket", active())
+
+	cfg.Theme = "mine"
+	cfg.Themes = map[string]Theme{"mine": {Pointer: "* ", Active: "green"}}
+	assert.Equal(t, "* \x1b[32mbucket\x1b[0m", active())
+
+	cfg.Theme = "unknown"
+	assert.Equal(t, "->\x1b[31mbucket\x1b[0m", active())
+}
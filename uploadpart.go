@@ -0,0 +1,96 @@
+package s3ry
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/seike460/s3ry/internal/events"
+)
+
+// partRetryClient S3 client uploading each part of a multipart upload again when S3 stored it differently
+//
+// The checksum handlers fail a part whose checksum S3 reports differently, and
+// the part ETag, the MD5 of the part unless it is encrypted with KMS or a customer
+// key, is checked here. Such a part is uploaded again up to attempts times before
+// the uploader aborts the whole upload.
+type partRetryClient struct {
+	s3iface.S3API
+	attempts int
+	// publish called for every uploaded part, and for every failed attempt with its error
+	publish func(part int64, n int64, err error)
+}
+
+// uploader return s3manager.Uploader of bucket key retrying parts stored differently
+// and publishing a Part event for every part
+func (s S3ry) uploader(operation string, bucket string, key string) *s3manager.Uploader {
+	return s3manager.NewUploaderWithClient(s.partClient(operation, bucket, key))
+}
+
+// partClient return partRetryClient of bucket key publishing a Part event for every part
+func (s S3ry) partClient(operation string, bucket string, key string) *partRetryClient {
+	return &partRetryClient{
+		S3API:    s.Svc,
+		attempts: s.config().Performance.UploadPartAttempts,
+		publish: func(part int64, n int64, err error) {
+			s.Events.Publish(events.Event{Type: events.Part, Operation: operation, Bucket: bucket, Key: key, Part: part, Bytes: n, Err: err})
+		},
+	}
+}
+
+// partMismatch check ETag of out is the MD5 of the part body, nil when it can't tell
+func partMismatch(input *s3.UploadPartInput, out *s3.UploadPartOutput) error {
+	if input.SSECustomerAlgorithm != nil || aws.StringValue(out.ServerSideEncryption) == s3.ServerSideEncryptionAwsKms {
+		return nil
+	}
+	etag := strings.Trim(aws.StringValue(out.ETag), `"`)
+	if len(etag) != md5.Size*2 {
+		// not an MD5, e.g. from storage computing it differently
+		return nil
+	}
+	h := md5.New()
+	if _, err := input.Body.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.Copy(h, input.Body); err != nil {
+		return err
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != etag {
+		return awserr.New(ErrCodeChecksumMismatch, fmt.Sprintf("MD5 sent %s, stored %s", sum, etag), nil)
+	}
+	return nil
+}
+
+// UploadPartWithContext upload part, again while S3 stores it differently
+func (c *partRetryClient) UploadPartWithContext(ctx aws.Context, input *s3.UploadPartInput, opts ...request.Option) (*s3.UploadPartOutput, error) {
+	part := aws.Int64Value(input.PartNumber)
+	for attempt := 1; ; attempt++ {
+		out, err := c.S3API.UploadPartWithContext(ctx, input, opts...)
+		if err == nil {
+			err = partMismatch(input, out)
+		}
+		if err == nil {
+			n, _ := input.Body.Seek(0, io.SeekEnd)
+			c.publish(part, n, nil)
+			return out, nil
+		}
+		if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != ErrCodeChecksumMismatch {
+			return nil, err
+		}
+		if attempt >= c.attempts {
+			return nil, awserr.New(ErrCodeChecksumMismatch, fmt.Sprintf("part %d was stored differently %d times", part, attempt), err)
+		}
+		c.publish(part, 0, err)
+		if _, serr := input.Body.Seek(0, io.SeekStart); serr != nil {
+			return nil, err
+		}
+	}
+}
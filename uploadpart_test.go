@@ -0,0 +1,95 @@
+package s3ry
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/seike460/s3ry/internal/events"
+	"github.com/stretchr/testify/assert"
+)
+
+// corruptPart answer uploads of part 2 with a wrong ETag times times
+func corruptPart(fake *fakeS3, times int) {
+	var mu sync.Mutex
+	fake.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodPut || r.URL.Query().Get("partNumber") != "2" {
+			return false
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if times == 0 {
+			return false
+		}
+		times--
+		fake.record("UPLOAD_PART")
+		ioutil.ReadAll(r.Body)
+		w.Header().Set("ETag", `"00000000000000000000000000000000"`)
+		return true
+	}
+}
+
+func TestUploadRetriesCorruptedPart(t *testing.T) {
+	fake := newFakeS3("bucket")
+	corruptPart(fake, 1)
+	s, srv := newTestS3ry(DefaultConfig(), fake)
+	defer srv.Close()
+	s.Events = events.NewBus()
+	var mu sync.Mutex
+	var parts []events.Event
+	s.Events.Subscribe(func(e events.Event) {
+		if e.Type == events.Part {
+			mu.Lock()
+			parts = append(parts, e)
+			mu.Unlock()
+		}
+	})
+
+	data := make([]byte, 11*1024*1024)
+	rand.New(rand.NewSource(3)).Read(data)
+	n, err := s.PutStream(context.Background(), "bucket", "big", bytes.NewReader(data))
+	s.Events.Close()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(data)), n)
+	o, ok := fake.get("bucket", "big")
+	if assert.True(t, ok) {
+		assert.True(t, bytes.Equal(data, o.data))
+	}
+	assert.Equal(t, 4, fake.count("UPLOAD_PART"))
+
+	// every part is reported, and the failed attempt of part 2
+	var uploaded int64
+	var retried []int64
+	for _, e := range parts {
+		if e.Err != nil {
+			retried = append(retried, e.Part)
+			continue
+		}
+		uploaded += e.Bytes
+	}
+	assert.Equal(t, int64(len(data)), uploaded)
+	assert.Equal(t, []int64{2}, retried)
+}
+
+func TestUploadAbortsOnRepeatedlyCorruptedPart(t *testing.T) {
+	fake := newFakeS3("bucket")
+	corruptPart(fake, 10)
+	cfg := DefaultConfig()
+	cfg.Performance.UploadPartAttempts = 2
+	s, srv := newTestS3ry(cfg, fake)
+	defer srv.Close()
+
+	data := make([]byte, 11*1024*1024)
+	_, err := s.PutStream(context.Background(), "bucket", "big", bytes.NewReader(data))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "part 2 was stored differently 2 times")
+	}
+	_, ok := fake.get("bucket", "big")
+	assert.False(t, ok)
+	assert.NotZero(t, fake.count("ABORT_MULTIPART"))
+	assert.Equal(t, 0, fake.count("COMPLETE_MULTIPART"))
+}
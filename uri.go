@@ -0,0 +1,77 @@
+package s3ry
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ErrInvalidURI argument is not an s3://bucket/key URI
+var ErrInvalidURI = errors.New("invalid s3:// URI")
+
+// ParseS3URI split s3://bucket/key into bucket and key
+// the bucket may be an access point ARN, s3://arn:aws:s3:region:account:accesspoint/name/key
+func ParseS3URI(uri string) (string, string, error) {
+	if !strings.HasPrefix(uri, "s3://") {
+		return "", "", fmt.Errorf("%w %q, it must start with s3://", ErrInvalidURI, uri)
+	}
+	if rest := strings.TrimPrefix(uri, "s3://"); isAccessPointARN(rest) {
+		bucket, key := splitAccessPointARN(rest)
+		if _, err := ParseAccessPointARN(bucket); err != nil {
+			return "", "", fmt.Errorf("%w %q, %v", ErrInvalidURI, uri, err)
+		}
+		return bucket, key, nil
+	}
+	parts := strings.SplitN(strings.TrimPrefix(uri, "s3://"), "/", 2)
+	if parts[0] == "" {
+		return "", "", fmt.Errorf("%w %q, it has no bucket", ErrInvalidURI, uri)
+	}
+	if len(parts) == 1 {
+		return parts[0], "", nil
+	}
+	return parts[0], parts[1], nil
+}
+
+// copySource x-amz-copy-source value of bucket and key
+func copySource(bucket string, key string) string {
+	if isAccessPointARN(bucket) {
+		return bucket + "/object/" + escapeKey(key)
+	}
+	return bucket + "/" + escapeKey(key)
+}
+
+// escapeKey escape key for URL paths keeping its slashes
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// ObjectURI return s3:// URI of object
+func ObjectURI(bucket string, key string) string {
+	return "s3://" + bucket + "/" + key
+}
+
+// ObjectURL return HTTPS URL of object in region, virtual-hosted (bucket.host/key) or path-style (host/bucket/key)
+// with a custom Endpoint its host is used; buckets with dots are always path-style since they don't match the certificate
+// an access point ARN bucket has a host of its own
+func (c *Config) ObjectURL(bucket string, key string, region string, virtualHosted bool) string {
+	if ap, err := ParseAccessPointARN(bucket); err == nil && !ap.MultiRegion {
+		if host, err := accessPointHost(ap); err == nil {
+			return "https://" + host + "/" + escapeKey(key)
+		}
+	}
+	scheme, host := "https", "s3."+region+".amazonaws.com"
+	if c.AWS.Endpoint != "" {
+		if u, err := url.Parse(c.AWS.Endpoint); err == nil && u.Host != "" {
+			scheme, host = u.Scheme, u.Host
+		}
+	}
+	if virtualHosted && !strings.Contains(bucket, ".") {
+		return scheme + "://" + bucket + "." + host + "/" + escapeKey(key)
+	}
+	return scheme + "://" + host + "/" + bucket + "/" + escapeKey(key)
+}
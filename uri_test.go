@@ -0,0 +1,62 @@
+package s3ry
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObjectURL(t *testing.T) {
+	aws := DefaultConfig()
+	custom := DefaultConfig()
+	custom.AWS.Endpoint = "http://localhost:9000"
+
+	for _, c := range []struct {
+		cfg           *Config
+		bucket        string
+		key           string
+		virtualHosted bool
+		expected      string
+	}{
+		{aws, "bucket", "dir/a b.txt", true, "https://bucket.s3.eu-west-1.amazonaws.com/dir/a%20b.txt"},
+		{aws, "bucket", "dir/a b.txt", false, "https://s3.eu-west-1.amazonaws.com/bucket/dir/a%20b.txt"},
+		{aws, "my.bucket", "key", true, "https://s3.eu-west-1.amazonaws.com/my.bucket/key"},
+		{aws, "bucket", "100%/#1", true, "https://bucket.s3.eu-west-1.amazonaws.com/100%25/%231"},
+		{custom, "bucket", "key", true, "http://bucket.localhost:9000/key"},
+		{custom, "bucket", "key", false, "http://localhost:9000/bucket/key"},
+	} {
+		assert.Equal(t, c.expected, c.cfg.ObjectURL(c.bucket, c.key, "eu-west-1", c.virtualHosted))
+	}
+	assert.Equal(t, "s3://bucket/dir/key", ObjectURI("bucket", "dir/key"))
+}
+
+func TestPrintObjectURIs(t *testing.T) {
+	s, srv := newTestS3ry(DefaultConfig(), newFakeS3("bucket"))
+	defer srv.Close()
+	var copied string
+	clipboardErr := error(nil)
+	defer func(c func(string) error) { copyToClipboard = c }(copyToClipboard)
+	copyToClipboard = func(text string) error {
+		copied = text
+		return clipboardErr
+	}
+
+	out := &bytes.Buffer{}
+	s.printObjectURIs("bucket", "dir/key", out)
+	assert.Equal(t, "s3://bucket/dir/key", copied)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if assert.Len(t, lines, 4) {
+		assert.Equal(t, "s3://bucket/dir/key", lines[0])
+		assert.Contains(t, lines[3], "Copied to clipboard")
+	}
+
+	// without a clipboard the printed URI is enough
+	clipboardErr = errors.New("no clipboard")
+	out.Reset()
+	s.printObjectURIs("bucket", "dir/key", out)
+	assert.Contains(t, out.String(), "s3://bucket/dir/key")
+	assert.Contains(t, out.String(), "Clipboard is unavailable")
+}
@@ -1,15 +1,18 @@
 package s3ry
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws/awserr"
-	"github.com/briandowns/spinner"
+	"github.com/seike460/s3ry/internal/events"
 )
 
 // PromptItems struct for promptui
@@ -21,40 +24,175 @@ type PromptItems struct {
 	Tag          string
 }
 
-// spinner var
-var sp = spinner.New(spinner.CharSets[34], 100*time.Millisecond)
-
 // sps Starts spinner
 func sps(label string) {
-	fmt.Println(label)
-	sp.Start()
+	reporter.start(label)
 }
 
 // spe end spinner
 func spe() {
-	sp.Stop()
-}
-
-// checkLocalExists check localFile
-func checkLocalExists(objectKey string) {
-	filename := filepath.Base(objectKey)
-	_, err := os.Stat(filename)
-	if err == nil {
-		var overlide string
-		fmt.Println(i18nPrinter.Sprintf("The file exists. Overwrite? File name:% s, [Yy] / [Nn]", filename))
-		fmt.Scan(&overlide)
-		if overlide != "y" && overlide != "Y" {
-			log.Fatal("End processing")
+	reporter.stop()
+}
+
+// spinnerProgress show transfer progress events in spinner
+func spinnerProgress(e events.Event) {
+	if e.Type != events.Progress {
+		return
+	}
+	if e.Total > 0 {
+		reporter.update(fmt.Sprintf(" %d / %d bytes", e.Bytes, e.Total))
+	} else {
+		reporter.update(fmt.Sprintf(" %d bytes", e.Bytes))
+	}
+}
+
+// spinnerSummary show progress of every object of an operation in spinner
+func spinnerSummary(e events.Event) {
+	if e.Type != events.Summary {
+		return
+	}
+	summary := *e.Summary
+	now := time.Now()
+	suffix := fmt.Sprintf(" %s / %s objects, %s / %s, %s/s", locale.Number(int64(summary.Done)), locale.Number(int64(summary.Objects)), locale.Bytes(summary.Bytes), locale.Bytes(summary.Total), locale.Bytes(int64(summary.Rate(now))))
+	if eta := summary.ETA(now); eta > 0 {
+		suffix += fmt.Sprintf(", ETA %s", locale.Duration(eta))
+	}
+	if summary.Failed > 0 {
+		suffix += fmt.Sprintf(", %d failed", summary.Failed)
+	}
+	reporter.update(suffix)
+}
+
+// track publish Started event and return func publishing Completed or Failed
+// the operation is recorded in the history when it finishes
+func (s S3ry) track(operation string, bucket string, key string) func(error) {
+	start := time.Now()
+	s.Events.Publish(events.Event{Type: events.Started, Operation: operation, Bucket: bucket, Key: key})
+	return func(err error) {
+		s.history.finish(operation, bucket, key, start, err)
+		e := events.Event{Type: events.Completed, Operation: operation, Bucket: bucket, Key: key}
+		if err != nil {
+			e.Type = events.Failed
+			e.Err = err
 		}
+		s.Events.Publish(e)
 	}
 }
 
-// awsErrorPrint print Error for AWS
-func awsErrorPrint(err error) {
-	if aerr, ok := err.(awserr.Error); ok {
-		log.Fatal(aerr.Error())
+// progress return func publishing Progress events
+func (s S3ry) progress(operation string, bucket string, key string, total int64) func(int64) {
+	return func(n int64) {
+		s.history.progress(operation, bucket, key, n)
+		s.Events.Publish(events.Event{Type: events.Progress, Operation: operation, Bucket: bucket, Key: key, Bytes: n, Total: total})
 	}
-	log.Fatal(err.Error())
+}
+
+// progressReader publish bytes read so far
+type progressReader struct {
+	r       io.Reader
+	publish func(int64)
+	read    int64
+}
+
+// Read read from r and publish progress
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.read += int64(n)
+		p.publish(p.read)
+	}
+	return n, err
+}
+
+// progressWriterAt publish bytes written so far, safe for concurrent WriteAt
+type progressWriterAt struct {
+	w       io.WriterAt
+	publish func(int64)
+	written int64
+}
+
+// WriteAt write to w and publish progress
+func (p *progressWriterAt) WriteAt(b []byte, off int64) (int, error) {
+	n, err := p.w.WriteAt(b, off)
+	if n > 0 {
+		p.publish(atomic.AddInt64(&p.written, int64(n)))
+	}
+	return n, err
+}
+
+// checkLocalExists check localFile, exit unless overwriting it is confirmed
+func (s S3ry) checkLocalExists(objectKey string) {
+	filename, err := s.config().sanitizeKeyName(objectKey)
+	if err != nil {
+		Exit(err)
+	}
+	if err := s.config().confirmOverwrite(filename); err != nil {
+		log.Println("End processing")
+		os.Exit(ExitCancelled)
+	}
+}
+
+// confirm ask yes or no on stdin, replaced in tests
+var confirm = func(message string) bool {
+	var answer string
+	fmt.Println(message)
+	fmt.Scan(&answer)
+	return answer == "y" || answer == "Y"
+}
+
+// errNoTerminal a question can't be asked as stdin carries data and there is no terminal
+var errNoTerminal = errors.New("stdin carries data and there is no terminal to ask on, answer with --yes")
+
+// askFunc ask a yes or no question
+type askFunc func(message string) (bool, error)
+
+// askMu serialize questions, so workers uploading concurrently don't read each other's answers
+var askMu sync.Mutex
+
+// askStdin ask with confirm
+func askStdin(message string) (bool, error) {
+	askMu.Lock()
+	defer askMu.Unlock()
+	return confirm(message), nil
+}
+
+// askYes answer yes without asking
+func askYes(message string) (bool, error) {
+	return true, nil
+}
+
+// askTerminal ask on the terminal with the question on stderr, for commands whose stdin carries data, replaced in tests
+var askTerminal = func(message string) (bool, error) {
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return false, errNoTerminal
+	}
+	defer tty.Close()
+	fmt.Fprintln(os.Stderr, message)
+	var answer string
+	fmt.Fscan(tty, &answer)
+	return answer == "y" || answer == "Y", nil
+}
+
+// askFor return how to ask when r is read as data: on the terminal when r is stdin, or not at all with --yes,
+// and with confirm otherwise
+func (c *Config) askFor(r io.Reader) askFunc {
+	if f, ok := r.(*os.File); !ok || f != os.Stdin {
+		return askStdin
+	}
+	if c.Security.AssumeYes {
+		return askYes
+	}
+	return func(message string) (bool, error) {
+		askMu.Lock()
+		defer askMu.Unlock()
+		return askTerminal(message)
+	}
+}
+
+// awsErrorPrint print Error for AWS and exit with its exit code
+func awsErrorPrint(err error) {
+	Exit(err)
 }
 
 // dirwalk get fileList
@@ -76,3 +214,9 @@ func dirwalk(dir string) []string {
 	}
 	return paths
 }
+
+// isTerminal report whether f is a terminal rather than a file or pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
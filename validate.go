@@ -0,0 +1,242 @@
+package s3ry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConfigError problem found in a config file
+type ConfigError struct {
+	File  string
+	Line  int
+	Field string
+	Msg   string
+}
+
+// Error format ConfigError as file:line: field: message
+func (e *ConfigError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
+	}
+	return fmt.Sprintf("%s:%d: %s: %s", e.File, e.Line, e.Field, e.Msg)
+}
+
+// ConfigErrors every problem found in a config file
+type ConfigErrors []*ConfigError
+
+// Error join all ConfigError
+func (e ConfigErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+var durationType = reflect.TypeOf(Duration(0))
+
+// configDecoder decode JSON into Config field by field, so a problem is
+// reported with its field name and line, and only that field keeps its default
+type configDecoder struct {
+	file string
+	data []byte
+	errs ConfigErrors
+}
+
+// decodeConfig decode data into cfg
+func decodeConfig(file string, data []byte, cfg *Config) ConfigErrors {
+	d := &configDecoder{file: file, data: data}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		offset := 0
+		if serr, ok := err.(*json.SyntaxError); ok {
+			offset = int(serr.Offset)
+		}
+		d.add(offset, "", err.Error())
+		return d.errs
+	}
+	d.decodeStruct(reflect.ValueOf(cfg).Elem(), raw, "", 0)
+	if err := cfg.AWS.checkSignatureVersion(); err != nil {
+		d.add(d.keyOffset("SignatureVersion", 0), "AWS.SignatureVersion", err.Error())
+		cfg.AWS.SignatureVersion = SignatureV4
+	}
+	return d.errs
+}
+
+// add record ConfigError at byte offset
+func (d *configDecoder) add(offset int, field string, msg string) {
+	d.errs = append(d.errs, &ConfigError{
+		File:  d.file,
+		Line:  bytes.Count(d.data[:offset], []byte("\n")) + 1,
+		Field: field,
+		Msg:   msg,
+	})
+}
+
+// keyOffset find offset of key in data after from
+func (d *configDecoder) keyOffset(key string, from int) int {
+	i := bytes.Index(d.data[from:], []byte(strconv.Quote(key)))
+	if i < 0 {
+		return from
+	}
+	return from + i
+}
+
+// decodeStruct decode raw object into struct v
+func (d *configDecoder) decodeStruct(v reflect.Value, raw map[string]json.RawMessage, prefix string, from int) {
+	keys := make([]string, 0, len(raw))
+	for key := range raw {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return d.keyOffset(keys[i], from) < d.keyOffset(keys[j], from)
+	})
+
+	for _, key := range keys {
+		offset := d.keyOffset(key, from)
+		field, ok := lookupField(v.Type(), key)
+		if !ok {
+			d.add(offset, prefix+key, "unknown field")
+			continue
+		}
+		name := prefix + field.Name
+		fv := v.FieldByIndex(field.Index)
+		if fv.Kind() == reflect.Struct {
+			var child map[string]json.RawMessage
+			if err := json.Unmarshal(raw[key], &child); err != nil {
+				d.add(offset, name, "must be an object")
+				continue
+			}
+			d.decodeStruct(fv, child, name+".", offset)
+			continue
+		}
+		if fv.Kind() == reflect.Map && fv.Type().Elem().Kind() == reflect.Struct {
+			d.decodeMap(fv, raw[key], name, offset)
+			continue
+		}
+		nv := reflect.New(fv.Type())
+		if err := json.Unmarshal(raw[key], nv.Interface()); err != nil {
+			d.add(offset, name, "invalid value "+string(raw[key]))
+			continue
+		}
+		if msg := checkRange(field, nv.Elem()) + checkEnum(field, nv.Elem()); msg != "" {
+			d.add(offset, name, msg)
+			continue
+		}
+		fv.Set(nv.Elem())
+	}
+}
+
+// decodeMap decode raw object into map v of structs, keys are checked as path.Match patterns
+func (d *configDecoder) decodeMap(v reflect.Value, raw json.RawMessage, name string, from int) {
+	var children map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &children); err != nil {
+		d.add(from, name, "must be an object")
+		return
+	}
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(v.Type()))
+	}
+	keys := make([]string, 0, len(children))
+	for key := range children {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return d.keyOffset(keys[i], from) < d.keyOffset(keys[j], from)
+	})
+	for _, key := range keys {
+		offset := d.keyOffset(key, from)
+		field := name + "." + key
+		if _, err := path.Match(key, ""); err != nil {
+			d.add(offset, field, "invalid pattern")
+			continue
+		}
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(children[key], &object); err != nil {
+			d.add(offset, field, "must be an object")
+			continue
+		}
+		ev := reflect.New(v.Type().Elem()).Elem()
+		d.decodeStruct(ev, object, field+".", offset)
+		v.SetMapIndex(reflect.ValueOf(key), ev)
+	}
+}
+
+// lookupField find struct field for JSON key like encoding/json does
+func lookupField(t reflect.Type, key string) (reflect.StructField, bool) {
+	var fold *reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if name == key {
+			return f, true
+		}
+		if fold == nil && strings.EqualFold(name, key) {
+			fold = &f
+		}
+	}
+	if fold != nil {
+		return *fold, true
+	}
+	return reflect.StructField{}, false
+}
+
+// checkEnum check string value against the comma separated values of the enum struct tag
+func checkEnum(field reflect.StructField, v reflect.Value) string {
+	values, ok := field.Tag.Lookup("enum")
+	if !ok || v.Kind() != reflect.String {
+		return ""
+	}
+	for _, value := range strings.Split(values, ",") {
+		if v.String() == value {
+			return ""
+		}
+	}
+	return "must be one of " + strings.Replace(values, ",", ", ", -1)
+}
+
+// checkRange check value against min/max struct tags
+func checkRange(field reflect.StructField, v reflect.Value) string {
+	for _, bound := range []string{"min", "max"} {
+		limit, ok := field.Tag.Lookup(bound)
+		if !ok {
+			continue
+		}
+		var value, l int64
+		var err error
+		switch {
+		case v.Type() == durationType:
+			var dl time.Duration
+			dl, err = time.ParseDuration(limit)
+			value, l = v.Int(), int64(dl)
+		case v.Kind() >= reflect.Int && v.Kind() <= reflect.Int64:
+			l, err = strconv.ParseInt(limit, 10, 64)
+			value = v.Int()
+		default:
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if bound == "min" && value < l {
+			return "must be at least " + limit
+		}
+		if bound == "max" && value > l {
+			return "must be at most " + limit
+		}
+	}
+	return ""
+}
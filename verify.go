@@ -0,0 +1,300 @@
+package s3ry
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/seike460/s3ry/internal/events"
+	"github.com/seike460/s3ry/internal/worker"
+)
+
+// ErrNoChecksum object was stored without a checksum
+var ErrNoChecksum = errors.New("no stored checksum")
+
+// ErrChecksumDiffers stored checksum of an object is not the checksum of its content
+var ErrChecksumDiffers = errors.New("stored checksum differs from the content")
+
+// VerifySummary result of VerifyPrefix
+type VerifySummary struct {
+	// Verified objects whose stored checksum is consistent
+	Verified int
+	// Skipped objects verified by an earlier run
+	Skipped    int
+	NoChecksum []string
+	// Mismatched objects whose content doesn't match the stored checksum
+	Mismatched []string
+	// Failed error of each key which could not be verified
+	Failed map[string]error `json:"-"`
+}
+
+// storedChecksum return the strongest checksum of d and its algorithm, empty when there is none
+func storedChecksum(d objectDigest) (string, string) {
+	for _, algorithm := range []string{ChecksumSHA256, ChecksumSHA1, ChecksumCRC32C, ChecksumCRC32} {
+		if v := d.Checksums[algorithm]; v != "" {
+			return algorithm, v
+		}
+	}
+	return "", ""
+}
+
+// contentChecksum return base64 checksum of algorithm of the object content
+// a checksum composed of parts, "...-N", is computed from each part read by its part number
+func (s S3ry) contentChecksum(ctx context.Context, bucket string, key string, algorithm string, stored string) (string, error) {
+	var parts int64
+	if i := strings.LastIndex(stored, "-"); i >= 0 {
+		n, err := strconv.ParseInt(stored[i+1:], 10, 64)
+		if err != nil || n < 1 {
+			return "", fmt.Errorf("invalid %s checksum %q", algorithm, stored)
+		}
+		parts = n
+	}
+	sum := func(part int64) ([]byte, error) {
+		h, err := newChecksum(algorithm)
+		if err != nil {
+			return nil, err
+		}
+		body, err := s.openObjectPart(ctx, bucket, key, part)
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		if _, err := io.Copy(h, body); err != nil {
+			return nil, err
+		}
+		return h.Sum(nil), nil
+	}
+	if parts == 0 {
+		b, err := sum(0)
+		if err != nil {
+			return "", err
+		}
+		return base64.StdEncoding.EncodeToString(b), nil
+	}
+	// the checksum of the checksums of the parts
+	h, err := newChecksum(algorithm)
+	if err != nil {
+		return "", err
+	}
+	for part := int64(1); part <= parts; part++ {
+		b, err := sum(part)
+		if err != nil {
+			return "", err
+		}
+		h.Write(b)
+	}
+	return base64.StdEncoding.EncodeToString(h.Sum(nil)) + "-" + strconv.FormatInt(parts, 10), nil
+}
+
+// VerifyObject check object was stored with a checksum, and with deep that it is the checksum of its content
+// the object fails with ErrNoChecksum or ErrChecksumDiffers
+func (s S3ry) VerifyObject(ctx context.Context, bucket string, key string, deep bool) error {
+	s, err := s.forBucket(bucket)
+	if err != nil {
+		return err
+	}
+	d, err := s.headDigest(ctx, bucket, key)
+	if err != nil {
+		return err
+	}
+	algorithm, stored := storedChecksum(d)
+	if stored == "" {
+		return ErrNoChecksum
+	}
+	if !deep {
+		return nil
+	}
+	computed, err := s.contentChecksum(ctx, bucket, key, algorithm, stored)
+	if err != nil {
+		return err
+	}
+	if computed != stored {
+		return fmt.Errorf("%w, %s stored %s, computed %s", ErrChecksumDiffers, algorithm, stored, computed)
+	}
+	return nil
+}
+
+// VerifyPrefix verify every object under prefix by VerifyObject, at most rate objects per second, 0 for no limit
+// objects are verified concurrently by Performance.Workers and like CopyPrefix the progress is saved
+// to Checkpoints, so verifying the same prefix again after an interruption resumes from the last checkpoint
+func (s S3ry) VerifyPrefix(ctx context.Context, bucket string, prefix string, deep bool, rate int) (VerifySummary, error) {
+	summary := VerifySummary{Failed: map[string]error{}}
+	s, err := s.forBucket(bucket)
+	if err != nil {
+		return summary, err
+	}
+	job := "verify s3://" + bucket + "/" + prefix
+	if deep {
+		job += " deep"
+	}
+	checkpoint, err := s.loadCheckpoint(job)
+	if err != nil {
+		return summary, err
+	}
+	var tick <-chan time.Time
+	if rate > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	var mu sync.Mutex
+	pool := worker.New(ctx, s.config().Performance.Workers)
+	var submitErr error
+	input := &s3.ListObjectsV2Input{
+		Bucket:  aws.String(bucket),
+		Prefix:  aws.String(prefix),
+		MaxKeys: aws.Int64(s.config().Performance.ListPageSize),
+	}
+	if checkpoint.ContinuationToken != "" {
+		input.ContinuationToken = aws.String(checkpoint.ContinuationToken)
+	}
+	for {
+		var page *s3.ListObjectsV2Output
+		page, err = s.Svc.ListObjectsV2WithContext(ctx, input)
+		if err != nil {
+			break
+		}
+		var wg sync.WaitGroup
+		for _, object := range page.Contents {
+			key := aws.StringValue(object.Key)
+			mu.Lock()
+			done := checkpoint.Processed[key]
+			if done {
+				summary.Skipped++
+				s.Events.Publish(events.Event{Type: events.Skipped, Operation: "verify", Bucket: bucket, Key: key})
+			}
+			mu.Unlock()
+			if done {
+				continue
+			}
+			if tick != nil {
+				select {
+				case <-tick:
+				case <-ctx.Done():
+					submitErr = ctx.Err()
+				}
+				if submitErr != nil {
+					break
+				}
+			}
+			wg.Add(1)
+			submitErr = pool.Submit(func(ctx context.Context) {
+				defer wg.Done()
+				// verifying only reads, so it is not recorded in the history like track does
+				s.Events.Publish(events.Event{Type: events.Started, Operation: "verify", Bucket: bucket, Key: key})
+				err := s.VerifyObject(ctx, bucket, key, deep)
+				e := events.Event{Type: events.Completed, Operation: "verify", Bucket: bucket, Key: key}
+				if err != nil {
+					e.Type = events.Failed
+					e.Err = err
+				}
+				s.Events.Publish(e)
+				mu.Lock()
+				defer mu.Unlock()
+				switch {
+				case err == nil:
+					summary.Verified++
+				case errors.Is(err, ErrNoChecksum):
+					summary.NoChecksum = append(summary.NoChecksum, key)
+				case errors.Is(err, ErrChecksumDiffers):
+					summary.Mismatched = append(summary.Mismatched, key)
+				default:
+					// verified again on resume
+					summary.Failed[key] = err
+					return
+				}
+				checkpoint.Processed[key] = true
+				if len(checkpoint.Processed)%checkpointEvery == 0 {
+					s.saveCheckpoint(job, checkpoint)
+				}
+			})
+			if submitErr != nil {
+				wg.Done()
+				break
+			}
+		}
+		wg.Wait()
+		if submitErr != nil || ctx.Err() != nil {
+			s.saveCheckpoint(job, checkpoint)
+			break
+		}
+		if !aws.BoolValue(page.IsTruncated) {
+			break
+		}
+		checkpoint = &Checkpoint{ContinuationToken: aws.StringValue(page.NextContinuationToken), Processed: map[string]bool{}}
+		s.saveCheckpoint(job, checkpoint)
+		input.ContinuationToken = page.NextContinuationToken
+	}
+	pool.Wait()
+	sort.Strings(summary.NoChecksum)
+	sort.Strings(summary.Mismatched)
+	if submitErr != nil {
+		return summary, submitErr
+	}
+	if err != nil {
+		return summary, err
+	}
+	if err := ctx.Err(); err != nil {
+		return summary, err
+	}
+	s.deleteCheckpoint(job)
+	return summary, nil
+}
+
+// Verify verify the objects under s3:// URI target and print the objects without a checksum or failing
+// verification, used by the verify command
+// with deep the content of every object is read to recompute its checksum
+func Verify(ctx context.Context, cfg *Config, target string, deep bool, w io.Writer) error {
+	bucket, prefix, err := ParseS3URI(target)
+	if err != nil {
+		return err
+	}
+	s := NewS3ryWithConfig(cfg.DefaultRegion(), cfg)
+	s.Events = events.NewBus()
+	defer s.Events.Close()
+	stop := events.Aggregate(s.Events, "verify")
+	s.Events.Subscribe(spinnerSummary)
+	// print problems as they are found, an interrupted run doesn't report them again on resume
+	s.Events.Subscribe(func(e events.Event) {
+		if e.Type == events.Failed && e.Operation == "verify" {
+			reporter.println(i18nPrinter.Sprintf("%s s3://%s/%s: %s", verifyClass(e.Err), e.Bucket, e.Key, strings.Join(strings.Fields(e.Err.Error()), " ")))
+		}
+	})
+	sps(i18nPrinter.Sprintf("Verifying objects ..."))
+	if dir := defaultCheckpointDir(); dir != "" {
+		s.Checkpoints = FileCheckpointStore{Dir: dir}
+	}
+	summary, err := s.VerifyPrefix(ctx, bucket, prefix, deep, cfg.Performance.VerifyRate)
+	stop()
+	spe()
+	fmt.Fprintln(w, i18nPrinter.Sprintf("Verified: %d, no checksum: %d, mismatched: %d, failed: %d, skipped: %d",
+		summary.Verified, len(summary.NoChecksum), len(summary.Mismatched), len(summary.Failed), summary.Skipped))
+	if err != nil {
+		return err
+	}
+	if problems := len(summary.NoChecksum) + len(summary.Mismatched) + len(summary.Failed); problems > 0 {
+		return &PartialError{Failed: problems, Op: "verify"}
+	}
+	return nil
+}
+
+// verifyClass return classification of the verify error err
+func verifyClass(err error) string {
+	switch {
+	case errors.Is(err, ErrNoChecksum):
+		return "no-checksum"
+	case errors.Is(err, ErrChecksumDiffers):
+		return "mismatch"
+	}
+	return "failed"
+}
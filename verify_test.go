@@ -0,0 +1,147 @@
+package s3ry
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// checksumOf return base64 checksum of algorithm of data, as S3 stores it
+func checksumOf(algorithm string, data ...string) []byte {
+	h, _ := newChecksum(algorithm)
+	for _, d := range data {
+		h.Write([]byte(d))
+	}
+	return h.Sum(nil)
+}
+
+// newVerifyFake return fakeS3 whose bucket mixes objects with and without checksums
+//
+//	ok         CRC32C of its content
+//	none       no checksum
+//	corrupt    SHA256 of other content
+//	multipart  composite CRC32C of its two parts
+//	denied     HeadObject is denied
+func newVerifyFake() *fakeS3 {
+	fake := newFakeS3("bucket")
+	set := func(key string, data string, header string, value string) *fakeObject {
+		fake.put("bucket", key, data)
+		o, _ := fake.get("bucket", key)
+		if header != "" {
+			o.header.Set(header, value)
+		}
+		return o
+	}
+	set("ok", "hello", checksumHeader(ChecksumCRC32C), base64.StdEncoding.EncodeToString(checksumOf(ChecksumCRC32C, "hello")))
+	set("none", "hello", "", "")
+	set("corrupt", "hello", checksumHeader(ChecksumSHA256), base64.StdEncoding.EncodeToString(checksumOf(ChecksumSHA256, "jello")))
+	composite := base64.StdEncoding.EncodeToString(checksumOf(ChecksumCRC32C, string(checksumOf(ChecksumCRC32C, "first ")), string(checksumOf(ChecksumCRC32C, "part"))))
+	o := set("multipart", "first part", checksumHeader(ChecksumCRC32C), composite+"-2")
+	o.parts = []int{6, 4}
+	set("denied", "hello", "", "")
+	fake.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if strings.HasSuffix(r.URL.Path, "/denied") {
+			writeFakeError(w, http.StatusForbidden, "AccessDenied")
+			return true
+		}
+		return false
+	}
+	return fake
+}
+
+func TestVerifyPrefix(t *testing.T) {
+	fake := newVerifyFake()
+	s, srv := newTestS3ry(DefaultConfig(), fake)
+	defer srv.Close()
+
+	summary, err := s.VerifyPrefix(context.Background(), "bucket", "", false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, summary.Verified)
+	assert.Equal(t, []string{"none"}, summary.NoChecksum)
+	assert.Empty(t, summary.Mismatched)
+	assert.Contains(t, summary.Failed, "denied")
+	assert.Zero(t, fake.count("GET"))
+
+	summary, err = s.VerifyPrefix(context.Background(), "bucket", "", true, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, summary.Verified)
+	assert.Equal(t, []string{"none"}, summary.NoChecksum)
+	assert.Equal(t, []string{"corrupt"}, summary.Mismatched)
+	assert.Len(t, summary.Failed, 1)
+	// ok and corrupt are read whole, multipart by each part
+	assert.Equal(t, 4, fake.count("GET"))
+}
+
+func TestVerifyObjectMismatch(t *testing.T) {
+	s, srv := newTestS3ry(DefaultConfig(), newVerifyFake())
+	defer srv.Close()
+
+	err := s.VerifyObject(context.Background(), "bucket", "corrupt", true)
+	assert.True(t, errors.Is(err, ErrChecksumDiffers))
+	assert.Contains(t, err.Error(), "SHA256 stored")
+	assert.True(t, errors.Is(s.VerifyObject(context.Background(), "bucket", "none", true), ErrNoChecksum))
+	assert.NoError(t, s.VerifyObject(context.Background(), "bucket", "multipart", true))
+}
+
+func TestVerifyPrefixResumesFromCheckpoint(t *testing.T) {
+	fake := newFakeS3("bucket")
+	for i := 0; i < 15; i++ {
+		fake.put("bucket", fmt.Sprintf("p/%02d", i), "x")
+	}
+	ctx, crash := context.WithCancel(context.Background())
+	defer crash()
+	heads := 0
+	fake.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodHead {
+			return false
+		}
+		heads++
+		if heads == 13 {
+			// crash in the middle of the second page
+			crash()
+			w.WriteHeader(http.StatusBadRequest)
+			return true
+		}
+		return false
+	}
+	cfg := DefaultConfig()
+	cfg.Performance.Workers = 1
+	cfg.Performance.ListPageSize = 10
+	s, srv := newTestS3ry(cfg, fake)
+	defer srv.Close()
+	store := &memoryCheckpointStore{checkpoints: map[string]Checkpoint{}}
+	s.Checkpoints = store
+
+	summary, err := s.VerifyPrefix(ctx, "bucket", "p/", false, 0)
+	assert.Equal(t, context.Canceled, err)
+	assert.Len(t, summary.NoChecksum, 12)
+
+	heads = 0
+	summary, err = s.VerifyPrefix(context.Background(), "bucket", "p/", false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, summary.Skipped)
+	assert.Len(t, summary.NoChecksum, 3)
+	assert.Equal(t, 3, heads)
+	assert.Empty(t, store.checkpoints)
+}
+
+func TestVerify(t *testing.T) {
+	cfg, done := newFakeEndpoint(t, newVerifyFake())
+	defer done()
+	cfg.Progress.Style = ProgressNone
+
+	var out bytes.Buffer
+	err := Verify(context.Background(), cfg, "s3://bucket", true, &out)
+	var partial *PartialError
+	if assert.True(t, errors.As(err, &partial)) {
+		assert.Equal(t, 3, partial.Failed)
+	}
+	assert.Contains(t, out.String(), "Verified: 2, no checksum: 1, mismatched: 1, failed: 1, skipped: 0")
+}
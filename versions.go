@@ -0,0 +1,97 @@
+package s3ry
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// versionPagesAhead listing pages fetched while the previous ones are handled
+// with Performance.ListPageSize versions a page, at most this many pages plus two are held in memory
+const versionPagesAhead = 2
+
+// ObjectVersion version or delete marker of an object in a versioned bucket
+type ObjectVersion struct {
+	Key          string
+	VersionID    string
+	IsLatest     bool
+	DeleteMarker bool
+	// Size and StorageClass are empty for a delete marker
+	Size         int64
+	StorageClass string
+	LastModified time.Time
+}
+
+// versionsOf return versions then delete markers of page
+func versionsOf(page *s3.ListObjectVersionsOutput) []ObjectVersion {
+	versions := make([]ObjectVersion, 0, len(page.Versions)+len(page.DeleteMarkers))
+	for _, v := range page.Versions {
+		versions = append(versions, ObjectVersion{
+			Key:          aws.StringValue(v.Key),
+			VersionID:    aws.StringValue(v.VersionId),
+			IsLatest:     aws.BoolValue(v.IsLatest),
+			Size:         aws.Int64Value(v.Size),
+			StorageClass: aws.StringValue(v.StorageClass),
+			LastModified: aws.TimeValue(v.LastModified),
+		})
+	}
+	for _, m := range page.DeleteMarkers {
+		versions = append(versions, ObjectVersion{
+			Key:          aws.StringValue(m.Key),
+			VersionID:    aws.StringValue(m.VersionId),
+			IsLatest:     aws.BoolValue(m.IsLatest),
+			DeleteMarker: true,
+			LastModified: aws.TimeValue(m.LastModified),
+		})
+	}
+	return versions
+}
+
+// EachVersion call fn with every version and delete marker under prefix of bucket, in listing order page by page
+// the next pages are listed while fn handles a page, but only versionPagesAhead of them, so a history of any length
+// is walked in bounded memory. An error returned by fn stops the listing and is returned
+func (s S3ry) EachVersion(ctx context.Context, bucket string, prefix string, fn func(ObjectVersion) error) error {
+	s, err := s.forBucket(bucket)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pages := make(chan []ObjectVersion, versionPagesAhead)
+	listed := make(chan error, 1)
+	go func() {
+		defer close(pages)
+		listed <- s.Svc.ListObjectVersionsPagesWithContext(ctx, &s3.ListObjectVersionsInput{
+			Bucket:  aws.String(bucket),
+			Prefix:  aws.String(prefix),
+			MaxKeys: aws.Int64(s.config().Performance.ListPageSize),
+		}, func(page *s3.ListObjectVersionsOutput, lastPage bool) bool {
+			select {
+			case pages <- versionsOf(page):
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+	}()
+
+	for page := range pages {
+		for _, v := range page {
+			if err := fn(v); err != nil {
+				cancel()
+				// drain so the lister isn't left blocked on a full channel
+				for range pages {
+				}
+				return err
+			}
+		}
+	}
+	if err := <-listed; err != nil {
+		return err
+	}
+	// a cancelled ctx stops the pages without an error
+	return ctx.Err()
+}
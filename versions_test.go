@@ -0,0 +1,83 @@
+package s3ry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersionsOf(t *testing.T) {
+	now := time.Now()
+	versions := versionsOf(&s3.ListObjectVersionsOutput{
+		Versions: []*s3.ObjectVersion{
+			{Key: aws.String("a"), VersionId: aws.String("2"), IsLatest: aws.Bool(false), Size: aws.Int64(3), StorageClass: aws.String("STANDARD"), LastModified: aws.Time(now)},
+		},
+		DeleteMarkers: []*s3.DeleteMarkerEntry{
+			{Key: aws.String("a"), VersionId: aws.String("3"), IsLatest: aws.Bool(true), LastModified: aws.Time(now)},
+		},
+	})
+	assert.Equal(t, []ObjectVersion{
+		{Key: "a", VersionID: "2", Size: 3, StorageClass: "STANDARD", LastModified: now},
+		{Key: "a", VersionID: "3", IsLatest: true, DeleteMarker: true, LastModified: now},
+	}, versions)
+}
+
+func TestEachVersion(t *testing.T) {
+	fake := newFakeS3("bucket")
+	for i := 0; i < 25; i++ {
+		fake.put("bucket", fmt.Sprintf("key%02d", i), "data")
+	}
+	cfg := DefaultConfig()
+	cfg.Performance.ListPageSize = 2
+	s, srv := newTestS3ry(cfg, fake)
+	defer srv.Close()
+
+	var keys []string
+	err := s.EachVersion(context.Background(), "bucket", "", func(v ObjectVersion) error {
+		if len(keys) == 0 {
+			// the lister runs ahead while the first page is handled, only by a few pages
+			time.Sleep(100 * time.Millisecond)
+			assert.True(t, fake.count("LIST_VERSIONS") <= versionPagesAhead+2)
+		}
+		keys = append(keys, v.Key)
+		assert.Equal(t, "null", v.VersionID)
+		assert.Equal(t, int64(4), v.Size)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Len(t, keys, 25)
+	assert.Equal(t, "key00", keys[0])
+	assert.Equal(t, "key24", keys[24])
+	assert.Equal(t, 13, fake.count("LIST_VERSIONS"))
+
+	stop := errors.New("stop")
+	seen := 0
+	err = s.EachVersion(context.Background(), "bucket", "key1", func(v ObjectVersion) error {
+		seen++
+		if seen == 3 {
+			return stop
+		}
+		return nil
+	})
+	assert.Equal(t, stop, err)
+	assert.Equal(t, 3, seen)
+
+	denied := newFakeS3("bucket")
+	denied.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		if !has(r.URL.Query(), "versions") {
+			return false
+		}
+		writeFakeError(w, http.StatusForbidden, "AccessDenied")
+		return true
+	}
+	s, srv = newTestS3ry(cfg, denied)
+	defer srv.Close()
+	assert.Error(t, s.EachVersion(context.Background(), "bucket", "", func(v ObjectVersion) error { return nil }))
+}
@@ -0,0 +1,251 @@
+package s3ry
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/defaults"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/manifoldco/promptui"
+)
+
+// InitWizard interactive setup of credentials, region and endpoint
+type InitWizard struct {
+	In  io.Reader
+	Out io.Writer
+	// ConfigPath s3ry config file to write
+	ConfigPath string
+	// CredentialsFile AWS shared credentials file to write
+	CredentialsFile string
+	// AWSConfigFile AWS shared config file to write
+	AWSConfigFile string
+	// STSEndpoint endpoint used to verify credentials, empty for AWS
+	STSEndpoint string
+	// Secret read the secret access key without echoing it, nil reads it from In like the other answers
+	Secret func(label string) (string, error)
+
+	scanner *bufio.Scanner
+}
+
+// NewInitWizard create InitWizard writing to the default locations
+// the secret access key typed on a terminal is masked, so it isn't left in the scrollback
+func NewInitWizard(in io.Reader, out io.Writer) *InitWizard {
+	w := &InitWizard{
+		In:              in,
+		Out:             out,
+		ConfigPath:      DefaultConfigPath(),
+		CredentialsFile: defaults.SharedCredentialsFilename(),
+		AWSConfigFile:   defaults.SharedConfigFilename(),
+	}
+	if f, ok := in.(*os.File); ok && isTerminal(f) {
+		w.Secret = maskedPrompt
+	}
+	return w
+}
+
+// maskedPrompt read an answer to label from the terminal, echoing * for every character
+func maskedPrompt(label string) (string, error) {
+	prompt := promptui.Prompt{Label: label, Mask: '*'}
+	return prompt.Run()
+}
+
+// Run ask settings, verify them and write config
+func (w *InitWizard) Run() error {
+	w.scanner = bufio.NewScanner(w.In)
+	cfg, err := LoadConfig(w.ConfigPath)
+	if err != nil {
+		if _, ok := err.(ConfigErrors); !ok {
+			return err
+		}
+		// init repairs the config, the invalid values keep their defaults and are saved as those
+		fmt.Fprintln(w.Out, err.Error())
+	}
+	settings := AWSConfig{}
+
+	useProfile := w.ask(i18nPrinter.Sprintf("Use an existing AWS profile? [y/N]"), "n")
+	if strings.EqualFold(useProfile, "y") {
+		settings.Profile = w.ask(i18nPrinter.Sprintf("Profile name"), "default")
+	} else {
+		settings.AccessKeyID = w.ask(i18nPrinter.Sprintf("AWS Access Key ID"), "")
+		if settings.SecretAccessKey, err = w.askSecret(i18nPrinter.Sprintf("AWS Secret Access Key")); err != nil {
+			return err
+		}
+		if settings.AccessKeyID == "" || settings.SecretAccessKey == "" {
+			return fmt.Errorf("access key id and secret access key are required")
+		}
+	}
+	settings.Region = w.ask(i18nPrinter.Sprintf("Default region"), cfg.DefaultRegion())
+	settings.Endpoint = w.ask(i18nPrinter.Sprintf("Custom endpoint (empty for AWS)"), "")
+	if err := w.scanner.Err(); err != nil {
+		return err
+	}
+
+	identity, err := w.verify(cfg, settings)
+	if err != nil {
+		return fmt.Errorf("verification failed: %s", err.Error())
+	}
+	fmt.Fprintln(w.Out, i18nPrinter.Sprintf("Verified as %s", identity))
+
+	if settings.Profile == "" {
+		target := w.ask(i18nPrinter.Sprintf("Save credentials to AWS profile or s3ry config? [aws/s3ry]"), "aws")
+		if target == "aws" {
+			settings.Profile = w.ask(i18nPrinter.Sprintf("Profile name"), "s3ry")
+			if err := w.writeProfile(settings); err != nil {
+				return err
+			}
+			settings.AccessKeyID = ""
+			settings.SecretAccessKey = ""
+		}
+	}
+	cfg.AWS = withWizardSettings(cfg.AWS, settings)
+	if err := SaveConfig(w.ConfigPath, cfg); err != nil {
+		return err
+	}
+	fmt.Fprintln(w.Out, i18nPrinter.Sprintf("Config saved:")+w.ConfigPath)
+	return nil
+}
+
+// ask print label and read one line, empty answer returns def
+func (w *InitWizard) ask(label string, def string) string {
+	if def != "" {
+		label += " [" + def + "]"
+	}
+	fmt.Fprint(w.Out, label+": ")
+	if !w.scanner.Scan() {
+		return def
+	}
+	answer := strings.TrimSpace(w.scanner.Text())
+	if answer == "" {
+		return def
+	}
+	return answer
+}
+
+// withWizardSettings return current with the profile, region, endpoint and keys asked by the wizard,
+// keeping the settings it doesn't ask like CredentialProvider and RefreshCredentialsBefore
+func withWizardSettings(current AWSConfig, settings AWSConfig) AWSConfig {
+	current.Profile = settings.Profile
+	current.Region = settings.Region
+	current.Endpoint = settings.Endpoint
+	current.AccessKeyID = settings.AccessKeyID
+	current.SecretAccessKey = settings.SecretAccessKey
+	return current
+}
+
+// askSecret read an answer to label with Secret, or from In like ask without it
+func (w *InitWizard) askSecret(label string) (string, error) {
+	if w.Secret == nil {
+		return w.ask(label, ""), nil
+	}
+	answer, err := w.Secret(label)
+	return strings.TrimSpace(answer), err
+}
+
+// verify check settings applied to current can call AWS and return caller identity
+func (w *InitWizard) verify(current *Config, settings AWSConfig) (string, error) {
+	cfg := DefaultConfig()
+	cfg.AWS = withWizardSettings(current.AWS, settings)
+	sess, err := cfg.newSession(settings.Region)
+	if err != nil {
+		return "", err
+	}
+	if settings.Endpoint != "" {
+		// S3 compatible storage has no STS, so check bucket access instead
+		if _, err := cfg.newS3Client(sess).ListBuckets(&s3.ListBucketsInput{}); err != nil {
+			return "", err
+		}
+		return settings.Endpoint, nil
+	}
+	stsConfig := aws.NewConfig()
+	if w.STSEndpoint != "" {
+		stsConfig.Endpoint = aws.String(w.STSEndpoint)
+	}
+	out, err := sts.New(sess, stsConfig).GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(out.Arn), nil
+}
+
+// writeProfile write credentials and region to AWS shared files
+func (w *InitWizard) writeProfile(settings AWSConfig) error {
+	err := updateINISection(w.CredentialsFile, settings.Profile, [][2]string{
+		{"aws_access_key_id", settings.AccessKeyID},
+		{"aws_secret_access_key", settings.SecretAccessKey},
+	})
+	if err != nil {
+		return err
+	}
+	section := "profile " + settings.Profile
+	if settings.Profile == "default" {
+		section = "default"
+	}
+	return updateINISection(w.AWSConfigFile, section, [][2]string{
+		{"region", settings.Region},
+	})
+}
+
+// updateINISection set values in section of INI file, other sections are kept
+func updateINISection(path string, section string, values [][2]string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	var lines []string
+	if len(b) > 0 {
+		lines = strings.Split(strings.TrimRight(string(b), "\n"), "\n")
+	}
+
+	var entries []string
+	for _, v := range values {
+		entries = append(entries, v[0]+" = "+v[1])
+	}
+	isValueKey := func(line string) bool {
+		key := strings.TrimSpace(strings.SplitN(line, "=", 2)[0])
+		for _, v := range values {
+			if key == v[0] {
+				return true
+			}
+		}
+		return false
+	}
+
+	var out []string
+	found := false
+	inSection := false
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			inSection = strings.TrimSpace(trimmed[1:len(trimmed)-1]) == section
+			out = append(out, line)
+			if inSection {
+				found = true
+				out = append(out, entries...)
+			}
+			continue
+		}
+		if inSection && isValueKey(line) {
+			continue
+		}
+		out = append(out, line)
+	}
+	if !found {
+		if len(out) > 0 {
+			out = append(out, "")
+		}
+		out = append(out, "["+section+"]")
+		out = append(out, entries...)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, []byte(strings.Join(out, "\n")+"\n"), 0600)
+}
@@ -0,0 +1,143 @@
+package s3ry
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestWizard(t *testing.T, input string, status int, body string) (*InitWizard, func()) {
+	dir, err := ioutil.TempDir("", "s3ry")
+	assert.NoError(t, err)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	w := &InitWizard{
+		In:              strings.NewReader(input),
+		Out:             &bytes.Buffer{},
+		ConfigPath:      filepath.Join(dir, "s3ry", "config.json"),
+		CredentialsFile: filepath.Join(dir, ".aws", "credentials"),
+		AWSConfigFile:   filepath.Join(dir, ".aws", "config"),
+		STSEndpoint:     srv.URL,
+	}
+	return w, func() {
+		srv.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+const callerIdentityResponse = `<GetCallerIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+<GetCallerIdentityResult><Arn>arn:aws:iam::123456789012:user/s3ry</Arn><UserId>AIDA</UserId><Account>123456789012</Account></GetCallerIdentityResult>
+<ResponseMetadata><RequestId>1</RequestId></ResponseMetadata></GetCallerIdentityResponse>`
+
+func TestInitWizardWritesAWSProfile(t *testing.T) {
+	input := "n\nAKIDEXAMPLE\nSECRETEXAMPLE\nus-west-2\n\naws\nwork\n"
+	w, cleanup := newTestWizard(t, input, http.StatusOK, callerIdentityResponse)
+	defer cleanup()
+	assert.NoError(t, os.MkdirAll(filepath.Dir(w.CredentialsFile), 0700))
+	assert.NoError(t, ioutil.WriteFile(w.CredentialsFile, []byte("[default]\naws_access_key_id = OLD\n"), 0600))
+	// settings the wizard doesn't ask are kept
+	existing := DefaultConfig()
+	existing.AWS.FallbackRegion = "eu-central-1"
+	existing.AWS.SignatureVersion = "v4"
+	existing.AWS.CredentialProvider = defaultCredentialProvider
+	existing.AWS.AccessKeyID = "OLDKEY"
+	assert.NoError(t, SaveConfig(w.ConfigPath, existing))
+
+	assert.NoError(t, w.Run())
+
+	credentials, err := ioutil.ReadFile(w.CredentialsFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "[default]\naws_access_key_id = OLD\n\n[work]\naws_access_key_id = AKIDEXAMPLE\naws_secret_access_key = SECRETEXAMPLE\n", string(credentials))
+	awsConfig, err := ioutil.ReadFile(w.AWSConfigFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "[profile work]\nregion = us-west-2\n", string(awsConfig))
+
+	cfg, err := LoadConfig(w.ConfigPath)
+	assert.NoError(t, err)
+	assert.Equal(t, AWSConfig{
+		Profile:                  "work",
+		Region:                   "us-west-2",
+		FallbackRegion:           "eu-central-1",
+		SignatureVersion:         "v4",
+		CredentialProvider:       defaultCredentialProvider,
+		RefreshCredentialsBefore: Duration(5 * time.Minute),
+	}, cfg.AWS)
+}
+
+func TestInitWizardWritesS3ryConfig(t *testing.T) {
+	input := "n\nAKIDEXAMPLE\nSECRETEXAMPLE\n\n\ns3ry\n"
+	w, cleanup := newTestWizard(t, input, http.StatusOK, callerIdentityResponse)
+	defer cleanup()
+	// the effective region is suggested
+	defer os.Setenv("AWS_REGION", os.Getenv("AWS_REGION"))
+	os.Setenv("AWS_REGION", "eu-west-1")
+
+	assert.NoError(t, w.Run())
+
+	cfg, err := LoadConfig(w.ConfigPath)
+	assert.NoError(t, err)
+	assert.Equal(t, AWSConfig{Region: "eu-west-1", AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "SECRETEXAMPLE", RefreshCredentialsBefore: Duration(5 * time.Minute)}, cfg.AWS)
+	_, err = os.Stat(w.CredentialsFile)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestInitWizardRepairsInvalidConfig(t *testing.T) {
+	input := "n\nAKIDEXAMPLE\nSECRETEXAMPLE\nus-west-2\n\ns3ry\n"
+	w, cleanup := newTestWizard(t, input, http.StatusOK, callerIdentityResponse)
+	defer cleanup()
+	assert.NoError(t, os.MkdirAll(filepath.Dir(w.ConfigPath), 0700))
+	broken := `{"AWS": {"FallbackRegion": "eu-central-1"}, "HTTP": {"MaxIdelConns": 5}, "Performance": {"Workers": "many"}}`
+	assert.NoError(t, ioutil.WriteFile(w.ConfigPath, []byte(broken), 0600))
+
+	assert.NoError(t, w.Run())
+	out := w.Out.(*bytes.Buffer).String()
+	assert.Contains(t, out, "HTTP.MaxIdelConns: unknown field")
+	assert.Contains(t, out, "Performance.Workers")
+
+	// saved with the defaults in place of the invalid values
+	cfg, err := LoadConfig(w.ConfigPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "eu-central-1", cfg.AWS.FallbackRegion)
+	assert.Equal(t, "AKIDEXAMPLE", cfg.AWS.AccessKeyID)
+	assert.Equal(t, DefaultConfig().Performance.Workers, cfg.Performance.Workers)
+}
+
+func TestInitWizardVerificationFailure(t *testing.T) {
+	body := `<ErrorResponse><Error><Type>Sender</Type><Code>InvalidClientTokenId</Code>
+<Message>The security token included in the request is invalid.</Message></Error><RequestId>1</RequestId></ErrorResponse>`
+	w, cleanup := newTestWizard(t, "n\nBAD\nBAD\n\n\n", http.StatusForbidden, body)
+	defer cleanup()
+
+	err := w.Run()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "InvalidClientTokenId")
+	}
+	_, err = os.Stat(w.ConfigPath)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestInitWizardReadsSecretWithoutEcho(t *testing.T) {
+	w, cleanup := newTestWizard(t, "n\nAKIDEXAMPLE\n\n\ns3ry\n", http.StatusOK, callerIdentityResponse)
+	defer cleanup()
+	w.Secret = func(label string) (string, error) {
+		assert.Equal(t, "AWS Secret Access Key", label)
+		return "SECRETEXAMPLE", nil
+	}
+
+	assert.NoError(t, w.Run())
+
+	cfg, err := LoadConfig(w.ConfigPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "SECRETEXAMPLE", cfg.AWS.SecretAccessKey)
+	assert.NotContains(t, w.Out.(*bytes.Buffer).String(), "SECRETEXAMPLE")
+}